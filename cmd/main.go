@@ -2,25 +2,45 @@ package main
 
 import (
 	"context"
-	"errors"
+	"flag"
 	"go.uber.org/zap"
 	systemLog "log"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	// Образ alpine не содержит базы часовых поясов, нужной для рядов балансов по дням
+	_ "time/tzdata"
 
 	"testtask/internal/config"
+	"testtask/internal/domain"
 	postgres "testtask/internal/repository"
+	"testtask/internal/repository/memory"
 	"testtask/internal/service"
 	"testtask/internal/transport/http/handler"
 	"testtask/internal/transport/http/router"
 	"testtask/pkg/logger"
+
+	"github.com/google/uuid"
 )
 
+// shutdownTimeout - сколько при остановке ждать завершения начатых запросов
+const shutdownTimeout = 30 * time.Second
+
 func main() {
+	migrateOnStart := flag.Bool("migrate", false, "применить миграции схемы БД перед запуском")
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-	ctx := context.Background()
+	// ctx отменяется по SIGINT или SIGTERM: сервер дожидается начатых запросов, фоновые задачи останавливаются
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	cfg := config.MustLoad()
-	log, err := logger.NewLogger(cfg.LogLevel)
+	cfg := config.MustLoad(configFlags)
+	logLevels := logger.NewLevels(zap.InfoLevel)
+	log, err := logger.NewLogger(cfg.LogLevel, append(cfg.Log.LoggerOptions(), logger.WithLevels(logLevels))...)
 	if err != nil {
 		panic(err)
 	}
@@ -31,23 +51,125 @@ func main() {
 		}
 	}()
 
-	storeRepo, err := postgres.NewStore(ctx, cfg.UserRepo, cfg.PasswordRepo, cfg.HostRepo, cfg.PortRepo, cfg.DBName, cfg.SSLMode, log)
-	if err != nil {
-		log.Error("Failed to initialized to postgres", zap.Error(err))
+	var storeRepo domain.UnitOfWork
+	switch cfg.Storage {
+	case "memory":
+		storeRepo = memory.NewStore(log)
+	case "postgres":
+		if *migrateOnStart {
+			if err := migrateUp(ctx, cfg, log); err != nil {
+				log.Error("Failed to apply migrations", zap.Error(err))
+				return
+			}
+			log.Info("Migrations applied")
+		}
+
+		store, err := postgres.NewStore(ctx, cfg.Database, log)
+		if err != nil {
+			log.Error("Failed to initialized to postgres", zap.Error(err))
+			return
+		}
+		if cfg.ReplicaHost != "" {
+			if err := connectReplica(ctx, store, cfg); err != nil {
+				log.Error("Failed to connect to replica", zap.Error(err))
+				return
+			}
+		}
+		storeRepo = store
+	default:
+		log.Error("Unknown STORAGE", zap.String("storage", cfg.Storage))
 		return
 	}
 
-	walletSrv := service.NewWalletService(storeRepo, log)
+	var walletOpts []service.Option
+	if cfg.FeeRevenueWalletID != uuid.Nil {
+		walletOpts = append(walletOpts, service.WithRevenueWallet(cfg.FeeRevenueWalletID))
+	}
+	if cfg.FeeScheduleFile != "" {
+		schedule, err := service.LoadFeeScheduleFile(cfg.FeeScheduleFile)
+		if err != nil {
+			log.Error("Failed to load fee schedule", zap.Error(err))
+			return
+		}
+		walletOpts = append(walletOpts, service.WithFeeSchedule(schedule))
+	}
+
+	if cfg.IdempotencyTTL > 0 {
+		walletOpts = append(walletOpts, service.WithIdempotencyTTL(cfg.IdempotencyTTL))
+	}
 
-	handl := handler.NewHandler(*walletSrv)
-	rout := router.NewRouter(handl, cfg.LogLevel, log)
+	walletSrv := service.NewWalletService(storeRepo, log, walletOpts...)
+
+	var background sync.WaitGroup
+	if cfg.ReconcileInterval > 0 {
+		log.Info("Starting background reconciliation", zap.Duration("interval", cfg.ReconcileInterval))
+		background.Go(func() {
+			walletSrv.RunReconciliation(ctx, cfg.ReconcileInterval, service.ReconcileOptions{Repair: cfg.ReconcileRepair})
+		})
+	}
+	if cfg.SnapshotInterval > 0 {
+		log.Info("Starting balance snapshots", zap.Duration("interval", cfg.SnapshotInterval))
+		background.Go(func() { walletSrv.RunBalanceSnapshots(ctx, cfg.SnapshotInterval) })
+	}
+	// Импорты, брошенные прошлым запуском, продолжаются с первой необработанной строки
+	background.Go(func() { walletSrv.RunImports(ctx) })
+	if cfg.IdempotencyTTL > 0 {
+		background.Go(func() { walletSrv.RunIdempotencyKeyPurge(ctx) })
+	}
+
+	handl := handler.NewHandler(walletSrv, handler.WithLogLevels(logLevels))
+	// Токены уже проверены при загрузке конфигурации
+	adminPrincipals, _ := cfg.AdminPrincipals()
+	if len(adminPrincipals) == 0 {
+		log.Warn("Admin tokens are not configured, admin API is disabled")
+	}
+	routerOpts := []router.Option{router.WithAdminAuth(adminPrincipals)}
+	if cfg.IdempotencyTTL > 0 {
+		routerOpts = append(routerOpts, router.WithIdempotency())
+	}
+	rout := router.NewRouter(handl, cfg.LogLevel, log, routerOpts...)
 	srv := &http.Server{
 		Addr:    cfg.HTTPAddr,
 		Handler: rout.GetEngine(),
 	}
 	log.Info("Starting server", zap.String("addr", srv.Addr))
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		log.Error("Failed to listen and server", zap.Error(err))
-		return
+		stop()
+	case <-ctx.Done():
+		log.Info("Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Error("Failed to shut down server", zap.Error(err))
+		}
 	}
+	// Текущий импорт останавливается после применяемой строки и возвращается в PENDING
+	background.Wait()
+	log.Info("Server stopped")
+}
+
+func connectReplica(ctx context.Context, store *postgres.Store, cfg *config.Config) error {
+	replica, err := cfg.Database.WithHost(cfg.ReplicaHost, cfg.ReplicaPort)
+	if err != nil {
+		return err
+	}
+
+	return store.ConnectReplica(ctx, replica, cfg.ReplicaMaxLag)
+}
+
+func migrateUp(ctx context.Context, cfg *config.Config, log *zap.Logger) error {
+	m, err := postgres.NewMigrator(ctx, cfg.Database, log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	return m.Up()
 }
@@ -0,0 +1,108 @@
+// Команда reconcile сверяет кэшированные балансы кошельков с журналом проводок
+// и печатает отчет о расхождениях в JSON.
+//
+// Код возврата 2 означает, что остались неисправленные расхождения.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"go.uber.org/zap"
+	systemLog "log"
+	"os"
+
+	"testtask/internal/config"
+	"testtask/internal/domain"
+	postgres "testtask/internal/repository"
+	"testtask/internal/service"
+	"testtask/internal/transport/http/dto"
+	"testtask/pkg/logger"
+)
+
+func main() {
+	var (
+		repair   = flag.Bool("repair", false, "привести wallets.balance к журналу с записью в журнал аудита")
+		pageSize = flag.Int("page-size", service.DefaultReconcilePageSize, "количество кошельков в одном запросе")
+		actor    = flag.String("actor", "", "автор исправлений для журнала аудита")
+		output   = flag.String("output", "", "файл для отчета, по умолчанию stdout")
+	)
+	configFlags := config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	os.Exit(run(config.MustLoad(configFlags), *repair, *pageSize, *actor, *output))
+}
+
+func run(cfg *config.Config, repair bool, pageSize int, actor string, output string) int {
+	ctx := context.Background()
+
+	log, err := logger.NewLogger(cfg.LogLevel, cfg.Log.LoggerOptions()...)
+	if err != nil {
+		systemLog.Printf("failed to create logger: %v", err)
+		return 1
+	}
+	defer func() {
+		if err := log.Sync(); err != nil {
+			systemLog.Printf("failed to sync logger: %v", err)
+		}
+	}()
+
+	storeRepo, err := postgres.NewStore(ctx, cfg.Database, log)
+	if err != nil {
+		log.Error("Failed to initialized to postgres", zap.Error(err))
+		return 1
+	}
+	defer storeRepo.Close()
+
+	walletSrv := service.NewWalletService(storeRepo, log)
+	report, err := walletSrv.Reconcile(ctx, service.ReconcileOptions{PageSize: pageSize, Repair: repair, Actor: actor})
+	if err != nil {
+		log.Error("Reconciliation failed", zap.Error(err))
+		return 1
+	}
+
+	out := os.Stdout
+	if output != "" {
+		out, err = os.Create(output)
+		if err != nil {
+			log.Error("Failed to create report file", zap.Error(err))
+			return 1
+		}
+		defer out.Close()
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(newReconcileReport(report)); err != nil {
+		log.Error("Failed to write report", zap.Error(err))
+		return 1
+	}
+
+	if len(report.Drifts) > report.Repaired() {
+		return 2
+	}
+	return 0
+}
+
+func newReconcileReport(report *domain.ReconcileReport) dto.ReconcileReportDTO {
+	drifts := make([]dto.BalanceDriftDTO, 0, len(report.Drifts))
+	for _, d := range report.Drifts {
+		drifts = append(drifts, dto.BalanceDriftDTO{
+			WalletID:    d.WalletID,
+			Currency:    d.Currency,
+			Stored:      d.Stored,
+			Ledger:      d.Ledger,
+			Drift:       d.Drift(),
+			Repaired:    d.Repaired,
+			RepairError: d.RepairError,
+		})
+	}
+
+	return dto.ReconcileReportDTO{
+		StartedAt:  report.StartedAt,
+		FinishedAt: report.FinishedAt,
+		Checked:    report.Checked,
+		Repaired:   report.Repaired(),
+		Drifts:     drifts,
+	}
+}
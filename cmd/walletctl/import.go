@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	"testtask/internal/domain"
+	"testtask/internal/service"
+
+	"github.com/google/uuid"
+)
+
+// runImport загружает CSV-файл операций и применяет его, печатая итог.
+// С флагом -resume продолжает ранее прерванный импорт. По Ctrl+C импорт останавливается после
+// применяемой строки и остается PENDING: его продолжит -resume или фоновый обработчик сервиса
+func runImport(ctx context.Context, a *app, args []string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	report := flags.String("report", "", "файл для отчета о строках, которые не удалось применить")
+	resume := flags.String("resume", "", "ID прерванного импорта, который нужно продолжить")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	wallet, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+
+	var importID uuid.UUID
+	switch {
+	case *resume != "":
+		id, err := uuid.Parse(*resume)
+		if err != nil {
+			return fmt.Errorf("invalid import ID: %w", err)
+		}
+		importID = id
+	case flags.NArg() == 1:
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		imp, err := wallet.StartImport(ctx, filepath.Base(flags.Arg(0)), f)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("import %s: staged %d rows\n", imp.ID, imp.TotalRows)
+		importID = imp.ID
+	default:
+		return errors.New("expected a CSV file or -resume IMPORT_ID")
+	}
+
+	imp, err := wallet.RunImport(ctx, importID)
+	if errors.Is(err, context.Canceled) {
+		return fmt.Errorf("import %s interrupted, continue it with -resume %s", importID, importID)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("import %s: %s, applied %d, failed %d of %d rows\n",
+		imp.ID, imp.Status, imp.AppliedRows, imp.FailedRows, imp.TotalRows)
+
+	if *report != "" && imp.FailedRows > 0 {
+		if err := writeImportReport(ctx, wallet, imp.ID, *report); err != nil {
+			return err
+		}
+		fmt.Printf("error report written to %s\n", *report)
+	}
+	if imp.FailedRows > 0 {
+		return fmt.Errorf("%d rows failed", imp.FailedRows)
+	}
+	return nil
+}
+
+func writeImportReport(ctx context.Context, wallet *service.WalletService, importID uuid.UUID, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"line", "error", "wallet", "operation_type", "amount", "target_wallet", "occurred_at"}); err != nil {
+		return err
+	}
+	err = wallet.ImportErrors(ctx, importID, func(row domain.ImportRow) error {
+		return w.Write([]string{
+			strconv.Itoa(row.Line), row.Error, row.WalletID, row.OperationType, row.Amount, row.TargetWalletID, row.OccurredAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,116 @@
+// Команда walletctl - административные операции над кошельками из командной строки.
+//
+//	walletctl <команда> [флаги] [аргументы]
+package main
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	systemLog "log"
+	"os"
+	"sort"
+
+	"testtask/internal/config"
+	postgres "testtask/internal/repository"
+	"testtask/internal/service"
+	"testtask/pkg/logger"
+)
+
+// app - зависимости, общие для всех команд. Подключение к БД создается при первом обращении,
+// чтобы команды migrate работали со схемой без автоматического применения миграций в NewStore
+type app struct {
+	cfg    *config.Config
+	log    *zap.Logger
+	store  *postgres.Store
+	wallet *service.WalletService
+}
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+var commands = map[string]command{
+	"create":     {usage: "create [-external-id ID] [-label LABEL] [-o table|json]", run: runCreate},
+	"inspect":    {usage: "inspect [-o table|json] WALLET_ID", run: runInspect},
+	"freeze":     {usage: "freeze -reason TEXT [-actor NAME] [-o table|json] WALLET_ID", run: runFreeze},
+	"unfreeze":   {usage: "unfreeze -reason TEXT [-actor NAME] [-o table|json] WALLET_ID", run: runUnfreeze},
+	"adjust":     {usage: "adjust -amount AMOUNT -reason TEXT [-actor NAME] [-o table|json] WALLET_ID", run: runAdjust},
+	"operations": {usage: "operations [-wallet WALLET_ID] [-limit N] [-o table|json]", run: runOperations},
+	"migrate":    {usage: "migrate [-o table|json] up | down [-steps N] | goto VERSION | status | drift", run: runMigrate},
+	"import":     {usage: "import [-report errors.csv] [-resume IMPORT_ID] [file.csv]", run: runImport},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	a, err := newApp()
+	if err != nil {
+		systemLog.Printf("failed to initialize: %v", err)
+		os.Exit(1)
+	}
+	defer a.close()
+
+	if err := cmd.run(ctx, a, os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+		a.close()
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "usage: walletctl <command> [flags] [args]")
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", commands[name].usage)
+	}
+}
+
+func newApp() (*app, error) {
+	cfg := config.MustLoad(nil)
+	log, err := logger.NewLogger(cfg.LogLevel, cfg.Log.LoggerOptions()...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &app{cfg: cfg, log: log}, nil
+}
+
+// service подключается к БД при первом вызове и возвращает сервис кошельков
+func (a *app) service(ctx context.Context) (*service.WalletService, error) {
+	if a.wallet != nil {
+		return a.wallet, nil
+	}
+
+	store, err := postgres.NewStore(ctx, a.cfg.Database, a.log)
+	if err != nil {
+		return nil, err
+	}
+	a.store = store
+	a.wallet = service.NewWalletService(store, a.log)
+	return a.wallet, nil
+}
+
+func (a *app) close() {
+	if a.store != nil {
+		a.store.Close()
+		a.store = nil
+	}
+	_ = a.log.Sync()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	postgres "testtask/internal/repository"
+)
+
+type migrationStatus struct {
+	Version uint `json:"version"`
+	Latest  uint `json:"latest"`
+	Dirty   bool `json:"dirty"`
+}
+
+// runMigrate управляет версией схемы БД и проверяет ее расхождения с миграциями. Подключение к БД создается напрямую через postgres.NewMigrator,
+// без NewStore, который отказывается работать с отстающей или грязной схемой
+func runMigrate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("expected one of: up, down, goto, status, drift")
+	}
+
+	m, err := postgres.NewMigrator(ctx, a.cfg.Database, a.log)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	sub, subArgs := flags.Arg(0), flags.Args()[1:]
+	switch sub {
+	case "up":
+		err = m.Up()
+	case "down":
+		downFlags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := downFlags.Int("steps", 1, "сколько последних миграций откатить")
+		if err := downFlags.Parse(subArgs); err != nil {
+			return err
+		}
+		err = m.Down(*steps)
+	case "goto":
+		if len(subArgs) != 1 {
+			return errors.New("expected VERSION argument")
+		}
+		version, parseErr := strconv.ParseUint(subArgs[0], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version: %w", parseErr)
+		}
+		err = m.Goto(uint(version))
+	case "status":
+	case "drift":
+		return printDrift(ctx, m, *output)
+	default:
+		return fmt.Errorf("unknown migrate command %q", sub)
+	}
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := m.Status()
+	if err != nil {
+		return err
+	}
+	latest, err := m.Latest()
+	if err != nil {
+		return err
+	}
+	return printOutput(*output, migrationStatus{Version: version, Latest: latest, Dirty: dirty},
+		[]string{"VERSION", "LATEST", "DIRTY"},
+		[][]string{{strconv.FormatUint(uint64(version), 10), strconv.FormatUint(uint64(latest), 10), strconv.FormatBool(dirty)}})
+}
+
+// printDrift печатает расхождения живой схемы с миграциями и возвращает ошибку, если они есть
+func printDrift(ctx context.Context, m *postgres.Migrator, format string) error {
+	drifts, err := m.Drift(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0, len(drifts))
+	for _, d := range drifts {
+		rows = append(rows, []string{d.Kind, d.Object, d.Expected, d.Actual})
+	}
+	if drifts == nil {
+		drifts = []postgres.SchemaDrift{}
+	}
+	if err := printOutput(format, drifts, []string{"KIND", "OBJECT", "EXPECTED", "ACTUAL"}, rows); err != nil {
+		return err
+	}
+	if len(drifts) > 0 {
+		return fmt.Errorf("schema has drifted from migrations in %d objects", len(drifts))
+	}
+	return nil
+}
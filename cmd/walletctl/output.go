@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// outputFlag добавляет команде флаг -o с форматом вывода
+func outputFlag(flags *flag.FlagSet) *string {
+	return flags.String("o", outputTable, "формат вывода: table или json")
+}
+
+// printOutput печатает v как JSON или как таблицу из заголовка header и строк rows
+func printOutput(format string, v any, header []string, rows [][]string) error {
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case outputTable:
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+type walletView struct {
+	ID          uuid.UUID       `json:"id"`
+	Balance     decimal.Decimal `json:"balance"`
+	CreditLimit decimal.Decimal `json:"credit_limit"`
+	Available   decimal.Decimal `json:"available"`
+	Tier        string          `json:"tier"`
+	Currency    string          `json:"currency"`
+	Status      string          `json:"status"`
+	ExternalID  string          `json:"external_id,omitempty"`
+	Label       string          `json:"label,omitempty"`
+	Metadata    map[string]any  `json:"metadata,omitempty"`
+}
+
+func printWallet(format string, wallet *domain.Wallet) error {
+	view := walletView{
+		ID:          wallet.ID,
+		Balance:     wallet.Balance,
+		CreditLimit: wallet.CreditLimit,
+		Available:   wallet.Available(),
+		Tier:        string(wallet.Tier),
+		Currency:    wallet.Currency,
+		Status:      string(wallet.Status),
+		ExternalID:  wallet.ExternalID,
+		Label:       wallet.Label,
+		Metadata:    wallet.Metadata,
+	}
+	return printOutput(format, view,
+		[]string{"ID", "BALANCE", "CREDIT_LIMIT", "AVAILABLE", "TIER", "CURRENCY", "STATUS", "EXTERNAL_ID", "LABEL"},
+		[][]string{{
+			view.ID.String(), view.Balance.String(), view.CreditLimit.String(), view.Available.String(),
+			view.Tier, view.Currency, view.Status, view.ExternalID, view.Label,
+		}})
+}
+
+type operationView struct {
+	ID            uuid.UUID       `json:"id"`
+	CorrelationID uuid.UUID       `json:"correlation_id"`
+	WalletID      uuid.UUID       `json:"wallet_id"`
+	OperationType string          `json:"operation_type"`
+	Amount        decimal.Decimal `json:"amount"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+func printOperations(format string, ops []domain.Operation) error {
+	views := make([]operationView, 0, len(ops))
+	rows := make([][]string, 0, len(ops))
+	for _, op := range ops {
+		views = append(views, operationView{
+			ID:            op.ID,
+			CorrelationID: op.CorrelationID,
+			WalletID:      op.WalletID,
+			OperationType: string(op.OperationType),
+			Amount:        op.Amount,
+			CreatedAt:     op.CreatedAt,
+		})
+		rows = append(rows, []string{
+			op.CreatedAt.Format(time.RFC3339), op.WalletID.String(), string(op.OperationType),
+			op.Amount.String(), op.CorrelationID.String(),
+		})
+	}
+	return printOutput(format, views, []string{"CREATED_AT", "WALLET", "TYPE", "AMOUNT", "CORRELATION_ID"}, rows)
+}
+
+func printOperationResult(format string, result *domain.OperationResult) error {
+	return printOutput(format, map[string]any{
+		"operation_id":   result.ID,
+		"wallet_id":      result.WalletID,
+		"operation_type": result.OperationType,
+		"amount":         result.Gross,
+		"balance":        result.Balance,
+	},
+		[]string{"OPERATION_ID", "WALLET", "TYPE", "AMOUNT", "BALANCE"},
+		[][]string{{
+			result.ID.String(), result.WalletID.String(), string(result.OperationType),
+			result.Gross.String(), result.Balance.String(),
+		}})
+}
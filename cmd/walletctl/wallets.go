@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// defaultActor - кто выполняет действие, если -actor не задан: пользователь ОС
+func defaultActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "walletctl:" + user
+	}
+	return "walletctl"
+}
+
+// walletArg разбирает единственный позиционный аргумент - ID кошелька
+func walletArg(flags *flag.FlagSet) (uuid.UUID, error) {
+	if flags.NArg() != 1 {
+		return uuid.Nil, errors.New("expected exactly one WALLET_ID argument")
+	}
+	id, err := uuid.Parse(flags.Arg(0))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid wallet ID: %w", err)
+	}
+	return id, nil
+}
+
+func runCreate(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	output := outputFlag(flags)
+	externalID := flags.String("external-id", "", "external wallet ID; an existing wallet with this ID is returned as is")
+	label := flags.String("label", "", "wallet label")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	wallet, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+	created, _, err := wallet.CreateWallet(ctx, domain.WalletDetails{ExternalID: *externalID, Label: *label})
+	if err != nil {
+		return err
+	}
+	return printWallet(*output, created)
+}
+
+func runInspect(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := walletArg(flags)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+	found, err := wallet.GetWallet(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printWallet(*output, found)
+}
+
+func runFreeze(ctx context.Context, a *app, args []string) error {
+	return setStatus(ctx, a, "freeze", domain.WalletFrozen, args)
+}
+
+func runUnfreeze(ctx context.Context, a *app, args []string) error {
+	return setStatus(ctx, a, "unfreeze", domain.WalletActive, args)
+}
+
+func setStatus(ctx context.Context, a *app, name string, status domain.WalletStatus, args []string) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	reason := flags.String("reason", "", "причина, сохраняется в журнале аудита")
+	actor := flags.String("actor", defaultActor(), "кто выполняет действие")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := walletArg(flags)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+	updated, err := wallet.SetWalletStatus(ctx, id, status, *actor, *reason)
+	if err != nil {
+		return err
+	}
+	return printWallet(*output, updated)
+}
+
+// runAdjust вручную корректирует баланс: положительная сумма зачисляется, отрицательная списывается
+func runAdjust(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("adjust", flag.ContinueOnError)
+	amount := flags.String("amount", "", "сумма корректировки, отрицательная для списания")
+	reason := flags.String("reason", "", "причина, сохраняется в журнале аудита")
+	actor := flags.String("actor", defaultActor(), "кто выполняет действие")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	id, err := walletArg(flags)
+	if err != nil {
+		return err
+	}
+	value, err := decimal.NewFromString(*amount)
+	if err != nil {
+		return fmt.Errorf("invalid amount: %w", err)
+	}
+
+	wallet, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := wallet.Adjust(ctx, id, value, *actor, *reason)
+	if err != nil {
+		return err
+	}
+	return printOperationResult(*output, result)
+}
+
+func runOperations(ctx context.Context, a *app, args []string) error {
+	flags := flag.NewFlagSet("operations", flag.ContinueOnError)
+	walletFlag := flags.String("wallet", "", "ID кошелька; по умолчанию - все кошельки")
+	limit := flags.Int("limit", 20, "сколько последних операций вывести")
+	output := outputFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	walletID := uuid.Nil
+	if *walletFlag != "" {
+		id, err := uuid.Parse(*walletFlag)
+		if err != nil {
+			return fmt.Errorf("invalid wallet ID: %w", err)
+		}
+		walletID = id
+	}
+
+	wallet, err := a.service(ctx)
+	if err != nil {
+		return err
+	}
+	ops, err := wallet.ListOperations(ctx, walletID, *limit)
+	if err != nil {
+		return err
+	}
+	return printOperations(*output, ops)
+}
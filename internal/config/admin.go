@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AdminPrincipals разбирает AdminTokens вида "имя=токен,имя=токен" и возвращает имена администраторов по токенам
+func (c *Config) AdminPrincipals() (map[string]string, error) {
+	principals := make(map[string]string)
+	if strings.TrimSpace(c.AdminTokens) == "" {
+		return principals, nil
+	}
+	for _, pair := range strings.Split(c.AdminTokens, ",") {
+		name, token, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || token == "" {
+			return nil, fmt.Errorf("expected name=token, got %q", pair)
+		}
+		if _, dup := principals[token]; dup {
+			return nil, fmt.Errorf("token of %q is used by another principal", name)
+		}
+		principals[token] = name
+	}
+	return principals, nil
+}
@@ -1,36 +1,306 @@
+// Package config собирает конфигурацию из значений по умолчанию, файла YAML или TOML,
+// переменных окружения (и файла config.env) и флагов командной строки - в порядке возрастания приоритета
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"net"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 )
 
+// DefaultReplicaMaxLag - допустимое отставание реплики, при большем чтения идут в основную БД
+const DefaultReplicaMaxLag = 5 * time.Second
+
+// DefaultIdempotencyTTL - сколько хранятся ответы на запросы с ключом идемпотентности
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// envFile читается при запуске; переменные окружения процесса имеют приоритет над ним
+const envFile = "config.env"
+
 type Config struct {
-	HTTPAddr     string
-	LogLevel     string
-	UserRepo     string
-	PasswordRepo string
-	HostRepo     string
-	PortRepo     string
-	DBName       string
-	SSLMode      string
+	HTTPAddr string
+	// LogLevel - debug или info
+	LogLevel string
+	Log      Log
+	Database Database
+
+	// ReplicaHost и ReplicaPort - реплика для чтений без блокировок с теми же учетными данными;
+	// если хост не задан, все запросы идут в основную БД
+	ReplicaHost string
+	ReplicaPort string
+	// ReplicaMaxLag - допустимое отставание реплики
+	ReplicaMaxLag time.Duration
+
+	// Storage - хранилище данных: "postgres" или "memory" для демонстраций без БД
+	Storage string
+
+	// FeeRevenueWalletID - кошелек, на который зачисляются комиссии; uuid.Nil - комиссии не зачисляются
+	FeeRevenueWalletID uuid.UUID
+	// FeeScheduleFile - JSON-файл с правилами комиссий; если не задан, правила читаются из таблицы fee_rules
+	FeeScheduleFile string
+
+	// ReconcileInterval - период фоновой сверки балансов с журналом; 0 - сверка не запускается
+	ReconcileInterval time.Duration
+	// ReconcileRepair - исправлять ли найденные расхождения при фоновой сверке
+	ReconcileRepair bool
+	// SnapshotInterval - период сохранения снимков балансов; 0 - снимки не сохраняются
+	SnapshotInterval time.Duration
+
+	// IdempotencyTTL - сколько хранятся ответы на запросы с заголовком Idempotency-Key; 0 - ключи не учитываются
+	IdempotencyTTL time.Duration
+
+	// AdminTokens - токены доступа к /api/v1/admin и /debug/vars вида "имя=токен,имя=токен".
+	// Имя попадает в журнал аудита как автор изменения; если токены не заданы, административные методы недоступны
+	AdminTokens string
+
+	// sources - откуда взято значение каждого параметра, для печати конфигурации
+	sources map[string]string
 }
 
-func MustLoad() *Config {
-	if err := godotenv.Load("config.env"); err != nil {
-		log.Println("No config.env file found, reading from environment variables")
+// Default возвращает конфигурацию со значениями по умолчанию
+func Default() *Config {
+	return &Config{
+		HTTPAddr: ":8080",
+		LogLevel: "info",
+		Storage:  "postgres",
+		Log: Log{
+			FileMaxSizeMB:         DefaultLogFileMaxSizeMB,
+			FileMaxBackups:        DefaultLogFileMaxBackups,
+			FileMaxAgeDays:        DefaultLogFileMaxAgeDays,
+			DebugSampleFirst:      DefaultLogDebugSampleFirst,
+			DebugSampleThereafter: DefaultLogDebugSampleThereafter,
+			TimeFormat:            TimeFormatLocal,
+			Redact:                true,
+		},
+		Database: Database{
+			Port:                "5432",
+			ApplicationName:     DefaultApplicationName,
+			ConnectTimeout:      DefaultConnectTimeout,
+			ConnectRetryTimeout: DefaultConnectRetryTimeout,
+			MaxConns:            DefaultMaxConns,
+			MinConns:            DefaultMinConns,
+			MaxConnLifetime:     DefaultMaxConnLifetime,
+			MaxConnIdleTime:     DefaultMaxConnIdleTime,
+			HealthCheckPeriod:   DefaultHealthCheckPeriod,
+		},
+		ReplicaMaxLag:  DefaultReplicaMaxLag,
+		IdempotencyTTL: DefaultIdempotencyTTL,
 	}
+}
 
-	return &Config{
-		HTTPAddr:     os.Getenv("HTTP_ADDR"),
-		LogLevel:     os.Getenv("LOG_LEVEL"),
-		UserRepo:     os.Getenv("DB_USER"),
-		PasswordRepo: os.Getenv("DB_PASSWORD"),
-		HostRepo:     os.Getenv("DB_HOST"),
-		PortRepo:     os.Getenv("DB_PORT"),
-		DBName:       os.Getenv("DB_NAME"),
-		SSLMode:      os.Getenv("DB_SSLMODE"),
+// Flags - флаги командной строки, переопределяющие конфигурацию
+type Flags struct {
+	file        string
+	printConfig bool
+	// values - значения параметров, заданные флагами
+	values map[string]string
+}
+
+// RegisterFlags добавляет в fs флаги -config, -print-config и флаг для каждого параметра, например -database.max_conns
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{values: make(map[string]string)}
+	fs.StringVar(&f.file, "config", "", "файл конфигурации YAML или TOML, по умолчанию CONFIG_FILE")
+	fs.BoolVar(&f.printConfig, "print-config", false, "напечатать итоговую конфигурацию со скрытыми секретами и выйти")
+	for _, fl := range fields {
+		usage := fmt.Sprintf("%s (%s)", fl.usage, fl.env)
+		setValue := func(value string) error {
+			f.values[fl.key] = value
+			return nil
+		}
+		if fl.isBool() {
+			fs.BoolFunc(fl.key, usage, setValue)
+		} else {
+			fs.Func(fl.key, usage, setValue)
+		}
+	}
+	return f
+}
+
+// MustLoad загружает конфигурацию и завершает процесс со списком всех ошибок, если она некорректна.
+// С флагом -print-config печатает конфигурацию и завершает процесс. flags может быть nil
+func MustLoad(flags *Flags) *Config {
+	cfg, err := Load(flags)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	if flags != nil && flags.printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatalf("failed to print configuration: %v", err)
+		}
+		os.Exit(0)
+	}
+	return cfg
+}
+
+// Load собирает конфигурацию и проверяет ее. Ошибки всех параметров возвращаются вместе
+func Load(flags *Flags) (*Config, error) {
+	var errs []error
+	if err := godotenv.Load(envFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("%s: %w", envFile, err))
+	}
+
+	if flags == nil {
+		flags = &Flags{}
+	}
+	file := flags.file
+	if file == "" {
+		file = os.Getenv("CONFIG_FILE")
+	}
+	var fileValues map[string]string
+	if file != "" {
+		var err error
+		if fileValues, err = readFile(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	cfg := Default()
+	cfg.sources = make(map[string]string, len(fields))
+	for _, f := range fields {
+		cfg.sources[f.key] = "default"
+		apply := func(value string, source string) {
+			if err := f.set(cfg, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s (%s): %w", f.key, source, err))
+				return
+			}
+			cfg.sources[f.key] = source
+		}
+
+		if value, ok := fileValues[f.key]; ok {
+			apply(value, "file "+file)
+		}
+		// Пустая переменная считается незаданной, как раньше, когда значения читались без проверки
+		if value := os.Getenv(f.env); value != "" {
+			apply(value, "env "+f.env)
+		}
+		if value, ok := flags.values[f.key]; ok {
+			apply(value, "flag -"+f.key)
+		}
+	}
+	for key := range fileValues {
+		if !knownKey(key) {
+			errs = append(errs, fmt.Errorf("%s: unknown key %q", file, key))
+		}
+	}
+
+	if err := cfg.Database.readPasswordFile(); err != nil {
+		errs = append(errs, err)
+	}
+	errs = append(errs, cfg.validate()...)
+
+	return cfg, errors.Join(errs...)
+}
+
+func (c *Config) validate() []error {
+	var errs []error
+	if c.HTTPAddr == "" {
+		errs = append(errs, errors.New("http_addr is required"))
+	} else if _, _, err := net.SplitHostPort(c.HTTPAddr); err != nil {
+		errs = append(errs, fmt.Errorf("http_addr: %w", err))
+	}
+	switch c.LogLevel {
+	case "debug", "info":
+	default:
+		errs = append(errs, fmt.Errorf("log_level must be debug or info, got %q", c.LogLevel))
+	}
+	errs = append(errs, c.Log.validate()...)
+	if _, err := c.AdminPrincipals(); err != nil {
+		errs = append(errs, fmt.Errorf("admin.tokens: %w", err))
+	}
+
+	switch c.Storage {
+	case "postgres":
+		errs = append(errs, c.Database.validate()...)
+	case "memory":
+		if c.ReplicaHost != "" {
+			errs = append(errs, errors.New("replica.host requires storage postgres"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("storage must be postgres or memory, got %q", c.Storage))
+	}
+	return errs
+}
+
+// Print печатает итоговую конфигурацию с источником каждого значения. Секреты скрываются,
+// остальное можно сохранить как файл конфигурации YAML
+func (c *Config) Print(w io.Writer) error {
+	for _, f := range fields {
+		value := f.format(c)
+		if f.redact != nil {
+			value = f.redact(value)
+		}
+		source := c.sources[f.key]
+		if source == "" {
+			source = "default"
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s # %s\n", f.key, strconv.Quote(value), source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFile читает файл конфигурации и возвращает значения по ключам вида database.max_conns
+func readFile(name string) (map[string]string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var doc map[string]any
+	switch ext := strings.ToLower(filepath.Ext(name)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format %q, expected .yaml, .yml or .toml", name, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", name, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", doc, values)
+	return values, nil
+}
+
+// flatten разворачивает вложенные разделы в ключи через точку
+func flatten(prefix string, doc map[string]any, values map[string]string) {
+	for key, value := range doc {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flatten(key, v, values)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+func knownKey(key string) bool {
+	for _, f := range fields {
+		if f.key == key {
+			return true
+		}
 	}
+	return false
 }
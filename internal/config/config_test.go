@@ -0,0 +1,181 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func parseFlags(t *testing.T, args ...string) *Flags {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := RegisterFlags(fs)
+	require.NoError(t, fs.Parse(args))
+	return flags
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+http_addr: ":9000"
+database:
+  host: file-host
+  user: wallet
+  name: wallets
+  max_conns: 20
+  min_conns: 4
+reconcile:
+  interval: 1h
+  repair: true
+`)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("DB_HOST", "env-host")
+	t.Setenv("DB_MAX_CONNS", "30")
+	t.Setenv("DB_NAME", "")
+
+	cfg, err := Load(parseFlags(t, "-database.max_conns=40", "-log_level", "debug"))
+	require.NoError(t, err)
+
+	assert.Equal(t, ":9000", cfg.HTTPAddr)
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, "env-host", cfg.Database.Host)
+	assert.Equal(t, "wallets", cfg.Database.Name)
+	assert.Equal(t, int32(40), cfg.Database.MaxConns)
+	assert.Equal(t, int32(4), cfg.Database.MinConns)
+	assert.Equal(t, DefaultMaxConnLifetime, cfg.Database.MaxConnLifetime)
+	assert.Equal(t, time.Hour, cfg.ReconcileInterval)
+	assert.True(t, cfg.ReconcileRepair)
+
+	assert.Equal(t, "file "+file, cfg.sources["database.name"])
+	assert.Equal(t, "env DB_HOST", cfg.sources["database.host"])
+	assert.Equal(t, "flag -database.max_conns", cfg.sources["database.max_conns"])
+	assert.Equal(t, "default", cfg.sources["storage"])
+}
+
+func TestLoad_TOML(t *testing.T) {
+	file := writeFile(t, "config.toml", `
+storage = "memory"
+
+[fees]
+revenue_wallet_id = "8f14e45f-ceea-467a-9af0-7b5a1f3a6b11"
+
+[snapshot]
+interval = "24h"
+`)
+
+	cfg, err := Load(parseFlags(t, "-config", file))
+	require.NoError(t, err)
+	assert.Equal(t, "memory", cfg.Storage)
+	assert.Equal(t, "8f14e45f-ceea-467a-9af0-7b5a1f3a6b11", cfg.FeeRevenueWalletID.String())
+	assert.Equal(t, 24*time.Hour, cfg.SnapshotInterval)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			name: "Все ошибки возвращаются вместе",
+			env: map[string]string{
+				"HTTP_ADDR": "8080", "LOG_LEVEL": "trace", "DB_MAX_CONNS": "many", "RECONCILE_INTERVAL": "hourly",
+			},
+			expected: []string{
+				`database.max_conns (env DB_MAX_CONNS): invalid integer "many"`,
+				`reconcile.interval (env RECONCILE_INTERVAL): invalid duration "hourly"`,
+				"http_addr: address 8080: missing port in address",
+				`log_level must be debug or info, got "trace"`,
+				"database.host is required unless database.url is set",
+			},
+		},
+		{
+			name: "Неизвестный ключ в файле",
+			file: "storage: memory\nhttp_adr: \":8080\"\n",
+			expected: []string{
+				`unknown key "http_adr"`,
+			},
+		},
+		{
+			name: "Минимум соединений больше максимума",
+			env: map[string]string{
+				"DATABASE_URL": "postgres://wallet@db/wallets", "DB_MIN_CONNS": "10", "DB_MAX_CONNS": "5",
+			},
+			expected: []string{
+				"database.min_conns (10) is greater than database.max_conns (5)",
+			},
+		},
+		{
+			name: "Реплика без PostgreSQL",
+			env:  map[string]string{"STORAGE": "memory", "REPLICA_DB_HOST": "replica"},
+			expected: []string{
+				"replica.host requires storage postgres",
+			},
+		},
+		{
+			name: "Токен администратора без имени",
+			env:  map[string]string{"STORAGE": "memory", "ADMIN_TOKENS": "ops=t1,t2"},
+			expected: []string{
+				`admin.tokens: expected name=token, got "t2"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var args []string
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "config.yaml", tt.file))
+			}
+
+			_, err := Load(parseFlags(t, args...))
+			require.Error(t, err)
+			for _, expected := range tt.expected {
+				assert.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://wallet:secret@db:5432/wallets")
+	t.Setenv("DB_PASSWORD", "also-secret")
+
+	cfg, err := Load(nil)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, cfg.Print(&out))
+	printed := out.String()
+
+	assert.NotContains(t, printed, "secret@")
+	assert.NotContains(t, printed, "also-secret")
+	assert.Contains(t, printed, `database.url: "postgres://wallet:xxxxx@db:5432/wallets" # env DATABASE_URL`+"\n")
+	assert.Contains(t, printed, `database.password: "******" # env DB_PASSWORD`+"\n")
+	assert.Contains(t, printed, `database.max_conns: "50" # default`+"\n")
+
+	// Напечатанная конфигурация без секретов читается обратно как файл
+	cfg.Database.URL, cfg.Database.Password = "", ""
+	out.Reset()
+	require.NoError(t, cfg.Print(&out))
+	values, err := readFile(writeFile(t, "printed.yaml", out.String()))
+	require.NoError(t, err)
+	assert.Equal(t, "50", values["database.max_conns"])
+	assert.Equal(t, "5s", values["replica.max_lag"])
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Значения по умолчанию для пула соединений с PostgreSQL
+const (
+	DefaultMaxConns            = 50
+	DefaultMinConns            = 2
+	DefaultMaxConnLifetime     = time.Hour
+	DefaultMaxConnIdleTime     = 30 * time.Minute
+	DefaultHealthCheckPeriod   = 30 * time.Second
+	DefaultConnectTimeout      = 5 * time.Second
+	DefaultConnectRetryTimeout = time.Minute
+	DefaultApplicationName     = "wallet-service"
+)
+
+// Database - подключение к PostgreSQL и параметры пула соединений
+type Database struct {
+	// URL - строка подключения postgres://...; если задана, заменяет User, Host, Port, Name и SSLMode
+	URL      string
+	User     string
+	Password string
+	// PasswordFile - файл с паролем; если задан, пароль читается из него
+	PasswordFile string
+	Host         string
+	Port         string
+	Name         string
+	SSLMode      string
+
+	// ApplicationName видна в pg_stat_activity и помогает найти соединения сервиса
+	ApplicationName string
+	// ConnectTimeout ограничивает установку одного соединения
+	ConnectTimeout time.Duration
+	// ConnectRetryTimeout - сколько при запуске ждать, пока БД станет доступна; 0 - одна попытка
+	ConnectRetryTimeout time.Duration
+
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+}
+
+// readPasswordFile заменяет пароль содержимым PasswordFile, например Docker secret: так пароль не попадает в окружение процесса
+func (d *Database) readPasswordFile() error {
+	if d.PasswordFile == "" {
+		return nil
+	}
+	password, err := os.ReadFile(d.PasswordFile)
+	if err != nil {
+		return fmt.Errorf("database.password_file: %w", err)
+	}
+	d.Password = strings.TrimRight(string(password), "\r\n")
+	return nil
+}
+
+func (d Database) validate() []error {
+	var errs []error
+	if d.URL == "" {
+		for _, required := range []struct{ key, value string }{
+			{"database.host", d.Host}, {"database.user", d.User}, {"database.name", d.Name},
+		} {
+			if required.value == "" {
+				errs = append(errs, fmt.Errorf("%s is required unless database.url is set", required.key))
+			}
+		}
+	} else if _, err := d.ConnString(); err != nil {
+		errs = append(errs, fmt.Errorf("database.url: %w", err))
+	}
+	if d.MaxConns < 1 {
+		errs = append(errs, fmt.Errorf("database.max_conns must be at least 1, got %d", d.MaxConns))
+	}
+	if d.MinConns > d.MaxConns {
+		errs = append(errs, fmt.Errorf("database.min_conns (%d) is greater than database.max_conns (%d)", d.MinConns, d.MaxConns))
+	}
+	return errs
+}
+
+// ConnString возвращает строку подключения. Имя пользователя и пароль экранируются,
+// поэтому в них допустимы любые символы
+func (d Database) ConnString() (string, error) {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(d.User, d.Password),
+		Host:   hostPort(d.Host, d.Port),
+		Path:   "/" + d.Name,
+	}
+	if d.URL != "" {
+		var err error
+		if u, err = url.Parse(d.URL); err != nil {
+			return "", fmt.Errorf("invalid DATABASE_URL: %w", err)
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return "", fmt.Errorf("invalid DATABASE_URL: unsupported scheme %q", u.Scheme)
+		}
+		if _, ok := u.User.Password(); !ok && d.Password != "" {
+			u.User = url.UserPassword(u.User.Username(), d.Password)
+		}
+	}
+
+	// Параметры, явно заданные в DATABASE_URL, не переопределяются
+	query := u.Query()
+	setDefault := func(key, value string) {
+		if value != "" && !query.Has(key) {
+			query.Set(key, value)
+		}
+	}
+	setDefault("sslmode", d.SSLMode)
+	setDefault("application_name", d.ApplicationName)
+	if d.ConnectTimeout > 0 {
+		// connect_timeout задается в целых секундах, меньшее значение означало бы отсутствие ограничения
+		setDefault("connect_timeout", strconv.Itoa(max(1, int(d.ConnectTimeout.Seconds()))))
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// WithHost возвращает параметры подключения к другому серверу с той же базой и учетными данными, например к реплике.
+// Пустой port оставляет прежний порт
+func (d Database) WithHost(host string, port string) (Database, error) {
+	if d.URL == "" {
+		d.Host = host
+		if port != "" {
+			d.Port = port
+		}
+		return d, nil
+	}
+
+	u, err := url.Parse(d.URL)
+	if err != nil {
+		return d, fmt.Errorf("invalid DATABASE_URL: %w", err)
+	}
+	if port == "" {
+		port = u.Port()
+	}
+	u.Host = hostPort(host, port)
+	d.URL = u.String()
+	return d, nil
+}
+
+func hostPort(host string, port string) string {
+	if port == "" {
+		return host
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// field - параметр конфигурации. key - имя в файле конфигурации и флага командной строки, env - переменная окружения
+type field struct {
+	key   string
+	env   string
+	usage string
+	// value возвращает указатель на поле Config
+	value func(c *Config) any
+	// redact скрывает секрет при печати конфигурации
+	redact func(value string) string
+}
+
+// fields - все параметры в порядке печати
+var fields = []field{
+	{key: "http_addr", env: "HTTP_ADDR", usage: "адрес HTTP-сервера", value: func(c *Config) any { return &c.HTTPAddr }},
+	{key: "log_level", env: "LOG_LEVEL", usage: "уровень логирования: debug или info", value: func(c *Config) any { return &c.LogLevel }},
+	{key: "log.file", env: "LOG_FILE", usage: "файл для логов в дополнение к stdout", value: func(c *Config) any { return &c.Log.File }},
+	{key: "log.file_max_size_mb", env: "LOG_FILE_MAX_SIZE_MB", usage: "размер файла логов для ротации, МБ", value: func(c *Config) any { return &c.Log.FileMaxSizeMB }},
+	{key: "log.file_max_backups", env: "LOG_FILE_MAX_BACKUPS", usage: "сколько старых файлов логов хранить, 0 - все", value: func(c *Config) any { return &c.Log.FileMaxBackups }},
+	{key: "log.file_max_age_days", env: "LOG_FILE_MAX_AGE_DAYS", usage: "сколько дней хранить старые файлы логов, 0 - без ограничения", value: func(c *Config) any { return &c.Log.FileMaxAgeDays }},
+	{key: "log.debug_sample_first", env: "LOG_DEBUG_SAMPLE_FIRST", usage: "сколько одинаковых отладочных сообщений в секунду писать полностью, 0 - без выборки", value: func(c *Config) any { return &c.Log.DebugSampleFirst }},
+	{key: "log.debug_sample_thereafter", env: "LOG_DEBUG_SAMPLE_THEREAFTER", usage: "каждое какое отладочное сообщение писать сверх log.debug_sample_first", value: func(c *Config) any { return &c.Log.DebugSampleThereafter }},
+	{key: "log.time_format", env: "LOG_TIME_FORMAT", usage: "формат времени: local или iso8601 (UTC)", value: func(c *Config) any { return &c.Log.TimeFormat }},
+	{key: "log.redact", env: "LOG_REDACT", usage: "скрывать в логах идентификаторы кошельков, суммы и токены", value: func(c *Config) any { return &c.Log.Redact }},
+	{key: "log.redact_policies", env: "LOG_REDACT_POLICIES", usage: "политики скрытия полей вида ключ=keep|mask|hash|drop через запятую", value: func(c *Config) any { return &c.Log.RedactPolicies }},
+	{key: "log.redact_hash_key", env: "LOG_REDACT_HASH_KEY", usage: "ключ HMAC для политики hash", value: func(c *Config) any { return &c.Log.RedactHashKey }, redact: redactSecret},
+	{key: "storage", env: "STORAGE", usage: "хранилище: postgres или memory", value: func(c *Config) any { return &c.Storage }},
+
+	{key: "database.url", env: "DATABASE_URL", usage: "строка подключения postgres://...", value: func(c *Config) any { return &c.Database.URL }, redact: redactURL},
+	{key: "database.user", env: "DB_USER", usage: "пользователь БД", value: func(c *Config) any { return &c.Database.User }},
+	{key: "database.password", env: "DB_PASSWORD", usage: "пароль БД", value: func(c *Config) any { return &c.Database.Password }, redact: redactSecret},
+	{key: "database.password_file", env: "DB_PASSWORD_FILE", usage: "файл с паролем БД", value: func(c *Config) any { return &c.Database.PasswordFile }},
+	{key: "database.host", env: "DB_HOST", usage: "хост БД", value: func(c *Config) any { return &c.Database.Host }},
+	{key: "database.port", env: "DB_PORT", usage: "порт БД", value: func(c *Config) any { return &c.Database.Port }},
+	{key: "database.name", env: "DB_NAME", usage: "имя БД", value: func(c *Config) any { return &c.Database.Name }},
+	{key: "database.sslmode", env: "DB_SSLMODE", usage: "режим SSL", value: func(c *Config) any { return &c.Database.SSLMode }},
+	{key: "database.application_name", env: "DB_APPLICATION_NAME", usage: "имя в pg_stat_activity", value: func(c *Config) any { return &c.Database.ApplicationName }},
+	{key: "database.connect_timeout", env: "DB_CONNECT_TIMEOUT", usage: "таймаут установки соединения", value: func(c *Config) any { return &c.Database.ConnectTimeout }},
+	{key: "database.connect_retry_timeout", env: "DB_CONNECT_RETRY_TIMEOUT", usage: "сколько при запуске ждать доступности БД", value: func(c *Config) any { return &c.Database.ConnectRetryTimeout }},
+	{key: "database.max_conns", env: "DB_MAX_CONNS", usage: "максимум соединений в пуле", value: func(c *Config) any { return &c.Database.MaxConns }},
+	{key: "database.min_conns", env: "DB_MIN_CONNS", usage: "минимум открытых соединений в пуле", value: func(c *Config) any { return &c.Database.MinConns }},
+	{key: "database.max_conn_lifetime", env: "DB_MAX_CONN_LIFETIME", usage: "время жизни соединения", value: func(c *Config) any { return &c.Database.MaxConnLifetime }},
+	{key: "database.max_conn_idle_time", env: "DB_MAX_CONN_IDLE_TIME", usage: "время простоя соединения", value: func(c *Config) any { return &c.Database.MaxConnIdleTime }},
+	{key: "database.health_check_period", env: "DB_HEALTH_CHECK_PERIOD", usage: "период проверки соединений", value: func(c *Config) any { return &c.Database.HealthCheckPeriod }},
+
+	{key: "replica.host", env: "REPLICA_DB_HOST", usage: "хост реплики для чтений", value: func(c *Config) any { return &c.ReplicaHost }},
+	{key: "replica.port", env: "REPLICA_DB_PORT", usage: "порт реплики", value: func(c *Config) any { return &c.ReplicaPort }},
+	{key: "replica.max_lag", env: "REPLICA_MAX_LAG", usage: "допустимое отставание реплики", value: func(c *Config) any { return &c.ReplicaMaxLag }},
+
+	{key: "fees.revenue_wallet_id", env: "FEE_REVENUE_WALLET_ID", usage: "кошелек для зачисления комиссий", value: func(c *Config) any { return &c.FeeRevenueWalletID }},
+	{key: "fees.schedule_file", env: "FEE_SCHEDULE_FILE", usage: "JSON-файл с правилами комиссий", value: func(c *Config) any { return &c.FeeScheduleFile }},
+
+	{key: "reconcile.interval", env: "RECONCILE_INTERVAL", usage: "период фоновой сверки, 0 - выключена", value: func(c *Config) any { return &c.ReconcileInterval }},
+	{key: "reconcile.repair", env: "RECONCILE_REPAIR", usage: "исправлять расхождения при фоновой сверке", value: func(c *Config) any { return &c.ReconcileRepair }},
+	{key: "snapshot.interval", env: "SNAPSHOT_INTERVAL", usage: "период снимков балансов, 0 - выключены", value: func(c *Config) any { return &c.SnapshotInterval }},
+
+	{key: "idempotency.ttl", env: "IDEMPOTENCY_TTL", usage: "время хранения ответов на запросы с Idempotency-Key, 0 - выключено", value: func(c *Config) any { return &c.IdempotencyTTL }},
+
+	{key: "admin.tokens", env: "ADMIN_TOKENS", usage: "токены администраторов вида имя=токен через запятую", value: func(c *Config) any { return &c.AdminTokens }, redact: redactSecret},
+}
+
+// set разбирает строковое значение в поле его типа
+func (f field) set(c *Config, value string) error {
+	switch p := f.value(c).(type) {
+	case *string:
+		*p = value
+	case *bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		*p = v
+	case *int32:
+		v, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		*p = int32(v)
+	case *time.Duration:
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		if v < 0 {
+			return fmt.Errorf("negative duration %q", value)
+		}
+		*p = v
+	case *uuid.UUID:
+		if value == "" {
+			*p = uuid.Nil
+			return nil
+		}
+		v, err := uuid.Parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid UUID %q", value)
+		}
+		*p = v
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T of %s", p, f.key))
+	}
+	return nil
+}
+
+// format возвращает значение поля в виде, который принимает set
+func (f field) format(c *Config) string {
+	switch p := f.value(c).(type) {
+	case *string:
+		return *p
+	case *bool:
+		return strconv.FormatBool(*p)
+	case *int32:
+		return strconv.FormatInt(int64(*p), 10)
+	case *time.Duration:
+		return p.String()
+	case *uuid.UUID:
+		if *p == uuid.Nil {
+			return ""
+		}
+		return p.String()
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T of %s", p, f.key))
+	}
+}
+
+func (f field) isBool() bool {
+	_, ok := f.value(&Config{}).(*bool)
+	return ok
+}
+
+func redactSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "******"
+}
+
+// redactURL скрывает пароль в строке подключения, оставляя остальное для диагностики
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return redactSecret(value)
+	}
+	return u.Redacted()
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+
+	"testtask/pkg/logger"
+)
+
+// Значения по умолчанию для логирования
+const (
+	DefaultLogFileMaxSizeMB         = 100
+	DefaultLogFileMaxBackups        = 5
+	DefaultLogFileMaxAgeDays        = 30
+	DefaultLogDebugSampleFirst      = 100
+	DefaultLogDebugSampleThereafter = 100
+)
+
+// Форматы времени в логах
+const (
+	// TimeFormatLocal - местное время в формате 02-01-2006 15:04:05
+	TimeFormatLocal = "local"
+	// TimeFormatISO8601 - время UTC в формате ISO-8601
+	TimeFormatISO8601 = "iso8601"
+)
+
+// Log - вывод логов. Уровень задается отдельно в Config.LogLevel
+type Log struct {
+	// File - файл для логов в дополнение к stdout; если не задан, логи пишутся только в stdout
+	File           string
+	FileMaxSizeMB  int32
+	FileMaxBackups int32
+	FileMaxAgeDays int32
+
+	// DebugSampleFirst одинаковых отладочных сообщений в секунду пишутся полностью, дальше - каждое DebugSampleThereafter-е
+	DebugSampleFirst      int32
+	DebugSampleThereafter int32
+
+	TimeFormat string
+
+	// Redact скрывает в логах идентификаторы кошельков, суммы, токены и заголовки
+	Redact bool
+	// RedactPolicies дополняет политики по умолчанию, например "amount=keep,account_id=mask"
+	RedactPolicies string
+	// RedactHashKey - ключ HMAC для политики hash; если не задан, хэши сопоставимы только в пределах процесса
+	RedactHashKey string
+}
+
+// LoggerOptions возвращает параметры логгера
+func (l Log) LoggerOptions() []logger.Option {
+	var opts []logger.Option
+	if l.File != "" {
+		opts = append(opts, logger.WithFile(logger.File{
+			Path:       l.File,
+			MaxSizeMB:  int(l.FileMaxSizeMB),
+			MaxBackups: int(l.FileMaxBackups),
+			MaxAgeDays: int(l.FileMaxAgeDays),
+		}))
+	}
+	if l.DebugSampleFirst > 0 {
+		opts = append(opts, logger.WithDebugSampling(int(l.DebugSampleFirst), int(l.DebugSampleThereafter)))
+	}
+	if l.TimeFormat == TimeFormatISO8601 {
+		opts = append(opts, logger.WithUTCTime())
+	}
+	if l.Redact {
+		// Политики уже проверены в validate
+		policies, _ := logger.ParsePolicies(l.RedactPolicies)
+		opts = append(opts, logger.WithRedactor(logger.NewRedactor(policies, []byte(l.RedactHashKey))))
+	}
+	return opts
+}
+
+func (l Log) validate() []error {
+	var errs []error
+	for _, v := range []struct {
+		key   string
+		value int32
+	}{
+		{"log.file_max_size_mb", l.FileMaxSizeMB}, {"log.file_max_backups", l.FileMaxBackups},
+		{"log.file_max_age_days", l.FileMaxAgeDays}, {"log.debug_sample_first", l.DebugSampleFirst},
+	} {
+		if v.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", v.key, v.value))
+		}
+	}
+	if l.DebugSampleFirst > 0 && l.DebugSampleThereafter < 1 {
+		errs = append(errs, fmt.Errorf("log.debug_sample_thereafter must be at least 1, got %d", l.DebugSampleThereafter))
+	}
+	if _, err := logger.ParsePolicies(l.RedactPolicies); err != nil {
+		errs = append(errs, fmt.Errorf("log.redact_policies: %w", err))
+	}
+	switch l.TimeFormat {
+	case TimeFormatLocal, TimeFormatISO8601:
+	default:
+		errs = append(errs, fmt.Errorf("log.time_format must be %s or %s, got %q", TimeFormatLocal, TimeFormatISO8601, l.TimeFormat))
+	}
+	return errs
+}
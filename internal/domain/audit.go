@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditAction string
+
+const (
+	AuditTierChanged    AuditAction = "TIER_CHANGED"
+	AuditWalletFrozen   AuditAction = "WALLET_FROZEN"
+	AuditWalletUnfrozen AuditAction = "WALLET_UNFROZEN"
+	AuditAdjustment     AuditAction = "ADJUSTMENT"
+)
+
+// AuditEntry - запись журнала административных изменений кошелька
+type AuditEntry struct {
+	ID        int64
+	WalletID  uuid.UUID
+	Action    AuditAction
+	OldValue  string
+	NewValue  string
+	Actor     string
+	Reason    string
+	CreatedAt time.Time
+}
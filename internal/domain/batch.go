@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrBatchEmpty       = errors.New("batch is empty")
+	ErrBatchTooLarge    = errors.New("batch is too large")
+	ErrUnknownBatchMode = errors.New("unknown batch mode")
+	ErrBatchInvalid     = errors.New("batch contains invalid operations")
+)
+
+type BatchMode string
+
+const (
+	// BatchAtomic исполняет все операции в одной транзакции: ошибка любой откатывает весь пакет
+	BatchAtomic BatchMode = "atomic"
+	// BatchIndependent исполняет каждую операцию в своей транзакции и возвращает результат по каждой
+	BatchIndependent BatchMode = "independent"
+)
+
+// BatchItemError связывает ошибку с позицией операции в пакете
+type BatchItemError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchItemError) Error() string {
+	return fmt.Sprintf("operation %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchItemError) Unwrap() error {
+	return e.Err
+}
+
+// BatchValidationError перечисляет все операции пакета, не прошедшие проверку.
+// errors.Is(err, ErrBatchInvalid) для нее истинно
+type BatchValidationError struct {
+	Items []BatchItemError
+}
+
+func (e *BatchValidationError) Error() string {
+	return fmt.Sprintf("%v: %d invalid", ErrBatchInvalid, len(e.Items))
+}
+
+func (e *BatchValidationError) Unwrap() error {
+	return ErrBatchInvalid
+}
+
+// BatchItemResult - результат одной операции пакета: либо Result, либо Err
+type BatchItemResult struct {
+	Index  int
+	Result *OperationResult
+	Err    error
+}
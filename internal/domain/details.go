@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"unicode/utf8"
+)
+
+var ErrInvalidWalletDetails = errors.New("invalid wallet details")
+
+// Ограничения реквизитов кошелька
+const (
+	MaxClientIDLength   = 255
+	MaxExternalIDLength = 255
+	MaxOwnerIDLength    = 255
+	MaxLabelLength      = 255
+	// MaxMetadataSize - предельный размер метаданных в JSON, байт
+	MaxMetadataSize = 16 << 10
+)
+
+// WalletDetails - реквизиты, которые клиент задает при создании кошелька
+type WalletDetails struct {
+	ClientID   string
+	ExternalID string
+	OwnerID    string
+	Label      string
+	Metadata   map[string]any
+}
+
+// Validate проверяет длину клиента, внешнего ID, владельца и названия и размер метаданных
+func (d WalletDetails) Validate() error {
+	if utf8.RuneCountInString(d.ClientID) > MaxClientIDLength {
+		return fmt.Errorf("%w: client_id is longer than %d characters", ErrInvalidWalletDetails, MaxClientIDLength)
+	}
+	if utf8.RuneCountInString(d.ExternalID) > MaxExternalIDLength {
+		return fmt.Errorf("%w: external_id is longer than %d characters", ErrInvalidWalletDetails, MaxExternalIDLength)
+	}
+	if utf8.RuneCountInString(d.OwnerID) > MaxOwnerIDLength {
+		return fmt.Errorf("%w: owner_id is longer than %d characters", ErrInvalidWalletDetails, MaxOwnerIDLength)
+	}
+	if utf8.RuneCountInString(d.Label) > MaxLabelLength {
+		return fmt.Errorf("%w: label is longer than %d characters", ErrInvalidWalletDetails, MaxLabelLength)
+	}
+	data, err := json.Marshal(d.Metadata)
+	if err != nil {
+		return fmt.Errorf("%w: metadata is not valid JSON: %w", ErrInvalidWalletDetails, err)
+	}
+	if len(data) > MaxMetadataSize {
+		return fmt.Errorf("%w: metadata is larger than %d bytes", ErrInvalidWalletDetails, MaxMetadataSize)
+	}
+	return nil
+}
+
+// WalletUpdate - изменение реквизитов кошелька. Nil Label не меняет название,
+// Metadata применяется к текущим метаданным как JSON Merge Patch (RFC 7396)
+type WalletUpdate struct {
+	Label    *string
+	Metadata map[string]any
+}
+
+// Apply возвращает реквизиты кошелька после изменения; клиент, внешний ID и владелец не меняются
+func (u WalletUpdate) Apply(w *Wallet) WalletDetails {
+	details := WalletDetails{ClientID: w.ClientID, ExternalID: w.ExternalID, OwnerID: w.OwnerID, Label: w.Label, Metadata: MergeMetadata(w.Metadata, u.Metadata)}
+	if u.Label != nil {
+		details.Label = *u.Label
+	}
+	return details
+}
+
+// MergeMetadata применяет patch к метаданным по правилам JSON Merge Patch: ключ со значением nil удаляется,
+// вложенные объекты объединяются, остальные значения заменяются. current не изменяется
+func MergeMetadata(current, patch map[string]any) map[string]any {
+	merged := maps.Clone(current)
+	if merged == nil {
+		merged = make(map[string]any, len(patch))
+	}
+	for key, value := range patch {
+		switch value := value.(type) {
+		case nil:
+			delete(merged, key)
+		case map[string]any:
+			nested, _ := merged[key].(map[string]any)
+			merged[key] = MergeMetadata(nested, value)
+		default:
+			merged[key] = value
+		}
+	}
+	return merged
+}
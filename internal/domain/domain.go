@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -13,22 +14,124 @@ var (
 	ErrAmountZeroOrNegative  = errors.New("amount is zero or is negative")
 	ErrInsufficientFunds     = errors.New("insufficient funds")
 	ErrUnknownOperationType  = errors.New("unknown operation type")
+	ErrCreditLimitNegative   = errors.New("credit limit is negative")
+	ErrCreditLimitTooLow     = errors.New("credit limit does not cover current debt")
+	ErrTransferToSameWallet  = errors.New("transfer to the same wallet")
+	ErrWalletFrozen          = errors.New("wallet is frozen")
+	ErrAdjustmentZero        = errors.New("adjustment amount is zero")
+	ErrReasonRequired        = errors.New("reason is required")
 )
 
 type OperationType string
 
+// Типы операций, которые может запросить клиент
 const (
 	Deposit  OperationType = "DEPOSIT"
 	Withdraw OperationType = "WITHDRAW"
+	Transfer OperationType = "TRANSFER"
+)
+
+// Типы записей истории, которые порождает перевод и удержание комиссии
+const (
+	TransferOut OperationType = "TRANSFER_OUT"
+	TransferIn  OperationType = "TRANSFER_IN"
+	Fee         OperationType = "FEE"
+)
+
+// Типы записей истории для ручных корректировок баланса администратором
+const (
+	AdjustmentIn  OperationType = "ADJUSTMENT_IN"
+	AdjustmentOut OperationType = "ADJUSTMENT_OUT"
+)
+
+var (
+	// OutgoingOperationTypes - записи истории, учитываемые в лимитах на списания
+	OutgoingOperationTypes = []OperationType{Withdraw, TransferOut}
+	// IncomingOperationTypes - записи истории, учитываемые в ограничениях уровня на пополнения
+	IncomingOperationTypes = []OperationType{Deposit, TransferIn}
+)
+
+type WalletTier string
+
+// WalletStatus - состояние кошелька; операции по замороженному кошельку запрещены
+type WalletStatus string
+
+const (
+	WalletActive WalletStatus = "ACTIVE"
+	WalletFrozen WalletStatus = "FROZEN"
 )
 
 type OperationRequest struct {
 	ID            uuid.UUID
 	OperationType OperationType
 	Amount        decimal.Decimal
+	// TargetID - кошелек получателя, заполняется только для переводов
+	TargetID uuid.UUID
+	// OccurredAt - время операции при загрузке истории задним числом; нулевое - время транзакции
+	OccurredAt time.Time
+}
+
+// OperationResult описывает исполненную операцию: Gross списывается или зачисляется на кошелек ID,
+// Fee уходит на кошелек доходов, Net получает внешняя сторона или кошелек получателя
+type OperationResult struct {
+	ID            uuid.UUID
+	WalletID      uuid.UUID
+	OperationType OperationType
+	Gross         decimal.Decimal
+	Fee           decimal.Decimal
+	Net           decimal.Decimal
+	Balance       decimal.Decimal
+	// LSN - позиция журнала после коммита операции, по ней клиент читает свои записи с реплики
+	LSN string
 }
 
 type Wallet struct {
-	ID      uuid.UUID
-	Balance decimal.Decimal
+	ID          uuid.UUID
+	Balance     decimal.Decimal
+	CreditLimit decimal.Decimal
+	Tier        WalletTier
+	Currency    string
+	Status      WalletStatus
+	// ClientID - клиент, в системе которого заведена запись ExternalID; пустой - клиент по умолчанию
+	ClientID string
+	// ExternalID - ссылка на запись клиента во внешней системе, уникальна в пределах ClientID; пустая - кошелек не связан
+	ExternalID string
+	// OwnerID - владелец кошелька (клиент, покупатель) во внешней системе; у владельца может быть несколько кошельков
+	OwnerID string
+	Label   string
+	// Metadata - произвольные данные клиента, JSON-объект
+	Metadata  map[string]any
+	CreatedAt time.Time
+}
+
+// Available возвращает сумму, доступную для списания с учетом кредитного лимита
+func (w *Wallet) Available() decimal.Decimal {
+	return w.Balance.Add(w.CreditLimit)
+}
+
+// Operation - запись об исполненной операции в истории кошелька.
+// Записи одной клиентской операции (списание, зачисление получателю, комиссия) имеют общий CorrelationID
+type Operation struct {
+	ID            uuid.UUID
+	CorrelationID uuid.UUID
+	WalletID      uuid.UUID
+	OperationType OperationType
+	Amount        decimal.Decimal
+	CreatedAt     time.Time
+}
+
+// InsufficientFundsError возвращается, когда доступных средств не хватает для списания.
+// errors.Is(err, ErrInsufficientFunds) для нее истинно. Суммы не входят в текст ошибки, чтобы не попасть в логи,
+// и передаются клиенту отдельными полями ответа
+type InsufficientFundsError struct {
+	Available decimal.Decimal
+	Amount    decimal.Decimal
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return ErrInsufficientFunds.Error()
+}
+
+func (e *InsufficientFundsError) Unwrap() error {
+	return ErrInsufficientFunds
 }
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrFeeExceedsAmount           = errors.New("fee exceeds operation amount")
+	ErrRevenueWalletNotConfigured = errors.New("revenue wallet is not configured")
+)
+
+// feePrecision - количество знаков после запятой, до которого округляется комиссия
+const feePrecision = 2
+
+// FeeRule - правило расчета комиссии для типа операции и уровня кошелька.
+// Пустой Tier означает правило для всех уровней, для которых нет собственного
+type FeeRule struct {
+	OperationType OperationType
+	Tier          WalletTier
+	Fixed         decimal.Decimal
+	// Percent - процент от суммы операции, 1.5 означает 1.5%
+	Percent decimal.Decimal
+	Min     decimal.NullDecimal
+	Max     decimal.NullDecimal
+}
+
+// Calculate возвращает комиссию для суммы операции: fixed + amount * percent / 100,
+// ограниченную снизу Min и сверху Max
+func (r *FeeRule) Calculate(amount decimal.Decimal) decimal.Decimal {
+	fee := r.Fixed.Add(amount.Mul(r.Percent).Div(decimal.NewFromInt(100)))
+	if r.Min.Valid && fee.LessThan(r.Min.Decimal) {
+		fee = r.Min.Decimal
+	}
+	if r.Max.Valid && fee.GreaterThan(r.Max.Decimal) {
+		fee = r.Max.Decimal
+	}
+	return fee.Round(feePrecision)
+}
+
+// FeeSchedule ищет правило комиссии. Отсутствие правила (nil, nil) означает операцию без комиссии
+type FeeSchedule interface {
+	FindRule(ctx context.Context, operationType OperationType, tier WalletTier) (*FeeRule, error)
+}
+
+// FeeableOperationTypes - операции, с которых удерживается комиссия
+var FeeableOperationTypes = []OperationType{Withdraw, Transfer}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrInvalidTimeRange  = errors.New("invalid time range")
+	ErrTimeRangeTooLarge = errors.New("time range is too large")
+)
+
+// MaxBalanceSeriesDays - наибольшее количество дней в ряду балансов на конец дня
+const MaxBalanceSeriesDays = 366
+
+// BalancePoint - баланс кошелька на конец дня Date в часовом поясе запроса
+type BalancePoint struct {
+	Date    time.Time
+	Balance decimal.Decimal
+}
+
+// DailyChange - сумма проводок по счету за календарный день
+type DailyChange struct {
+	Date   time.Time
+	Amount decimal.Decimal
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrIdempotencyKeyReused - ключ идемпотентности уже использован для запроса с другим содержимым
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// IdempotencyKey - ключ, которым клиент помечает повторы одного запроса, и отпечаток содержимого запроса.
+// Replayed отмечается, если результат запроса взят из сохраненных, а не получен его выполнением
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint [sha256.Size]byte
+	Replayed    bool
+}
+
+// Item возвращает ключ отдельной операции пакета: операции независимого пакета выполняются
+// в собственных транзакциях, поэтому и их результаты сохраняются по отдельности
+func (k *IdempotencyKey) Item(index int) *IdempotencyKey {
+	return &IdempotencyKey{Key: k.Key + "#" + strconv.Itoa(index), Fingerprint: k.Fingerprint}
+}
+
+// IdempotentResponse - сохраненный по ключу результат запроса в JSON.
+// Response пуст, пока транзакция, занявшая ключ, не завершилась
+type IdempotentResponse struct {
+	Key         string
+	Fingerprint [sha256.Size]byte
+	Response    []byte
+	ExpiresAt   time.Time
+}
+
+type IdempotencyRepository interface {
+	// Acquire занимает ключ до конца транзакции. Если по ключу уже сохранен неистекший результат, возвращает его;
+	// если ключ занят незавершенной транзакцией, дожидается ее. Для нового или истекшего ключа возвращает nil
+	Acquire(ctx context.Context, key string, fingerprint [sha256.Size]byte, expiresAt time.Time) (*IdempotentResponse, error)
+	// Save сохраняет результат запроса по занятому ключу
+	Save(ctx context.Context, key string, response []byte) error
+	// DeleteExpired удаляет ключи, истекшие к моменту before, и возвращает их количество
+	DeleteExpired(ctx context.Context, before time.Time) (int, error)
+}
+
+type idempotencyKeyKey struct{}
+
+// ContextWithIdempotencyKey возвращает контекст, запрос в котором выполняется не больше одного раза на ключ
+func ContextWithIdempotencyKey(ctx context.Context, key *IdempotencyKey) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKeyFromContext возвращает ключ идемпотентности, переданный в контексте
+func IdempotencyKeyFromContext(ctx context.Context) (*IdempotencyKey, bool) {
+	key, ok := ctx.Value(idempotencyKeyKey{}).(*IdempotencyKey)
+	return key, ok
+}
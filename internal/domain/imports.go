@@ -0,0 +1,102 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrImportNotFound    = errors.New("import not found")
+	ErrInvalidImportFile = errors.New("invalid import file")
+	ErrInvalidImportRow  = errors.New("invalid import row")
+	// ErrImportNotClaimable - импорт уже применяет другой обработчик или он завершен
+	ErrImportNotClaimable = errors.New("import is running or completed")
+	// ErrImportRowProcessed - строку уже применил или отклонил другой обработчик
+	ErrImportRowProcessed = errors.New("import row is already processed")
+)
+
+type ImportStatus string
+
+const (
+	// ImportPending - строки загружены, но еще не применялись
+	ImportPending   ImportStatus = "PENDING"
+	ImportRunning   ImportStatus = "RUNNING"
+	ImportCompleted ImportStatus = "COMPLETED"
+	// ImportFailed - импорт прерван ошибкой инфраструктуры; ошибки отдельных строк сюда не относятся
+	ImportFailed ImportStatus = "FAILED"
+)
+
+type ImportRowStatus string
+
+const (
+	ImportRowPending ImportRowStatus = "PENDING"
+	ImportRowApplied ImportRowStatus = "APPLIED"
+	ImportRowFailed  ImportRowStatus = "FAILED"
+)
+
+// Import - задание на загрузку операций из файла
+type Import struct {
+	ID          uuid.UUID
+	FileName    string
+	Status      ImportStatus
+	TotalRows   int
+	AppliedRows int
+	FailedRows  int
+	Error       string
+	CreatedAt   time.Time
+	FinishedAt  *time.Time
+	// HeartbeatAt - последняя отметка обработчика RUNNING-импорта; импорт без отметки дольше аренды считается брошенным
+	HeartbeatAt *time.Time
+}
+
+// ImportRow - строка файла импорта в исходном виде. Line - номер строки в файле
+type ImportRow struct {
+	ImportID       uuid.UUID
+	Line           int
+	WalletID       string
+	OperationType  string
+	Amount         string
+	TargetWalletID string
+	// OccurredAt - время операции в RFC 3339; пустое - операция проводится временем применения
+	OccurredAt  string
+	Status      ImportRowStatus
+	Error       string
+	OperationID uuid.UUID
+}
+
+// ImportRowSource возвращает следующую строку файла или nil, когда строки закончились
+type ImportRowSource func() (*ImportRow, error)
+
+// Request разбирает строку в запрос операции. Бизнес-правила проверяются при исполнении
+func (r *ImportRow) Request() (OperationRequest, error) {
+	walletID, err := uuid.Parse(r.WalletID)
+	if err != nil {
+		return OperationRequest{}, fmt.Errorf("%w: invalid wallet: %v", ErrInvalidImportRow, err)
+	}
+	amount, err := decimal.NewFromString(r.Amount)
+	if err != nil {
+		return OperationRequest{}, fmt.Errorf("%w: invalid amount: %v", ErrInvalidImportRow, err)
+	}
+
+	req := OperationRequest{
+		ID:            walletID,
+		OperationType: OperationType(r.OperationType),
+		Amount:        amount,
+	}
+	if r.TargetWalletID != "" {
+		if req.TargetID, err = uuid.Parse(r.TargetWalletID); err != nil {
+			return OperationRequest{}, fmt.Errorf("%w: invalid target_wallet: %v", ErrInvalidImportRow, err)
+		}
+	}
+	if r.OccurredAt != "" {
+		if req.OccurredAt, err = time.Parse(time.RFC3339, r.OccurredAt); err != nil {
+			return OperationRequest{}, fmt.Errorf("%w: invalid occurred_at: %v", ErrInvalidImportRow, err)
+		}
+	}
+
+	return req, nil
+}
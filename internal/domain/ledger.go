@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrJournalUnbalanced     = errors.New("journal is not balanced")
+	ErrCurrencyMismatch      = errors.New("wallet currencies do not match")
+	ErrSystemAccountNotFound = errors.New("system account not found")
+)
+
+// DefaultCurrency - валюта новых кошельков
+const DefaultCurrency = "RUB"
+
+// SystemAccount - счет, через который деньги входят в систему и покидают ее.
+// Для каждой валюты заводится свой счет
+type SystemAccount string
+
+const (
+	// CashIn - источник пополнений
+	CashIn SystemAccount = "cash-in"
+	// CashOut - получатель выводов
+	CashOut SystemAccount = "cash-out"
+	// OpeningBalance - счет, против которого перенесены балансы, существовавшие до ведения журнала
+	OpeningBalance SystemAccount = "opening-balance"
+	// Adjustments - счет, против которого проводятся ручные корректировки балансов
+	Adjustments SystemAccount = "adjustments"
+)
+
+// Posting - проводка по счету. Положительная сумма увеличивает баланс счета, отрицательная - уменьшает.
+// Счет кошелька имеет тот же ID, что и кошелек
+type Posting struct {
+	AccountID uuid.UUID
+	Amount    decimal.Decimal
+	Currency  string
+	// OperationID - запись истории операций, которую отражает проводка. Пуст для системных счетов
+	OperationID uuid.UUID
+}
+
+// Journal - набор проводок одной клиентской операции. Сумма проводок по каждой валюте равна нулю,
+// поэтому деньги только перемещаются между счетами и не создаются и не исчезают
+type Journal struct {
+	ID            uuid.UUID
+	CorrelationID uuid.UUID
+	Description   string
+	Postings      []Posting
+	// CreatedAt - время проводок. Заданное до сохранения проводит журнал задним числом, иначе это время транзакции
+	CreatedAt time.Time
+}
+
+func NewJournal(correlationID uuid.UUID, description string) *Journal {
+	return &Journal{
+		ID:            uuid.New(),
+		CorrelationID: correlationID,
+		Description:   description,
+	}
+}
+
+// Backdated сообщает, что журнал проводится задним числом, например при загрузке истории операций
+func (j *Journal) Backdated() bool {
+	return !j.CreatedAt.IsZero()
+}
+
+// Post добавляет проводку в журнал
+func (j *Journal) Post(accountID uuid.UUID, amount decimal.Decimal, currency string) {
+	j.Postings = append(j.Postings, Posting{AccountID: accountID, Amount: amount, Currency: currency})
+}
+
+// PostOperation добавляет проводку по кошельку, связанную с записью истории операций
+func (j *Journal) PostOperation(operationID, accountID uuid.UUID, amount decimal.Decimal, currency string) {
+	j.Postings = append(j.Postings, Posting{AccountID: accountID, Amount: amount, Currency: currency, OperationID: operationID})
+}
+
+// Validate проверяет, что журнал не пуст, не содержит нулевых проводок и сбалансирован по каждой валюте
+func (j *Journal) Validate() error {
+	if len(j.Postings) == 0 {
+		return fmt.Errorf("%w: no postings", ErrJournalUnbalanced)
+	}
+
+	sums := make(map[string]decimal.Decimal)
+	for _, p := range j.Postings {
+		if p.Amount.IsZero() {
+			return fmt.Errorf("%w: zero posting", ErrJournalUnbalanced)
+		}
+		sums[p.Currency] = sums[p.Currency].Add(p.Amount)
+	}
+	for currency, sum := range sums {
+		if !sum.IsZero() {
+			return fmt.Errorf("%w: %s postings do not sum to zero", ErrJournalUnbalanced, currency)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrLimitExceeded    = errors.New("limit exceeded")
+	ErrLimitNotPositive = errors.New("limit must be positive")
+)
+
+const (
+	// DailyLimitWindow и MonthlyLimitWindow - скользящие окна, за которые суммируются списания
+	DailyLimitWindow   = 24 * time.Hour
+	MonthlyLimitWindow = 30 * 24 * time.Hour
+)
+
+type LimitKind string
+
+const (
+	LimitDailyWithdrawal   LimitKind = "daily_withdrawal"
+	LimitMonthlyWithdrawal LimitKind = "monthly_withdrawal"
+	LimitSingleWithdrawal  LimitKind = "single_withdrawal"
+)
+
+// SpendingLimits описывает ограничения на списания. Невалидное значение означает отсутствие лимита
+type SpendingLimits struct {
+	DailyWithdrawal   decimal.NullDecimal
+	MonthlyWithdrawal decimal.NullDecimal
+	SingleWithdrawal  decimal.NullDecimal
+}
+
+// Override возвращает лимиты, в которых заданные в override значения заменяют текущие
+func (l SpendingLimits) Override(override SpendingLimits) SpendingLimits {
+	if override.DailyWithdrawal.Valid {
+		l.DailyWithdrawal = override.DailyWithdrawal
+	}
+	if override.MonthlyWithdrawal.Valid {
+		l.MonthlyWithdrawal = override.MonthlyWithdrawal
+	}
+	if override.SingleWithdrawal.Valid {
+		l.SingleWithdrawal = override.SingleWithdrawal
+	}
+	return l
+}
+
+// Validate проверяет, что все заданные лимиты положительны
+func (l SpendingLimits) Validate() error {
+	for _, limit := range []decimal.NullDecimal{l.DailyWithdrawal, l.MonthlyWithdrawal, l.SingleWithdrawal} {
+		if limit.Valid && !limit.Decimal.IsPositive() {
+			return ErrLimitNotPositive
+		}
+	}
+	return nil
+}
+
+// LimitExceededError возвращается, когда операция превышает один из лимитов.
+// errors.Is(err, ErrLimitExceeded) для нее истинно. Суммы, как и у InsufficientFundsError, в текст ошибки не входят
+type LimitExceededError struct {
+	Limit     LimitKind
+	Max       decimal.Decimal
+	Remaining decimal.Decimal
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("%s limit exceeded", e.Limit)
+}
+
+func (e *LimitExceededError) Unwrap() error {
+	return ErrLimitExceeded
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap/zapcore"
+)
+
+// MarshalLogObject пишет запрос в лог полями с теми же ключами, что и в остальных записях,
+// чтобы к ним применялись политики скрытия
+func (r OperationRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("wallet_id", r.ID.String())
+	enc.AddString("operation_type", string(r.OperationType))
+	enc.AddString("amount", r.Amount.String())
+	if r.TargetID != uuid.Nil {
+		enc.AddString("target_wallet_id", r.TargetID.String())
+	}
+	if !r.OccurredAt.IsZero() {
+		enc.AddTime("occurred_at", r.OccurredAt)
+	}
+	return nil
+}
+
+func (w Wallet) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("wallet_id", w.ID.String())
+	enc.AddString("balance", w.Balance.String())
+	enc.AddString("credit_limit", w.CreditLimit.String())
+	enc.AddString("tier", string(w.Tier))
+	enc.AddString("currency", w.Currency)
+	enc.AddString("status", string(w.Status))
+	return nil
+}
+
+// MarshalLogObject пишет журнал без сумм и идентификаторов счетов в открытом виде:
+// проводки пишутся с ключами account_id и amount, к которым применяются политики скрытия
+func (j *Journal) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("journal_id", j.ID.String())
+	enc.AddString("correlation_id", j.CorrelationID.String())
+	enc.AddString("description", j.Description)
+	if j.Backdated() {
+		enc.AddTime("created_at", j.CreatedAt)
+	}
+	return enc.AddArray("postings", loggedPostings(j.Postings))
+}
+
+type loggedPostings []Posting
+
+func (ps loggedPostings) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, p := range ps {
+		if err := enc.AppendObject(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Posting) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("account_id", p.AccountID.String())
+	enc.AddString("amount", p.Amount.String())
+	enc.AddString("currency", p.Currency)
+	if p.OperationID != uuid.Nil {
+		enc.AddString("operation_id", p.OperationID.String())
+	}
+	return nil
+}
+
+// MarshalLogObject пишет каждый заданный лимит объектом с ключом limit, к которому применяется политика скрытия
+func (l SpendingLimits) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if err := addLimit(enc, "daily_withdrawal", l.DailyWithdrawal); err != nil {
+		return err
+	}
+	if err := addLimit(enc, "monthly_withdrawal", l.MonthlyWithdrawal); err != nil {
+		return err
+	}
+	return addLimit(enc, "single_withdrawal", l.SingleWithdrawal)
+}
+
+func addLimit(enc zapcore.ObjectEncoder, key string, limit decimal.NullDecimal) error {
+	if !limit.Valid {
+		return nil
+	}
+	return enc.AddObject(key, loggedLimit(limit.Decimal))
+}
+
+type loggedLimit decimal.Decimal
+
+func (l loggedLimit) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("limit", decimal.Decimal(l).String())
+	return nil
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const AuditBalanceRepaired AuditAction = "BALANCE_REPAIRED"
+
+// WalletBalance - кэшированный баланс кошелька рядом с балансом, посчитанным по проводкам его счета
+type WalletBalance struct {
+	WalletID uuid.UUID
+	Currency string
+	Stored   decimal.Decimal
+	Ledger   decimal.Decimal
+}
+
+// Drift возвращает расхождение кэшированного баланса с журналом
+func (b WalletBalance) Drift() decimal.Decimal {
+	return b.Stored.Sub(b.Ledger)
+}
+
+// BalanceDrift - найденное расхождение и результат его исправления
+type BalanceDrift struct {
+	WalletBalance
+	Repaired bool
+	// RepairError - причина, по которой расхождение не удалось исправить
+	RepairError string
+}
+
+// ReconcileReport - итог сверки кэшированных балансов с журналом проводок
+type ReconcileReport struct {
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Checked    int
+	Drifts     []BalanceDrift
+}
+
+// Repaired возвращает количество исправленных расхождений
+func (r *ReconcileReport) Repaired() int {
+	repaired := 0
+	for _, d := range r.Drifts {
+		if d.Repaired {
+			repaired++
+		}
+	}
+	return repaired
+}
@@ -3,6 +3,7 @@ package domain
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
@@ -10,22 +11,141 @@ import (
 
 var (
 	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrExternalIDExists - кошелек с таким внешним ID у клиента уже создан
+	ErrExternalIDExists = errors.New("wallet with this external id already exists")
 )
 
 type WalletRepository interface {
-	GetBalanceForUpdate(ctx context.Context, walletID uuid.UUID) (decimal.Decimal, error)
+	GetForUpdate(ctx context.Context, walletID uuid.UUID) (*Wallet, error)
 	UpdateBalance(ctx context.Context, walletID uuid.UUID, newBalance decimal.Decimal) error
+	UpdateCreditLimit(ctx context.Context, walletID uuid.UUID, creditLimit decimal.Decimal) error
+	UpdateTier(ctx context.Context, walletID uuid.UUID, tier WalletTier) error
+	UpdateStatus(ctx context.Context, walletID uuid.UUID, status WalletStatus) error
 
-	GetBalance(ctx context.Context, walletID uuid.UUID) (decimal.Decimal, error)
+	// UpdateDetails заменяет название и метаданные кошелька
+	UpdateDetails(ctx context.Context, walletID uuid.UUID, label string, metadata map[string]any) error
+
+	Get(ctx context.Context, walletID uuid.UUID) (*Wallet, error)
+	// GetByExternalID получает кошелек клиента clientID по внешнему ID без блокировки
+	GetByExternalID(ctx context.Context, clientID, externalID string) (*Wallet, error)
+	// Create создает кошелек и заполняет CreatedAt; занятый внешний ID - ErrExternalIDExists
 	Create(ctx context.Context, wallet *Wallet) error
+	// List возвращает до query.Limit кошельков, подходящих под фильтр, в порядке сортировки запроса,
+	// начиная после курсора query.After
+	List(ctx context.Context, query WalletListQuery) ([]Wallet, error)
+	// Count возвращает количество кошельков, подходящих под фильтр
+	Count(ctx context.Context, filter WalletFilter) (int, error)
+}
+
+type OperationRepository interface {
+	Add(ctx context.Context, operation *Operation) error
+	// SumSince возвращает сумму операций указанных типов по кошельку начиная с момента since
+	SumSince(ctx context.Context, walletID uuid.UUID, operationTypes []OperationType, since time.Time) (decimal.Decimal, error)
+	// ListRecent возвращает до limit последних операций кошелька, начиная с новых; uuid.Nil - по всем кошелькам
+	ListRecent(ctx context.Context, walletID uuid.UUID, limit int) ([]Operation, error)
+}
+
+type LimitRepository interface {
+	// GetEffective возвращает лимиты кошелька с учетом значений по умолчанию для его уровня
+	GetEffective(ctx context.Context, walletID uuid.UUID) (SpendingLimits, error)
+	SetForWallet(ctx context.Context, walletID uuid.UUID, limits SpendingLimits) error
+	SetForTier(ctx context.Context, tier WalletTier, limits SpendingLimits) error
+}
+
+type TierRepository interface {
+	GetRules(ctx context.Context, tier WalletTier) (TierRules, error)
+}
+
+type AuditRepository interface {
+	Add(ctx context.Context, entry *AuditEntry) error
+}
+
+type LedgerRepository interface {
+	// CreateWalletAccount заводит счет кошелька с тем же ID, что и кошелек
+	CreateWalletAccount(ctx context.Context, wallet *Wallet) error
+	// SystemAccountID возвращает ID системного счета в указанной валюте
+	SystemAccountID(ctx context.Context, account SystemAccount, currency string) (uuid.UUID, error)
+	// PostJournal сохраняет журнал вместе с проводками
+	PostJournal(ctx context.Context, journal *Journal) error
+	// WalletBalances возвращает до limit кошельков с ID больше after вместе с балансами по журналу, по возрастанию ID
+	WalletBalances(ctx context.Context, after uuid.UUID, limit int) ([]WalletBalance, error)
+	// AccountBalance возвращает баланс счета как сумму его проводок
+	AccountBalance(ctx context.Context, accountID uuid.UUID) (decimal.Decimal, error)
+	// BalanceAt возвращает баланс счета с учетом проводок, созданных не позже at
+	BalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error)
+	// DailyChanges возвращает суммы проводок счета по календарным дням в часовом поясе loc
+	// для проводок с from <= created_at < to; дни без проводок пропускаются
+	DailyChanges(ctx context.Context, accountID uuid.UUID, from, to time.Time, loc *time.Location) ([]DailyChange, error)
+	// CreateSnapshots сохраняет снимки балансов на момент at для до limit кошельков с ID больше after.
+	// Возвращает количество обработанных кошельков и ID последнего из них
+	CreateSnapshots(ctx context.Context, at time.Time, after uuid.UUID, limit int) (int, uuid.UUID, error)
+	// StreamPostings передает в fn проводки счета с from <= created_at < to в хронологическом порядке,
+	// читая их из курсора по мере обработки. Balance в передаваемых строках не заполнен
+	StreamPostings(ctx context.Context, accountID uuid.UUID, from, to time.Time, fn func(line StatementLine) error) error
+}
+
+type ImportRepository interface {
+	Create(ctx context.Context, imp *Import) error
+	Get(ctx context.Context, id uuid.UUID) (*Import, error)
+	// Stage загружает строки файла в промежуточную таблицу и возвращает их количество
+	Stage(ctx context.Context, importID uuid.UUID, next ImportRowSource) (int, error)
+	// SetStatus меняет статус импорта и пересчитывает счетчики строк
+	SetStatus(ctx context.Context, id uuid.UUID, status ImportStatus, errMsg string) (*Import, error)
+	// PendingRows возвращает до limit необработанных строк с номером больше afterLine
+	PendingRows(ctx context.Context, importID uuid.UUID, afterLine int, limit int) ([]ImportRow, error)
+	// MarkRow записывает результат необработанной строки. Если строка уже обработана, возвращает ErrImportRowProcessed
+	MarkRow(ctx context.Context, row *ImportRow) error
+	// Claim переводит импорт в RUNNING, если он PENDING, FAILED или брошен: RUNNING с последней отметкой
+	// обработчика раньше staleBefore. Иначе возвращает ErrImportNotClaimable
+	Claim(ctx context.Context, id uuid.UUID, staleBefore time.Time) (*Import, error)
+	// Heartbeat отмечает, что обработчик продолжает применять RUNNING-импорт
+	Heartbeat(ctx context.Context, id uuid.UUID) error
+	// Claimable возвращает до limit импортов, ожидающих обработчика: PENDING и брошенные RUNNING, от старых к новым
+	Claimable(ctx context.Context, staleBefore time.Time, limit int) ([]uuid.UUID, error)
+	// StreamFailedRows передает в fn строки, которые не удалось применить, по возрастанию номера
+	StreamFailedRows(ctx context.Context, importID uuid.UUID, fn func(row ImportRow) error) error
 }
 
 type UnitOfWork interface {
 	// Wallets возвращает репозиторий для кошельков
 	Wallets() WalletRepository
+	// Operations возвращает репозиторий истории операций
+	Operations() OperationRepository
+	// Limits возвращает репозиторий лимитов на списания
+	Limits() LimitRepository
+	// Tiers возвращает репозиторий правил уровней кошельков
+	Tiers() TierRepository
+	// Audit возвращает журнал административных изменений
+	Audit() AuditRepository
+	// Fees возвращает расписание комиссий, хранящееся в БД
+	Fees() FeeSchedule
+	// Ledger возвращает журнал проводок по счетам
+	Ledger() LedgerRepository
+	// Imports возвращает задания на импорт операций
+	Imports() ImportRepository
+	// Idempotency возвращает результаты запросов, сохраненные по ключам идемпотентности
+	Idempotency() IdempotencyRepository
 
 	// Do выполняет функцию в транзакции
 	// Если функция возвращает ошибку, транзакция откатывается
 	// Если нет - коммитится
-	Do(ctx context.Context, fn func(uow UnitOfWork) error) error
+	// При уровне Serializable транзакция повторяется после ошибки сериализации, поэтому fn не должна иметь
+	// побочных эффектов вне транзакции
+	// Do транзакционного UnitOfWork открывает вложенную транзакцию: ошибка откатывает только ее изменения,
+	// параметры вложенной транзакции игнорируются - она наследует параметры внешней
+	Do(ctx context.Context, fn func(uow UnitOfWork) error, opts ...TxOption) error
+}
+
+type unitOfWorkKey struct{}
+
+// ContextWithUnitOfWork возвращает контекст, несущий открытую транзакцию.
+// Сервисные методы, вызванные с таким контекстом, присоединяются к ней вместо того, чтобы открывать новую
+func ContextWithUnitOfWork(ctx context.Context, uow UnitOfWork) context.Context {
+	return context.WithValue(ctx, unitOfWorkKey{}, uow)
+}
+
+// UnitOfWorkFromContext возвращает транзакцию, переданную в контексте
+func UnitOfWorkFromContext(ctx context.Context) (UnitOfWork, bool) {
+	uow, ok := ctx.Value(unitOfWorkKey{}).(UnitOfWork)
+	return uow, ok
 }
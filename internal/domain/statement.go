@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// OpeningBalanceEntry - тип записи журнала, перенесшей баланс, существовавший до ведения журнала
+const OpeningBalanceEntry = "OPENING_BALANCE"
+
+// StatementHeader - начало выписки: период и входящий остаток
+type StatementHeader struct {
+	WalletID uuid.UUID
+	Currency string
+	From     time.Time
+	To       time.Time
+	Opening  decimal.Decimal
+}
+
+// StatementLine - движение по счету кошелька с остатком после него
+type StatementLine struct {
+	PostingID     int64
+	CorrelationID uuid.UUID
+	OperationType string
+	Amount        decimal.Decimal
+	Balance       decimal.Decimal
+	CreatedAt     time.Time
+}
+
+// StatementFooter - конец выписки: исходящий остаток и количество движений
+type StatementFooter struct {
+	Closing decimal.Decimal
+	Lines   int
+}
+
+// StatementWriter записывает выписку построчно, не накапливая ее в памяти
+type StatementWriter interface {
+	WriteHeader(header StatementHeader) error
+	WriteLine(line StatementLine) error
+	WriteFooter(footer StatementFooter) error
+}
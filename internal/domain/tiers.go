@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrUnknownTier    = errors.New("unknown wallet tier")
+	ErrTierNotUpgrade = errors.New("target tier is not an upgrade")
+)
+
+const (
+	TierAnonymous WalletTier = "ANONYMOUS"
+	TierBasic     WalletTier = "BASIC"
+	TierVerified  WalletTier = "VERIFIED"
+
+	// DefaultTier - уровень новых кошельков до идентификации клиента
+	DefaultTier = TierAnonymous
+)
+
+const (
+	LimitMaxBalance      LimitKind = "max_balance"
+	LimitMonthlyIncoming LimitKind = "monthly_incoming"
+)
+
+// TierRules - ограничения уровня кошелька. Невалидное значение означает отсутствие ограничения
+type TierRules struct {
+	Tier               WalletTier
+	Rank               int
+	MaxBalance         decimal.NullDecimal
+	MaxMonthlyIncoming decimal.NullDecimal
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrSerializationFailure = errors.New("could not serialize access due to concurrent update")
+	ErrReadOnlyTransaction  = errors.New("cannot write in a read-only transaction")
+)
+
+// IsolationLevel - уровень изоляции транзакции, значения совпадают с SQL
+type IsolationLevel string
+
+const (
+	ReadCommitted  IsolationLevel = "read committed"
+	RepeatableRead IsolationLevel = "repeatable read"
+	Serializable   IsolationLevel = "serializable"
+)
+
+// TxOptions - параметры транзакции. Нулевое значение - READ COMMITTED на чтение и запись
+type TxOptions struct {
+	// Isolation - уровень изоляции, пустой означает уровень по умолчанию
+	Isolation IsolationLevel
+	// ReadOnly запрещает запись в транзакции
+	ReadOnly bool
+	// Deferrable для SERIALIZABLE READ ONLY ждет снимка, при котором транзакция не может получить ошибку сериализации
+	Deferrable bool
+	// Replica разрешает выполнить транзакцию только для чтения на реплике, если та отстает не больше допустимого
+	Replica bool
+	// MinLSN - позиция журнала, которую реплика должна воспроизвести, чтобы клиент увидел свои записи
+	MinLSN string
+	// CommitLSN, если задан, получает позицию журнала после коммита
+	CommitLSN *string
+}
+
+type TxOption func(o *TxOptions)
+
+// WithIsolation задает уровень изоляции транзакции
+func WithIsolation(level IsolationLevel) TxOption {
+	return func(o *TxOptions) {
+		o.Isolation = level
+	}
+}
+
+// ReadOnly открывает транзакцию только для чтения
+func ReadOnly() TxOption {
+	return func(o *TxOptions) {
+		o.ReadOnly = true
+	}
+}
+
+// Deferrable откладывает начало транзакции до безопасного снимка
+func Deferrable() TxOption {
+	return func(o *TxOptions) {
+		o.Deferrable = true
+	}
+}
+
+// FromReplica разрешает читать с реплики, которая воспроизвела журнал хотя бы до minLSN; пустая minLSN не ограничивает
+func FromReplica(minLSN string) TxOption {
+	return func(o *TxOptions) {
+		o.Replica = true
+		o.MinLSN = minLSN
+	}
+}
+
+// WithCommitLSN сохраняет в lsn позицию журнала после коммита транзакции
+func WithCommitLSN(lsn *string) TxOption {
+	return func(o *TxOptions) {
+		o.CommitLSN = lsn
+	}
+}
+
+func NewTxOptions(opts ...TxOption) TxOptions {
+	var o TxOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Snapshot сообщает, видит ли транзакция один снимок данных на все время выполнения
+func (o TxOptions) Snapshot() bool {
+	return o.Isolation == RepeatableRead || o.Isolation == Serializable
+}
+
+// ValidLSN проверяет, что lsn записана в формате pg_lsn: две шестнадцатеричные половины через косую черту
+func ValidLSN(lsn string) bool {
+	hi, lo, ok := strings.Cut(lsn, "/")
+	if !ok {
+		return false
+	}
+	_, hiErr := strconv.ParseUint(hi, 16, 32)
+	_, loErr := strconv.ParseUint(lo, 16, 32)
+	return hiErr == nil && loErr == nil
+}
+
+type minLSNKey struct{}
+
+// ContextWithMinLSN возвращает контекст, чтения в котором должны видеть записи до позиции журнала lsn
+func ContextWithMinLSN(ctx context.Context, lsn string) context.Context {
+	return context.WithValue(ctx, minLSNKey{}, lsn)
+}
+
+// MinLSNFromContext возвращает позицию журнала, переданную в контексте, или пустую строку
+func MinLSNFromContext(ctx context.Context) string {
+	lsn, _ := ctx.Value(minLSNKey{}).(string)
+	return lsn
+}
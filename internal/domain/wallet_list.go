@@ -0,0 +1,140 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// ErrInvalidWalletQuery - недопустимые параметры списка кошельков: курсор, размер страницы или границы фильтра
+var ErrInvalidWalletQuery = errors.New("invalid wallet list query")
+
+// WalletSort - ключ сортировки списка кошельков; при равных значениях кошельки упорядочиваются по ID
+type WalletSort string
+
+const (
+	SortByCreatedAt WalletSort = "created_at"
+	SortByBalance   WalletSort = "balance"
+)
+
+// Размер страницы списка кошельков
+const (
+	DefaultWalletPageSize = 50
+	MaxWalletPageSize     = 200
+)
+
+// WalletFilter - условия отбора кошельков; пустое поле не ограничивает выборку
+type WalletFilter struct {
+	Status   WalletStatus
+	ClientID string
+	// ExternalID уникален в пределах клиента, поэтому вместе с ClientID отбирает не больше одного кошелька
+	ExternalID string
+	// OwnerID - владелец во внешней системе, все его кошельки
+	OwnerID  string
+	Currency string
+	// MinBalance и MaxBalance - границы баланса включительно
+	MinBalance *decimal.Decimal
+	MaxBalance *decimal.Decimal
+	// CreatedFrom и CreatedTo - период создания: CreatedFrom <= created_at < CreatedTo
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// MetadataKeys - ключи, которые все должны быть в метаданных
+	MetadataKeys []string
+	// Metadata должны содержаться в метаданных кошелька, как в операторе @> JSONB
+	Metadata map[string]any
+}
+
+// Validate проверяет, что границы баланса и периода создания не перепутаны
+func (f WalletFilter) Validate() error {
+	if f.MinBalance != nil && f.MaxBalance != nil && f.MinBalance.GreaterThan(*f.MaxBalance) {
+		return fmt.Errorf("%w: balance_min is greater than balance_max", ErrInvalidWalletQuery)
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && !f.CreatedFrom.Before(f.CreatedTo) {
+		return fmt.Errorf("%w: created_from must be before created_to", ErrInvalidWalletQuery)
+	}
+	return nil
+}
+
+// WalletListQuery - запрос страницы списка кошельков
+type WalletListQuery struct {
+	Filter WalletFilter
+	Sort   WalletSort
+	Desc   bool
+	// After - курсор предыдущей страницы; nil - первая страница
+	After *WalletCursor
+	Limit int
+	// WithTotal запрашивает количество кошельков по фильтру; подсчет читает все подходящие строки
+	WithTotal bool
+}
+
+// WalletPage - страница списка кошельков
+type WalletPage struct {
+	Wallets []Wallet
+	// Next - курсор следующей страницы; nil, если страница последняя
+	Next *WalletCursor
+	// Total заполнен, если запрошен WithTotal
+	Total *int
+}
+
+// WalletCursor - позиция в списке: ключ сортировки и ID последнего кошелька страницы.
+// Следующая страница начинается с кошельков, идущих после него в том же порядке
+type WalletCursor struct {
+	Sort      WalletSort
+	Desc      bool
+	Balance   decimal.Decimal
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// CursorAfter возвращает курсор, указывающий на кошелек w в списке с сортировкой sort
+func CursorAfter(w *Wallet, sort WalletSort, desc bool) *WalletCursor {
+	return &WalletCursor{Sort: sort, Desc: desc, Balance: w.Balance, CreatedAt: w.CreatedAt, ID: w.ID}
+}
+
+type cursorJSON struct {
+	Sort  WalletSort `json:"s"`
+	Desc  bool       `json:"d,omitempty"`
+	Value string     `json:"v"`
+	ID    uuid.UUID  `json:"id"`
+}
+
+// Encode возвращает курсор в виде непрозрачной строки для клиента
+func (c *WalletCursor) Encode() string {
+	value := c.CreatedAt.UTC().Format(time.RFC3339Nano)
+	if c.Sort == SortByBalance {
+		value = c.Balance.String()
+	}
+	data, _ := json.Marshal(cursorJSON{Sort: c.Sort, Desc: c.Desc, Value: value, ID: c.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeWalletCursor разбирает строку, полученную из Encode
+func DecodeWalletCursor(s string) (*WalletCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidWalletQuery)
+	}
+	var raw cursorJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidWalletQuery)
+	}
+
+	c := &WalletCursor{Sort: raw.Sort, Desc: raw.Desc, ID: raw.ID}
+	switch raw.Sort {
+	case SortByCreatedAt:
+		c.CreatedAt, err = time.Parse(time.RFC3339Nano, raw.Value)
+	case SortByBalance:
+		c.Balance, err = decimal.NewFromString(raw.Value)
+	default:
+		err = fmt.Errorf("unknown sort %q", raw.Sort)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor: %w", ErrInvalidWalletQuery, err)
+	}
+	return c, nil
+}
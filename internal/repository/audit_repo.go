@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+)
+
+const (
+	addAuditEntryQuery = `INSERT INTO wallet_audit_log (wallet_id, action, old_value, new_value, actor, reason)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at;`
+)
+
+type AuditRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+// Add записывает административное изменение кошелька в журнал аудита
+func (r *AuditRepo) Add(ctx context.Context, entry *domain.AuditEntry) error {
+	err := r.exec.QueryRow(ctx, addAuditEntryQuery,
+		entry.WalletID, entry.Action, entry.OldValue, entry.NewValue, entry.Actor, entry.Reason,
+	).Scan(&entry.ID, &entry.CreatedAt)
+	if err != nil {
+		r.log.Error("Failed to insert audit entry", zap.Error(err), zap.String("wallet_id", entry.WalletID.String()))
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+
+	return nil
+}
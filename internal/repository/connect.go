@@ -0,0 +1,36 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	connectBaseBackoff = 500 * time.Millisecond
+	connectMaxBackoff  = 5 * time.Second
+)
+
+// retryConnect повторяет connect с растущей паузой, пока он не завершится успешно или не пройдет timeout.
+// Так сервис переживает запуск раньше PostgreSQL. При нулевом timeout выполняется одна попытка
+func retryConnect(ctx context.Context, timeout time.Duration, log *zap.Logger, connect func(ctx context.Context) error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := connectBaseBackoff
+	for attempt := 1; ; attempt++ {
+		err := connect(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+
+		log.Warn("Database is not available, retrying", zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff), zap.Error(err))
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+		backoff = min(2*backoff, connectMaxBackoff)
+	}
+}
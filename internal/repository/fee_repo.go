@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Правило для уровня кошелька приоритетнее общего правила (tier IS NULL)
+const (
+	findFeeRuleQuery = `SELECT operation_type, COALESCE(tier, ''), fixed, percent, min_fee, max_fee
+		FROM fee_rules
+		WHERE operation_type = $1 AND (tier = $2 OR tier IS NULL)
+		ORDER BY tier NULLS LAST
+		LIMIT 1;`
+)
+
+type FeeRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+// FindRule возвращает правило комиссии для типа операции и уровня кошелька или nil, если комиссии нет
+func (r *FeeRepo) FindRule(ctx context.Context, operationType domain.OperationType, tier domain.WalletTier) (*domain.FeeRule, error) {
+	var rule domain.FeeRule
+	err := r.exec.QueryRow(ctx, findFeeRuleQuery, operationType, tier).Scan(
+		&rule.OperationType, &rule.Tier, &rule.Fixed, &rule.Percent, &rule.Min, &rule.Max,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find fee rule: %w", err)
+	}
+
+	return &rule, nil
+}
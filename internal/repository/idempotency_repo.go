@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// Вставка ждет транзакцию, которая заняла тот же ключ, а конфликтующая строка блокируется до конца транзакции
+	// и при DO UPDATE с ложным условием. Истекший ключ занимается заново
+	acquireIdempotencyKeyQuery = `INSERT INTO idempotency_keys (key, fingerprint, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (key) DO UPDATE
+			SET fingerprint = EXCLUDED.fingerprint, response = NULL, created_at = now(), expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= now()
+		RETURNING key;`
+	getIdempotencyKeyQuery     = `SELECT key, fingerprint, response, expires_at FROM idempotency_keys WHERE key = $1;`
+	saveIdempotencyKeyQuery    = `UPDATE idempotency_keys SET response = $2 WHERE key = $1;`
+	deleteIdempotencyKeysQuery = `DELETE FROM idempotency_keys WHERE expires_at <= $1;`
+)
+
+type IdempotencyRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+func (r *IdempotencyRepo) Acquire(ctx context.Context, key string, fingerprint [sha256.Size]byte, expiresAt time.Time) (*domain.IdempotentResponse, error) {
+	var acquired string
+	err := r.exec.QueryRow(ctx, acquireIdempotencyKeyQuery, key, fingerprint[:], expiresAt).Scan(&acquired)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		r.log.Error("Failed to acquire idempotency key", zap.Error(err))
+		return nil, fmt.Errorf("failed to acquire idempotency key: %w", err)
+	}
+
+	// Ключ занят другой транзакцией, которая уже закоммичена: новый запрос READ COMMITTED видит ее результат
+	var stored domain.IdempotentResponse
+	var storedFingerprint []byte
+	err = r.exec.QueryRow(ctx, getIdempotencyKeyQuery, key).Scan(&stored.Key, &storedFingerprint, &stored.Response, &stored.ExpiresAt)
+	if err != nil {
+		r.log.Error("Failed to get idempotency key", zap.Error(err))
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	copy(stored.Fingerprint[:], storedFingerprint)
+	return &stored, nil
+}
+
+func (r *IdempotencyRepo) Save(ctx context.Context, key string, response []byte) error {
+	cmdTag, err := r.exec.Exec(ctx, saveIdempotencyKeyQuery, key, response)
+	if err != nil {
+		r.log.Error("Failed to save idempotent response", zap.Error(err))
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	if cmdTag.RowsAffected() == 0 {
+		return fmt.Errorf("idempotency key %q is not acquired", key)
+	}
+
+	return nil
+}
+
+func (r *IdempotencyRepo) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	cmdTag, err := r.exec.Exec(ctx, deleteIdempotencyKeysQuery, before)
+	if err != nil {
+		r.log.Error("Failed to delete expired idempotency keys", zap.Error(err))
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+
+	return int(cmdTag.RowsAffected()), nil
+}
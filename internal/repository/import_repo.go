@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	createImportQuery = `INSERT INTO imports (id, file_name, status) VALUES ($1, $2, $3) RETURNING created_at;`
+	getImportQuery    = `SELECT id, file_name, status, total_rows, applied_rows, failed_rows, error, created_at, finished_at, heartbeat_at
+		FROM imports WHERE id = $1;`
+	setImportStatusQuery = `UPDATE imports SET
+			status = $2,
+			error = $3,
+			total_rows = (SELECT COUNT(*) FROM import_rows WHERE import_id = $1),
+			applied_rows = (SELECT COUNT(*) FROM import_rows WHERE import_id = $1 AND status = 'APPLIED'),
+			failed_rows = (SELECT COUNT(*) FROM import_rows WHERE import_id = $1 AND status = 'FAILED'),
+			finished_at = CASE WHEN $2 IN ('COMPLETED', 'FAILED') THEN now() END
+		WHERE id = $1
+		RETURNING id, file_name, status, total_rows, applied_rows, failed_rows, error, created_at, finished_at, heartbeat_at;`
+	// Условие в WHERE проверяется заново после ожидания блокировки строки, поэтому из параллельных
+	// обработчиков импорт получает только один
+	claimImportQuery = `UPDATE imports SET status = 'RUNNING', error = '', finished_at = NULL, heartbeat_at = now()
+		WHERE id = $1
+			AND (status IN ('PENDING', 'FAILED') OR status = 'RUNNING' AND COALESCE(heartbeat_at, '-infinity') < $2)
+		RETURNING id, file_name, status, total_rows, applied_rows, failed_rows, error, created_at, finished_at, heartbeat_at;`
+	heartbeatImportQuery  = `UPDATE imports SET heartbeat_at = now() WHERE id = $1 AND status = 'RUNNING';`
+	claimableImportsQuery = `SELECT id FROM imports
+		WHERE status = 'PENDING' OR status = 'RUNNING' AND COALESCE(heartbeat_at, '-infinity') < $1
+		ORDER BY created_at
+		LIMIT $2;`
+	pendingImportRowsQuery = `SELECT import_id, line, wallet_id, operation_type, amount, target_wallet_id, occurred_at, status, error
+		FROM import_rows
+		WHERE import_id = $1 AND status = 'PENDING' AND line > $2
+		ORDER BY line
+		LIMIT $3;`
+	markImportRowQuery = `UPDATE import_rows SET status = $3, error = $4, operation_id = $5
+		WHERE import_id = $1 AND line = $2 AND status = 'PENDING';`
+	importRowExistsQuery  = `SELECT EXISTS (SELECT 1 FROM import_rows WHERE import_id = $1 AND line = $2);`
+	failedImportRowsQuery = `SELECT import_id, line, wallet_id, operation_type, amount, target_wallet_id, occurred_at, status, error
+		FROM import_rows
+		WHERE import_id = $1 AND status = 'FAILED'
+		ORDER BY line;`
+)
+
+var importRowColumns = []string{"import_id", "line", "wallet_id", "operation_type", "amount", "target_wallet_id", "occurred_at"}
+
+type ImportRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+func (r *ImportRepo) Create(ctx context.Context, imp *domain.Import) error {
+	if err := r.exec.QueryRow(ctx, createImportQuery, imp.ID, imp.FileName, imp.Status).Scan(&imp.CreatedAt); err != nil {
+		r.log.Error("Failed to create import", zap.Error(err))
+		return fmt.Errorf("failed to create import: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ImportRepo) Get(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	return r.scanImport(r.exec.QueryRow(ctx, getImportQuery, id))
+}
+
+// Stage загружает строки через COPY, читая их из источника по одной
+func (r *ImportRepo) Stage(ctx context.Context, importID uuid.UUID, next domain.ImportRowSource) (int, error) {
+	source := pgx.CopyFromFunc(func() ([]any, error) {
+		row, err := next()
+		if err != nil || row == nil {
+			return nil, err
+		}
+		return []any{importID, row.Line, row.WalletID, row.OperationType, row.Amount, row.TargetWalletID, row.OccurredAt}, nil
+	})
+
+	count, err := r.exec.CopyFrom(ctx, pgx.Identifier{"import_rows"}, importRowColumns, source)
+	if err != nil {
+		r.log.Error("Failed to copy import rows", zap.Error(err), zap.String("import_id", importID.String()))
+		return 0, fmt.Errorf("failed to copy import rows: %w", err)
+	}
+
+	return int(count), nil
+}
+
+func (r *ImportRepo) SetStatus(ctx context.Context, id uuid.UUID, status domain.ImportStatus, errMsg string) (*domain.Import, error) {
+	return r.scanImport(r.exec.QueryRow(ctx, setImportStatusQuery, id, status, errMsg))
+}
+
+func (r *ImportRepo) PendingRows(ctx context.Context, importID uuid.UUID, afterLine int, limit int) ([]domain.ImportRow, error) {
+	rows, err := r.exec.Query(ctx, pendingImportRowsQuery, importID, afterLine, limit)
+	if err != nil {
+		r.log.Error("Failed to query pending import rows", zap.Error(err))
+		return nil, fmt.Errorf("failed to query pending import rows: %w", err)
+	}
+
+	return pgx.CollectRows(rows, scanImportRow)
+}
+
+func (r *ImportRepo) MarkRow(ctx context.Context, row *domain.ImportRow) error {
+	var operationID *uuid.UUID
+	if row.OperationID != uuid.Nil {
+		operationID = &row.OperationID
+	}
+
+	cmdTag, err := r.exec.Exec(ctx, markImportRowQuery, row.ImportID, row.Line, row.Status, row.Error, operationID)
+	if err != nil {
+		r.log.Error("Failed to mark import row", zap.Error(err), zap.Int("line", row.Line))
+		return fmt.Errorf("failed to mark import row: %w", err)
+	}
+	if cmdTag.RowsAffected() == 1 {
+		return nil
+	}
+
+	var exists bool
+	if err := r.exec.QueryRow(ctx, importRowExistsQuery, row.ImportID, row.Line).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check import row: %w", err)
+	}
+	if exists {
+		return domain.ErrImportRowProcessed
+	}
+	return fmt.Errorf("import row %d not found", row.Line)
+}
+
+func (r *ImportRepo) Claim(ctx context.Context, id uuid.UUID, staleBefore time.Time) (*domain.Import, error) {
+	imp, err := r.scanImport(r.exec.QueryRow(ctx, claimImportQuery, id, staleBefore))
+	if !errors.Is(err, domain.ErrImportNotFound) {
+		return imp, err
+	}
+	if _, err := r.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, domain.ErrImportNotClaimable
+}
+
+func (r *ImportRepo) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	cmdTag, err := r.exec.Exec(ctx, heartbeatImportQuery, id)
+	if err != nil {
+		r.log.Error("Failed to update import heartbeat", zap.Error(err), zap.String("import_id", id.String()))
+		return fmt.Errorf("failed to update import heartbeat: %w", err)
+	}
+	if cmdTag.RowsAffected() != 1 {
+		return domain.ErrImportNotClaimable
+	}
+
+	return nil
+}
+
+func (r *ImportRepo) Claimable(ctx context.Context, staleBefore time.Time, limit int) ([]uuid.UUID, error) {
+	rows, err := r.exec.Query(ctx, claimableImportsQuery, staleBefore, limit)
+	if err != nil {
+		r.log.Error("Failed to query claimable imports", zap.Error(err))
+		return nil, fmt.Errorf("failed to query claimable imports: %w", err)
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
+
+func (r *ImportRepo) StreamFailedRows(ctx context.Context, importID uuid.UUID, fn func(row domain.ImportRow) error) error {
+	rows, err := r.exec.Query(ctx, failedImportRowsQuery, importID)
+	if err != nil {
+		r.log.Error("Failed to query failed import rows", zap.Error(err))
+		return fmt.Errorf("failed to query failed import rows: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row, err := scanImportRow(rows)
+		if err != nil {
+			return fmt.Errorf("failed to scan import row: %w", err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *ImportRepo) scanImport(row pgx.Row) (*domain.Import, error) {
+	var imp domain.Import
+	err := row.Scan(&imp.ID, &imp.FileName, &imp.Status, &imp.TotalRows, &imp.AppliedRows, &imp.FailedRows,
+		&imp.Error, &imp.CreatedAt, &imp.FinishedAt, &imp.HeartbeatAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrImportNotFound
+		}
+		r.log.Error("Failed to scan import", zap.Error(err))
+		return nil, fmt.Errorf("failed to scan import: %w", err)
+	}
+
+	return &imp, nil
+}
+
+func scanImportRow(row pgx.CollectableRow) (domain.ImportRow, error) {
+	var r domain.ImportRow
+	err := row.Scan(&r.ImportID, &r.Line, &r.WalletID, &r.OperationType, &r.Amount, &r.TargetWalletID, &r.OccurredAt,
+		&r.Status, &r.Error)
+	return r, err
+}
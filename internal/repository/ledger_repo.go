@@ -0,0 +1,284 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	createWalletAccountQuery = `INSERT INTO accounts (id, code, kind, wallet_id, currency)
+		VALUES ($1, $2, 'WALLET', $1, $3);`
+	getSystemAccountIDQuery = `SELECT id FROM accounts WHERE code = $1 AND kind = 'SYSTEM';`
+	addJournalQuery         = `INSERT INTO journals (id, correlation_id, description, created_at)
+		VALUES ($1, $2, $3, COALESCE($4, now()))
+		RETURNING created_at;`
+	addPostingQuery = `INSERT INTO postings (journal_id, account_id, amount, currency, created_at, operation_id)
+		VALUES ($1, $2, $3, $4, $5, $6);`
+	// Проводка задним числом меняет балансы уже сохраненных снимков, сделанных после нее
+	adjustSnapshotsQuery = `UPDATE balance_snapshots SET balance = balance + $3 WHERE wallet_id = $1 AND taken_at >= $2;`
+	// Кошельки читаются страницами по ключу без блокировок; баланс и проводки меняются в одной транзакции,
+	// поэтому снимок одного запроса согласован
+	walletBalancesQuery = `SELECT w.id, w.currency, w.balance, COALESCE(SUM(p.amount), 0)
+		FROM wallets w
+		LEFT JOIN postings p ON p.account_id = w.id
+		WHERE w.id > $1
+		GROUP BY w.id
+		ORDER BY w.id
+		LIMIT $2;`
+	accountBalanceQuery = `SELECT COALESCE(SUM(amount), 0) FROM postings WHERE account_id = $1;`
+	// Баланс берется из последнего снимка до at, к нему добавляются проводки между снимком и at
+	balanceAtQuery = `WITH s AS (
+			SELECT taken_at, balance FROM balance_snapshots
+			WHERE wallet_id = $1 AND taken_at <= $2
+			ORDER BY taken_at DESC
+			LIMIT 1
+		)
+		SELECT COALESCE((SELECT balance FROM s), 0) + COALESCE(SUM(p.amount), 0)
+		FROM postings p
+		WHERE p.account_id = $1
+			AND p.created_at <= $2
+			AND p.created_at > COALESCE((SELECT taken_at FROM s), '-infinity');`
+	// Проводки суммируются по 15-минутным интервалам UTC, а по дням их раскладывает сервис: смещения часовых поясов
+	// кратны 15 минутам, поэтому интервал не пересекает полночь, а база часовых поясов PostgreSQL не участвует
+	dailyChangesQuery = `SELECT date_bin('15 minutes', created_at, TIMESTAMPTZ 'epoch') AS bin, SUM(amount)
+		FROM postings
+		WHERE account_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY bin
+		ORDER BY bin;`
+	// Тип движения берется из записи истории, которую отражает проводка; у перенесенных остатков ее нет
+	streamPostingsQuery = `SELECT p.id, j.correlation_id, COALESCE(ot.name, $4), p.amount, p.created_at
+		FROM postings p
+		JOIN journals j ON j.id = p.journal_id
+		LEFT JOIN operations o ON o.id = p.operation_id
+		LEFT JOIN operation_types ot ON ot.id = o.operation_type_id
+		WHERE p.account_id = $1 AND p.created_at >= $2 AND p.created_at < $3
+		ORDER BY p.created_at, p.id;`
+	// Снимок строится от предыдущего снимка кошелька, поэтому не пересчитывает всю историю
+	createSnapshotsQuery = `WITH page AS (
+			SELECT id FROM wallets WHERE id > $2 ORDER BY id LIMIT $3
+		), inserted AS (
+			INSERT INTO balance_snapshots (wallet_id, taken_at, balance)
+			SELECT page.id, $1, COALESCE(s.balance, 0) + COALESCE((
+				SELECT SUM(p.amount) FROM postings p
+				WHERE p.account_id = page.id
+					AND p.created_at <= $1
+					AND p.created_at > COALESCE(s.taken_at, '-infinity')
+			), 0)
+			FROM page
+			LEFT JOIN LATERAL (
+				SELECT taken_at, balance FROM balance_snapshots
+				WHERE wallet_id = page.id AND taken_at <= $1
+				ORDER BY taken_at DESC
+				LIMIT 1
+			) s ON true
+			ON CONFLICT (wallet_id, taken_at) DO NOTHING
+		)
+		SELECT COUNT(*), COALESCE((SELECT id FROM page ORDER BY id DESC LIMIT 1), $2) FROM page;`
+)
+
+type LedgerRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+// CreateWalletAccount заводит счет кошелька в его валюте
+func (r *LedgerRepo) CreateWalletAccount(ctx context.Context, wallet *domain.Wallet) error {
+	if _, err := r.exec.Exec(ctx, createWalletAccountQuery, wallet.ID, "wallet:"+wallet.ID.String(), wallet.Currency); err != nil {
+		r.log.Error("Failed to create wallet account", zap.Error(err), zap.String("wallet_id", wallet.ID.String()))
+		return fmt.Errorf("failed to create wallet account: %w", err)
+	}
+
+	return nil
+}
+
+// SystemAccountID ищет системный счет по коду вида "cash-in:RUB"
+func (r *LedgerRepo) SystemAccountID(ctx context.Context, account domain.SystemAccount, currency string) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := r.exec.QueryRow(ctx, getSystemAccountIDQuery, fmt.Sprintf("%s:%s", account, currency)).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("%w: %s:%s", domain.ErrSystemAccountNotFound, account, currency)
+		}
+		r.log.Error("Failed to get system account", zap.Error(err), zap.String("account", string(account)))
+		return uuid.Nil, fmt.Errorf("failed to get system account: %w", err)
+	}
+
+	return id, nil
+}
+
+// PostJournal сохраняет журнал и его проводки.
+// Баланс журнала дополнительно проверяется триггером при коммите транзакции
+func (r *LedgerRepo) PostJournal(ctx context.Context, journal *domain.Journal) error {
+	backdated := journal.Backdated()
+	err := r.exec.QueryRow(ctx, addJournalQuery, journal.ID, journal.CorrelationID, journal.Description,
+		nullTime(journal.CreatedAt)).Scan(&journal.CreatedAt)
+	if err != nil {
+		r.log.Error("Failed to insert journal", zap.Error(err), zap.String("journal_id", journal.ID.String()))
+		return fmt.Errorf("failed to insert journal: %w", err)
+	}
+
+	for _, p := range journal.Postings {
+		if _, err := r.exec.Exec(ctx, addPostingQuery, journal.ID, p.AccountID, p.Amount, p.Currency, journal.CreatedAt,
+			nullUUID(p.OperationID)); err != nil {
+			r.log.Error("Failed to insert posting", zap.Error(err), zap.String("journal_id", journal.ID.String()),
+				zap.String("account_id", p.AccountID.String()))
+			return fmt.Errorf("failed to insert posting: %w", err)
+		}
+		if !backdated {
+			continue
+		}
+		if _, err := r.exec.Exec(ctx, adjustSnapshotsQuery, p.AccountID, journal.CreatedAt, p.Amount); err != nil {
+			r.log.Error("Failed to adjust balance snapshots", zap.Error(err), zap.String("account_id", p.AccountID.String()))
+			return fmt.Errorf("failed to adjust balance snapshots: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// WalletBalances читает страницу кошельков с балансами, пересчитанными по журналу
+func (r *LedgerRepo) WalletBalances(ctx context.Context, after uuid.UUID, limit int) ([]domain.WalletBalance, error) {
+	rows, err := r.exec.Query(ctx, walletBalancesQuery, after, limit)
+	if err != nil {
+		r.log.Error("Failed to query wallet balances", zap.Error(err))
+		return nil, fmt.Errorf("failed to query wallet balances: %w", err)
+	}
+
+	balances, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.WalletBalance, error) {
+		var b domain.WalletBalance
+		err := row.Scan(&b.WalletID, &b.Currency, &b.Stored, &b.Ledger)
+		return b, err
+	})
+	if err != nil {
+		r.log.Error("Failed to scan wallet balances", zap.Error(err))
+		return nil, fmt.Errorf("failed to scan wallet balances: %w", err)
+	}
+
+	return balances, nil
+}
+
+// AccountBalance суммирует проводки счета
+func (r *LedgerRepo) AccountBalance(ctx context.Context, accountID uuid.UUID) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	if err := r.exec.QueryRow(ctx, accountBalanceQuery, accountID).Scan(&balance); err != nil {
+		r.log.Error("Failed to get account balance", zap.Error(err), zap.String("account_id", accountID.String()))
+		return decimal.Zero, fmt.Errorf("failed to get account balance: %w", err)
+	}
+
+	return balance, nil
+}
+
+// BalanceAt считает баланс счета на момент at от ближайшего предшествующего снимка
+func (r *LedgerRepo) BalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	if err := r.exec.QueryRow(ctx, balanceAtQuery, accountID, at).Scan(&balance); err != nil {
+		r.log.Error("Failed to get balance at time", zap.Error(err), zap.String("account_id", accountID.String()),
+			zap.Time("at", at))
+		return decimal.Zero, fmt.Errorf("failed to get balance at time: %w", err)
+	}
+
+	return balance, nil
+}
+
+// DailyChanges группирует проводки счета по календарным дням часового пояса loc
+func (r *LedgerRepo) DailyChanges(ctx context.Context, accountID uuid.UUID, from, to time.Time, loc *time.Location) ([]domain.DailyChange, error) {
+	rows, err := r.exec.Query(ctx, dailyChangesQuery, accountID, from, to)
+	if err != nil {
+		r.log.Error("Failed to query daily changes", zap.Error(err), zap.String("account_id", accountID.String()))
+		return nil, fmt.Errorf("failed to query daily changes: %w", err)
+	}
+	defer rows.Close()
+
+	// Интервалы упорядочены по времени, поэтому интервалы одного дня идут подряд
+	var changes []domain.DailyChange
+	for rows.Next() {
+		var (
+			bin    time.Time
+			amount decimal.Decimal
+		)
+		if err := rows.Scan(&bin, &amount); err != nil {
+			r.log.Error("Failed to scan daily changes", zap.Error(err))
+			return nil, fmt.Errorf("failed to scan daily changes: %w", err)
+		}
+		local := bin.In(loc)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+		if n := len(changes); n > 0 && changes[n-1].Date.Equal(day) {
+			changes[n-1].Amount = changes[n-1].Amount.Add(amount)
+			continue
+		}
+		changes = append(changes, domain.DailyChange{Date: day, Amount: amount})
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error("Failed to read daily changes", zap.Error(err))
+		return nil, fmt.Errorf("failed to read daily changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// CreateSnapshots сохраняет снимки балансов для страницы кошельков; существующие снимки не перезаписываются
+func (r *LedgerRepo) CreateSnapshots(ctx context.Context, at time.Time, after uuid.UUID, limit int) (int, uuid.UUID, error) {
+	var (
+		count int
+		last  uuid.UUID
+	)
+	if err := r.exec.QueryRow(ctx, createSnapshotsQuery, at, after, limit).Scan(&count, &last); err != nil {
+		r.log.Error("Failed to create balance snapshots", zap.Error(err), zap.Time("at", at))
+		return 0, uuid.Nil, fmt.Errorf("failed to create balance snapshots: %w", err)
+	}
+
+	return count, last, nil
+}
+
+// StreamPostings читает проводки счета построчно и передает их в fn
+func (r *LedgerRepo) StreamPostings(ctx context.Context, accountID uuid.UUID, from, to time.Time, fn func(line domain.StatementLine) error) error {
+	rows, err := r.exec.Query(ctx, streamPostingsQuery, accountID, from, to, domain.OpeningBalanceEntry)
+	if err != nil {
+		r.log.Error("Failed to query postings", zap.Error(err), zap.String("account_id", accountID.String()))
+		return fmt.Errorf("failed to query postings: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line domain.StatementLine
+		if err := rows.Scan(&line.PostingID, &line.CorrelationID, &line.OperationType, &line.Amount, &line.CreatedAt); err != nil {
+			r.log.Error("Failed to scan posting", zap.Error(err))
+			return fmt.Errorf("failed to scan posting: %w", err)
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		r.log.Error("Failed to read postings", zap.Error(err))
+		return fmt.Errorf("failed to read postings: %w", err)
+	}
+
+	return nil
+}
+
+// nullUUID передает нулевой ID как NULL
+func nullUUID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+// nullTime передает нулевое время как NULL, чтобы значение по умолчанию подставила БД
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	getEffectiveLimitsQuery = `SELECT
+			COALESCE(wl.daily_withdrawal, tl.daily_withdrawal),
+			COALESCE(wl.monthly_withdrawal, tl.monthly_withdrawal),
+			COALESCE(wl.single_withdrawal, tl.single_withdrawal)
+		FROM wallets w
+		LEFT JOIN wallet_spending_limits wl ON wl.wallet_id = w.id
+		LEFT JOIN tier_spending_limits tl ON tl.tier = w.tier
+		WHERE w.id = $1;`
+	setWalletLimitsQuery = `INSERT INTO wallet_spending_limits (wallet_id, daily_withdrawal, monthly_withdrawal, single_withdrawal)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (wallet_id) DO UPDATE SET
+			daily_withdrawal = EXCLUDED.daily_withdrawal,
+			monthly_withdrawal = EXCLUDED.monthly_withdrawal,
+			single_withdrawal = EXCLUDED.single_withdrawal;`
+	setTierLimitsQuery = `INSERT INTO tier_spending_limits (tier, daily_withdrawal, monthly_withdrawal, single_withdrawal)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tier) DO UPDATE SET
+			daily_withdrawal = EXCLUDED.daily_withdrawal,
+			monthly_withdrawal = EXCLUDED.monthly_withdrawal,
+			single_withdrawal = EXCLUDED.single_withdrawal;`
+)
+
+type LimitRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+// GetEffective возвращает лимиты кошелька: собственные значения кошелька перекрывают значения его уровня
+func (r *LimitRepo) GetEffective(ctx context.Context, walletID uuid.UUID) (domain.SpendingLimits, error) {
+	var limits domain.SpendingLimits
+	err := r.exec.QueryRow(ctx, getEffectiveLimitsQuery, walletID).Scan(
+		&limits.DailyWithdrawal, &limits.MonthlyWithdrawal, &limits.SingleWithdrawal,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.SpendingLimits{}, domain.ErrWalletNotFound
+		}
+		return domain.SpendingLimits{}, fmt.Errorf("failed to get spending limits: %w", err)
+	}
+
+	return limits, nil
+}
+
+// SetForWallet задает собственные лимиты кошелька, пустые значения наследуются от уровня
+func (r *LimitRepo) SetForWallet(ctx context.Context, walletID uuid.UUID, limits domain.SpendingLimits) error {
+	_, err := r.exec.Exec(ctx, setWalletLimitsQuery, walletID,
+		limits.DailyWithdrawal, limits.MonthlyWithdrawal, limits.SingleWithdrawal)
+	if err != nil {
+		r.log.Error("Failed to set wallet spending limits", zap.Error(err), zap.String("wallet_id", walletID.String()))
+		return fmt.Errorf("failed to set wallet spending limits: %w", err)
+	}
+
+	return nil
+}
+
+// SetForTier задает лимиты по умолчанию для уровня кошельков
+func (r *LimitRepo) SetForTier(ctx context.Context, tier domain.WalletTier, limits domain.SpendingLimits) error {
+	_, err := r.exec.Exec(ctx, setTierLimitsQuery, tier,
+		limits.DailyWithdrawal, limits.MonthlyWithdrawal, limits.SingleWithdrawal)
+	if err != nil {
+		r.log.Error("Failed to set tier spending limits", zap.Error(err), zap.String("tier", string(tier)))
+		return fmt.Errorf("failed to set tier spending limits: %w", err)
+	}
+
+	return nil
+}
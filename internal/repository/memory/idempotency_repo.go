@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// idempotencyKeyLocks - пространство имен блокировок ключей идемпотентности
+var idempotencyKeyLocks = uuid.MustParse("9e4c1a7d-2f6b-4c85-a3d0-6b8e5f1c2a94")
+
+type IdempotencyRepo struct {
+	repo
+}
+
+// Acquire, как вставка в таблицу с первичным ключом в PostgreSQL, ждет транзакцию, занявшую тот же ключ:
+// для этого ключ блокируется до конца транзакции
+func (r *IdempotencyRepo) Acquire(ctx context.Context, key string, fingerprint [sha256.Size]byte, expiresAt time.Time) (*domain.IdempotentResponse, error) {
+	var stored *domain.IdempotentResponse
+	err := r.run(func(t *tx) error {
+		if err := t.lock(ctx, uuid.NewSHA1(idempotencyKeyLocks, []byte(key))); err != nil {
+			return err
+		}
+		if found, ok := t.idempotency.get(key); ok && found.ExpiresAt.After(t.startedAt) {
+			found.Response = bytes.Clone(found.Response)
+			stored = &found
+			return nil
+		}
+		t.idempotency.set(key, domain.IdempotentResponse{Key: key, Fingerprint: fingerprint, ExpiresAt: expiresAt})
+		return nil
+	})
+	return stored, err
+}
+
+func (r *IdempotencyRepo) Save(ctx context.Context, key string, response []byte) error {
+	return r.run(func(t *tx) error {
+		found, ok := t.idempotency.get(key)
+		if !ok {
+			return fmt.Errorf("idempotency key %q is not acquired", key)
+		}
+		found.Response = bytes.Clone(response)
+		t.idempotency.set(key, found)
+		return nil
+	})
+}
+
+func (r *IdempotencyRepo) DeleteExpired(ctx context.Context, before time.Time) (int, error) {
+	count := 0
+	err := r.run(func(t *tx) error {
+		var expired []string
+		t.idempotency.each(func(key string, stored domain.IdempotentResponse) {
+			if !stored.ExpiresAt.After(before) {
+				expired = append(expired, key)
+			}
+		})
+		for _, key := range expired {
+			t.idempotency.remove(key)
+		}
+		count = len(expired)
+		return nil
+	})
+	return count, err
+}
@@ -0,0 +1,264 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type ImportRepo struct {
+	repo
+}
+
+func (r *ImportRepo) Create(ctx context.Context, imp *domain.Import) error {
+	return r.run(func(t *tx) error {
+		if _, ok := t.imports.get(imp.ID); ok {
+			return constraintError("imports_pkey")
+		}
+		imp.CreatedAt = t.startedAt
+		t.imports.set(imp.ID, *imp)
+		return nil
+	})
+}
+
+func (r *ImportRepo) Get(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	var imp *domain.Import
+	err := r.run(func(t *tx) error {
+		found, ok := t.imports.get(id)
+		if !ok {
+			return domain.ErrImportNotFound
+		}
+		imp = &found
+		return nil
+	})
+	return imp, err
+}
+
+// Stage загружает строки из источника по одной
+func (r *ImportRepo) Stage(ctx context.Context, importID uuid.UUID, next domain.ImportRowSource) (int, error) {
+	count := 0
+	err := r.run(func(t *tx) error {
+		if _, ok := t.imports.get(importID); !ok {
+			return constraintError("import_rows_import_id_fkey")
+		}
+		for {
+			row, err := next()
+			if err != nil {
+				return err
+			}
+			if row == nil {
+				return nil
+			}
+
+			key := importRowKey{ImportID: importID, Line: row.Line}
+			if _, ok := t.importRows.get(key); ok {
+				return constraintError("import_rows_pkey")
+			}
+			t.importRows.set(key, domain.ImportRow{
+				ImportID:       importID,
+				Line:           row.Line,
+				WalletID:       row.WalletID,
+				OperationType:  row.OperationType,
+				Amount:         row.Amount,
+				TargetWalletID: row.TargetWalletID,
+				OccurredAt:     row.OccurredAt,
+				Status:         domain.ImportRowPending,
+			})
+			count++
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// SetStatus меняет статус импорта и пересчитывает счетчики строк
+func (r *ImportRepo) SetStatus(ctx context.Context, id uuid.UUID, status domain.ImportStatus, errMsg string) (*domain.Import, error) {
+	var imp *domain.Import
+	err := r.run(func(t *tx) error {
+		found, ok := t.imports.get(id)
+		if !ok {
+			return domain.ErrImportNotFound
+		}
+
+		found.Status, found.Error = status, errMsg
+		found.TotalRows, found.AppliedRows, found.FailedRows = 0, 0, 0
+		for _, row := range t.importRowsOf(id) {
+			found.TotalRows++
+			switch row.Status {
+			case domain.ImportRowApplied:
+				found.AppliedRows++
+			case domain.ImportRowFailed:
+				found.FailedRows++
+			}
+		}
+		found.FinishedAt = nil
+		if status == domain.ImportCompleted || status == domain.ImportFailed {
+			finishedAt := t.startedAt
+			found.FinishedAt = &finishedAt
+		}
+
+		t.imports.set(id, found)
+		imp = &found
+		return nil
+	})
+	return imp, err
+}
+
+func (r *ImportRepo) PendingRows(ctx context.Context, importID uuid.UUID, afterLine int, limit int) ([]domain.ImportRow, error) {
+	var rows []domain.ImportRow
+	err := r.run(func(t *tx) error {
+		for _, row := range t.importRowsOf(importID) {
+			if row.Status == domain.ImportRowPending && row.Line > afterLine && len(rows) < limit {
+				rows = append(rows, row)
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func (r *ImportRepo) MarkRow(ctx context.Context, row *domain.ImportRow) error {
+	return r.run(func(t *tx) error {
+		// Как строка таблицы в PostgreSQL, строка импорта блокируется до конца транзакции,
+		// поэтому параллельный обработчик увидит ее уже обработанной
+		key := importRowKey{ImportID: row.ImportID, Line: row.Line}
+		if err := t.lock(ctx, importRowLockID(key)); err != nil {
+			return err
+		}
+		stored, ok := t.importRows.get(key)
+		if !ok {
+			return fmt.Errorf("import row %d not found", row.Line)
+		}
+		if stored.Status != domain.ImportRowPending {
+			return domain.ErrImportRowProcessed
+		}
+		stored.Status, stored.Error, stored.OperationID = row.Status, row.Error, row.OperationID
+		t.importRows.set(key, stored)
+		return nil
+	})
+}
+
+func (r *ImportRepo) Claim(ctx context.Context, id uuid.UUID, staleBefore time.Time) (*domain.Import, error) {
+	var imp *domain.Import
+	err := r.run(func(t *tx) error {
+		if err := t.lock(ctx, importLockID(id)); err != nil {
+			return err
+		}
+		found, ok := t.imports.get(id)
+		if !ok {
+			return domain.ErrImportNotFound
+		}
+		if !claimable(found, staleBefore) && found.Status != domain.ImportFailed {
+			return domain.ErrImportNotClaimable
+		}
+
+		heartbeatAt := t.startedAt
+		found.Status, found.Error, found.FinishedAt, found.HeartbeatAt = domain.ImportRunning, "", nil, &heartbeatAt
+		t.imports.set(id, found)
+		imp = &found
+		return nil
+	})
+	return imp, err
+}
+
+func (r *ImportRepo) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	return r.run(func(t *tx) error {
+		found, ok := t.imports.get(id)
+		if !ok || found.Status != domain.ImportRunning {
+			return domain.ErrImportNotClaimable
+		}
+		heartbeatAt := t.startedAt
+		found.HeartbeatAt = &heartbeatAt
+		t.imports.set(id, found)
+		return nil
+	})
+}
+
+func (r *ImportRepo) Claimable(ctx context.Context, staleBefore time.Time, limit int) ([]uuid.UUID, error) {
+	var found []domain.Import
+	err := r.run(func(t *tx) error {
+		t.imports.each(func(_ uuid.UUID, imp domain.Import) {
+			if claimable(imp, staleBefore) {
+				found = append(found, imp)
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(found, func(a, b domain.Import) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	ids := make([]uuid.UUID, 0, min(len(found), limit))
+	for _, imp := range found[:min(len(found), limit)] {
+		ids = append(ids, imp.ID)
+	}
+	return ids, nil
+}
+
+// importLocks - пространство имен блокировок импортов и их строк
+var importLocks = uuid.MustParse("c4e1a9d2-6f3b-4b7e-8d05-2a9f6c1e3b74")
+
+func importLockID(id uuid.UUID) uuid.UUID {
+	return uuid.NewSHA1(importLocks, id[:])
+}
+
+func importRowLockID(key importRowKey) uuid.UUID {
+	return uuid.NewSHA1(importLocks, fmt.Appendf(key.ImportID[:], ":%d", key.Line))
+}
+
+// claimable сообщает, ждет ли импорт обработчика: он PENDING или RUNNING без отметки обработчика с staleBefore
+func claimable(imp domain.Import, staleBefore time.Time) bool {
+	switch imp.Status {
+	case domain.ImportPending:
+		return true
+	case domain.ImportRunning:
+		return imp.HeartbeatAt == nil || imp.HeartbeatAt.Before(staleBefore)
+	}
+	return false
+}
+
+func (r *ImportRepo) StreamFailedRows(ctx context.Context, importID uuid.UUID, fn func(row domain.ImportRow) error) error {
+	var failed []domain.ImportRow
+	err := r.run(func(t *tx) error {
+		for _, row := range t.importRowsOf(importID) {
+			if row.Status == domain.ImportRowFailed {
+				failed = append(failed, row)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, row := range failed {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importRowsOf возвращает строки импорта по возрастанию номера
+func (t *tx) importRowsOf(importID uuid.UUID) []domain.ImportRow {
+	var rows []domain.ImportRow
+	t.importRows.each(func(key importRowKey, row domain.ImportRow) {
+		if key.ImportID == importID {
+			rows = append(rows, row)
+		}
+	})
+	slices.SortFunc(rows, func(a, b domain.ImportRow) int {
+		return a.Line - b.Line
+	})
+	return rows
+}
@@ -0,0 +1,256 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type LedgerRepo struct {
+	repo
+}
+
+// CreateWalletAccount заводит счет кошелька в его валюте
+func (r *LedgerRepo) CreateWalletAccount(ctx context.Context, wallet *domain.Wallet) error {
+	return r.run(func(t *tx) error {
+		if _, ok := t.wallets.get(wallet.ID); !ok {
+			return constraintError("accounts_wallet_id_fkey")
+		}
+		if _, ok := t.accounts.get(wallet.ID); ok {
+			return constraintError("accounts_pkey")
+		}
+		t.accounts.set(wallet.ID, account{
+			ID:       wallet.ID,
+			Code:     "wallet:" + wallet.ID.String(),
+			WalletID: wallet.ID,
+			Currency: wallet.Currency,
+		})
+		return nil
+	})
+}
+
+// SystemAccountID ищет системный счет по коду вида "cash-in:RUB"
+func (r *LedgerRepo) SystemAccountID(ctx context.Context, systemAccount domain.SystemAccount, currency string) (uuid.UUID, error) {
+	code := fmt.Sprintf("%s:%s", systemAccount, currency)
+	id := uuid.Nil
+	err := r.run(func(t *tx) error {
+		t.accounts.each(func(_ uuid.UUID, a account) {
+			if a.Code == code && a.WalletID == uuid.Nil {
+				id = a.ID
+			}
+		})
+		if id == uuid.Nil {
+			return fmt.Errorf("%w: %s", domain.ErrSystemAccountNotFound, code)
+		}
+		return nil
+	})
+	return id, err
+}
+
+// PostJournal сохраняет журнал и его проводки. Баланс журнала проверяется при коммите транзакции
+func (r *LedgerRepo) PostJournal(ctx context.Context, j *domain.Journal) error {
+	return r.run(func(t *tx) error {
+		for _, p := range j.Postings {
+			a, ok := t.accounts.get(p.AccountID)
+			if !ok || a.Currency != p.Currency {
+				return constraintError("postings_account_currency_fkey")
+			}
+			if p.Amount.IsZero() {
+				return constraintError("postings_amount_must_be_non_zero")
+			}
+		}
+
+		if !j.Backdated() {
+			j.CreatedAt = t.startedAt
+		}
+		t.journals.append(journal{ID: j.ID, CorrelationID: j.CorrelationID, Description: j.Description, CreatedAt: j.CreatedAt})
+		for _, p := range j.Postings {
+			t.postings.append(posting{
+				ID:          r.store.postingSeq.Add(1),
+				JournalID:   j.ID,
+				AccountID:   p.AccountID,
+				Amount:      numeric(p.Amount),
+				Currency:    p.Currency,
+				CreatedAt:   j.CreatedAt,
+				OperationID: p.OperationID,
+			})
+		}
+		return nil
+	})
+}
+
+// WalletBalances читает страницу кошельков с балансами, пересчитанными по журналу
+func (r *LedgerRepo) WalletBalances(ctx context.Context, after uuid.UUID, limit int) ([]domain.WalletBalance, error) {
+	var balances []domain.WalletBalance
+	err := r.run(func(t *tx) error {
+		for _, w := range t.walletPage(after, limit) {
+			balances = append(balances, domain.WalletBalance{
+				WalletID: w.ID,
+				Currency: w.Currency,
+				Stored:   w.Balance,
+				Ledger:   t.balanceAt(w.ID, nil),
+			})
+		}
+		return nil
+	})
+	return balances, err
+}
+
+// AccountBalance суммирует проводки счета
+func (r *LedgerRepo) AccountBalance(ctx context.Context, accountID uuid.UUID) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := r.run(func(t *tx) error {
+		balance = t.balanceAt(accountID, nil)
+		return nil
+	})
+	return balance, err
+}
+
+// BalanceAt суммирует проводки счета, созданные не позже at
+func (r *LedgerRepo) BalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	var balance decimal.Decimal
+	err := r.run(func(t *tx) error {
+		balance = t.balanceAt(accountID, &at)
+		return nil
+	})
+	return balance, err
+}
+
+// DailyChanges группирует проводки счета по календарным дням часового пояса loc
+func (r *LedgerRepo) DailyChanges(ctx context.Context, accountID uuid.UUID, from, to time.Time, loc *time.Location) ([]domain.DailyChange, error) {
+	byDay := make(map[time.Time]decimal.Decimal)
+	err := r.run(func(t *tx) error {
+		t.postings.each(func(p posting) bool {
+			if p.AccountID == accountID && !p.CreatedAt.Before(from) && p.CreatedAt.Before(to) {
+				local := p.CreatedAt.In(loc)
+				day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+				byDay[day] = byDay[day].Add(p.Amount)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]domain.DailyChange, 0, len(byDay))
+	for day, amount := range byDay {
+		changes = append(changes, domain.DailyChange{Date: day, Amount: amount})
+	}
+	slices.SortFunc(changes, func(a, b domain.DailyChange) int {
+		return a.Date.Compare(b.Date)
+	})
+	return changes, nil
+}
+
+// CreateSnapshots сохраняет снимки балансов для страницы кошельков; существующие снимки не перезаписываются
+func (r *LedgerRepo) CreateSnapshots(ctx context.Context, at time.Time, after uuid.UUID, limit int) (int, uuid.UUID, error) {
+	var (
+		count int
+		last  = after
+	)
+	err := r.run(func(t *tx) error {
+		page := t.walletPage(after, limit)
+		for _, w := range page {
+			key := snapshotKey{WalletID: w.ID, TakenAt: at.UnixMicro()}
+			if _, ok := t.snapshots.get(key); !ok {
+				t.snapshots.set(key, t.balanceAt(w.ID, &at))
+			}
+			last = w.ID
+		}
+		count = len(page)
+		return nil
+	})
+	return count, last, err
+}
+
+// StreamPostings передает в fn проводки счета в хронологическом порядке
+func (r *LedgerRepo) StreamPostings(ctx context.Context, accountID uuid.UUID, from, to time.Time, fn func(line domain.StatementLine) error) error {
+	var lines []domain.StatementLine
+	err := r.run(func(t *tx) error {
+		correlations := make(map[uuid.UUID]uuid.UUID)
+		t.journals.each(func(j journal) bool {
+			correlations[j.ID] = j.CorrelationID
+			return true
+		})
+		types := make(map[uuid.UUID]domain.OperationType)
+		t.operations.each(func(op domain.Operation) bool {
+			if op.WalletID == accountID {
+				types[op.ID] = op.OperationType
+			}
+			return true
+		})
+
+		t.postings.each(func(p posting) bool {
+			if p.AccountID != accountID || p.CreatedAt.Before(from) || !p.CreatedAt.Before(to) {
+				return true
+			}
+			line := domain.StatementLine{
+				PostingID:     p.ID,
+				CorrelationID: correlations[p.JournalID],
+				OperationType: domain.OpeningBalanceEntry,
+				Amount:        p.Amount,
+				CreatedAt:     p.CreatedAt,
+			}
+			if operationType, ok := types[p.OperationID]; ok {
+				line.OperationType = string(operationType)
+			}
+			lines = append(lines, line)
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(lines, func(a, b domain.StatementLine) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return int(a.PostingID - b.PostingID)
+	})
+	for _, line := range lines {
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// balanceAt суммирует проводки счета, созданные не позже at; nil - все проводки
+func (t *tx) balanceAt(accountID uuid.UUID, at *time.Time) decimal.Decimal {
+	balance := decimal.Zero
+	t.postings.each(func(p posting) bool {
+		if p.AccountID == accountID && (at == nil || !p.CreatedAt.After(*at)) {
+			balance = balance.Add(p.Amount)
+		}
+		return true
+	})
+	return balance
+}
+
+// walletPage возвращает до limit кошельков с ID больше after по возрастанию ID
+func (t *tx) walletPage(after uuid.UUID, limit int) []domain.Wallet {
+	var page []domain.Wallet
+	t.wallets.each(func(id uuid.UUID, w domain.Wallet) {
+		if bytes.Compare(id[:], after[:]) > 0 {
+			page = append(page, w)
+		}
+	})
+	slices.SortFunc(page, func(a, b domain.Wallet) int {
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	if len(page) > limit {
+		page = page[:limit]
+	}
+	return page
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+type LimitRepo struct {
+	repo
+}
+
+// GetEffective возвращает лимиты кошелька: собственные значения кошелька перекрывают значения его уровня
+func (r *LimitRepo) GetEffective(ctx context.Context, walletID uuid.UUID) (domain.SpendingLimits, error) {
+	var limits domain.SpendingLimits
+	err := r.run(func(t *tx) error {
+		wallet, ok := t.wallets.get(walletID)
+		if !ok {
+			return domain.ErrWalletNotFound
+		}
+		tierLimits, _ := t.tierLimits.get(wallet.Tier)
+		walletLimits, _ := t.walletLimits.get(walletID)
+		limits = tierLimits.Override(walletLimits)
+		return nil
+	})
+	return limits, err
+}
+
+// SetForWallet задает собственные лимиты кошелька, пустые значения наследуются от уровня
+func (r *LimitRepo) SetForWallet(ctx context.Context, walletID uuid.UUID, limits domain.SpendingLimits) error {
+	return r.run(func(t *tx) error {
+		if _, ok := t.wallets.get(walletID); !ok {
+			return constraintError("wallet_spending_limits_wallet_id_fkey")
+		}
+		if limits.Validate() != nil {
+			return constraintError("wallet_limits_must_be_positive")
+		}
+		t.walletLimits.set(walletID, limits)
+		return nil
+	})
+}
+
+// SetForTier задает лимиты по умолчанию для уровня кошельков
+func (r *LimitRepo) SetForTier(ctx context.Context, tier domain.WalletTier, limits domain.SpendingLimits) error {
+	return r.run(func(t *tx) error {
+		if !r.store.tierExists(tier) {
+			return constraintError("tier_spending_limits_tier_fkey")
+		}
+		if limits.Validate() != nil {
+			return constraintError("tier_limits_must_be_positive")
+		}
+		t.tierLimits.set(tier, limits)
+		return nil
+	})
+}
+
+type TierRepo struct {
+	repo
+}
+
+// GetRules возвращает ограничения уровня кошелька
+func (r *TierRepo) GetRules(ctx context.Context, tier domain.WalletTier) (domain.TierRules, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	rules, ok := r.store.state.tiers[tier]
+	if !ok {
+		return domain.TierRules{}, domain.ErrUnknownTier
+	}
+	return rules, nil
+}
+
+// FeeRepo - правила комиссий. В памяти правила не хранятся, их задают через service.WithFeeSchedule
+type FeeRepo struct {
+	repo
+}
+
+// FindRule всегда сообщает, что комиссии нет
+func (r *FeeRepo) FindRule(ctx context.Context, operationType domain.OperationType, tier domain.WalletTier) (*domain.FeeRule, error) {
+	return nil, nil
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// operationTypes - справочник типов операций, как таблица operation_types
+var operationTypes = []domain.OperationType{
+	domain.Deposit, domain.Withdraw, domain.TransferOut, domain.TransferIn, domain.Fee,
+	domain.AdjustmentIn, domain.AdjustmentOut,
+}
+
+type OperationRepo struct {
+	repo
+}
+
+// Add сохраняет операцию в историю кошелька
+func (r *OperationRepo) Add(ctx context.Context, operation *domain.Operation) error {
+	return r.run(func(t *tx) error {
+		if _, ok := t.wallets.get(operation.WalletID); !ok {
+			return constraintError("operations_wallet_id_fkey")
+		}
+		if !slices.Contains(operationTypes, operation.OperationType) {
+			return constraintError("operations_operation_type_id_fkey")
+		}
+		if !operation.Amount.IsPositive() {
+			return constraintError("amount_must_be_positive")
+		}
+
+		if operation.CreatedAt.IsZero() {
+			operation.CreatedAt = t.startedAt
+		}
+		op := *operation
+		op.Amount = numeric(op.Amount)
+		t.operations.append(op)
+		return nil
+	})
+}
+
+// SumSince суммирует операции указанных типов по кошельку начиная с since
+func (r *OperationRepo) SumSince(ctx context.Context, walletID uuid.UUID, operationTypes []domain.OperationType, since time.Time) (decimal.Decimal, error) {
+	sum := decimal.Zero
+	err := r.run(func(t *tx) error {
+		t.operations.each(func(op domain.Operation) bool {
+			if op.WalletID == walletID && slices.Contains(operationTypes, op.OperationType) && !op.CreatedAt.Before(since) {
+				sum = sum.Add(op.Amount)
+			}
+			return true
+		})
+		return nil
+	})
+	return sum, err
+}
+
+// ListRecent возвращает последние операции кошелька или всех кошельков
+func (r *OperationRepo) ListRecent(ctx context.Context, walletID uuid.UUID, limit int) ([]domain.Operation, error) {
+	var ops []domain.Operation
+	err := r.run(func(t *tx) error {
+		t.operations.each(func(op domain.Operation) bool {
+			if walletID == uuid.Nil || op.WalletID == walletID {
+				ops = append(ops, op)
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(ops, func(a, b domain.Operation) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	if len(ops) > limit {
+		ops = ops[:limit]
+	}
+	return ops, nil
+}
+
+type AuditRepo struct {
+	repo
+}
+
+// Add записывает административное изменение кошелька в журнал аудита
+func (r *AuditRepo) Add(ctx context.Context, entry *domain.AuditEntry) error {
+	return r.run(func(t *tx) error {
+		if _, ok := t.wallets.get(entry.WalletID); !ok {
+			return constraintError("wallet_audit_log_wallet_id_fkey")
+		}
+
+		entry.ID = r.store.auditSeq.Add(1)
+		entry.CreatedAt = t.startedAt
+		t.audit.append(*entry)
+		return nil
+	})
+}
@@ -0,0 +1,255 @@
+// Package memory - хранилище в памяти процесса, реализующее domain.UnitOfWork.
+// Подходит для демонстраций (STORAGE=memory) и тестов: данные не переживают перезапуск.
+//
+// Транзакция пишет в собственный слой измененных строк поверх общего состояния и видит
+// закоммиченные изменения других транзакций, а на уровнях REPEATABLE READ и SERIALIZABLE - снимок на свое начало.
+// При коммите слой переносится в общее состояние, при ошибке отбрасывается.
+// Изменяемые кошельки блокируются до конца транзакции, как при SELECT ... FOR UPDATE
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// numericScale - точность сумм, как у колонок NUMERIC(15, 2) в PostgreSQL
+const numericScale = 2
+
+// maxSerializationAttempts - сколько раз выполняется транзакция Serializable, прерываемая ошибками сериализации
+const maxSerializationAttempts = 5
+
+type account struct {
+	ID       uuid.UUID
+	Code     string
+	WalletID uuid.UUID
+	Currency string
+}
+
+type journal struct {
+	ID            uuid.UUID
+	CorrelationID uuid.UUID
+	Description   string
+	CreatedAt     time.Time
+}
+
+type posting struct {
+	ID          int64
+	JournalID   uuid.UUID
+	AccountID   uuid.UUID
+	Amount      decimal.Decimal
+	Currency    string
+	CreatedAt   time.Time
+	OperationID uuid.UUID
+}
+
+type snapshotKey struct {
+	WalletID uuid.UUID
+	TakenAt  int64
+}
+
+type importRowKey struct {
+	ImportID uuid.UUID
+	Line     int
+}
+
+// state - закоммиченные данные хранилища
+type state struct {
+	wallets      map[uuid.UUID]domain.Wallet
+	walletLimits map[uuid.UUID]domain.SpendingLimits
+	tierLimits   map[domain.WalletTier]domain.SpendingLimits
+	tiers        map[domain.WalletTier]domain.TierRules
+	accounts     map[uuid.UUID]account
+	snapshots    map[snapshotKey]decimal.Decimal
+	imports      map[uuid.UUID]domain.Import
+	importRows   map[importRowKey]domain.ImportRow
+	idempotency  map[string]domain.IdempotentResponse
+	operations   []domain.Operation
+	audit        []domain.AuditEntry
+	journals     []journal
+	postings     []posting
+}
+
+// clone возвращает снимок состояния. Журналы только дополняются, поэтому снимку достаточно их текущей длины
+func (s *state) clone() *state {
+	return &state{
+		wallets:      maps.Clone(s.wallets),
+		walletLimits: maps.Clone(s.walletLimits),
+		tierLimits:   maps.Clone(s.tierLimits),
+		tiers:        maps.Clone(s.tiers),
+		accounts:     maps.Clone(s.accounts),
+		snapshots:    maps.Clone(s.snapshots),
+		imports:      maps.Clone(s.imports),
+		importRows:   maps.Clone(s.importRows),
+		idempotency:  maps.Clone(s.idempotency),
+		operations:   s.operations[:len(s.operations):len(s.operations)],
+		audit:        s.audit[:len(s.audit):len(s.audit)],
+		journals:     s.journals[:len(s.journals):len(s.journals)],
+		postings:     s.postings[:len(s.postings):len(s.postings)],
+	}
+}
+
+type Store struct {
+	repos
+	mu    sync.RWMutex
+	state state
+	// lsn - номер последнего коммита с изменениями, аналог позиции журнала PostgreSQL
+	lsn uint64
+
+	locksMu sync.Mutex
+	locks   map[uuid.UUID]chan struct{}
+
+	// Последовательности, как и в PostgreSQL, не откатываются вместе с транзакцией
+	auditSeq   atomic.Int64
+	postingSeq atomic.Int64
+
+	now func() time.Time
+	log *zap.Logger
+}
+
+// NewStore создает пустое хранилище со справочниками, которые в PostgreSQL заполняют миграции:
+// уровнями кошельков и системными счетами в валюте по умолчанию
+func NewStore(log *zap.Logger) *Store {
+	s := &Store{
+		state: state{
+			wallets:      make(map[uuid.UUID]domain.Wallet),
+			walletLimits: make(map[uuid.UUID]domain.SpendingLimits),
+			tierLimits:   make(map[domain.WalletTier]domain.SpendingLimits),
+			tiers:        make(map[domain.WalletTier]domain.TierRules),
+			accounts:     make(map[uuid.UUID]account),
+			snapshots:    make(map[snapshotKey]decimal.Decimal),
+			imports:      make(map[uuid.UUID]domain.Import),
+			importRows:   make(map[importRowKey]domain.ImportRow),
+			idempotency:  make(map[string]domain.IdempotentResponse),
+		},
+		locks: make(map[uuid.UUID]chan struct{}),
+		now:   time.Now,
+		log:   log.Named("memory"),
+	}
+	s.repos = repos{repo{store: s}}
+
+	for _, rules := range []domain.TierRules{
+		{Tier: domain.TierAnonymous, Rank: 1, MaxBalance: limit(15000), MaxMonthlyIncoming: limit(40000)},
+		{Tier: domain.TierBasic, Rank: 2, MaxBalance: limit(60000), MaxMonthlyIncoming: limit(200000)},
+		{Tier: domain.TierVerified, Rank: 3},
+	} {
+		s.state.tiers[rules.Tier] = rules
+		s.state.tierLimits[rules.Tier] = domain.SpendingLimits{}
+	}
+	for _, code := range []domain.SystemAccount{domain.CashIn, domain.CashOut, domain.OpeningBalance, domain.Adjustments} {
+		id := uuid.New()
+		s.state.accounts[id] = account{
+			ID:       id,
+			Code:     fmt.Sprintf("%s:%s", code, domain.DefaultCurrency),
+			Currency: domain.DefaultCurrency,
+		}
+	}
+
+	s.log.Info("Using in-memory storage, data will be lost on restart")
+	return s
+}
+
+func limit(value int64) decimal.NullDecimal {
+	return decimal.NullDecimal{Decimal: decimal.NewFromInt(value), Valid: true}
+}
+
+// Do выполняет fn в транзакции: изменения становятся видны другим только после успешного завершения fn.
+// Транзакция уровня Serializable, прерванная ошибкой сериализации, выполняется заново
+func (s *Store) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error, opts ...domain.TxOption) error {
+	options := domain.NewTxOptions(opts...)
+	for attempt := 1; ; attempt++ {
+		err := s.do(fn, options)
+		if err == nil || options.Isolation != domain.Serializable || !errors.Is(err, domain.ErrSerializationFailure) ||
+			attempt == maxSerializationAttempts {
+			return err
+		}
+
+		s.log.Debug("Serialization failure, retrying transaction", zap.Int("attempt", attempt), zap.Error(err))
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Store) do(fn func(uow domain.UnitOfWork) error, options domain.TxOptions) error {
+	t := s.begin(options)
+	if err := fn(&unitOfWork{repos{repo{store: s, tx: t}}}); err != nil {
+		t.rollback()
+		s.log.Debug("Transaction function returned error, rolling back", zap.Error(err))
+		return fmt.Errorf("transaction function returned error: %w", err)
+	}
+
+	if err := t.commit(); err != nil {
+		return err
+	}
+
+	if options.CommitLSN != nil {
+		s.mu.RLock()
+		*options.CommitLSN = fmt.Sprintf("0/%X", s.lsn)
+		s.mu.RUnlock()
+	}
+	return nil
+}
+
+// Close нужен для совместимости с хранилищем PostgreSQL
+func (s *Store) Close() {}
+
+type unitOfWork struct {
+	repos
+}
+
+// Do выполняет fn во вложенной транзакции: ошибка fn откатывает только изменения, сделанные внутри fn
+func (u *unitOfWork) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error, _ ...domain.TxOption) error {
+	rollback := u.tx.savepoint()
+	if err := fn(&unitOfWork{u.repos}); err != nil {
+		rollback()
+		u.store.log.Debug("Nested transaction function returned error, rolling back to savepoint", zap.Error(err))
+		return fmt.Errorf("nested transaction function returned error: %w", err)
+	}
+
+	return nil
+}
+
+// lock ждет блокировки кошелька, пока не отменен контекст
+func (s *Store) lock(ctx context.Context, id uuid.UUID) error {
+	s.locksMu.Lock()
+	ch, ok := s.locks[id]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		s.locks[id] = ch
+	}
+	s.locksMu.Unlock()
+
+	select {
+	case ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to lock wallet %s: %w", id, ctx.Err())
+	}
+}
+
+func (s *Store) unlock(id uuid.UUID) {
+	s.locksMu.Lock()
+	ch := s.locks[id]
+	s.locksMu.Unlock()
+	<-ch
+}
+
+// constraintError - нарушение ограничения, которое в PostgreSQL обеспечивает схема
+func constraintError(name string) error {
+	return fmt.Errorf("violates constraint %q", name)
+}
+
+func numeric(d decimal.Decimal) decimal.Decimal {
+	return d.Round(numericScale)
+}
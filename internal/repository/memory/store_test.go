@@ -0,0 +1,17 @@
+package memory_test
+
+import (
+	"testing"
+
+	"testtask/internal/domain"
+	"testtask/internal/repository/memory"
+	"testtask/internal/repository/storetest"
+
+	"go.uber.org/zap"
+)
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) domain.UnitOfWork {
+		return memory.NewStore(zap.NewNop())
+	})
+}
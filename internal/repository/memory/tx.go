@@ -0,0 +1,373 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// overlay - измененные и удаленные транзакцией строки таблицы поверх закоммиченных
+type overlay[K comparable, V any] struct {
+	tx      *tx
+	base    func(s *state) map[K]V
+	dirty   map[K]V
+	deleted map[K]bool
+}
+
+func newOverlay[K comparable, V any](t *tx, base func(s *state) map[K]V) overlay[K, V] {
+	return overlay[K, V]{tx: t, base: base, dirty: make(map[K]V), deleted: make(map[K]bool)}
+}
+
+func (o *overlay[K, V]) get(key K) (v V, ok bool) {
+	if v, ok := o.dirty[key]; ok {
+		return v, true
+	}
+	if o.deleted[key] {
+		return v, false
+	}
+	o.tx.view(func(s *state) {
+		v, ok = o.base(s)[key]
+	})
+	return v, ok
+}
+
+func (o *overlay[K, V]) set(key K, value V) {
+	delete(o.deleted, key)
+	o.dirty[key] = value
+}
+
+func (o *overlay[K, V]) remove(key K) {
+	delete(o.dirty, key)
+	o.deleted[key] = true
+}
+
+// each обходит строки таблицы в том виде, в каком их видит транзакция. fn не должна обращаться к хранилищу
+func (o *overlay[K, V]) each(fn func(key K, value V)) {
+	for k, v := range o.dirty {
+		fn(k, v)
+	}
+	o.tx.view(func(s *state) {
+		for k, v := range o.base(s) {
+			if _, ok := o.dirty[k]; !ok && !o.deleted[k] {
+				fn(k, v)
+			}
+		}
+	})
+}
+
+// savepoint запоминает изменения транзакции и возвращает функцию отката к ним
+func (o *overlay[K, V]) savepoint() func() {
+	saved, savedDeleted := maps.Clone(o.dirty), maps.Clone(o.deleted)
+	return func() { o.dirty, o.deleted = saved, savedDeleted }
+}
+
+// changed сообщает, изменила ли транзакция строки таблицы
+func (o *overlay[K, V]) changed() bool {
+	return len(o.dirty) > 0 || len(o.deleted) > 0
+}
+
+func (o *overlay[K, V]) commit(s *state) {
+	base := o.base(s)
+	for k := range o.deleted {
+		delete(base, k)
+	}
+	for k, v := range o.dirty {
+		base[k] = v
+	}
+}
+
+// appendOnly - добавленные транзакцией записи журнала, который только дополняется
+type appendOnly[T any] struct {
+	tx    *tx
+	base  func(s *state) *[]T
+	added []T
+}
+
+func (l *appendOnly[T]) append(v T) {
+	l.added = append(l.added, v)
+}
+
+// each обходит записи в порядке добавления, пока fn возвращает true. fn не должна обращаться к хранилищу
+func (l *appendOnly[T]) each(fn func(v T) bool) {
+	stopped := false
+	l.tx.view(func(s *state) {
+		for _, v := range *l.base(s) {
+			if !fn(v) {
+				stopped = true
+				return
+			}
+		}
+	})
+	if stopped {
+		return
+	}
+	for _, v := range l.added {
+		if !fn(v) {
+			return
+		}
+	}
+}
+
+func (l *appendOnly[T]) savepoint() func() {
+	n := len(l.added)
+	return func() { l.added = l.added[:n] }
+}
+
+func (l *appendOnly[T]) commit(s *state) {
+	*l.base(s) = append(*l.base(s), l.added...)
+}
+
+type tx struct {
+	store   *Store
+	options domain.TxOptions
+	// snapshot - закоммиченное состояние на начало транзакции REPEATABLE READ и SERIALIZABLE.
+	// Транзакция READ COMMITTED читает текущее состояние
+	snapshot *state
+	// startedAt - время создания записей транзакции, как now() в PostgreSQL
+	startedAt time.Time
+	locked    map[uuid.UUID]bool
+
+	wallets      overlay[uuid.UUID, domain.Wallet]
+	walletLimits overlay[uuid.UUID, domain.SpendingLimits]
+	tierLimits   overlay[domain.WalletTier, domain.SpendingLimits]
+	accounts     overlay[uuid.UUID, account]
+	snapshots    overlay[snapshotKey, decimal.Decimal]
+	imports      overlay[uuid.UUID, domain.Import]
+	importRows   overlay[importRowKey, domain.ImportRow]
+	idempotency  overlay[string, domain.IdempotentResponse]
+	operations   appendOnly[domain.Operation]
+	audit        appendOnly[domain.AuditEntry]
+	journals     appendOnly[journal]
+	postings     appendOnly[posting]
+}
+
+func (s *Store) begin(options domain.TxOptions) *tx {
+	t := &tx{
+		store:     s,
+		options:   options,
+		startedAt: s.now().Truncate(time.Microsecond),
+		locked:    make(map[uuid.UUID]bool),
+	}
+	if options.Snapshot() {
+		s.mu.RLock()
+		t.snapshot = s.state.clone()
+		s.mu.RUnlock()
+	}
+
+	t.wallets = newOverlay(t, func(s *state) map[uuid.UUID]domain.Wallet { return s.wallets })
+	t.walletLimits = newOverlay(t, func(s *state) map[uuid.UUID]domain.SpendingLimits { return s.walletLimits })
+	t.tierLimits = newOverlay(t, func(s *state) map[domain.WalletTier]domain.SpendingLimits { return s.tierLimits })
+	t.accounts = newOverlay(t, func(s *state) map[uuid.UUID]account { return s.accounts })
+	t.snapshots = newOverlay(t, func(s *state) map[snapshotKey]decimal.Decimal { return s.snapshots })
+	t.imports = newOverlay(t, func(s *state) map[uuid.UUID]domain.Import { return s.imports })
+	t.importRows = newOverlay(t, func(s *state) map[importRowKey]domain.ImportRow { return s.importRows })
+	t.idempotency = newOverlay(t, func(s *state) map[string]domain.IdempotentResponse { return s.idempotency })
+	t.operations = appendOnly[domain.Operation]{tx: t, base: func(s *state) *[]domain.Operation { return &s.operations }}
+	t.audit = appendOnly[domain.AuditEntry]{tx: t, base: func(s *state) *[]domain.AuditEntry { return &s.audit }}
+	t.journals = appendOnly[journal]{tx: t, base: func(s *state) *[]journal { return &s.journals }}
+	t.postings = appendOnly[posting]{tx: t, base: func(s *state) *[]posting { return &s.postings }}
+	return t
+}
+
+// view передает fn состояние, которое видит транзакция: снимок на ее начало или текущее закоммиченное
+func (t *tx) view(fn func(s *state)) {
+	if t.snapshot != nil {
+		fn(t.snapshot)
+		return
+	}
+	t.store.mu.RLock()
+	defer t.store.mu.RUnlock()
+	fn(&t.store.state)
+}
+
+// lock блокирует кошелек до конца транзакции; повторная блокировка в той же транзакции ничего не делает
+func (t *tx) lock(ctx context.Context, id uuid.UUID) error {
+	if t.locked[id] {
+		return nil
+	}
+	if err := t.store.lock(ctx, id); err != nil {
+		return err
+	}
+	t.locked[id] = true
+	return t.checkConcurrentUpdate(id)
+}
+
+// checkConcurrentUpdate отказывает транзакции со снимком в изменении кошелька, который после начала транзакции
+// изменила другая, как PostgreSQL на уровнях REPEATABLE READ и SERIALIZABLE
+func (t *tx) checkConcurrentUpdate(id uuid.UUID) error {
+	if t.snapshot == nil {
+		return nil
+	}
+	if _, ok := t.wallets.dirty[id]; ok {
+		return nil
+	}
+
+	seen, seenOK := t.snapshot.wallets[id]
+	t.store.mu.RLock()
+	current, currentOK := t.store.state.wallets[id]
+	t.store.mu.RUnlock()
+	if seenOK != currentOK || seenOK && !sameWallet(seen, current) {
+		return fmt.Errorf("%w: wallet %s", domain.ErrSerializationFailure, id)
+	}
+	return nil
+}
+
+func sameWallet(a, b domain.Wallet) bool {
+	return a.Balance.Equal(b.Balance) && a.CreditLimit.Equal(b.CreditLimit) &&
+		a.Tier == b.Tier && a.Currency == b.Currency && a.Status == b.Status &&
+		a.ClientID == b.ClientID && a.ExternalID == b.ExternalID && a.OwnerID == b.OwnerID && a.Label == b.Label && reflect.DeepEqual(a.Metadata, b.Metadata)
+}
+
+// commit проверяет баланс добавленных журналов, как отложенный триггер в PostgreSQL, и переносит изменения в общее состояние
+func (t *tx) commit() error {
+	defer t.release()
+
+	if t.options.ReadOnly && t.changed() {
+		return domain.ErrReadOnlyTransaction
+	}
+
+	sums := make(map[uuid.UUID]map[string]decimal.Decimal)
+	for _, p := range t.postings.added {
+		if sums[p.JournalID] == nil {
+			sums[p.JournalID] = make(map[string]decimal.Decimal)
+		}
+		sums[p.JournalID][p.Currency] = sums[p.JournalID][p.Currency].Add(p.Amount)
+	}
+	for journalID, byCurrency := range sums {
+		for _, sum := range byCurrency {
+			if !sum.IsZero() {
+				return fmt.Errorf("%w: journal %s", domain.ErrJournalUnbalanced, journalID)
+			}
+		}
+	}
+
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t.changed() {
+		s.lsn++
+	}
+	t.wallets.commit(&s.state)
+	t.walletLimits.commit(&s.state)
+	t.tierLimits.commit(&s.state)
+	t.accounts.commit(&s.state)
+	t.snapshots.commit(&s.state)
+	t.imports.commit(&s.state)
+	t.importRows.commit(&s.state)
+	t.idempotency.commit(&s.state)
+	t.operations.commit(&s.state)
+	t.audit.commit(&s.state)
+	t.journals.commit(&s.state)
+	t.postings.commit(&s.state)
+	return nil
+}
+
+// savepoint возвращает функцию отката транзакции к текущему состоянию, как ROLLBACK TO SAVEPOINT.
+// Блокировки кошельков, взятые после точки сохранения, держатся до конца транзакции
+func (t *tx) savepoint() func() {
+	restore := []func(){
+		t.wallets.savepoint(),
+		t.walletLimits.savepoint(),
+		t.tierLimits.savepoint(),
+		t.accounts.savepoint(),
+		t.snapshots.savepoint(),
+		t.imports.savepoint(),
+		t.importRows.savepoint(),
+		t.idempotency.savepoint(),
+		t.operations.savepoint(),
+		t.audit.savepoint(),
+		t.journals.savepoint(),
+		t.postings.savepoint(),
+	}
+	return func() {
+		for _, fn := range restore {
+			fn()
+		}
+	}
+}
+
+// changed сообщает, изменила ли транзакция что-нибудь
+func (t *tx) changed() bool {
+	return t.wallets.changed() || t.walletLimits.changed() || t.tierLimits.changed() ||
+		t.accounts.changed() || t.snapshots.changed() || t.imports.changed() ||
+		t.importRows.changed() || t.idempotency.changed() || len(t.operations.added) > 0 || len(t.audit.added) > 0 ||
+		len(t.journals.added) > 0 || len(t.postings.added) > 0
+}
+
+func (t *tx) rollback() {
+	t.release()
+}
+
+func (t *tx) release() {
+	for id := range t.locked {
+		t.store.unlock(id)
+	}
+	t.locked = nil
+}
+
+// repo - общая часть репозиториев: вне транзакции каждый вызов выполняется в собственной транзакции
+// с автоматическим коммитом, как отдельный запрос к пулу соединений
+type repo struct {
+	store *Store
+	tx    *tx
+}
+
+func (r repo) run(fn func(t *tx) error) error {
+	if r.tx != nil {
+		return fn(r.tx)
+	}
+	t := r.store.begin(domain.TxOptions{})
+	if err := fn(t); err != nil {
+		t.rollback()
+		return err
+	}
+	return t.commit()
+}
+
+// repos дает доступ к репозиториям; используется и хранилищем, и транзакционным UnitOfWork
+type repos struct {
+	repo
+}
+
+func (r repos) Wallets() domain.WalletRepository {
+	return &WalletRepo{r.repo}
+}
+
+func (r repos) Operations() domain.OperationRepository {
+	return &OperationRepo{r.repo}
+}
+
+func (r repos) Limits() domain.LimitRepository {
+	return &LimitRepo{r.repo}
+}
+
+func (r repos) Tiers() domain.TierRepository {
+	return &TierRepo{r.repo}
+}
+
+func (r repos) Audit() domain.AuditRepository {
+	return &AuditRepo{r.repo}
+}
+
+func (r repos) Fees() domain.FeeSchedule {
+	return &FeeRepo{r.repo}
+}
+
+func (r repos) Ledger() domain.LedgerRepository {
+	return &LedgerRepo{r.repo}
+}
+
+func (r repos) Imports() domain.ImportRepository {
+	return &ImportRepo{r.repo}
+}
+
+func (r repos) Idempotency() domain.IdempotencyRepository {
+	return &IdempotencyRepo{r.repo}
+}
@@ -0,0 +1,350 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+type WalletRepo struct {
+	repo
+}
+
+// GetForUpdate получает кошелек и блокирует его до конца транзакции
+func (r *WalletRepo) GetForUpdate(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	var wallet *domain.Wallet
+	err := r.run(func(t *tx) error {
+		if err := t.lock(ctx, id); err != nil {
+			return err
+		}
+		w, ok := t.wallets.get(id)
+		if !ok {
+			return domain.ErrWalletNotFound
+		}
+		wallet = walletCopy(w)
+		return nil
+	})
+	return wallet, err
+}
+
+// UpdateBalance обновляет баланс кошелька
+func (r *WalletRepo) UpdateBalance(ctx context.Context, id uuid.UUID, newBalance decimal.Decimal) error {
+	return r.update(ctx, id, errors.New("wallet not found or not updated"), func(w *domain.Wallet) error {
+		w.Balance = numeric(newBalance)
+		return nil
+	})
+}
+
+// UpdateCreditLimit обновляет кредитный лимит кошелька
+func (r *WalletRepo) UpdateCreditLimit(ctx context.Context, id uuid.UUID, creditLimit decimal.Decimal) error {
+	return r.update(ctx, id, domain.ErrWalletNotFound, func(w *domain.Wallet) error {
+		if creditLimit.IsNegative() {
+			return constraintError("credit_limit_must_be_non_negative")
+		}
+		w.CreditLimit = numeric(creditLimit)
+		return nil
+	})
+}
+
+// UpdateTier переводит кошелек на другой уровень
+func (r *WalletRepo) UpdateTier(ctx context.Context, id uuid.UUID, tier domain.WalletTier) error {
+	return r.update(ctx, id, domain.ErrWalletNotFound, func(w *domain.Wallet) error {
+		if !r.store.tierExists(tier) {
+			return constraintError("wallets_tier_fkey")
+		}
+		w.Tier = tier
+		return nil
+	})
+}
+
+// UpdateStatus замораживает или размораживает кошелек
+func (r *WalletRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.WalletStatus) error {
+	return r.update(ctx, id, domain.ErrWalletNotFound, func(w *domain.Wallet) error {
+		w.Status = status
+		return nil
+	})
+}
+
+// Get получает кошелек без блокировки
+func (r *WalletRepo) Get(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	var wallet *domain.Wallet
+	err := r.run(func(t *tx) error {
+		w, ok := t.wallets.get(id)
+		if !ok {
+			return domain.ErrWalletNotFound
+		}
+		wallet = walletCopy(w)
+		return nil
+	})
+	return wallet, err
+}
+
+// UpdateDetails заменяет название и метаданные кошелька
+func (r *WalletRepo) UpdateDetails(ctx context.Context, id uuid.UUID, label string, metadata map[string]any) error {
+	return r.update(ctx, id, domain.ErrWalletNotFound, func(w *domain.Wallet) error {
+		value, err := jsonb(metadata)
+		if err != nil {
+			return err
+		}
+		w.Label, w.Metadata = label, value
+		return nil
+	})
+}
+
+// GetByExternalID получает кошелек клиента по внешнему ID без блокировки
+func (r *WalletRepo) GetByExternalID(ctx context.Context, clientID, externalID string) (*domain.Wallet, error) {
+	var wallet *domain.Wallet
+	err := r.run(func(t *tx) error {
+		t.wallets.each(func(_ uuid.UUID, w domain.Wallet) {
+			if externalID != "" && w.ClientID == clientID && w.ExternalID == externalID {
+				wallet = walletCopy(w)
+			}
+		})
+		if wallet == nil {
+			return domain.ErrWalletNotFound
+		}
+		return nil
+	})
+	return wallet, err
+}
+
+// Create создает новый кошелек
+func (r *WalletRepo) Create(ctx context.Context, wallet *domain.Wallet) error {
+	return r.run(func(t *tx) error {
+		if _, ok := t.wallets.get(wallet.ID); ok {
+			return constraintError("wallets_pkey")
+		}
+		if wallet.ExternalID != "" {
+			if err := r.checkExternalID(ctx, t, wallet.ClientID, wallet.ExternalID); err != nil {
+				return err
+			}
+		}
+		w := *wallet
+		w.Balance, w.CreditLimit = numeric(w.Balance), numeric(w.CreditLimit)
+		metadata, err := jsonb(w.Metadata)
+		if err != nil {
+			return err
+		}
+		w.Metadata = metadata
+		if w.Currency == "" {
+			w.Currency = domain.DefaultCurrency
+		}
+		if w.Status == "" {
+			w.Status = domain.WalletActive
+		}
+		if !r.store.tierExists(w.Tier) {
+			return constraintError("wallets_tier_fkey")
+		}
+		if err := checkWallet(w); err != nil {
+			return err
+		}
+		w.CreatedAt = t.startedAt
+		t.wallets.set(w.ID, w)
+		wallet.CreatedAt = w.CreatedAt
+		return nil
+	})
+}
+
+// List возвращает страницу кошельков по фильтру в порядке сортировки запроса
+func (r *WalletRepo) List(ctx context.Context, query domain.WalletListQuery) ([]domain.Wallet, error) {
+	var wallets []domain.Wallet
+	err := r.run(func(t *tx) error {
+		t.wallets.each(func(_ uuid.UUID, w domain.Wallet) {
+			if matchWallet(query.Filter, w) && (query.After == nil || compareWallet(w, query.After) > 0) {
+				wallets = append(wallets, *walletCopy(w))
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(wallets, func(a, b domain.Wallet) int {
+		return compareWallet(a, domain.CursorAfter(&b, query.Sort, query.Desc))
+	})
+	if len(wallets) > query.Limit {
+		wallets = wallets[:query.Limit]
+	}
+	return wallets, nil
+}
+
+// Count возвращает количество кошельков по фильтру
+func (r *WalletRepo) Count(ctx context.Context, filter domain.WalletFilter) (int, error) {
+	count := 0
+	err := r.run(func(t *tx) error {
+		t.wallets.each(func(_ uuid.UUID, w domain.Wallet) {
+			if matchWallet(filter, w) {
+				count++
+			}
+		})
+		return nil
+	})
+	return count, err
+}
+
+// matchWallet проверяет кошелек на соответствие фильтру, как условие WHERE PostgreSQL-реализации
+func matchWallet(f domain.WalletFilter, w domain.Wallet) bool {
+	switch {
+	case f.Status != "" && w.Status != f.Status,
+		f.ClientID != "" && w.ClientID != f.ClientID,
+		f.ExternalID != "" && w.ExternalID != f.ExternalID,
+		f.OwnerID != "" && w.OwnerID != f.OwnerID,
+		f.Currency != "" && w.Currency != f.Currency,
+		f.MinBalance != nil && w.Balance.LessThan(*f.MinBalance),
+		f.MaxBalance != nil && w.Balance.GreaterThan(*f.MaxBalance),
+		!f.CreatedFrom.IsZero() && w.CreatedAt.Before(f.CreatedFrom),
+		!f.CreatedTo.IsZero() && !w.CreatedAt.Before(f.CreatedTo):
+		return false
+	}
+	for _, key := range f.MetadataKeys {
+		if _, ok := w.Metadata[key]; !ok {
+			return false
+		}
+	}
+	if len(f.Metadata) > 0 {
+		patch, err := jsonb(f.Metadata)
+		if err != nil || !jsonbContains(w.Metadata, patch) {
+			return false
+		}
+	}
+	return true
+}
+
+// compareWallet сравнивает положение кошелька в списке с позицией курсора: по ключу сортировки, затем по ID
+func compareWallet(w domain.Wallet, c *domain.WalletCursor) int {
+	var cmp int
+	switch c.Sort {
+	case domain.SortByBalance:
+		cmp = w.Balance.Cmp(c.Balance)
+	default:
+		cmp = w.CreatedAt.Compare(c.CreatedAt)
+	}
+	if cmp == 0 {
+		cmp = bytes.Compare(w.ID[:], c.ID[:])
+	}
+	if c.Desc {
+		return -cmp
+	}
+	return cmp
+}
+
+// jsonbContains повторяет оператор @> JSONB: объект содержит все пары вложенного, массив - все его элементы,
+// скаляры совпадают
+func jsonbContains(value, sub any) bool {
+	switch sub := sub.(type) {
+	case map[string]any:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, v := range sub {
+			if nested, ok := object[key]; !ok || !jsonbContains(nested, v) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		array, ok := value.([]any)
+		if !ok {
+			return false
+		}
+		for _, v := range sub {
+			if !slices.ContainsFunc(array, func(item any) bool { return jsonbContains(item, v) }) {
+				return false
+			}
+		}
+		return true
+	default:
+		return value == sub
+	}
+}
+
+// update блокирует кошелек, как UPDATE в PostgreSQL, меняет его и проверяет ограничения таблицы wallets
+func (r *WalletRepo) update(ctx context.Context, id uuid.UUID, notFound error, fn func(w *domain.Wallet) error) error {
+	return r.run(func(t *tx) error {
+		if err := t.lock(ctx, id); err != nil {
+			return err
+		}
+		w, ok := t.wallets.get(id)
+		if !ok {
+			return notFound
+		}
+		if err := fn(&w); err != nil {
+			return err
+		}
+		if err := checkWallet(w); err != nil {
+			return err
+		}
+		t.wallets.set(id, w)
+		return nil
+	})
+}
+
+// externalIDLocks - пространство имен блокировок внешних ID
+var externalIDLocks = uuid.MustParse("5b0f7c2e-3d8a-4e61-9c4f-8a2d1e6b7f30")
+
+// checkExternalID проверяет, что внешний ID клиента свободен. Как уникальный индекс PostgreSQL, вставка ждет транзакцию,
+// которая создает кошелек с тем же клиентом и внешним ID: для этого до конца транзакции блокируется ключ, полученный из пары
+func (r *WalletRepo) checkExternalID(ctx context.Context, t *tx, clientID, externalID string) error {
+	// Нулевой байт не встречается в TEXT, поэтому разные пары не дают один ключ
+	if err := t.lock(ctx, uuid.NewSHA1(externalIDLocks, []byte(clientID+"\x00"+externalID))); err != nil {
+		return err
+	}
+	taken := false
+	t.wallets.each(func(_ uuid.UUID, w domain.Wallet) {
+		taken = taken || w.ClientID == clientID && w.ExternalID == externalID
+	})
+	if taken {
+		return fmt.Errorf("%w: %w", domain.ErrExternalIDExists, constraintError("wallets_client_id_external_id_key"))
+	}
+	return nil
+}
+
+// walletCopy возвращает копию кошелька, изменение которой не затрагивает хранилище
+func walletCopy(w domain.Wallet) *domain.Wallet {
+	w.Metadata, _ = jsonb(w.Metadata)
+	return &w
+}
+
+// jsonb приводит метаданные к виду, в котором их возвращает колонка JSONB: копирует их через JSON,
+// поэтому числа становятся float64, а nil - пустым объектом
+func jsonb(metadata map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	var value map[string]any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	if value == nil {
+		value = map[string]any{}
+	}
+	return value, nil
+}
+
+func checkWallet(w domain.Wallet) error {
+	if w.Balance.LessThan(w.CreditLimit.Neg()) {
+		return constraintError("balance_must_be_within_credit_limit")
+	}
+	if w.Status != domain.WalletActive && w.Status != domain.WalletFrozen {
+		return constraintError("wallets_status_check")
+	}
+	return nil
+}
+
+func (s *Store) tierExists(tier domain.WalletTier) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.state.tiers[tier]
+	return ok
+}
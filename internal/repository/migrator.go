@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/config"
+	"testtask/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+var (
+	ErrSchemaDirty    = errors.New("schema is dirty: a migration failed halfway and must be fixed manually")
+	ErrSchemaOutdated = errors.New("schema version is behind the migrations")
+)
+
+// Migrator управляет версией схемы БД по миграциям, встроенным в бинарный файл
+type Migrator struct {
+	m       *migrate.Migrate
+	connStr string
+}
+
+// NewMigrator подключается к БД для управления миграциями, при необходимости дожидаясь, пока БД станет доступна
+func NewMigrator(ctx context.Context, db config.Database, log *zap.Logger) (*Migrator, error) {
+	connStr, err := db.ConnString()
+	if err != nil {
+		return nil, err
+	}
+
+	var m *Migrator
+	err = retryConnect(ctx, db.ConnectRetryTimeout, log, func(ctx context.Context) (err error) {
+		m, err = newMigrator(connStr)
+		return err
+	})
+	return m, err
+}
+
+func newMigrator(connStr string) (*Migrator, error) {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	m, err := migrate.NewWithSourceInstance("iofs", src, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("start migrations error %v", err)
+	}
+	return &Migrator{m: m, connStr: connStr}, nil
+}
+
+// Up применяет все еще не примененные миграции
+func (m *Migrator) Up() error {
+	if err := m.m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration up error: %v", err)
+	}
+	return nil
+}
+
+// Down откатывает steps последних миграций
+func (m *Migrator) Down(steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
+	}
+	if err := m.m.Steps(-steps); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration down error: %v", err)
+	}
+	return nil
+}
+
+// Goto переводит схему на версию version, применяя или откатывая миграции
+func (m *Migrator) Goto(version uint) error {
+	if err := m.m.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migration to version %d error: %v", version, err)
+	}
+	return nil
+}
+
+// Status возвращает текущую версию схемы и признак незавершенной миграции.
+// Для пустой БД версия равна 0
+func (m *Migrator) Status() (uint, bool, error) {
+	version, dirty, err := m.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to get migration version: %v", err)
+	}
+	return version, dirty, nil
+}
+
+// Latest возвращает версию последней встроенной миграции
+func (m *Migrator) Latest() (uint, error) {
+	var latest uint
+	err := walkMigrations(func(version uint, _ source.Driver) error {
+		latest = version
+		return nil
+	})
+	return latest, err
+}
+
+// Check проверяет, что схема в чистом состоянии и не отстает от встроенных миграций.
+// Схема новее миграций допустима: так бывает, пока старая версия сервиса работает во время выкладки новой
+func (m *Migrator) Check() error {
+	version, dirty, err := m.Status()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%w (version %d)", ErrSchemaDirty, version)
+	}
+	latest, err := m.Latest()
+	if err != nil {
+		return err
+	}
+	if version < latest {
+		return fmt.Errorf("%w: database is at %d, latest is %d", ErrSchemaOutdated, version, latest)
+	}
+	return nil
+}
+
+// Close закрывает подключения к БД и к источнику миграций
+func (m *Migrator) Close() error {
+	sourceErr, dbErr := m.m.Close()
+	return errors.Join(sourceErr, dbErr)
+}
+
+// walkMigrations обходит встроенные миграции в порядке возрастания версий
+func walkMigrations(fn func(version uint, src source.Driver) error) error {
+	src, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		return fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+	defer src.Close()
+
+	version, err := src.First()
+	for err == nil {
+		if err := fn(version, src); err != nil {
+			return err
+		}
+		version, err = src.Next(version)
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return fmt.Errorf("failed to read embedded migrations: %w", err)
+}
+
+// readUp возвращает текст up-миграции версии version
+func readUp(src source.Driver, version uint) (string, error) {
+	r, _, err := src.ReadUp(version)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+	return string(body), nil
+}
+
+// Drift сравнивает живую схему с той, что получается применением миграций до ее текущей версии
+func (m *Migrator) Drift(ctx context.Context) ([]SchemaDrift, error) {
+	version, dirty, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	if dirty {
+		return nil, fmt.Errorf("%w (version %d)", ErrSchemaDirty, version)
+	}
+	return schemaDrift(ctx, m.connStr, version)
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	addOperationQuery = `INSERT INTO operations (id, correlation_id, wallet_id, operation_type_id, amount, created_at)
+		SELECT $1, $2, $3, ot.id, $5, COALESCE($6, now()) FROM operation_types ot WHERE ot.name = $4
+		RETURNING created_at;`
+	sumOperationsSinceQuery = `SELECT COALESCE(SUM(o.amount), 0) FROM operations o
+		JOIN operation_types ot ON ot.id = o.operation_type_id
+		WHERE o.wallet_id = $1 AND ot.name = ANY($2) AND o.created_at >= $3;`
+	listRecentOperationsQuery = `SELECT o.id, o.correlation_id, o.wallet_id, ot.name, o.amount, o.created_at
+		FROM operations o
+		JOIN operation_types ot ON ot.id = o.operation_type_id
+		WHERE $1::uuid IS NULL OR o.wallet_id = $1
+		ORDER BY o.created_at DESC, o.id
+		LIMIT $2;`
+)
+
+type OperationRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+// Add сохраняет операцию в историю кошелька
+func (r *OperationRepo) Add(ctx context.Context, operation *domain.Operation) error {
+	err := r.exec.QueryRow(ctx, addOperationQuery,
+		operation.ID, operation.CorrelationID, operation.WalletID, operation.OperationType, operation.Amount,
+		nullTime(operation.CreatedAt),
+	).Scan(&operation.CreatedAt)
+	if err != nil {
+		r.log.Error("Failed to insert operation", zap.Error(err), zap.String("wallet_id", operation.WalletID.String()))
+		return fmt.Errorf("failed to insert operation: %w", err)
+	}
+
+	return nil
+}
+
+// SumSince суммирует операции указанных типов по кошельку начиная с since
+func (r *OperationRepo) SumSince(ctx context.Context, walletID uuid.UUID, operationTypes []domain.OperationType, since time.Time) (decimal.Decimal, error) {
+	names := make([]string, len(operationTypes))
+	for i, operationType := range operationTypes {
+		names[i] = string(operationType)
+	}
+
+	var sum decimal.Decimal
+	if err := r.exec.QueryRow(ctx, sumOperationsSinceQuery, walletID, names, since).Scan(&sum); err != nil {
+		return decimal.Zero, fmt.Errorf("failed to sum operations: %w", err)
+	}
+
+	return sum, nil
+}
+
+// ListRecent возвращает последние операции кошелька или всех кошельков
+func (r *OperationRepo) ListRecent(ctx context.Context, walletID uuid.UUID, limit int) ([]domain.Operation, error) {
+	var filter *uuid.UUID
+	if walletID != uuid.Nil {
+		filter = &walletID
+	}
+
+	rows, err := r.exec.Query(ctx, listRecentOperationsQuery, filter, limit)
+	if err != nil {
+		r.log.Error("Failed to query recent operations", zap.Error(err))
+		return nil, fmt.Errorf("failed to query recent operations: %w", err)
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.Operation, error) {
+		var op domain.Operation
+		err := row.Scan(&op.ID, &op.CorrelationID, &op.WalletID, &op.OperationType, &op.Amount, &op.CreatedAt)
+		return op, err
+	})
+}
@@ -5,15 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
-	"os"
-	"path/filepath"
 	"time"
 
+	"testtask/internal/config"
 	"testtask/internal/domain"
 
-	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,6 +17,8 @@ import (
 
 type pgxExecutor interface {
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
 }
 
@@ -32,68 +30,180 @@ type WalletRepo struct {
 type unitOfWork struct {
 	tx pgx.Tx
 	WalletRepo
+	operations  OperationRepo
+	limits      LimitRepo
+	tiers       TierRepo
+	audit       AuditRepo
+	fees        FeeRepo
+	ledger      LedgerRepo
+	imports     ImportRepo
+	idempotency IdempotencyRepo
 }
 
-// Это заглушка, не вызывать!
-func (u *unitOfWork) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error) error {
-	return errors.New("Do() should not be called on a transactional unitOfWork")
+func newUnitOfWork(tx pgx.Tx, log *zap.Logger) *unitOfWork {
+	return &unitOfWork{
+		tx: tx,
+		WalletRepo: WalletRepo{
+			exec: tx,
+			log:  log,
+		},
+		operations:  OperationRepo{exec: tx, log: log},
+		limits:      LimitRepo{exec: tx, log: log},
+		tiers:       TierRepo{exec: tx, log: log},
+		audit:       AuditRepo{exec: tx, log: log},
+		fees:        FeeRepo{exec: tx, log: log},
+		ledger:      LedgerRepo{exec: tx, log: log},
+		imports:     ImportRepo{exec: tx, log: log},
+		idempotency: IdempotencyRepo{exec: tx, log: log},
+	}
+}
+
+// Do выполняет fn во вложенной транзакции на точке сохранения.
+// Ошибка fn откатывает только изменения, сделанные внутри fn, внешняя транзакция продолжается
+func (u *unitOfWork) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error, _ ...domain.TxOption) error {
+	savepoint, err := u.tx.Begin(ctx)
+	if err != nil {
+		u.log.Error("Failed to create savepoint", zap.Error(err))
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+
+	defer savepoint.Rollback(ctx)
+
+	if err := fn(newUnitOfWork(savepoint, u.log)); err != nil {
+		u.log.Debug("Nested transaction function returned error, rolling back to savepoint", zap.Error(err))
+		return fmt.Errorf("nested transaction function returned error: %w", err)
+	}
+
+	return savepoint.Commit(ctx)
 }
 
 func (u *unitOfWork) Wallets() domain.WalletRepository {
 	return &u.WalletRepo
 }
 
+func (u *unitOfWork) Operations() domain.OperationRepository {
+	return &u.operations
+}
+
+func (u *unitOfWork) Limits() domain.LimitRepository {
+	return &u.limits
+}
+
+func (u *unitOfWork) Tiers() domain.TierRepository {
+	return &u.tiers
+}
+
+func (u *unitOfWork) Audit() domain.AuditRepository {
+	return &u.audit
+}
+
+func (u *unitOfWork) Fees() domain.FeeSchedule {
+	return &u.fees
+}
+
+func (u *unitOfWork) Ledger() domain.LedgerRepository {
+	return &u.ledger
+}
+
+func (u *unitOfWork) Imports() domain.ImportRepository {
+	return &u.imports
+}
+
+func (u *unitOfWork) Idempotency() domain.IdempotencyRepository {
+	return &u.idempotency
+}
+
 type Store struct {
 	pool *pgxpool.Pool
+	// replica - пул соединений с репликой для чтений без блокировок; nil, если реплика не настроена
+	replica       *pgxpool.Pool
+	maxReplicaLag time.Duration
 	WalletRepo
-	log *zap.Logger
+	operations  OperationRepo
+	limits      LimitRepo
+	tiers       TierRepo
+	audit       AuditRepo
+	fees        FeeRepo
+	ledger      LedgerRepo
+	imports     ImportRepo
+	idempotency IdempotencyRepo
+	log         *zap.Logger
 }
 
-func NewStore(ctx context.Context, user string, password string, host string, port string, dbname string, sslmode string, log *zap.Logger) (*Store, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, password, host, port, dbname, sslmode)
+// NewStore подключается к PostgreSQL, при необходимости дожидаясь, пока БД станет доступна, и проверяет схему
+func NewStore(ctx context.Context, db config.Database, log *zap.Logger) (*Store, error) {
+	poolConfig, err := newPoolConfig(db)
+	if err != nil {
+		log.Error("Error parsing connection string", zap.Error(err))
+		return nil, err
+	}
 
-	log = log.With(zap.String("dbname", dbname),
-		zap.String("host:port", fmt.Sprintf("%s:%s", host, port)),
-		zap.String("user", user),
+	conn := poolConfig.ConnConfig
+	log = log.With(zap.String("dbname", conn.Database),
+		zap.String("host:port", fmt.Sprintf("%s:%d", conn.Host, conn.Port)),
 	)
 
-	log.Info("Connecting to PostgreSQL")
+	return newStore(ctx, poolConfig, db.ConnectRetryTimeout, log)
+}
 
-	config, err := pgxpool.ParseConfig(connStr)
+// newPoolConfig собирает конфигурацию пула из строки подключения и параметров пула
+func newPoolConfig(db config.Database) (*pgxpool.Config, error) {
+	connStr, err := db.ConnString()
+	if err != nil {
+		return nil, err
+	}
+	poolConfig, err := pgxpool.ParseConfig(connStr)
 	if err != nil {
-		log.Error("Error parsing connection string", zap.Error(err))
 		return nil, fmt.Errorf("error parsing connection string: %w", err)
 	}
-	config.MaxConns = 50
-	config.HealthCheckPeriod = 30 * time.Second
-	config.MinConns = 2
 
-	db, err := pgxpool.NewWithConfig(ctx, config)
+	poolConfig.MaxConns = db.MaxConns
+	poolConfig.MinConns = db.MinConns
+	poolConfig.MaxConnLifetime = db.MaxConnLifetime
+	poolConfig.MaxConnIdleTime = db.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = db.HealthCheckPeriod
+	return poolConfig, nil
+}
+
+func newStore(ctx context.Context, poolConfig *pgxpool.Config, retryTimeout time.Duration, log *zap.Logger) (*Store, error) {
+	log.Info("Connecting to PostgreSQL")
+
+	db, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		log.Error("Failed connecting to PostgreSQL", zap.Error(err))
 		return nil, fmt.Errorf("error connecting to PostgreSQL: %w", err)
 	}
 
 	log.Info("Testing database connection")
-	if err := db.Ping(ctx); err != nil {
+	if err := retryConnect(ctx, retryTimeout, log, db.Ping); err != nil {
+		db.Close()
 		log.Error("failed pinging PostgreSQL", zap.Error(err))
 		return nil, fmt.Errorf("failed pinging PostgreSQL: %w", err)
 	}
 
 	log.Info("Successfully connected to PostgreSQL")
 
-	log.Info("Starting database migrations")
+	log.Info("Checking database schema")
 
-	if err := runMigrations(connStr); err != nil {
-		log.Error("Failed to run migrations", zap.Error(err))
-		return nil, fmt.Errorf("failed to run migration: %w", err)
+	if err := checkSchema(ctx, poolConfig.ConnString(), log); err != nil {
+		db.Close()
+		log.Error("Database schema check failed", zap.Error(err))
+		return nil, fmt.Errorf("database schema check failed: %w", err)
 	}
 
-	log.Info("Successfully migrated database")
+	log.Info("Database schema is up to date")
 	return &Store{
-		pool:       db,
-		WalletRepo: WalletRepo{exec: db, log: log},
-		log:        log.Named("repository"),
+		pool:        db,
+		WalletRepo:  WalletRepo{exec: db, log: log},
+		operations:  OperationRepo{exec: db, log: log},
+		limits:      LimitRepo{exec: db, log: log},
+		tiers:       TierRepo{exec: db, log: log},
+		audit:       AuditRepo{exec: db, log: log},
+		fees:        FeeRepo{exec: db, log: log},
+		ledger:      LedgerRepo{exec: db, log: log},
+		imports:     ImportRepo{exec: db, log: log},
+		idempotency: IdempotencyRepo{exec: db, log: log},
+		log:         log.Named("repository"),
 	}, nil
 }
 
@@ -102,9 +212,61 @@ func (s *Store) Wallets() domain.WalletRepository {
 	return &s.WalletRepo
 }
 
+func (s *Store) Operations() domain.OperationRepository {
+	return &s.operations
+}
+
+func (s *Store) Limits() domain.LimitRepository {
+	return &s.limits
+}
+
+func (s *Store) Tiers() domain.TierRepository {
+	return &s.tiers
+}
+
+func (s *Store) Audit() domain.AuditRepository {
+	return &s.audit
+}
+
+func (s *Store) Fees() domain.FeeSchedule {
+	return &s.fees
+}
+
+func (s *Store) Ledger() domain.LedgerRepository {
+	return &s.ledger
+}
+
+func (s *Store) Imports() domain.ImportRepository {
+	return &s.imports
+}
+
+func (s *Store) Idempotency() domain.IdempotencyRepository {
+	return &s.idempotency
+}
+
 // Do - это главная точка входа для выполнения бизнес-логики в транзакции.
-func (s *Store) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error) error {
-	tx, err := s.pool.Begin(ctx)
+// Транзакция уровня Serializable, прерванная ошибкой сериализации, выполняется заново
+func (s *Store) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error, opts ...domain.TxOption) error {
+	options := domain.NewTxOptions(opts...)
+	for attempt := 1; ; attempt++ {
+		err := s.do(ctx, fn, options)
+		if err == nil || options.Isolation != domain.Serializable || !errors.Is(err, domain.ErrSerializationFailure) {
+			return err
+		}
+		if attempt == maxSerializationAttempts {
+			s.log.Warn("Serialization failure, giving up", zap.Int("attempts", attempt), zap.Error(err))
+			return err
+		}
+
+		s.log.Debug("Serialization failure, retrying transaction", zap.Int("attempt", attempt), zap.Error(err))
+		if err := sleep(ctx, serializationBackoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Store) do(ctx context.Context, fn func(uow domain.UnitOfWork) error, options domain.TxOptions) error {
+	tx, err := s.poolFor(ctx, options).BeginTx(ctx, txOptions(options))
 	if err != nil {
 		s.log.Error("Failed to begin transaction", zap.Error(err))
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -112,48 +274,53 @@ func (s *Store) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error) er
 
 	defer tx.Rollback(ctx)
 
-	uow := &unitOfWork{
-		tx: tx,
-		WalletRepo: WalletRepo{
-			exec: tx,
-			log:  s.log,
-		},
-	}
-
-	if err := fn(uow); err != nil {
+	if err := fn(newUnitOfWork(tx, s.log)); err != nil {
 		s.log.Debug("Transaction function returned error, rolling back", zap.Error(err))
-		return fmt.Errorf("transaction function returned error: %w", err)
+		return fmt.Errorf("transaction function returned error: %w", txError(err))
 	}
 
 	s.log.Debug("Committing transaction")
-	return tx.Commit(ctx)
+	if err := tx.Commit(ctx); err != nil {
+		return txError(err)
+	}
+
+	if options.CommitLSN != nil {
+		// Операция уже закоммичена, поэтому без позиции журнала клиент только теряет read-your-writes
+		if err := s.pool.QueryRow(ctx, currentLSNQuery).Scan(options.CommitLSN); err != nil {
+			s.log.Warn("Failed to read commit LSN", zap.Error(err))
+		}
+	}
+	return nil
 }
 
 func (r *Store) Close() {
 	r.log.Info("Closing database connection")
 	r.pool.Close()
+	if r.replica != nil {
+		r.replica.Close()
+	}
 }
 
-func runMigrations(connStr string) error {
-	migratePath := os.Getenv("MIGRATE_PATH")
-	if migratePath == "" {
-		migratePath = "./migrations"
-	}
-	absPath, err := filepath.Abs(migratePath)
+// checkSchema отказывает в запуске, если схема отстает от встроенных миграций или осталась в грязном состоянии.
+// Расхождения живой схемы с ожидаемой только логируются: их нужно разобрать, но обслуживание они не блокируют
+func checkSchema(ctx context.Context, connStr string, log *zap.Logger) error {
+	m, err := newMigrator(connStr)
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %w", err)
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Check(); err != nil {
+		return err
 	}
-	absPath = filepath.ToSlash(absPath)
-	migrateUrl := fmt.Sprintf("file://%s", absPath)
-	m, err := migrate.New(migrateUrl, connStr)
+
+	drifts, err := m.Drift(ctx)
 	if err != nil {
-		return fmt.Errorf("start migrations error %v", err)
+		log.Warn("Failed to check schema drift", zap.Error(err))
+		return nil
 	}
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			return nil
-		}
-		return fmt.Errorf("migration up error: %v", err)
+	for _, drift := range drifts {
+		log.Warn("Schema drift detected", zap.String("drift", drift.String()))
 	}
 	return nil
 }
@@ -0,0 +1,89 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/config"
+	"testtask/internal/domain"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	currentLSNQuery = `SELECT pg_current_wal_lsn()::text;`
+	// Если реплика воспроизвела все полученное, она не отстает, даже когда на основной БД давно не было коммитов.
+	// Вне режима восстановления функции возвращают NULL, и такой сервер репликой не считается
+	replicaStatusQuery = `SELECT
+			COALESCE(CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END, 'Infinity')::float8,
+			COALESCE(pg_last_wal_replay_lsn() >= $1::pg_lsn, false);`
+)
+
+// ConnectReplica подключает реплику для чтений без блокировок. Реплика, отстающая больше maxLag
+// или не воспроизведшая запрошенную позицию журнала, пропускается, и чтение идет в основную БД
+func (s *Store) ConnectReplica(ctx context.Context, db config.Database, maxLag time.Duration) error {
+	poolConfig, err := newPoolConfig(db)
+	if err != nil {
+		return fmt.Errorf("replica: %w", err)
+	}
+
+	conn := poolConfig.ConnConfig
+	log := s.log.With(zap.String("replica", fmt.Sprintf("%s:%d", conn.Host, conn.Port)))
+	log.Info("Connecting to PostgreSQL replica")
+
+	replica, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		log.Error("Failed connecting to PostgreSQL replica", zap.Error(err))
+		return fmt.Errorf("error connecting to PostgreSQL replica: %w", err)
+	}
+	if err := retryConnect(ctx, db.ConnectRetryTimeout, log, replica.Ping); err != nil {
+		replica.Close()
+		log.Error("Failed pinging PostgreSQL replica", zap.Error(err))
+		return fmt.Errorf("failed pinging PostgreSQL replica: %w", err)
+	}
+
+	s.replica = replica
+	s.maxReplicaLag = maxLag
+	log.Info("Successfully connected to PostgreSQL replica", zap.Duration("max_lag", maxLag))
+	return nil
+}
+
+// poolFor выбирает пул для транзакции: реплику для чтений, которым она разрешена и которые она может обслужить.
+// SERIALIZABLE на реплике недоступен
+func (s *Store) poolFor(ctx context.Context, options domain.TxOptions) *pgxpool.Pool {
+	if s.replica == nil || !options.Replica || !options.ReadOnly || options.Isolation == domain.Serializable {
+		return s.pool
+	}
+	if !s.replicaReady(ctx, options.MinLSN) {
+		return s.pool
+	}
+	return s.replica
+}
+
+// replicaReady проверяет, что реплика отстает не больше допустимого и воспроизвела журнал до minLSN
+func (s *Store) replicaReady(ctx context.Context, minLSN string) bool {
+	if minLSN == "" {
+		minLSN = "0/0"
+	}
+
+	var lagSeconds float64
+	var caughtUp bool
+	if err := s.replica.QueryRow(ctx, replicaStatusQuery, minLSN).Scan(&lagSeconds, &caughtUp); err != nil {
+		s.log.Warn("Failed to check replica status, reading from primary", zap.Error(err))
+		return false
+	}
+
+	if lagSeconds > s.maxReplicaLag.Seconds() {
+		s.log.Debug("Replica lags behind, reading from primary", zap.Float64("lag_seconds", lagSeconds))
+		return false
+	}
+	if !caughtUp {
+		s.log.Debug("Replica has not replayed requested LSN, reading from primary", zap.String("min_lsn", minLSN))
+		return false
+	}
+	return true
+}
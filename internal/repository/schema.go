@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/jackc/pgx/v5"
+)
+
+// driftSchema - временная схема, в которую проигрываются миграции для сравнения.
+// Она создается в транзакции, которая всегда откатывается, поэтому не видна другим подключениям
+const driftSchema = "schema_drift_check"
+
+// schemaObjectsQuery описывает объекты схемы $1: колонки, ограничения, индексы, триггеры и функции.
+// Имя объекта включает таблицу, определение берется из каталога PostgreSQL
+const schemaObjectsQuery = `
+	SELECT 'column', cl.relname || '.' || a.attname,
+		format_type(a.atttypid, a.atttypmod)
+			|| CASE WHEN a.attnotnull THEN ' NOT NULL' ELSE '' END
+			|| COALESCE(' DEFAULT ' || pg_get_expr(d.adbin, d.adrelid), '')
+	FROM pg_attribute a
+	JOIN pg_class cl ON cl.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = cl.relnamespace
+	LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+	WHERE n.nspname = $1 AND cl.relkind = 'r' AND cl.relname <> 'schema_migrations'
+		AND a.attnum > 0 AND NOT a.attisdropped
+	UNION ALL
+	SELECT 'constraint', cl.relname || '.' || co.conname, pg_get_constraintdef(co.oid)
+	FROM pg_constraint co
+	JOIN pg_class cl ON cl.oid = co.conrelid
+	JOIN pg_namespace n ON n.oid = cl.relnamespace
+	WHERE n.nspname = $1 AND cl.relname <> 'schema_migrations'
+	UNION ALL
+	SELECT 'index', cl.relname || '.' || i.relname, pg_get_indexdef(i.oid)
+	FROM pg_index x
+	JOIN pg_class i ON i.oid = x.indexrelid
+	JOIN pg_class cl ON cl.oid = x.indrelid
+	JOIN pg_namespace n ON n.oid = cl.relnamespace
+	WHERE n.nspname = $1 AND cl.relname <> 'schema_migrations'
+	UNION ALL
+	SELECT 'trigger', cl.relname || '.' || t.tgname, pg_get_triggerdef(t.oid)
+	FROM pg_trigger t
+	JOIN pg_class cl ON cl.oid = t.tgrelid
+	JOIN pg_namespace n ON n.oid = cl.relnamespace
+	WHERE n.nspname = $1 AND NOT t.tgisinternal
+	UNION ALL
+	SELECT 'function', p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+		pg_get_function_result(p.oid)
+	FROM pg_proc p
+	JOIN pg_namespace n ON n.oid = p.pronamespace
+	WHERE n.nspname = $1
+		AND NOT EXISTS (SELECT 1 FROM pg_depend dep WHERE dep.objid = p.oid AND dep.deptype = 'e');`
+
+// SchemaDrift - расхождение объекта живой схемы с ожидаемым по миграциям.
+// Пустой Expected означает лишний объект, пустой Actual - отсутствующий
+type SchemaDrift struct {
+	Kind     string `json:"kind"`
+	Object   string `json:"object"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (d SchemaDrift) String() string {
+	switch {
+	case d.Expected == "":
+		return fmt.Sprintf("unexpected %s %s: %s", d.Kind, d.Object, d.Actual)
+	case d.Actual == "":
+		return fmt.Sprintf("missing %s %s: %s", d.Kind, d.Object, d.Expected)
+	default:
+		return fmt.Sprintf("changed %s %s: expected %s, got %s", d.Kind, d.Object, d.Expected, d.Actual)
+	}
+}
+
+// schemaDrift проигрывает миграции до версии version во временную схему и сравнивает ее с текущей схемой подключения
+func schemaDrift(ctx context.Context, connStr string, version uint) ([]SchemaDrift, error) {
+	conn, err := pgx.Connect(ctx, connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close(ctx)
+
+	var live string
+	if err := conn.QueryRow(ctx, "SELECT current_schema();").Scan(&live); err != nil {
+		return nil, fmt.Errorf("failed to get current schema: %w", err)
+	}
+	actual, err := schemaObjects(ctx, conn, live)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, "CREATE SCHEMA "+driftSchema+"; SET LOCAL search_path TO "+driftSchema+";"); err != nil {
+		return nil, fmt.Errorf("failed to create scratch schema: %w", err)
+	}
+	err = walkMigrations(func(v uint, src source.Driver) error {
+		if v > version {
+			return nil
+		}
+		body, err := readUp(src, v)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, body); err != nil {
+			return fmt.Errorf("failed to replay migration %d: %w", v, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	expected, err := schemaObjects(ctx, tx, driftSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffSchemas(expected, actual), nil
+}
+
+// schemaObjects возвращает определения объектов схемы по ключу "вид имя" без префикса схемы,
+// чтобы определения из разных схем можно было сравнивать
+func schemaObjects(ctx context.Context, exec pgxExecutor, schema string) (map[[2]string]string, error) {
+	rows, err := exec.Query(ctx, schemaObjectsQuery, schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe schema %s: %w", schema, err)
+	}
+	defer rows.Close()
+
+	objects := make(map[[2]string]string)
+	for rows.Next() {
+		var kind, name, definition string
+		if err := rows.Scan(&kind, &name, &definition); err != nil {
+			return nil, err
+		}
+		objects[[2]string{kind, name}] = strings.ReplaceAll(definition, schema+".", "")
+	}
+	return objects, rows.Err()
+}
+
+func diffSchemas(expected, actual map[[2]string]string) []SchemaDrift {
+	var drifts []SchemaDrift
+	for key, want := range expected {
+		if got := actual[key]; got != want {
+			drifts = append(drifts, SchemaDrift{Kind: key[0], Object: key[1], Expected: want, Actual: got})
+		}
+	}
+	for key, got := range actual {
+		if _, ok := expected[key]; !ok {
+			drifts = append(drifts, SchemaDrift{Kind: key[0], Object: key[1], Actual: got})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Object != drifts[j].Object {
+			return drifts[i].Object < drifts[j].Object
+		}
+		return drifts[i].Kind < drifts[j].Kind
+	})
+	return drifts
+}
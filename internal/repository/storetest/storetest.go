@@ -0,0 +1,965 @@
+// Package storetest - общий набор проверок реализаций domain.UnitOfWork.
+// Один и тот же набор запускается для хранилища в памяти и для PostgreSQL,
+// чтобы они одинаково вели себя там, где на это полагается сервис
+package storetest
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStore создает проверяемое хранилище. В нем могут быть данные других тестов,
+// поэтому проверки работают только с кошельками и импортами, которые создали сами
+type NewStore func(t *testing.T) domain.UnitOfWork
+
+var errRollback = errors.New("rollback")
+
+// Run запускает все проверки для хранилища
+func Run(t *testing.T, newStore NewStore) {
+	t.Run("Кошельки", func(t *testing.T) { testWallets(t, newStore(t)) })
+	t.Run("Реквизиты кошелька", func(t *testing.T) { testWalletDetails(t, newStore(t)) })
+	t.Run("Список кошельков", func(t *testing.T) { testWalletList(t, newStore(t)) })
+	t.Run("Транзакции", func(t *testing.T) { testTransactions(t, newStore(t)) })
+	t.Run("Блокировка кошелька", func(t *testing.T) { testLocking(t, newStore(t)) })
+	t.Run("История операций и аудит", func(t *testing.T) { testOperations(t, newStore(t)) })
+	t.Run("Лимиты и уровни", func(t *testing.T) { testLimits(t, newStore(t)) })
+	t.Run("Журнал проводок", func(t *testing.T) { testLedger(t, newStore(t)) })
+	t.Run("Импорты", func(t *testing.T) { testImports(t, newStore(t)) })
+	t.Run("Ключи идемпотентности", func(t *testing.T) { testIdempotency(t, newStore(t)) })
+}
+
+// createWallet создает кошелек вместе со счетом в журнале, как это делает сервис
+func createWallet(t *testing.T, store domain.UnitOfWork) *domain.Wallet {
+	t.Helper()
+	wallet := &domain.Wallet{
+		ID:          uuid.New(),
+		Balance:     decimal.Zero,
+		CreditLimit: decimal.Zero,
+		Tier:        domain.DefaultTier,
+		Currency:    domain.DefaultCurrency,
+		Status:      domain.WalletActive,
+	}
+	require.NoError(t, store.Do(context.Background(), func(uow domain.UnitOfWork) error {
+		if err := uow.Wallets().Create(context.Background(), wallet); err != nil {
+			return err
+		}
+		return uow.Ledger().CreateWalletAccount(context.Background(), wallet)
+	}))
+	return wallet
+}
+
+// deposit зачисляет amount на кошелек в одной транзакции: баланс, запись в истории и журнал против cash-in
+func deposit(t *testing.T, store domain.UnitOfWork, walletID uuid.UUID, amount int64) uuid.UUID {
+	t.Helper()
+	correlationID := uuid.New()
+	require.NoError(t, store.Do(context.Background(), func(uow domain.UnitOfWork) error {
+		return depositIn(context.Background(), uow, walletID, correlationID, amount)
+	}))
+	return correlationID
+}
+
+func depositIn(ctx context.Context, uow domain.UnitOfWork, walletID, correlationID uuid.UUID, amount int64) error {
+	wallet, err := uow.Wallets().GetForUpdate(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	value := decimal.NewFromInt(amount)
+	if err := uow.Wallets().UpdateBalance(ctx, walletID, wallet.Balance.Add(value)); err != nil {
+		return err
+	}
+	operationID := uuid.New()
+	if err := uow.Operations().Add(ctx, &domain.Operation{
+		ID: operationID, CorrelationID: correlationID, WalletID: walletID, OperationType: domain.Deposit, Amount: value,
+	}); err != nil {
+		return err
+	}
+	cashIn, err := uow.Ledger().SystemAccountID(ctx, domain.CashIn, wallet.Currency)
+	if err != nil {
+		return err
+	}
+	journal := domain.NewJournal(correlationID, string(domain.Deposit))
+	journal.PostOperation(operationID, walletID, value, wallet.Currency)
+	journal.Post(cashIn, value.Neg(), wallet.Currency)
+	return uow.Ledger().PostJournal(ctx, journal)
+}
+
+// before возвращает ID, непосредственно предшествующий id, чтобы страница с ним начиналась с id
+func before(id uuid.UUID) uuid.UUID {
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i]--
+		if id[i] != 0xff {
+			break
+		}
+	}
+	return id
+}
+
+func assertDecimal(t *testing.T, expected string, actual decimal.Decimal) {
+	t.Helper()
+	assert.True(t, decimal.RequireFromString(expected).Equal(actual), "expected %s, got %s", expected, actual)
+}
+
+func testWallets(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	wallet := createWallet(t, store)
+
+	got, err := store.Wallets().Get(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.ID, got.ID)
+	assertDecimal(t, "0", got.Balance)
+	assert.Equal(t, domain.DefaultTier, got.Tier)
+	assert.Equal(t, domain.DefaultCurrency, got.Currency)
+	assert.Equal(t, domain.WalletActive, got.Status)
+
+	require.NoError(t, store.Wallets().UpdateCreditLimit(ctx, wallet.ID, decimal.NewFromInt(50)))
+	require.NoError(t, store.Wallets().UpdateTier(ctx, wallet.ID, domain.TierVerified))
+	require.NoError(t, store.Wallets().UpdateStatus(ctx, wallet.ID, domain.WalletFrozen))
+	got, err = store.Wallets().Get(ctx, wallet.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "50", got.CreditLimit)
+	assert.Equal(t, domain.TierVerified, got.Tier)
+	assert.Equal(t, domain.WalletFrozen, got.Status)
+
+	assert.Error(t, store.Wallets().UpdateBalance(ctx, wallet.ID, decimal.NewFromInt(-51)), "баланс ниже кредитного лимита")
+	assert.Error(t, store.Wallets().UpdateTier(ctx, wallet.ID, "PLATINUM"), "неизвестный уровень")
+	got, err = store.Wallets().Get(ctx, wallet.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "0", got.Balance)
+
+	unknown := uuid.New()
+	_, err = store.Wallets().Get(ctx, unknown)
+	assert.ErrorIs(t, err, domain.ErrWalletNotFound)
+	err = store.Do(ctx, func(uow domain.UnitOfWork) error {
+		_, err := uow.Wallets().GetForUpdate(ctx, unknown)
+		return err
+	})
+	assert.ErrorIs(t, err, domain.ErrWalletNotFound)
+	assert.ErrorIs(t, store.Wallets().UpdateCreditLimit(ctx, unknown, decimal.Zero), domain.ErrWalletNotFound)
+	assert.ErrorIs(t, store.Wallets().UpdateTier(ctx, unknown, domain.TierBasic), domain.ErrWalletNotFound)
+	assert.ErrorIs(t, store.Wallets().UpdateStatus(ctx, unknown, domain.WalletFrozen), domain.ErrWalletNotFound)
+}
+
+func testWalletDetails(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	newWallet := func(externalID string) *domain.Wallet {
+		return &domain.Wallet{
+			ID:         uuid.New(),
+			Tier:       domain.DefaultTier,
+			Currency:   domain.DefaultCurrency,
+			Status:     domain.WalletActive,
+			ExternalID: externalID,
+		}
+	}
+
+	externalID := "customer-" + uuid.NewString()
+	wallet := newWallet(externalID)
+	wallet.Label = "Основной"
+	wallet.Metadata = map[string]any{"segment": "retail", "score": 7}
+	require.NoError(t, store.Wallets().Create(ctx, wallet))
+
+	got, err := store.Wallets().GetByExternalID(ctx, "", externalID)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.ID, got.ID)
+	assert.Equal(t, externalID, got.ExternalID)
+	assert.Equal(t, "Основной", got.Label)
+	assert.Equal(t, map[string]any{"segment": "retail", "score": float64(7)}, got.Metadata)
+
+	err = store.Wallets().Create(ctx, newWallet(externalID))
+	assert.ErrorIs(t, err, domain.ErrExternalIDExists)
+
+	// Внешний ID уникален в пределах клиента: другой клиент может использовать ту же ссылку
+	other := newWallet(externalID)
+	other.ClientID = "partner-" + uuid.NewString()
+	require.NoError(t, store.Wallets().Create(ctx, other))
+	got, err = store.Wallets().GetByExternalID(ctx, other.ClientID, externalID)
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, got.ID)
+	assert.Equal(t, other.ClientID, got.ClientID)
+	got, err = store.Wallets().GetByExternalID(ctx, "", externalID)
+	require.NoError(t, err)
+	assert.Equal(t, wallet.ID, got.ID)
+
+	// Кошельки без внешнего ID не конфликтуют друг с другом
+	plain := newWallet("")
+	require.NoError(t, store.Wallets().Create(ctx, plain))
+	require.NoError(t, store.Wallets().Create(ctx, newWallet("")))
+	got, err = store.Wallets().Get(ctx, plain.ID)
+	require.NoError(t, err)
+	assert.Empty(t, got.ExternalID)
+	assert.Empty(t, got.Label)
+	assert.Equal(t, map[string]any{}, got.Metadata)
+
+	require.NoError(t, store.Wallets().UpdateDetails(ctx, wallet.ID, "Накопления", map[string]any{"segment": "vip"}))
+	got, err = store.Wallets().Get(ctx, wallet.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Накопления", got.Label)
+	assert.Equal(t, map[string]any{"segment": "vip"}, got.Metadata)
+	assert.Equal(t, externalID, got.ExternalID)
+
+	_, err = store.Wallets().GetByExternalID(ctx, "", "customer-"+uuid.NewString())
+	assert.ErrorIs(t, err, domain.ErrWalletNotFound)
+	assert.ErrorIs(t, store.Wallets().UpdateDetails(ctx, uuid.New(), "", nil), domain.ErrWalletNotFound)
+
+	t.Run("Параллельное создание с одним внешним ID", func(t *testing.T) {
+		const workers = 10
+		externalID := "customer-" + uuid.NewString()
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- store.Do(ctx, func(uow domain.UnitOfWork) error {
+					return uow.Wallets().Create(ctx, newWallet(externalID))
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			if err == nil {
+				created++
+				continue
+			}
+			assert.ErrorIs(t, err, domain.ErrExternalIDExists)
+		}
+		assert.Equal(t, 1, created)
+	})
+}
+
+func testWalletList(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	// Метка отделяет кошельки теста от кошельков других тестов в том же хранилище
+	batch := uuid.NewString()
+	owner := "owner-" + batch
+	newWallet := func(balance int64, status domain.WalletStatus, currency, ownerID string, metadata map[string]any) *domain.Wallet {
+		metadata["batch"] = batch
+		wallet := &domain.Wallet{
+			ID:       uuid.New(),
+			Balance:  decimal.NewFromInt(balance),
+			Tier:     domain.DefaultTier,
+			Currency: currency,
+			Status:   status,
+			OwnerID:  ownerID,
+			Metadata: metadata,
+		}
+		require.NoError(t, store.Wallets().Create(ctx, wallet))
+		assert.False(t, wallet.CreatedAt.IsZero())
+		return wallet
+	}
+	wallets := []*domain.Wallet{
+		newWallet(30, domain.WalletActive, "RUB", owner, map[string]any{"segment": "retail", "tags": []any{"a", "b"}}),
+		newWallet(10, domain.WalletFrozen, "RUB", "", map[string]any{"segment": "vip"}),
+		newWallet(20, domain.WalletActive, "USD", owner, map[string]any{"segment": "retail", "manager": "ivanov"}),
+		newWallet(20, domain.WalletActive, "RUB", "", map[string]any{}),
+	}
+	inBatch := domain.WalletFilter{Metadata: map[string]any{"batch": batch}}
+	ids := func(list []domain.Wallet) []uuid.UUID {
+		result := make([]uuid.UUID, len(list))
+		for i, w := range list {
+			result[i] = w.ID
+		}
+		return result
+	}
+
+	t.Run("Сортировка по балансу с ID при равных значениях", func(t *testing.T) {
+		list, err := store.Wallets().List(ctx, domain.WalletListQuery{Filter: inBatch, Sort: domain.SortByBalance, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, list, 4)
+		assert.Equal(t, wallets[1].ID, list[0].ID)
+		assert.Equal(t, wallets[0].ID, list[3].ID)
+		sameBalance := []uuid.UUID{wallets[2].ID, wallets[3].ID}
+		if wallets[3].ID.String() < wallets[2].ID.String() {
+			sameBalance[0], sameBalance[1] = sameBalance[1], sameBalance[0]
+		}
+		assert.Equal(t, sameBalance, ids(list[1:3]))
+		assert.Equal(t, wallets[0].CreatedAt.UTC(), list[3].CreatedAt.UTC())
+	})
+
+	t.Run("Курсор продолжает выдачу после последнего кошелька", func(t *testing.T) {
+		for _, desc := range []bool{false, true} {
+			for _, sort := range []domain.WalletSort{domain.SortByBalance, domain.SortByCreatedAt} {
+				query := domain.WalletListQuery{Filter: inBatch, Sort: sort, Desc: desc, Limit: 10}
+				all, err := store.Wallets().List(ctx, query)
+				require.NoError(t, err)
+
+				var paged []domain.Wallet
+				query.Limit = 1
+				for range all {
+					page, err := store.Wallets().List(ctx, query)
+					require.NoError(t, err)
+					require.Len(t, page, 1)
+					paged = append(paged, page...)
+					query.After = domain.CursorAfter(&page[0], sort, desc)
+				}
+				rest, err := store.Wallets().List(ctx, query)
+				require.NoError(t, err)
+				assert.Empty(t, rest)
+				assert.Equal(t, ids(all), ids(paged), "sort %s desc %v", sort, desc)
+			}
+		}
+	})
+
+	minBalance, maxBalance := decimal.NewFromInt(15), decimal.NewFromInt(25)
+	tests := []struct {
+		name   string
+		filter domain.WalletFilter
+		want   []*domain.Wallet
+	}{
+		{name: "Статус", filter: domain.WalletFilter{Status: domain.WalletFrozen}, want: []*domain.Wallet{wallets[1]}},
+		{name: "Валюта", filter: domain.WalletFilter{Currency: "USD"}, want: []*domain.Wallet{wallets[2]}},
+		{name: "Владелец", filter: domain.WalletFilter{OwnerID: owner}, want: []*domain.Wallet{wallets[0], wallets[2]}},
+		{name: "Диапазон баланса", filter: domain.WalletFilter{MinBalance: &minBalance, MaxBalance: &maxBalance}, want: []*domain.Wallet{wallets[2], wallets[3]}},
+		{name: "Ключи метаданных", filter: domain.WalletFilter{MetadataKeys: []string{"segment", "manager"}}, want: []*domain.Wallet{wallets[2]}},
+		{name: "Содержимое метаданных", filter: domain.WalletFilter{Metadata: map[string]any{"segment": "retail"}}, want: []*domain.Wallet{wallets[0], wallets[2]}},
+		{name: "Элемент массива в метаданных", filter: domain.WalletFilter{Metadata: map[string]any{"tags": []any{"b"}}}, want: []*domain.Wallet{wallets[0]}},
+		{name: "Создан до момента", filter: domain.WalletFilter{CreatedTo: wallets[0].CreatedAt}, want: nil},
+		{name: "Создан после момента", filter: domain.WalletFilter{CreatedFrom: wallets[0].CreatedAt}, want: wallets},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			if filter.Metadata == nil {
+				filter.Metadata = map[string]any{}
+			}
+			filter.Metadata["batch"] = batch
+
+			list, err := store.Wallets().List(ctx, domain.WalletListQuery{Filter: filter, Sort: domain.SortByCreatedAt, Limit: 10})
+			require.NoError(t, err)
+			want := make([]uuid.UUID, len(tt.want))
+			for i, w := range tt.want {
+				want[i] = w.ID
+			}
+			assert.ElementsMatch(t, want, ids(list))
+
+			count, err := store.Wallets().Count(ctx, filter)
+			require.NoError(t, err)
+			assert.Equal(t, len(tt.want), count)
+		})
+	}
+}
+
+func testTransactions(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	wallet := createWallet(t, store)
+	deposit(t, store, wallet.ID, 100)
+
+	t.Run("Ошибка откатывает все изменения транзакции", func(t *testing.T) {
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			if err := uow.Wallets().UpdateBalance(ctx, wallet.ID, decimal.NewFromInt(500)); err != nil {
+				return err
+			}
+			got, err := uow.Wallets().Get(ctx, wallet.ID)
+			if err != nil {
+				return err
+			}
+			assertDecimal(t, "500", got.Balance)
+			if err := uow.Operations().Add(ctx, &domain.Operation{
+				ID: uuid.New(), CorrelationID: uuid.New(), WalletID: wallet.ID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(1),
+			}); err != nil {
+				return err
+			}
+			if err := uow.Audit().Add(ctx, &domain.AuditEntry{WalletID: wallet.ID, Action: domain.AuditTierChanged, Actor: "test"}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+
+		got, err := store.Wallets().Get(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "100", got.Balance)
+		ops, err := store.Operations().ListRecent(ctx, wallet.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, ops, 1)
+	})
+
+	t.Run("Несбалансированный журнал не коммитится", func(t *testing.T) {
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			cashIn, err := uow.Ledger().SystemAccountID(ctx, domain.CashIn, domain.DefaultCurrency)
+			if err != nil {
+				return err
+			}
+			journal := domain.NewJournal(uuid.New(), "unbalanced")
+			journal.Post(wallet.ID, decimal.NewFromInt(10), domain.DefaultCurrency)
+			journal.Post(cashIn, decimal.NewFromInt(-5), domain.DefaultCurrency)
+			return uow.Ledger().PostJournal(ctx, journal)
+		})
+		assert.Error(t, err)
+
+		balance, err := store.Ledger().AccountBalance(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "100", balance)
+	})
+	t.Run("Ошибка вложенной транзакции откатывает только ее изменения", func(t *testing.T) {
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			if err := depositIn(ctx, uow, wallet.ID, uuid.New(), 10); err != nil {
+				return err
+			}
+			err := uow.Do(ctx, func(nested domain.UnitOfWork) error {
+				if err := depositIn(ctx, nested, wallet.ID, uuid.New(), 1000); err != nil {
+					return err
+				}
+				return errRollback
+			})
+			assert.ErrorIs(t, err, errRollback)
+
+			got, err := uow.Wallets().Get(ctx, wallet.ID)
+			if err != nil {
+				return err
+			}
+			assertDecimal(t, "110", got.Balance)
+			return uow.Do(ctx, func(nested domain.UnitOfWork) error {
+				return depositIn(ctx, nested, wallet.ID, uuid.New(), 5)
+			})
+		})
+		require.NoError(t, err)
+
+		got, err := store.Wallets().Get(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "115", got.Balance)
+		balance, err := store.Ledger().AccountBalance(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "115", balance)
+		ops, err := store.Operations().ListRecent(ctx, wallet.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, ops, 3)
+	})
+
+	t.Run("Откат внешней транзакции отменяет и вложенную", func(t *testing.T) {
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			if err := uow.Do(ctx, func(nested domain.UnitOfWork) error {
+				return depositIn(ctx, nested, wallet.ID, uuid.New(), 50)
+			}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+
+		balance, err := store.Ledger().AccountBalance(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "115", balance)
+	})
+
+	t.Run("Запись в транзакции только для чтения отклоняется", func(t *testing.T) {
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			return depositIn(ctx, uow, wallet.ID, uuid.New(), 1)
+		}, domain.ReadOnly())
+		assert.ErrorIs(t, err, domain.ErrReadOnlyTransaction)
+
+		balance, err := store.Ledger().AccountBalance(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "115", balance)
+	})
+
+	t.Run("Repeatable read не видит изменений других транзакций", func(t *testing.T) {
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			before, err := uow.Wallets().Get(ctx, wallet.ID)
+			if err != nil {
+				return err
+			}
+			deposit(t, store, wallet.ID, 1)
+
+			after, err := uow.Wallets().Get(ctx, wallet.ID)
+			if err != nil {
+				return err
+			}
+			assertDecimal(t, before.Balance.String(), after.Balance)
+			return nil
+		}, domain.WithIsolation(domain.RepeatableRead), domain.ReadOnly())
+		require.NoError(t, err)
+
+		got, err := store.Wallets().Get(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "116", got.Balance)
+	})
+
+	t.Run("Serializable повторяется после ошибки сериализации", func(t *testing.T) {
+		attempts := 0
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			attempts++
+			if _, err := uow.Wallets().Get(ctx, wallet.ID); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				deposit(t, store, wallet.ID, 1)
+			}
+			return depositIn(ctx, uow, wallet.ID, uuid.New(), 10)
+		}, domain.WithIsolation(domain.Serializable))
+		require.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+
+		balance, err := store.Ledger().AccountBalance(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "127", balance)
+	})
+
+	t.Run("Позиция журнала после коммита", func(t *testing.T) {
+		var first, second string
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			return depositIn(ctx, uow, wallet.ID, uuid.New(), 1)
+		}, domain.WithCommitLSN(&first)))
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			return depositIn(ctx, uow, wallet.ID, uuid.New(), 1)
+		}, domain.WithCommitLSN(&second)))
+
+		assert.True(t, domain.ValidLSN(first), first)
+		assert.True(t, domain.ValidLSN(second), second)
+		assert.NotEqual(t, first, second)
+
+		// Без реплики чтение с позицией журнала выполняется на основной БД и видит обе записи
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			got, err := uow.Wallets().Get(ctx, wallet.ID)
+			if err != nil {
+				return err
+			}
+			assertDecimal(t, "129", got.Balance)
+			return nil
+		}, domain.ReadOnly(), domain.FromReplica(second))
+		require.NoError(t, err)
+	})
+}
+
+func testLocking(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	wallet := createWallet(t, store)
+
+	t.Run("Параллельные изменения не теряются", func(t *testing.T) {
+		const workers = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- store.Do(ctx, func(uow domain.UnitOfWork) error {
+					w, err := uow.Wallets().GetForUpdate(ctx, wallet.ID)
+					if err != nil {
+						return err
+					}
+					return uow.Wallets().UpdateBalance(ctx, wallet.ID, w.Balance.Add(decimal.NewFromInt(1)))
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		got, err := store.Wallets().Get(ctx, wallet.ID)
+		require.NoError(t, err)
+		assertDecimal(t, "20", got.Balance)
+	})
+
+	t.Run("Ожидание блокировки прерывается отменой контекста", func(t *testing.T) {
+		locked := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- store.Do(ctx, func(uow domain.UnitOfWork) error {
+				if _, err := uow.Wallets().GetForUpdate(ctx, wallet.ID); err != nil {
+					return err
+				}
+				close(locked)
+				<-release
+				return nil
+			})
+		}()
+		<-locked
+
+		waitCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		err := store.Do(waitCtx, func(uow domain.UnitOfWork) error {
+			_, err := uow.Wallets().GetForUpdate(waitCtx, wallet.ID)
+			return err
+		})
+		assert.Error(t, err)
+
+		close(release)
+		require.NoError(t, <-done)
+	})
+}
+
+func testOperations(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	wallet := createWallet(t, store)
+	for _, op := range []struct {
+		operationType domain.OperationType
+		amount        int64
+	}{
+		{domain.Deposit, 10}, {domain.Withdraw, 3}, {domain.Deposit, 5},
+	} {
+		require.NoError(t, store.Operations().Add(ctx, &domain.Operation{
+			ID: uuid.New(), CorrelationID: uuid.New(), WalletID: wallet.ID, OperationType: op.operationType, Amount: decimal.NewFromInt(op.amount),
+		}))
+	}
+
+	sum, err := store.Operations().SumSince(ctx, wallet.ID, []domain.OperationType{domain.Deposit}, time.Time{})
+	require.NoError(t, err)
+	assertDecimal(t, "15", sum)
+
+	ops, err := store.Operations().ListRecent(ctx, wallet.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, ops, 3)
+	for i := 1; i < len(ops); i++ {
+		assert.False(t, ops[i].CreatedAt.After(ops[i-1].CreatedAt), "операции должны идти от новых к старым")
+	}
+	ops, err = store.Operations().ListRecent(ctx, wallet.ID, 2)
+	require.NoError(t, err)
+	assert.Len(t, ops, 2)
+
+	assert.Error(t, store.Operations().Add(ctx, &domain.Operation{
+		ID: uuid.New(), CorrelationID: uuid.New(), WalletID: wallet.ID, OperationType: domain.Deposit, Amount: decimal.Zero,
+	}), "нулевая сумма")
+	assert.Error(t, store.Operations().Add(ctx, &domain.Operation{
+		ID: uuid.New(), CorrelationID: uuid.New(), WalletID: wallet.ID, OperationType: "UNKNOWN", Amount: decimal.NewFromInt(1),
+	}), "неизвестный тип операции")
+
+	entry := &domain.AuditEntry{WalletID: wallet.ID, Action: domain.AuditTierChanged, OldValue: "ANONYMOUS", NewValue: "BASIC", Actor: "test"}
+	require.NoError(t, store.Audit().Add(ctx, entry))
+	assert.NotZero(t, entry.ID)
+	assert.False(t, entry.CreatedAt.IsZero())
+}
+
+func testLimits(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	wallet := createWallet(t, store)
+
+	daily := decimal.NullDecimal{Decimal: decimal.NewFromInt(100), Valid: true}
+	require.NoError(t, store.Limits().SetForWallet(ctx, wallet.ID, domain.SpendingLimits{DailyWithdrawal: daily}))
+	limits, err := store.Limits().GetEffective(ctx, wallet.ID)
+	require.NoError(t, err)
+	require.True(t, limits.DailyWithdrawal.Valid)
+	assertDecimal(t, "100", limits.DailyWithdrawal.Decimal)
+
+	_, err = store.Limits().GetEffective(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrWalletNotFound)
+
+	rules, err := store.Tiers().GetRules(ctx, domain.TierVerified)
+	require.NoError(t, err)
+	assert.Equal(t, 3, rules.Rank)
+	_, err = store.Tiers().GetRules(ctx, "PLATINUM")
+	assert.ErrorIs(t, err, domain.ErrUnknownTier)
+}
+
+func testLedger(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour)
+	wallet := createWallet(t, store)
+
+	_, err := store.Ledger().SystemAccountID(ctx, domain.CashIn, "XXX")
+	assert.ErrorIs(t, err, domain.ErrSystemAccountNotFound)
+
+	first := deposit(t, store, wallet.ID, 100)
+	second := deposit(t, store, wallet.ID, 50)
+
+	balance, err := store.Ledger().AccountBalance(ctx, wallet.ID)
+	require.NoError(t, err)
+	assertDecimal(t, "150", balance)
+
+	var lines []domain.StatementLine
+	require.NoError(t, store.Ledger().StreamPostings(ctx, wallet.ID, created, time.Now().Add(time.Hour), func(line domain.StatementLine) error {
+		lines = append(lines, line)
+		return nil
+	}))
+	require.Len(t, lines, 2)
+	assert.Equal(t, first, lines[0].CorrelationID)
+	assert.Equal(t, second, lines[1].CorrelationID)
+	assert.Equal(t, string(domain.Deposit), lines[0].OperationType)
+	assertDecimal(t, "100", lines[0].Amount)
+	assertDecimal(t, "50", lines[1].Amount)
+
+	at, err := store.Ledger().BalanceAt(ctx, wallet.ID, lines[0].CreatedAt)
+	require.NoError(t, err)
+	assertDecimal(t, "100", at)
+	at, err = store.Ledger().BalanceAt(ctx, wallet.ID, created)
+	require.NoError(t, err)
+	assertDecimal(t, "0", at)
+
+	// Пояс со смещением, не кратным часу, как и UTC, раскладывает проводки по дням без потерь
+	kathmandu, err := time.LoadLocation("Asia/Kathmandu")
+	require.NoError(t, err)
+	for _, loc := range []*time.Location{time.UTC, kathmandu} {
+		changes, err := store.Ledger().DailyChanges(ctx, wallet.ID, created, time.Now().Add(time.Hour), loc)
+		require.NoError(t, err)
+		total := decimal.Zero
+		for i, change := range changes {
+			total = total.Add(change.Amount)
+			if i > 0 {
+				assert.True(t, changes[i-1].Date.Before(change.Date), "days are not ordered")
+			}
+		}
+		assertDecimal(t, "150", total)
+	}
+
+	balances, err := store.Ledger().WalletBalances(ctx, before(wallet.ID), 1)
+	require.NoError(t, err)
+	require.Len(t, balances, 1)
+	assert.Equal(t, wallet.ID, balances[0].WalletID)
+	assertDecimal(t, "150", balances[0].Stored)
+	assertDecimal(t, "150", balances[0].Ledger)
+
+	t.Run("Баланс на момент считается от снимка", func(t *testing.T) {
+		count, last, err := store.Ledger().CreateSnapshots(ctx, lines[1].CreatedAt, before(wallet.ID), 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, wallet.ID, last)
+
+		deposit(t, store, wallet.ID, 25)
+		at, err := store.Ledger().BalanceAt(ctx, wallet.ID, lines[1].CreatedAt)
+		require.NoError(t, err)
+		assertDecimal(t, "150", at)
+		at, err = store.Ledger().BalanceAt(ctx, wallet.ID, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assertDecimal(t, "175", at)
+	})
+
+	t.Run("Проводка задним числом учитывается на свою дату и в снимках", func(t *testing.T) {
+		cashIn, err := store.Ledger().SystemAccountID(ctx, domain.CashIn, wallet.Currency)
+		require.NoError(t, err)
+		occurredAt := created.Add(time.Minute).Truncate(time.Microsecond)
+		journal := domain.NewJournal(uuid.New(), string(domain.Deposit))
+		journal.CreatedAt = occurredAt
+		journal.Post(wallet.ID, decimal.NewFromInt(10), wallet.Currency)
+		journal.Post(cashIn, decimal.NewFromInt(-10), wallet.Currency)
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			return uow.Ledger().PostJournal(ctx, journal)
+		}))
+		assert.True(t, journal.CreatedAt.Equal(occurredAt))
+
+		at, err := store.Ledger().BalanceAt(ctx, wallet.ID, occurredAt)
+		require.NoError(t, err)
+		assertDecimal(t, "10", at)
+		at, err = store.Ledger().BalanceAt(ctx, wallet.ID, lines[1].CreatedAt)
+		require.NoError(t, err)
+		assertDecimal(t, "160", at)
+	})
+
+	t.Run("Проводка получает тип своей записи истории", func(t *testing.T) {
+		// Перевод на кошелек доходов с комиссией: две записи истории одного кошелька в одном журнале
+		revenue := createWallet(t, store)
+		cashIn, err := store.Ledger().SystemAccountID(ctx, domain.CashIn, revenue.Currency)
+		require.NoError(t, err)
+		journal := domain.NewJournal(uuid.New(), string(domain.Transfer))
+		operations := []*domain.Operation{
+			{ID: uuid.New(), CorrelationID: journal.CorrelationID, WalletID: revenue.ID, OperationType: domain.TransferIn, Amount: decimal.NewFromInt(90)},
+			{ID: uuid.New(), CorrelationID: journal.CorrelationID, WalletID: revenue.ID, OperationType: domain.Fee, Amount: decimal.NewFromInt(10)},
+		}
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			for _, op := range operations {
+				if err := uow.Operations().Add(ctx, op); err != nil {
+					return err
+				}
+				journal.PostOperation(op.ID, revenue.ID, op.Amount, revenue.Currency)
+			}
+			journal.Post(cashIn, decimal.NewFromInt(-100), revenue.Currency)
+			return uow.Ledger().PostJournal(ctx, journal)
+		}))
+
+		var lines []domain.StatementLine
+		require.NoError(t, store.Ledger().StreamPostings(ctx, revenue.ID, created, time.Now().Add(time.Hour), func(line domain.StatementLine) error {
+			lines = append(lines, line)
+			return nil
+		}))
+		require.Len(t, lines, 2)
+		assert.Equal(t, string(domain.TransferIn), lines[0].OperationType)
+		assertDecimal(t, "90", lines[0].Amount)
+		assert.Equal(t, string(domain.Fee), lines[1].OperationType)
+		assertDecimal(t, "10", lines[1].Amount)
+	})
+}
+
+func testImports(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	imp := &domain.Import{ID: uuid.New(), FileName: "ops.csv", Status: domain.ImportPending}
+	require.NoError(t, store.Imports().Create(ctx, imp))
+	assert.False(t, imp.CreatedAt.IsZero())
+
+	source := []*domain.ImportRow{
+		{Line: 2, WalletID: uuid.NewString(), OperationType: "DEPOSIT", Amount: "10", OccurredAt: "2025-01-10T12:00:00Z"},
+		{Line: 3, WalletID: "not-a-uuid", OperationType: "DEPOSIT", Amount: "10"},
+		{Line: 4, WalletID: uuid.NewString(), OperationType: "WITHDRAW", Amount: "5"},
+	}
+	next := 0
+	count, err := store.Imports().Stage(ctx, imp.ID, func() (*domain.ImportRow, error) {
+		if next == len(source) {
+			return nil, nil
+		}
+		next++
+		return source[next-1], nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	rows, err := store.Imports().PendingRows(ctx, imp.ID, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Equal(t, domain.ImportRowPending, rows[0].Status)
+	assert.Equal(t, "2025-01-10T12:00:00Z", rows[0].OccurredAt)
+
+	rows[0].Status, rows[0].OperationID = domain.ImportRowApplied, uuid.New()
+	require.NoError(t, store.Imports().MarkRow(ctx, &rows[0]))
+	rows[1].Status, rows[1].Error = domain.ImportRowFailed, "invalid wallet"
+	require.NoError(t, store.Imports().MarkRow(ctx, &rows[1]))
+	assert.Error(t, store.Imports().MarkRow(ctx, &domain.ImportRow{ImportID: imp.ID, Line: 99, Status: domain.ImportRowFailed}))
+	rows[0].Status = domain.ImportRowFailed
+	assert.ErrorIs(t, store.Imports().MarkRow(ctx, &rows[0]), domain.ErrImportRowProcessed)
+
+	rows, err = store.Imports().PendingRows(ctx, imp.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, 4, rows[0].Line)
+
+	// Захват: ожидающий импорт забирает только один обработчик, зависший - переходит к следующему
+	ids, err := store.Imports().Claimable(ctx, time.Now().Add(-time.Minute), 10)
+	require.NoError(t, err)
+	assert.Contains(t, ids, imp.ID)
+	claimed, err := store.Imports().Claim(ctx, imp.ID, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, domain.ImportRunning, claimed.Status)
+	require.NotNil(t, claimed.HeartbeatAt)
+	_, err = store.Imports().Claim(ctx, imp.ID, time.Now().Add(-time.Minute))
+	assert.ErrorIs(t, err, domain.ErrImportNotClaimable)
+	ids, err = store.Imports().Claimable(ctx, time.Now().Add(-time.Minute), 10)
+	require.NoError(t, err)
+	assert.NotContains(t, ids, imp.ID)
+	require.NoError(t, store.Imports().Heartbeat(ctx, imp.ID))
+	_, err = store.Imports().Claim(ctx, imp.ID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+
+	updated, err := store.Imports().SetStatus(ctx, imp.ID, domain.ImportCompleted, "")
+	require.NoError(t, err)
+	assert.Equal(t, domain.ImportCompleted, updated.Status)
+	assert.Equal(t, 3, updated.TotalRows)
+	assert.Equal(t, 1, updated.AppliedRows)
+	assert.Equal(t, 1, updated.FailedRows)
+	assert.NotNil(t, updated.FinishedAt)
+
+	var failed []domain.ImportRow
+	require.NoError(t, store.Imports().StreamFailedRows(ctx, imp.ID, func(row domain.ImportRow) error {
+		failed = append(failed, row)
+		return nil
+	}))
+	require.Len(t, failed, 1)
+	assert.Equal(t, 3, failed[0].Line)
+	assert.Equal(t, "invalid wallet", failed[0].Error)
+
+	_, err = store.Imports().Claim(ctx, imp.ID, time.Now().Add(time.Minute))
+	assert.ErrorIs(t, err, domain.ErrImportNotClaimable)
+	assert.ErrorIs(t, store.Imports().Heartbeat(ctx, imp.ID), domain.ErrImportNotClaimable)
+
+	_, err = store.Imports().Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrImportNotFound)
+}
+
+func testIdempotency(t *testing.T, store domain.UnitOfWork) {
+	ctx := context.Background()
+	fingerprint := sha256.Sum256([]byte("POST /api/v1/wallet"))
+	expiresAt := time.Now().Add(time.Hour)
+
+	t.Run("Результат сохраняется вместе с транзакцией", func(t *testing.T) {
+		key := uuid.NewString()
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			stored, err := uow.Idempotency().Acquire(ctx, key, fingerprint, expiresAt)
+			require.NoError(t, err)
+			assert.Nil(t, stored)
+			return uow.Idempotency().Save(ctx, key, []byte(`{"balance":"100"}`))
+		}))
+
+		stored, err := store.Idempotency().Acquire(ctx, key, sha256.Sum256(nil), expiresAt)
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, fingerprint, stored.Fingerprint)
+		assert.JSONEq(t, `{"balance":"100"}`, string(stored.Response))
+	})
+
+	t.Run("Откат освобождает ключ", func(t *testing.T) {
+		key := uuid.NewString()
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			_, err := uow.Idempotency().Acquire(ctx, key, fingerprint, expiresAt)
+			require.NoError(t, err)
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+
+		stored, err := store.Idempotency().Acquire(ctx, key, fingerprint, expiresAt)
+		require.NoError(t, err)
+		assert.Nil(t, stored)
+	})
+
+	t.Run("Повтор ждет транзакцию, занявшую ключ", func(t *testing.T) {
+		key := uuid.NewString()
+		acquired := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- store.Do(ctx, func(uow domain.UnitOfWork) error {
+				if _, err := uow.Idempotency().Acquire(ctx, key, fingerprint, expiresAt); err != nil {
+					return err
+				}
+				close(acquired)
+				<-release
+				return uow.Idempotency().Save(ctx, key, []byte(`{}`))
+			})
+		}()
+		<-acquired
+
+		replayed := make(chan *domain.IdempotentResponse, 1)
+		go func() {
+			stored, err := store.Idempotency().Acquire(ctx, key, fingerprint, expiresAt)
+			assert.NoError(t, err)
+			replayed <- stored
+		}()
+		select {
+		case <-replayed:
+			t.Fatal("key acquired while another transaction holds it")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		require.NoError(t, <-done)
+		stored := <-replayed
+		require.NotNil(t, stored)
+		assert.JSONEq(t, `{}`, string(stored.Response))
+	})
+
+	t.Run("Истекший ключ занимается заново и удаляется", func(t *testing.T) {
+		key := uuid.NewString()
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			if _, err := uow.Idempotency().Acquire(ctx, key, fingerprint, time.Now().Add(-time.Minute)); err != nil {
+				return err
+			}
+			return uow.Idempotency().Save(ctx, key, []byte(`{}`))
+		}))
+
+		purged, err := store.Idempotency().DeleteExpired(ctx, time.Now())
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, purged, 1)
+
+		require.NoError(t, store.Do(ctx, func(uow domain.UnitOfWork) error {
+			stored, err := uow.Idempotency().Acquire(ctx, key, fingerprint, time.Now().Add(-time.Minute))
+			assert.Nil(t, stored)
+			return err
+		}))
+		stored, err := store.Idempotency().Acquire(ctx, key, fingerprint, expiresAt)
+		require.NoError(t, err)
+		assert.Nil(t, stored, "expired key must be acquired again")
+	})
+}
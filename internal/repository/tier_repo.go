@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	getTierRulesQuery = `SELECT name, rank, max_balance, max_monthly_incoming FROM wallet_tiers WHERE name = $1;`
+)
+
+type TierRepo struct {
+	exec pgxExecutor
+	log  *zap.Logger
+}
+
+// GetRules возвращает ограничения уровня кошелька
+func (r *TierRepo) GetRules(ctx context.Context, tier domain.WalletTier) (domain.TierRules, error) {
+	var rules domain.TierRules
+	err := r.exec.QueryRow(ctx, getTierRulesQuery, tier).Scan(
+		&rules.Tier, &rules.Rank, &rules.MaxBalance, &rules.MaxMonthlyIncoming,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.TierRules{}, domain.ErrUnknownTier
+		}
+		return domain.TierRules{}, fmt.Errorf("failed to get tier rules: %w", err)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	// maxSerializationAttempts - сколько раз выполняется транзакция Serializable, прерываемая ошибками сериализации
+	maxSerializationAttempts = 5
+	serializationBaseBackoff = 10 * time.Millisecond
+
+	serializationFailureCode   = "40001"
+	readOnlySQLTransactionCode = "25006"
+)
+
+func txOptions(o domain.TxOptions) pgx.TxOptions {
+	options := pgx.TxOptions{IsoLevel: pgx.TxIsoLevel(o.Isolation)}
+	if o.ReadOnly {
+		options.AccessMode = pgx.ReadOnly
+	}
+	if o.Deferrable {
+		options.DeferrableMode = pgx.Deferrable
+	}
+	return options
+}
+
+// txError сопоставляет ошибки PostgreSQL, зависящие от параметров транзакции, с ошибками домена
+func txError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	switch pgErr.Code {
+	case serializationFailureCode:
+		return fmt.Errorf("%w: %w", domain.ErrSerializationFailure, err)
+	case readOnlySQLTransactionCode:
+		return fmt.Errorf("%w: %w", domain.ErrReadOnlyTransaction, err)
+	default:
+		return err
+	}
+}
+
+// serializationBackoff растет с каждой попыткой; случайная добавка разводит конфликтующие транзакции
+func serializationBackoff(attempt int) time.Duration {
+	backoff := serializationBaseBackoff << (attempt - 1)
+	return backoff + rand.N(backoff)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -5,35 +5,51 @@ import (
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"strconv"
+	"strings"
 
 	"testtask/internal/domain"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/shopspring/decimal"
 )
 
 const (
-	getBalanceForUpdateQuery = `SELECT balance FROM wallets WHERE id = $1 FOR UPDATE;`
-	updateBalanceQuery       = `UPDATE wallets SET balance = $1 WHERE id = $2;`
-	getBalanceQuery          = `SELECT balance FROM wallets WHERE id = $1;`
-	createWalletQuery        = `INSERT INTO wallets (id, balance) VALUES ($1, $2);`
-)
+	walletColumns = `id, balance, credit_limit, tier, currency, status, client_id, COALESCE(external_id, ''), COALESCE(owner_id, ''),
+		label, metadata, created_at`
 
-// GetBalanceForUpdate получает баланс кошелька, используя пессимистическую блокировку
-func (r *WalletRepo) GetBalanceForUpdate(ctx context.Context, id uuid.UUID) (decimal.Decimal, error) {
+	getWalletForUpdateQuery    = `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1 FOR UPDATE;`
+	updateBalanceQuery         = `UPDATE wallets SET balance = $1 WHERE id = $2;`
+	updateCreditLimitQuery     = `UPDATE wallets SET credit_limit = $1 WHERE id = $2;`
+	updateTierQuery            = `UPDATE wallets SET tier = $1 WHERE id = $2;`
+	updateStatusQuery          = `UPDATE wallets SET status = $1 WHERE id = $2;`
+	updateDetailsQuery         = `UPDATE wallets SET label = $1, metadata = $2 WHERE id = $3;`
+	getWalletQuery             = `SELECT ` + walletColumns + ` FROM wallets WHERE id = $1;`
+	getWalletByExternalIDQuery = `SELECT ` + walletColumns + ` FROM wallets WHERE client_id = $1 AND external_id = $2;`
+	createWalletQuery          = `INSERT INTO wallets (id, balance, credit_limit, tier, currency, status, client_id, external_id, owner_id, label, metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), $10, $11)
+		RETURNING created_at;`
+	listWalletsQuery  = `SELECT ` + walletColumns + ` FROM wallets`
+	countWalletsQuery = `SELECT COUNT(*) FROM wallets`
+)
 
-	var balance decimal.Decimal
-	err := r.exec.QueryRow(ctx, getBalanceForUpdateQuery, id).Scan(&balance)
+// walletSortColumns - колонки ключей сортировки списка кошельков
+var walletSortColumns = map[domain.WalletSort]string{
+	domain.SortByCreatedAt: "created_at",
+	domain.SortByBalance:   "balance",
+}
 
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return decimal.Zero, domain.ErrWalletNotFound
-		}
-		return decimal.Zero, err
-	}
+const (
+	uniqueViolationCode = "23505"
+	// externalIDConstraint - уникальный индекс внешних ID кошельков клиента
+	externalIDConstraint = "wallets_client_id_external_id_key"
+)
 
-	return balance, nil
+// GetForUpdate получает кошелек, используя пессимистическую блокировку
+func (r *WalletRepo) GetForUpdate(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	return r.getWallet(ctx, getWalletForUpdateQuery, id)
 }
 
 // UpdateBalance обновляет баланс кошелька
@@ -49,33 +65,212 @@ func (r *WalletRepo) UpdateBalance(ctx context.Context, id uuid.UUID, newBalance
 	return nil
 }
 
-// GetBalance получает текущий баланс без блокировки
-func (r *WalletRepo) GetBalance(ctx context.Context, id uuid.UUID) (decimal.Decimal, error) {
-	var balance decimal.Decimal
-	err := r.exec.QueryRow(ctx, getBalanceQuery, id).Scan(&balance)
+// UpdateCreditLimit обновляет кредитный лимит кошелька
+func (r *WalletRepo) UpdateCreditLimit(ctx context.Context, id uuid.UUID, creditLimit decimal.Decimal) error {
+	cmdTag, err := r.exec.Exec(ctx, updateCreditLimitQuery, creditLimit, id)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() != 1 {
+		return domain.ErrWalletNotFound
+	}
+
+	return nil
+}
 
+// UpdateTier переводит кошелек на другой уровень
+func (r *WalletRepo) UpdateTier(ctx context.Context, id uuid.UUID, tier domain.WalletTier) error {
+	cmdTag, err := r.exec.Exec(ctx, updateTierQuery, tier, id)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() != 1 {
+		return domain.ErrWalletNotFound
+	}
+
+	return nil
+}
+
+// UpdateStatus замораживает или размораживает кошелек
+func (r *WalletRepo) UpdateStatus(ctx context.Context, id uuid.UUID, status domain.WalletStatus) error {
+	cmdTag, err := r.exec.Exec(ctx, updateStatusQuery, status, id)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() != 1 {
+		return domain.ErrWalletNotFound
+	}
+
+	return nil
+}
+
+// UpdateDetails заменяет название и метаданные кошелька
+func (r *WalletRepo) UpdateDetails(ctx context.Context, id uuid.UUID, label string, metadata map[string]any) error {
+	cmdTag, err := r.exec.Exec(ctx, updateDetailsQuery, label, metadataValue(metadata), id)
+	if err != nil {
+		return err
+	}
+	if cmdTag.RowsAffected() != 1 {
+		return domain.ErrWalletNotFound
+	}
+
+	return nil
+}
+
+// Get получает кошелек без блокировки
+func (r *WalletRepo) Get(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	return r.getWallet(ctx, getWalletQuery, id)
+}
+
+// GetByExternalID получает кошелек клиента по внешнему ID без блокировки
+func (r *WalletRepo) GetByExternalID(ctx context.Context, clientID, externalID string) (*domain.Wallet, error) {
+	return r.getWallet(ctx, getWalletByExternalIDQuery, clientID, externalID)
+}
+
+func (r *WalletRepo) getWallet(ctx context.Context, query string, args ...any) (*domain.Wallet, error) {
+	wallet, err := scanWallet(r.exec.QueryRow(ctx, query, args...))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return decimal.Zero, domain.ErrWalletNotFound
+			return nil, domain.ErrWalletNotFound
+		}
+		return nil, err
+	}
+
+	return &wallet, nil
+}
+
+func scanWallet(row pgx.Row) (domain.Wallet, error) {
+	var wallet domain.Wallet
+	err := row.Scan(&wallet.ID, &wallet.Balance, &wallet.CreditLimit, &wallet.Tier, &wallet.Currency, &wallet.Status,
+		&wallet.ClientID, &wallet.ExternalID, &wallet.OwnerID, &wallet.Label, &wallet.Metadata, &wallet.CreatedAt)
+	return wallet, err
+}
+
+// List возвращает страницу кошельков по фильтру. Страницы читаются по курсору: условие (ключ, id) > (значение, id)
+// использует индекс ключа сортировки, поэтому дальние страницы читаются так же быстро, как первая
+func (r *WalletRepo) List(ctx context.Context, query domain.WalletListQuery) ([]domain.Wallet, error) {
+	column, ok := walletSortColumns[query.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown wallet sort %q", query.Sort)
+	}
+	order, compare := "ASC", ">"
+	if query.Desc {
+		order, compare = "DESC", "<"
+	}
+
+	var args queryArgs
+	conditions := walletConditions(query.Filter, &args)
+	if after := query.After; after != nil {
+		var value any = after.CreatedAt
+		if query.Sort == domain.SortByBalance {
+			value = after.Balance
 		}
-		return decimal.Zero, err
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s (%s, %s)", column, compare, args.add(value), args.add(after.ID)))
+	}
+	sql := listWalletsQuery + where(conditions) +
+		fmt.Sprintf(" ORDER BY %s %s, id %s LIMIT %s;", column, order, order, args.add(query.Limit))
+
+	rows, err := r.exec.Query(ctx, sql, args...)
+	if err != nil {
+		r.log.Error("Failed to query wallets", zap.Error(err))
+		return nil, fmt.Errorf("failed to query wallets: %w", err)
+	}
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.Wallet, error) {
+		return scanWallet(row)
+	})
+}
+
+// Count возвращает количество кошельков по фильтру
+func (r *WalletRepo) Count(ctx context.Context, filter domain.WalletFilter) (int, error) {
+	var args queryArgs
+	sql := countWalletsQuery + where(walletConditions(filter, &args)) + ";"
+
+	var count int
+	if err := r.exec.QueryRow(ctx, sql, args...).Scan(&count); err != nil {
+		r.log.Error("Failed to count wallets", zap.Error(err))
+		return 0, fmt.Errorf("failed to count wallets: %w", err)
+	}
+	return count, nil
+}
+
+// walletConditions переводит фильтр в условия WHERE, добавляя значения в args
+func walletConditions(filter domain.WalletFilter, args *queryArgs) []string {
+	var conditions []string
+	if filter.Status != "" {
+		conditions = append(conditions, "status = "+args.add(filter.Status))
+	}
+	if filter.ClientID != "" {
+		conditions = append(conditions, "client_id = "+args.add(filter.ClientID))
+	}
+	if filter.ExternalID != "" {
+		conditions = append(conditions, "external_id = "+args.add(filter.ExternalID))
+	}
+	if filter.OwnerID != "" {
+		conditions = append(conditions, "owner_id = "+args.add(filter.OwnerID))
+	}
+	if filter.Currency != "" {
+		conditions = append(conditions, "currency = "+args.add(filter.Currency))
+	}
+	if filter.MinBalance != nil {
+		conditions = append(conditions, "balance >= "+args.add(*filter.MinBalance))
+	}
+	if filter.MaxBalance != nil {
+		conditions = append(conditions, "balance <= "+args.add(*filter.MaxBalance))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "created_at >= "+args.add(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "created_at < "+args.add(filter.CreatedTo))
+	}
+	if len(filter.MetadataKeys) > 0 {
+		conditions = append(conditions, "metadata ?& "+args.add(filter.MetadataKeys)+"::text[]")
+	}
+	if len(filter.Metadata) > 0 {
+		conditions = append(conditions, "metadata @> "+args.add(filter.Metadata)+"::jsonb")
 	}
+	return conditions
+}
+
+func where(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
+// queryArgs накапливает параметры запроса, собираемого из частей
+type queryArgs []any
 
-	return balance, nil
+// add добавляет значение и возвращает ссылку на него в тексте запроса
+func (a *queryArgs) add(value any) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
 }
 
 // Create создает новый кошелек в базе данных.
 func (r *WalletRepo) Create(ctx context.Context, wallet *domain.Wallet) error {
-	r.log.Debug("Executing create wallet query", zap.String("id", wallet.ID.String()))
+	r.log.Debug("Executing create wallet query", zap.Stringer("wallet_id", wallet.ID))
 
-	cmdTag, err := r.exec.Exec(ctx, createWalletQuery, wallet.ID, wallet.Balance)
+	err := r.exec.QueryRow(ctx, createWalletQuery, wallet.ID, wallet.Balance, wallet.CreditLimit, wallet.Tier, wallet.Currency, wallet.Status,
+		wallet.ClientID, wallet.ExternalID, wallet.OwnerID, wallet.Label, metadataValue(wallet.Metadata),
+	).Scan(&wallet.CreatedAt)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode && pgErr.ConstraintName == externalIDConstraint {
+			return fmt.Errorf("%w: %w", domain.ErrExternalIDExists, err)
+		}
 		r.log.Error("Failed to execute insert query for new wallet", zap.Error(err))
 		return fmt.Errorf("failed to execute insert query for new wallet: %w", err)
 	}
-	if cmdTag.RowsAffected() != 1 {
-		return errors.New("failed to create wallet: no rows affected")
-	}
 
 	return nil
 }
+
+// metadataValue заменяет nil пустым объектом: колонка metadata не допускает NULL
+func metadataValue(metadata map[string]any) map[string]any {
+	if metadata == nil {
+		return map[string]any{}
+	}
+	return metadata
+}
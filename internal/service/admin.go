@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultRecentOperations - сколько последних операций возвращается, если лимит не задан
+const DefaultRecentOperations = 20
+
+// checkActive запрещает движение денег по замороженному кошельку
+func (s *WalletService) checkActive(wallet *domain.Wallet) error {
+	if wallet.Status == domain.WalletFrozen {
+		s.log.Warn("Wallet is frozen", zap.Stringer("wallet_id", wallet.ID))
+		return domain.ErrWalletFrozen
+	}
+	return nil
+}
+
+// SetWalletStatus замораживает или размораживает кошелек и записывает изменение в журнал аудита.
+// Повторная установка того же состояния ничего не меняет
+func (s *WalletService) SetWalletStatus(ctx context.Context, id uuid.UUID, status domain.WalletStatus, actor string, reason string) (*domain.Wallet, error) {
+	s.log.Debug("Set wallet status", zap.Stringer("wallet_id", id), zap.String("status", string(status)), zap.String("actor", actor))
+	if id == uuid.Nil {
+		return nil, domain.ErrIDIsNil
+	}
+	if reason == "" {
+		return nil, domain.ErrReasonRequired
+	}
+	action := domain.AuditWalletFrozen
+	if status == domain.WalletActive {
+		action = domain.AuditWalletUnfrozen
+	}
+
+	var updated *domain.Wallet
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		wallet, err := uow.Wallets().GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		updated = wallet
+		if wallet.Status == status {
+			return nil
+		}
+
+		if err := uow.Wallets().UpdateStatus(ctx, id, status); err != nil {
+			return err
+		}
+		if err := uow.Audit().Add(ctx, &domain.AuditEntry{
+			WalletID: id,
+			Action:   action,
+			OldValue: string(wallet.Status),
+			NewValue: string(status),
+			Actor:    actor,
+			Reason:   reason,
+		}); err != nil {
+			return err
+		}
+
+		wallet.Status = status
+		return nil
+	})
+	if err != nil {
+		s.log.Error("Failed to set wallet status", zap.Error(err), zap.Stringer("wallet_id", id))
+		return nil, err
+	}
+
+	s.log.Info("Wallet status set", zap.Stringer("wallet_id", id), zap.String("status", string(status)), zap.String("actor", actor))
+	return updated, nil
+}
+
+// Adjust вручную корректирует баланс кошелька на amount: положительная сумма зачисляется, отрицательная списывается.
+// Корректировка проводится против системного счета корректировок, не учитывается в лимитах
+// и разрешена для замороженных кошельков; причина и автор сохраняются в журнале аудита
+func (s *WalletService) Adjust(ctx context.Context, id uuid.UUID, amount decimal.Decimal, actor string, reason string) (*domain.OperationResult, error) {
+	s.log.Debug("Adjust balance", zap.Stringer("wallet_id", id), zap.String("amount", amount.String()), zap.String("actor", actor))
+	if id == uuid.Nil {
+		return nil, domain.ErrIDIsNil
+	}
+	if amount.IsZero() {
+		return nil, domain.ErrAdjustmentZero
+	}
+	if reason == "" {
+		return nil, domain.ErrReasonRequired
+	}
+
+	operationType := domain.AdjustmentIn
+	if amount.IsNegative() {
+		operationType = domain.AdjustmentOut
+	}
+	result := &domain.OperationResult{
+		ID:            uuid.New(),
+		WalletID:      id,
+		OperationType: operationType,
+		Gross:         amount.Abs(),
+		Fee:           decimal.Zero,
+		Net:           amount.Abs(),
+	}
+
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		wallet, err := uow.Wallets().GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		newBalance := wallet.Balance.Add(amount)
+		if newBalance.Add(wallet.CreditLimit).IsNegative() {
+			s.log.Warn("Adjustment exceeds available funds", zap.Stringer("wallet_id", id),
+				zap.String("balance", wallet.Balance.String()), zap.String("amount", amount.String()))
+			return &domain.InsufficientFundsError{Available: wallet.Available(), Amount: amount.Neg()}
+		}
+
+		oldBalance := wallet.Balance
+		journal := domain.NewJournal(result.ID, reason)
+		if err := s.record(ctx, uow, journal, wallet, amount.Abs(), operationType, newBalance); err != nil {
+			return err
+		}
+		if err := s.postSystem(ctx, uow, journal, domain.Adjustments, amount.Neg(), wallet.Currency); err != nil {
+			return err
+		}
+		if err := s.postJournal(ctx, uow, journal); err != nil {
+			return err
+		}
+
+		result.Balance = wallet.Balance
+		return uow.Audit().Add(ctx, &domain.AuditEntry{
+			WalletID: id,
+			Action:   domain.AuditAdjustment,
+			OldValue: oldBalance.String(),
+			NewValue: newBalance.String(),
+			Actor:    actor,
+			Reason:   reason,
+		})
+	})
+	if err != nil {
+		s.log.Error("Failed to adjust balance", zap.Error(err), zap.Stringer("wallet_id", id))
+		return nil, err
+	}
+
+	s.log.Info("Wallet balance adjusted", zap.Stringer("wallet_id", id), zap.String("amount", amount.String()), zap.String("actor", actor))
+	return result, nil
+}
+
+// ListOperations возвращает последние операции кошелька; для uuid.Nil - последние операции по всем кошелькам
+func (s *WalletService) ListOperations(ctx context.Context, walletID uuid.UUID, limit int) ([]domain.Operation, error) {
+	if limit <= 0 {
+		limit = DefaultRecentOperations
+	}
+	return s.store(ctx).Operations().ListRecent(ctx, walletID, limit)
+}
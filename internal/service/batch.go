@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMaxBatchSize - максимальное число операций в пакете по умолчанию
+const DefaultMaxBatchSize = 1000
+
+// WithMaxBatchSize задает максимальное число операций в пакете
+func WithMaxBatchSize(size int) Option {
+	return func(s *WalletService) {
+		s.maxBatchSize = size
+	}
+}
+
+// PerformBatch исполняет пакет операций. В режиме atomic все операции выполняются в одной транзакции
+// и при любой ошибке пакет откатывается целиком; в режиме independent каждая операция выполняется
+// в своей транзакции, а ошибки возвращаются в результатах по отдельным операциям
+func (s *WalletService) PerformBatch(ctx context.Context, mode domain.BatchMode, reqs []domain.OperationRequest) ([]domain.BatchItemResult, error) {
+	s.log.Debug("Perform batch", zap.String("mode", string(mode)), zap.Int("size", len(reqs)))
+	if len(reqs) == 0 {
+		return nil, domain.ErrBatchEmpty
+	}
+	if len(reqs) > s.maxBatchSize {
+		s.log.Warn("Batch is too large", zap.Int("size", len(reqs)), zap.Int("max", s.maxBatchSize))
+		return nil, domain.ErrBatchTooLarge
+	}
+
+	switch mode {
+	case domain.BatchAtomic:
+		return s.performAtomicBatch(ctx, reqs)
+	case domain.BatchIndependent:
+		return s.performIndependentBatch(ctx, reqs), nil
+	default:
+		s.log.Warn("Unknown batch mode", zap.String("mode", string(mode)))
+		return nil, domain.ErrUnknownBatchMode
+	}
+}
+
+func (s *WalletService) performAtomicBatch(ctx context.Context, reqs []domain.OperationRequest) ([]domain.BatchItemResult, error) {
+	var invalid []domain.BatchItemError
+	var ids []uuid.UUID
+	for i, req := range reqs {
+		if err := s.validateOperation(req); err != nil {
+			invalid = append(invalid, domain.BatchItemError{Index: i, Err: err})
+			continue
+		}
+		ids = append(ids, s.walletsToLock(req)...)
+	}
+	if len(invalid) > 0 {
+		return nil, &domain.BatchValidationError{Items: invalid}
+	}
+
+	results := make([]domain.BatchItemResult, len(reqs))
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		if replayed, err := s.replay(ctx, uow, &results); err != nil || replayed {
+			return err
+		}
+		// Все кошельки пакета блокируются заранее и в одном порядке,
+		// поэтому пакеты с пересекающимися кошельками не могут взаимно заблокироваться
+		wallets, err := s.lockWallets(ctx, uow, ids)
+		if err != nil {
+			return err
+		}
+
+		for i, req := range reqs {
+			result, err := s.apply(ctx, uow, wallets, req)
+			if err != nil {
+				s.log.Warn("Batch operation failed, rolling back batch", zap.Int("index", i), zap.Error(err))
+				return &domain.BatchItemError{Index: i, Err: err}
+			}
+			results[i] = domain.BatchItemResult{Index: i, Result: result}
+		}
+		return s.remember(ctx, uow, results)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// performIndependentBatch исполняет операции по одной. С ключом идемпотентности каждая операция сохраняет
+// результат по собственному ключу, поэтому повтор пакета не выполнит заново уже примененные операции
+func (s *WalletService) performIndependentBatch(ctx context.Context, reqs []domain.OperationRequest) []domain.BatchItemResult {
+	key, idempotent := domain.IdempotencyKeyFromContext(ctx)
+	results := make([]domain.BatchItemResult, len(reqs))
+	for i, req := range reqs {
+		opCtx := ctx
+		var item *domain.IdempotencyKey
+		if idempotent {
+			item = key.Item(i)
+			opCtx = domain.ContextWithIdempotencyKey(ctx, item)
+		}
+		result, err := s.PerformOperation(opCtx, req)
+		if item != nil && item.Replayed {
+			key.Replayed = true
+		}
+		results[i] = domain.BatchItemResult{Index: i, Result: result, Err: err}
+	}
+	return results
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"os"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// calculateFee рассчитывает комиссию операции по расписанию для уровня кошелька-плательщика.
+// С кошелька доходов комиссия не удерживается
+func (s *WalletService) calculateFee(ctx context.Context, uow domain.UnitOfWork, wallet *domain.Wallet, req domain.OperationRequest) (decimal.Decimal, error) {
+	if wallet.ID == s.revenueWalletID {
+		return decimal.Zero, nil
+	}
+
+	schedule := s.feeSchedule
+	if schedule == nil {
+		schedule = uow.Fees()
+	}
+
+	rule, err := schedule.FindRule(ctx, req.OperationType, wallet.Tier)
+	if err != nil {
+		s.log.Error("Failed to find fee rule", zap.Object("req", req), zap.Error(err))
+		return decimal.Zero, fmt.Errorf("failed to find fee rule: %w", err)
+	}
+	if rule == nil {
+		return decimal.Zero, nil
+	}
+
+	fee := rule.Calculate(req.Amount)
+	if !fee.IsPositive() {
+		return decimal.Zero, nil
+	}
+	if fee.GreaterThanOrEqual(req.Amount) {
+		s.log.Warn("Fee exceeds operation amount", zap.String("fee", fee.String()), zap.Object("req", req))
+		return decimal.Zero, domain.ErrFeeExceedsAmount
+	}
+	if s.revenueWalletID == uuid.Nil {
+		s.log.Error("Fee is charged but revenue wallet is not configured", zap.String("fee", fee.String()), zap.Object("req", req))
+		return decimal.Zero, domain.ErrRevenueWalletNotConfigured
+	}
+
+	return fee, nil
+}
+
+// collectFee зачисляет удержанную комиссию на кошелек доходов отдельной записью истории
+func (s *WalletService) collectFee(ctx context.Context, uow domain.UnitOfWork, journal *domain.Journal, payer *domain.Wallet, wallets map[uuid.UUID]*domain.Wallet, fee decimal.Decimal) error {
+	if !fee.IsPositive() {
+		return nil
+	}
+
+	revenue := wallets[s.revenueWalletID]
+	if revenue.Currency != payer.Currency {
+		s.log.Error("Revenue wallet currency does not match payer", zap.String("currency", payer.Currency),
+			zap.String("revenue_currency", revenue.Currency))
+		return domain.ErrCurrencyMismatch
+	}
+	return s.record(ctx, uow, journal, revenue, fee, domain.Fee, revenue.Balance.Add(fee))
+}
+
+// StaticFeeSchedule - расписание комиссий, загруженное из конфигурации
+type StaticFeeSchedule []domain.FeeRule
+
+// FindRule возвращает правило для уровня кошелька, а при его отсутствии - общее правило для типа операции
+func (s StaticFeeSchedule) FindRule(_ context.Context, operationType domain.OperationType, tier domain.WalletTier) (*domain.FeeRule, error) {
+	var fallback *domain.FeeRule
+	for i := range s {
+		rule := &s[i]
+		if rule.OperationType != operationType {
+			continue
+		}
+		if rule.Tier == tier {
+			return rule, nil
+		}
+		if rule.Tier == "" {
+			fallback = rule
+		}
+	}
+	return fallback, nil
+}
+
+type feeRuleFile struct {
+	OperationType string              `json:"operation_type"`
+	Tier          string              `json:"tier"`
+	Fixed         decimal.Decimal     `json:"fixed"`
+	Percent       decimal.Decimal     `json:"percent"`
+	Min           decimal.NullDecimal `json:"min"`
+	Max           decimal.NullDecimal `json:"max"`
+}
+
+// LoadFeeScheduleFile читает расписание комиссий из JSON-файла со списком правил
+func LoadFeeScheduleFile(path string) (StaticFeeSchedule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fee schedule file: %w", err)
+	}
+
+	var rules []feeRuleFile
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse fee schedule file: %w", err)
+	}
+
+	schedule := make(StaticFeeSchedule, 0, len(rules))
+	for i, r := range rules {
+		operationType := domain.OperationType(r.OperationType)
+		if operationType != domain.Withdraw && operationType != domain.Transfer {
+			return nil, fmt.Errorf("fee rule %d: %w: %s", i, domain.ErrUnknownOperationType, r.OperationType)
+		}
+		if r.Fixed.IsNegative() || r.Percent.IsNegative() || (r.Min.Valid && r.Min.Decimal.IsNegative()) ||
+			(r.Max.Valid && r.Max.Decimal.IsNegative()) {
+			return nil, fmt.Errorf("fee rule %d: fee components must be non-negative", i)
+		}
+		if r.Min.Valid && r.Max.Valid && r.Max.Decimal.LessThan(r.Min.Decimal) {
+			return nil, fmt.Errorf("fee rule %d: max is less than min", i)
+		}
+		schedule = append(schedule, domain.FeeRule{
+			OperationType: operationType,
+			Tier:          domain.WalletTier(r.Tier),
+			Fixed:         r.Fixed,
+			Percent:       r.Percent,
+			Min:           r.Min,
+			Max:           r.Max,
+		})
+	}
+
+	return schedule, nil
+}
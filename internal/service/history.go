@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// snapshotSafetyLag - насколько момент снимка отстает от текущего времени.
+	// Проводка получает created_at в начале транзакции, поэтому снимок делается только для моментов,
+	// после которых уже не может закоммититься проводка с более ранним временем
+	snapshotSafetyLag = time.Hour
+	snapshotPageSize  = 1000
+	dateLayout        = "2006-01-02"
+)
+
+// BalanceAt возвращает баланс кошелька на момент at по журналу проводок
+func (s *WalletService) BalanceAt(ctx context.Context, id uuid.UUID, at time.Time) (*domain.Wallet, decimal.Decimal, error) {
+	s.log.Debug("Get balance at time", zap.Stringer("wallet_id", id), zap.Time("at", at))
+	var wallet *domain.Wallet
+	var balance decimal.Decimal
+	err := s.read(ctx, func(ctx context.Context, uow domain.UnitOfWork) (err error) {
+		wallet, err = uow.Wallets().Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		balance, err = uow.Ledger().BalanceAt(ctx, id, at)
+		return err
+	})
+	if err != nil {
+		return nil, decimal.Zero, err
+	}
+
+	return wallet, balance, nil
+}
+
+// BalanceSeries возвращает балансы кошелька на конец каждого дня с from по to включительно в часовом поясе loc.
+// Баланс на начало периода берется из журнала, дальше к нему добавляются суммы проводок по дням
+func (s *WalletService) BalanceSeries(ctx context.Context, id uuid.UUID, from, to time.Time, loc *time.Location) ([]domain.BalancePoint, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	if !start.Before(end) {
+		return nil, domain.ErrInvalidTimeRange
+	}
+	// Сутки в поясе loc длятся не ровно 24 часа при переходе на летнее время и обратно. Сдвиги за период
+	// намного меньше 12 часов, поэтому длина периода, округленная до суток, дает точное число дней
+	// без обхода всего периода. Sub не переполняется на огромных периодах, а возвращает максимальную длительность
+	span := end.Sub(start)
+	if span >= (domain.MaxBalanceSeriesDays*24+12)*time.Hour {
+		return nil, domain.ErrTimeRangeTooLarge
+	}
+	days := int((span + 12*time.Hour) / (24 * time.Hour))
+
+	s.log.Debug("Get balance series", zap.Stringer("wallet_id", id), zap.Time("from", start), zap.Time("to", end))
+	var balance decimal.Decimal
+	var changes []domain.DailyChange
+	err := s.read(ctx, func(ctx context.Context, uow domain.UnitOfWork) (err error) {
+		if _, err := uow.Wallets().Get(ctx, id); err != nil {
+			return err
+		}
+
+		ledger := uow.Ledger()
+		// Проводки хранятся с точностью до микросекунды, поэтому это последний момент перед началом периода
+		balance, err = ledger.BalanceAt(ctx, id, start.Add(-time.Microsecond))
+		if err != nil {
+			return err
+		}
+		changes, err = ledger.DailyChanges(ctx, id, start, end, loc)
+		return err
+	}, domain.WithIsolation(domain.RepeatableRead))
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]decimal.Decimal, len(changes))
+	for _, change := range changes {
+		byDay[change.Date.Format(dateLayout)] = change.Amount
+	}
+
+	points := make([]domain.BalancePoint, 0, days)
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		balance = balance.Add(byDay[day.Format(dateLayout)])
+		points = append(points, domain.BalancePoint{Date: day, Balance: balance})
+	}
+
+	return points, nil
+}
+
+// TakeBalanceSnapshots сохраняет снимки балансов всех кошельков на момент at, обходя кошельки страницами
+func (s *WalletService) TakeBalanceSnapshots(ctx context.Context, at time.Time) (int, error) {
+	s.log.Info("Taking balance snapshots", zap.Time("at", at))
+
+	total := 0
+	after := uuid.Nil
+	for {
+		count, last, err := s.store(ctx).Ledger().CreateSnapshots(ctx, at, after, snapshotPageSize)
+		if err != nil {
+			return total, err
+		}
+		total += count
+		if count < snapshotPageSize {
+			break
+		}
+		after = last
+	}
+
+	s.log.Info("Balance snapshots taken", zap.Time("at", at), zap.Int("wallets", total))
+	return total, nil
+}
+
+// RunBalanceSnapshots периодически сохраняет снимки балансов на границу последнего завершенного интервала
+func (s *WalletService) RunBalanceSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			at := s.now().Add(-snapshotSafetyLag).Truncate(interval)
+			if _, err := s.TakeBalanceSnapshots(ctx, at); err != nil {
+				s.log.Error("Failed to take balance snapshots", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Statement формирует выписку по кошельку за период [from, to): входящий остаток, движения с остатком
+// после каждого и исходящий остаток. Движения передаются в w по одному, не накапливаясь в памяти
+func (s *WalletService) Statement(ctx context.Context, id uuid.UUID, from, to time.Time, w domain.StatementWriter) error {
+	if !from.Before(to) {
+		return domain.ErrInvalidTimeRange
+	}
+
+	s.log.Debug("Write statement", zap.Stringer("wallet_id", id), zap.Time("from", from), zap.Time("to", to))
+	// Входящий остаток и движения читаются из одного снимка, иначе проводка, закоммиченная между запросами,
+	// попала бы в движения, но не в остаток
+	return s.read(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		wallet, err := uow.Wallets().Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		ledger := uow.Ledger()
+		balance, err := ledger.BalanceAt(ctx, id, from.Add(-time.Microsecond))
+		if err != nil {
+			return err
+		}
+
+		if err := w.WriteHeader(domain.StatementHeader{
+			WalletID: wallet.ID,
+			Currency: wallet.Currency,
+			From:     from,
+			To:       to,
+			Opening:  balance,
+		}); err != nil {
+			return err
+		}
+
+		lines := 0
+		err = ledger.StreamPostings(ctx, id, from, to, func(line domain.StatementLine) error {
+			balance = balance.Add(line.Amount)
+			line.Balance = balance
+			lines++
+			return w.WriteLine(line)
+		})
+		if err != nil {
+			return err
+		}
+
+		return w.WriteFooter(domain.StatementFooter{Closing: balance, Lines: lines})
+	}, domain.WithIsolation(domain.RepeatableRead))
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+)
+
+const (
+	// DefaultIdempotencyTTL - сколько хранятся результаты запросов с ключом идемпотентности
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyPurgeInterval - как часто удаляются истекшие ключи идемпотентности
+	idempotencyPurgeInterval = time.Hour
+)
+
+// WithIdempotencyTTL задает, сколько хранятся результаты запросов с ключом идемпотентности
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(s *WalletService) {
+		s.idempotencyTTL = ttl
+	}
+}
+
+// replay занимает ключ идемпотентности из ctx в транзакции запроса до ее завершения. Если запрос с этим ключом
+// уже выполнен, его результат разбирается в result и возвращается true: повторно запрос не выполняется
+func (s *WalletService) replay(ctx context.Context, uow domain.UnitOfWork, result any) (bool, error) {
+	key, ok := domain.IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return false, nil
+	}
+	stored, err := uow.Idempotency().Acquire(ctx, key.Key, key.Fingerprint, s.now().Add(s.idempotencyTTL))
+	if err != nil || stored == nil {
+		return false, err
+	}
+	if stored.Fingerprint != key.Fingerprint {
+		s.log.Warn("Idempotency key reused for another request", zap.String("idempotency_key", key.Key))
+		return false, domain.ErrIdempotencyKeyReused
+	}
+	if err := json.Unmarshal(stored.Response, result); err != nil {
+		s.log.Error("Failed to decode idempotent response", zap.String("idempotency_key", key.Key), zap.Error(err))
+		return false, fmt.Errorf("failed to decode idempotent response: %w", err)
+	}
+
+	s.log.Debug("Replaying idempotent response", zap.String("idempotency_key", key.Key))
+	key.Replayed = true
+	return true, nil
+}
+
+// remember сохраняет результат запроса по ключу идемпотентности из ctx. Он записывается в той же транзакции,
+// что и сам запрос, поэтому операция и ее результат фиксируются или откатываются вместе
+func (s *WalletService) remember(ctx context.Context, uow domain.UnitOfWork, result any) error {
+	key, ok := domain.IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	response, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotent response: %w", err)
+	}
+
+	return uow.Idempotency().Save(ctx, key.Key, response)
+}
+
+// PurgeIdempotencyKeys удаляет истекшие ключи идемпотентности и возвращает их количество
+func (s *WalletService) PurgeIdempotencyKeys(ctx context.Context) (int, error) {
+	purged, err := s.store(ctx).Idempotency().DeleteExpired(ctx, s.now())
+	if err != nil {
+		s.log.Error("Failed to purge idempotency keys", zap.Error(err))
+		return 0, err
+	}
+
+	s.log.Debug("Idempotency keys purged", zap.Int("purged", purged))
+	return purged, nil
+}
+
+// RunIdempotencyKeyPurge периодически удаляет истекшие ключи идемпотентности, пока не отменен ctx
+func (s *WalletService) RunIdempotencyKeyPurge(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, _ = s.PurgeIdempotencyKeys(ctx)
+		}
+	}
+}
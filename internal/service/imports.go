@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"strings"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// importPageSize - сколько необработанных строк импорта читается за один запрос. Страница - только единица
+// чтения: каждая строка применяется в своей транзакции
+const importPageSize = 1000
+
+// importLease - сколько RUNNING-импорт без отметки обработчика считается занятым. Обработчик отмечается
+// несколько раз за аренду; импорт без отметки брошен остановленным процессом и подхватывается снова
+const importLease = 5 * time.Minute
+
+// importPollInterval - период поиска брошенных импортов; новые импорты RunImports начинает сразу
+const importPollInterval = 30 * time.Second
+
+// Колонки файла импорта; target_wallet нужна только для переводов
+const (
+	importColumnWallet        = "wallet"
+	importColumnOperationType = "operation_type"
+	importColumnAmount        = "amount"
+	importColumnTargetWallet  = "target_wallet"
+	// importColumnOccurredAt - время операции при загрузке истории; без него операция проводится временем применения
+	importColumnOccurredAt = "occurred_at"
+)
+
+// StartImport создает задание и загружает строки CSV-файла в промежуточную таблицу в одной транзакции,
+// поэтому обработчики видят импорт только со всеми строками. Файл читается потоком;
+// строки проверяются и применяются позже в RunImport
+func (s *WalletService) StartImport(ctx context.Context, fileName string, r io.Reader) (*domain.Import, error) {
+	next, err := newImportRowSource(r)
+	if err != nil {
+		s.log.Warn("Invalid import file", zap.String("file", fileName), zap.Error(err))
+		return nil, err
+	}
+
+	imp := &domain.Import{ID: uuid.New(), FileName: fileName, Status: domain.ImportPending}
+	s.log.Info("Staging import", zap.String("import_id", imp.ID.String()), zap.String("file", fileName))
+	err = s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		if err := uow.Imports().Create(ctx, imp); err != nil {
+			return err
+		}
+		imp.TotalRows, err = uow.Imports().Stage(ctx, imp.ID, next)
+		return err
+	})
+	if err != nil {
+		s.log.Error("Failed to stage import", zap.String("import_id", imp.ID.String()), zap.Error(err))
+		return nil, err
+	}
+	s.log.Info("Import staged", zap.String("import_id", imp.ID.String()), zap.Int("rows", imp.TotalRows))
+
+	select {
+	case s.importWake <- struct{}{}:
+	default:
+	}
+	return imp, nil
+}
+
+// RunImports применяет импорты в фоне, пока не отменен ctx: новые - сразу после загрузки,
+// брошенные остановленным процессом - по истечении аренды. После отмены ctx текущий импорт
+// останавливается на границе строки и возвращается в PENDING, чтобы его продолжил следующий запуск
+func (s *WalletService) RunImports(ctx context.Context) {
+	ticker := time.NewTicker(importPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.runClaimableImports(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.importWake:
+		}
+	}
+}
+
+// runClaimableImports по одному применяет импорты, ожидающие обработчика
+func (s *WalletService) runClaimableImports(ctx context.Context) {
+	for ctx.Err() == nil {
+		ids, err := s.store(ctx).Imports().Claimable(ctx, s.now().Add(-importLease), 1)
+		if err != nil {
+			s.log.Error("Failed to find imports to run", zap.Error(err))
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+
+		// Импорт, который успел занять другой обработчик, пропускается
+		if _, err := s.RunImport(ctx, ids[0]); err != nil && !errors.Is(err, domain.ErrImportNotClaimable) {
+			return
+		}
+	}
+}
+
+// RunImport занимает импорт и применяет его необработанные строки. Импорт занимает только один обработчик,
+// поэтому параллельный вызов для того же импорта возвращает ErrImportNotClaimable. Каждая строка применяется
+// в своей транзакции вместе с отметкой о применении, поэтому прерванный импорт продолжается с первой
+// необработанной строки. После отмены ctx применяемая строка завершается, а импорт возвращается в PENDING
+func (s *WalletService) RunImport(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	imports := s.store(ctx).Imports()
+	if _, err := imports.Claim(ctx, id, s.now().Add(-importLease)); err != nil {
+		s.log.Warn("Failed to claim import", zap.String("import_id", id.String()), zap.Error(err))
+		return nil, err
+	}
+	s.log.Info("Running import", zap.String("import_id", id.String()))
+
+	// Строки применяются без отмены, чтобы остановка не превращала начатую строку в сбой импорта
+	rowCtx := context.WithoutCancel(ctx)
+	heartbeatAt := s.now()
+	afterLine := 0
+	for {
+		rows, err := imports.PendingRows(rowCtx, id, afterLine, importPageSize)
+		if err != nil {
+			return s.failImport(rowCtx, id, err)
+		}
+
+		for i := range rows {
+			if ctx.Err() != nil {
+				return s.releaseImport(rowCtx, id, ctx.Err())
+			}
+			if s.now().Sub(heartbeatAt) >= importLease/5 {
+				if err := imports.Heartbeat(rowCtx, id); err != nil {
+					s.log.Warn("Lost import claim", zap.String("import_id", id.String()), zap.Error(err))
+					return nil, err
+				}
+				heartbeatAt = s.now()
+			}
+			if err := s.applyImportRow(rowCtx, &rows[i]); err != nil {
+				return s.failImport(rowCtx, id, err)
+			}
+		}
+
+		if len(rows) < importPageSize {
+			break
+		}
+		afterLine = rows[len(rows)-1].Line
+	}
+
+	imp, err := imports.SetStatus(rowCtx, id, domain.ImportCompleted, "")
+	if err != nil {
+		return nil, err
+	}
+	s.log.Info("Import completed", zap.String("import_id", id.String()), zap.Int("applied", imp.AppliedRows),
+		zap.Int("failed", imp.FailedRows))
+
+	return imp, nil
+}
+
+// applyImportRow исполняет строку по тем же правилам, что и PerformOperation.
+// Отказ по бизнес-правилам записывается в строку; возвращаются только ошибки инфраструктуры
+func (s *WalletService) applyImportRow(ctx context.Context, row *domain.ImportRow) error {
+	req, err := row.Request()
+	if err == nil {
+		err = s.validateOperation(req)
+	}
+	if err == nil && req.OccurredAt.After(s.now()) {
+		err = fmt.Errorf("%w: occurred_at is in the future", domain.ErrInvalidImportRow)
+	}
+	// Задним числом загружаются только зачисления: списание вне текущих окон обошло бы лимиты на вывод средств
+	if err == nil && !req.OccurredAt.IsZero() && req.OperationType != domain.Deposit {
+		err = fmt.Errorf("%w: occurred_at is allowed only for %s", domain.ErrInvalidImportRow, domain.Deposit)
+	}
+	if err == nil {
+		err = s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+			wallets, err := s.lockWallets(ctx, uow, s.walletsToLock(req))
+			if err != nil {
+				return err
+			}
+			result, err := s.apply(ctx, uow, wallets, req)
+			if err != nil {
+				return err
+			}
+
+			row.Status = domain.ImportRowApplied
+			row.OperationID = result.ID
+			return uow.Imports().MarkRow(ctx, row)
+		})
+	}
+	if err == nil || errors.Is(err, domain.ErrImportRowProcessed) {
+		return nil
+	}
+	reason, ok := rowErrorReason(err)
+	if !ok {
+		return err
+	}
+
+	row.Status = domain.ImportRowFailed
+	row.Error = reason
+	if err := s.store(ctx).Imports().MarkRow(ctx, row); err != nil && !errors.Is(err, domain.ErrImportRowProcessed) {
+		return err
+	}
+	return nil
+}
+
+// rowErrorReason возвращает причину отказа, если операцию отклонили бизнес-правила, а не сбой хранилища
+func rowErrorReason(err error) (string, bool) {
+	var limitErr *domain.LimitExceededError
+	switch {
+	case errors.As(err, &limitErr):
+		return limitErr.Error(), true
+	case errors.Is(err, domain.ErrInvalidImportRow):
+		return err.Error(), true
+	}
+
+	for _, target := range []error{
+		domain.ErrIDIsNil, domain.ErrAmountZeroOrNegative, domain.ErrUnknownOperationType, domain.ErrTransferToSameWallet,
+		domain.ErrWalletNotFound, domain.ErrInsufficientFunds, domain.ErrFeeExceedsAmount, domain.ErrCurrencyMismatch,
+		domain.ErrWalletFrozen,
+	} {
+		if errors.Is(err, target) {
+			return target.Error(), true
+		}
+	}
+	return "", false
+}
+
+func (s *WalletService) failImport(ctx context.Context, id uuid.UUID, cause error) (*domain.Import, error) {
+	s.log.Error("Import failed", zap.String("import_id", id.String()), zap.Error(cause))
+	if _, err := s.store(ctx).Imports().SetStatus(ctx, id, domain.ImportFailed, cause.Error()); err != nil {
+		s.log.Error("Failed to mark import as failed", zap.Error(err))
+	}
+	return nil, cause
+}
+
+// releaseImport возвращает прерванный импорт в PENDING, чтобы его продолжил следующий обработчик
+func (s *WalletService) releaseImport(ctx context.Context, id uuid.UUID, cause error) (*domain.Import, error) {
+	s.log.Info("Import interrupted, releasing it for resume", zap.String("import_id", id.String()), zap.Error(cause))
+	if _, err := s.store(ctx).Imports().SetStatus(ctx, id, domain.ImportPending, ""); err != nil {
+		s.log.Error("Failed to release import", zap.String("import_id", id.String()), zap.Error(err))
+	}
+	return nil, cause
+}
+
+// GetImport возвращает состояние импорта
+func (s *WalletService) GetImport(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	return s.store(ctx).Imports().Get(ctx, id)
+}
+
+// ImportErrors передает в fn строки импорта, которые не удалось применить
+func (s *WalletService) ImportErrors(ctx context.Context, id uuid.UUID, fn func(row domain.ImportRow) error) error {
+	if _, err := s.store(ctx).Imports().Get(ctx, id); err != nil {
+		return err
+	}
+	return s.store(ctx).Imports().StreamFailedRows(ctx, id, fn)
+}
+
+// newImportRowSource читает заголовок CSV и возвращает источник строк с номерами строк файла
+func newImportRowSource(r io.Reader) (domain.ImportRowSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to read header: %v", domain.ErrInvalidImportFile, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{importColumnWallet, importColumnOperationType, importColumnAmount} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", domain.ErrInvalidImportFile, required)
+		}
+	}
+
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	return func() (*domain.ImportRow, error) {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", domain.ErrInvalidImportFile, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		return &domain.ImportRow{
+			Line:           line,
+			WalletID:       field(record, importColumnWallet),
+			OperationType:  strings.ToUpper(field(record, importColumnOperationType)),
+			Amount:         field(record, importColumnAmount),
+			TargetWalletID: field(record, importColumnTargetWallet),
+			OccurredAt:     field(record, importColumnOccurredAt),
+		}, nil
+	}, nil
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// checkSpendingLimits проверяет списание по лимитам кошелька. Суммы за скользящие окна
+// считаются по истории операций внутри той же транзакции, что и само списание,
+// поэтому блокировка кошелька защищает от параллельного обхода лимита
+func (s *WalletService) checkSpendingLimits(ctx context.Context, uow domain.UnitOfWork, walletID uuid.UUID, amount decimal.Decimal) error {
+	limits, err := uow.Limits().GetEffective(ctx, walletID)
+	if err != nil {
+		s.log.Error("Failed to get spending limits", zap.Stringer("wallet_id", walletID), zap.Error(err))
+		return fmt.Errorf("failed to get spending limits: %w", err)
+	}
+
+	if limits.SingleWithdrawal.Valid && amount.GreaterThan(limits.SingleWithdrawal.Decimal) {
+		s.log.Warn("Single withdrawal limit exceeded", zap.Stringer("wallet_id", walletID),
+			zap.String("limit", limits.SingleWithdrawal.Decimal.String()), zap.String("amount", amount.String()))
+		return &domain.LimitExceededError{
+			Limit:     domain.LimitSingleWithdrawal,
+			Max:       limits.SingleWithdrawal.Decimal,
+			Remaining: limits.SingleWithdrawal.Decimal,
+		}
+	}
+
+	if err := s.checkWindowLimit(ctx, uow, walletID, amount, domain.LimitDailyWithdrawal, limits.DailyWithdrawal,
+		domain.OutgoingOperationTypes, domain.DailyLimitWindow); err != nil {
+		return err
+	}
+	return s.checkWindowLimit(ctx, uow, walletID, amount, domain.LimitMonthlyWithdrawal, limits.MonthlyWithdrawal,
+		domain.OutgoingOperationTypes, domain.MonthlyLimitWindow)
+}
+
+// checkTierCaps проверяет зачисление по ограничениям уровня кошелька:
+// максимальному балансу и объему входящих средств за скользящий месяц, кроме зачислений задним числом
+func (s *WalletService) checkTierCaps(ctx context.Context, uow domain.UnitOfWork, wallet *domain.Wallet, amount decimal.Decimal, backdated bool) error {
+	rules, err := uow.Tiers().GetRules(ctx, wallet.Tier)
+	if err != nil {
+		s.log.Error("Failed to get tier rules", zap.Stringer("wallet_id", wallet.ID), zap.String("tier", string(wallet.Tier)), zap.Error(err))
+		return fmt.Errorf("failed to get tier rules: %w", err)
+	}
+
+	if rules.MaxBalance.Valid && wallet.Balance.Add(amount).GreaterThan(rules.MaxBalance.Decimal) {
+		remaining := decimal.Max(rules.MaxBalance.Decimal.Sub(wallet.Balance), decimal.Zero)
+		s.log.Warn("Max balance exceeded", zap.Stringer("wallet_id", wallet.ID), zap.String("tier", string(wallet.Tier)),
+			zap.String("limit", rules.MaxBalance.Decimal.String()), zap.String("remaining", remaining.String()))
+		return &domain.LimitExceededError{
+			Limit:     domain.LimitMaxBalance,
+			Max:       rules.MaxBalance.Decimal,
+			Remaining: remaining,
+		}
+	}
+
+	if backdated {
+		return nil
+	}
+	return s.checkWindowLimit(ctx, uow, wallet.ID, amount, domain.LimitMonthlyIncoming, rules.MaxMonthlyIncoming,
+		domain.IncomingOperationTypes, domain.MonthlyLimitWindow)
+}
+
+// checkWindowLimit проверяет, что сумма операций указанных типов за окно window вместе с amount не превышает limit
+func (s *WalletService) checkWindowLimit(ctx context.Context, uow domain.UnitOfWork, walletID uuid.UUID, amount decimal.Decimal,
+	kind domain.LimitKind, limit decimal.NullDecimal, operationTypes []domain.OperationType, window time.Duration) error {
+	if !limit.Valid {
+		return nil
+	}
+
+	used, err := uow.Operations().SumSince(ctx, walletID, operationTypes, s.now().Add(-window))
+	if err != nil {
+		s.log.Error("Failed to sum operations", zap.String("kind", string(kind)), zap.Stringer("wallet_id", walletID), zap.Error(err))
+		return fmt.Errorf("failed to sum operations: %w", err)
+	}
+
+	remaining := decimal.Max(limit.Decimal.Sub(used), decimal.Zero)
+	if amount.GreaterThan(remaining) {
+		s.log.Warn("Limit exceeded", zap.String("kind", string(kind)), zap.Stringer("wallet_id", walletID),
+			zap.String("limit", limit.Decimal.String()), zap.String("remaining", remaining.String()))
+		return &domain.LimitExceededError{
+			Limit:     kind,
+			Max:       limit.Decimal,
+			Remaining: remaining,
+		}
+	}
+
+	return nil
+}
+
+// GetLimits возвращает действующие лимиты кошелька
+func (s *WalletService) GetLimits(ctx context.Context, id uuid.UUID) (domain.SpendingLimits, error) {
+	s.log.Debug("Get spending limits", zap.Stringer("wallet_id", id))
+	limits, err := s.store(ctx).Limits().GetEffective(ctx, id)
+	if err != nil {
+		s.log.Error("Failed to get spending limits", zap.Error(err), zap.Stringer("wallet_id", id))
+		return domain.SpendingLimits{}, err
+	}
+	return limits, nil
+}
+
+// SetWalletLimits задает собственные лимиты кошелька и возвращает действующие после изменения
+func (s *WalletService) SetWalletLimits(ctx context.Context, id uuid.UUID, limits domain.SpendingLimits) (domain.SpendingLimits, error) {
+	s.log.Debug("Set wallet spending limits", zap.Stringer("wallet_id", id), zap.Object("limits", limits))
+	if id == uuid.Nil {
+		return domain.SpendingLimits{}, domain.ErrIDIsNil
+	}
+	if err := limits.Validate(); err != nil {
+		s.log.Warn("Invalid spending limits", zap.Stringer("wallet_id", id), zap.Error(err))
+		return domain.SpendingLimits{}, err
+	}
+
+	var effective domain.SpendingLimits
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		if _, err := uow.Wallets().GetForUpdate(ctx, id); err != nil {
+			return err
+		}
+		if err := uow.Limits().SetForWallet(ctx, id, limits); err != nil {
+			return err
+		}
+
+		var err error
+		effective, err = uow.Limits().GetEffective(ctx, id)
+		return err
+	})
+	if err != nil {
+		s.log.Error("Failed to set wallet spending limits", zap.Error(err), zap.Stringer("wallet_id", id))
+		return domain.SpendingLimits{}, err
+	}
+
+	return effective, nil
+}
+
+// SetTierLimits задает лимиты по умолчанию для всех кошельков уровня
+func (s *WalletService) SetTierLimits(ctx context.Context, tier domain.WalletTier, limits domain.SpendingLimits) error {
+	s.log.Debug("Set tier spending limits", zap.String("tier", string(tier)), zap.Object("limits", limits))
+	if err := limits.Validate(); err != nil {
+		s.log.Warn("Invalid spending limits", zap.String("tier", string(tier)), zap.Error(err))
+		return err
+	}
+	if _, err := s.store(ctx).Tiers().GetRules(ctx, tier); err != nil {
+		s.log.Warn("Failed to get tier rules", zap.String("tier", string(tier)), zap.Error(err))
+		return err
+	}
+
+	if err := s.store(ctx).Limits().SetForTier(ctx, tier, limits); err != nil {
+		s.log.Error("Failed to set tier spending limits", zap.Error(err), zap.String("tier", string(tier)))
+		return err
+	}
+
+	return nil
+}
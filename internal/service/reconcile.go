@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultReconcilePageSize - количество кошельков, читаемых за один запрос при сверке
+const DefaultReconcilePageSize = 1000
+
+// reconcileActor - автор исправлений в журнале аудита, если он не указан явно
+const reconcileActor = "reconciler"
+
+// Метрики последней сверки, публикуются через /debug/vars
+var (
+	reconcileCheckedWallets = expvar.NewInt("reconcile_checked_wallets")
+	reconcileDriftWallets   = expvar.NewInt("reconcile_drift_wallets")
+	reconcileDriftAmount    = expvar.NewString("reconcile_drift_amount")
+	reconcileRepairedTotal  = expvar.NewInt("reconcile_repaired_total")
+	reconcileLastRun        = expvar.NewString("reconcile_last_run")
+)
+
+type ReconcileOptions struct {
+	PageSize int
+	// Repair приводит кэшированный баланс к журналу с записью в журнал аудита
+	Repair bool
+	Actor  string
+}
+
+// Reconcile пересчитывает балансы всех кошельков по журналу проводок и сравнивает их с wallets.balance.
+// Кошельки читаются страницами по ключу, блокируется только исправляемый кошелек и только на время исправления
+func (s *WalletService) Reconcile(ctx context.Context, opts ReconcileOptions) (*domain.ReconcileReport, error) {
+	if opts.PageSize <= 0 {
+		opts.PageSize = DefaultReconcilePageSize
+	}
+	if opts.Actor == "" {
+		opts.Actor = reconcileActor
+	}
+
+	report := &domain.ReconcileReport{StartedAt: s.now()}
+	s.log.Info("Starting reconciliation", zap.Int("page_size", opts.PageSize), zap.Bool("repair", opts.Repair))
+
+	// Каждая страница читается в собственной короткой транзакции, и ее расхождения исправляются до чтения следующей:
+	// сверка не держит снимок базы на все время обхода и не копит расхождения в памяти
+	after := uuid.Nil
+	for {
+		var page []domain.WalletBalance
+		err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+			var err error
+			page, err = uow.Ledger().WalletBalances(ctx, after, opts.PageSize)
+			return err
+		}, domain.ReadOnly())
+		if err != nil {
+			s.log.Error("Failed to read wallet balances", zap.Error(err))
+			return nil, fmt.Errorf("failed to read wallet balances: %w", err)
+		}
+
+		for _, balance := range page {
+			report.Checked++
+			if balance.Drift().IsZero() {
+				continue
+			}
+			report.Drifts = append(report.Drifts, s.reconcileDrift(ctx, balance, opts))
+		}
+
+		if len(page) < opts.PageSize {
+			break
+		}
+		after = page[len(page)-1].WalletID
+	}
+
+	report.FinishedAt = s.now()
+	publishReconcileMetrics(report)
+	s.log.Info("Reconciliation finished", zap.Int("checked", report.Checked),
+		zap.Int("drifts", len(report.Drifts)), zap.Int("repaired", report.Repaired()))
+
+	return report, nil
+}
+
+// reconcileDrift сообщает о расхождении кошелька и, если задано, исправляет его
+func (s *WalletService) reconcileDrift(ctx context.Context, balance domain.WalletBalance, opts ReconcileOptions) domain.BalanceDrift {
+	s.log.Warn("Balance drift detected", zap.String("wallet_id", balance.WalletID.String()),
+		zap.String("stored", balance.Stored.String()), zap.String("ledger", balance.Ledger.String()))
+	drift := domain.BalanceDrift{WalletBalance: balance}
+	if !opts.Repair {
+		return drift
+	}
+
+	if err := s.repairBalance(ctx, &drift, opts.Actor); err != nil {
+		s.log.Error("Failed to repair balance", zap.String("wallet_id", drift.WalletID.String()), zap.Error(err))
+		drift.RepairError = err.Error()
+	}
+	return drift
+}
+
+// repairBalance записывает в wallets.balance баланс по журналу: журнал - источник истины, баланс - его проекция.
+// Баланс перечитывается под блокировкой, чтобы не исправить расхождение, которое устранила параллельная операция
+func (s *WalletService) repairBalance(ctx context.Context, drift *domain.BalanceDrift, actor string) error {
+	return s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		wallet, err := uow.Wallets().GetForUpdate(ctx, drift.WalletID)
+		if err != nil {
+			return err
+		}
+		ledger, err := uow.Ledger().AccountBalance(ctx, drift.WalletID)
+		if err != nil {
+			return err
+		}
+
+		drift.Stored = wallet.Balance
+		drift.Ledger = ledger
+		if drift.Drift().IsZero() {
+			s.log.Info("Balance drift resolved concurrently", zap.String("wallet_id", drift.WalletID.String()))
+			drift.Repaired = true
+			return nil
+		}
+
+		if err := uow.Wallets().UpdateBalance(ctx, wallet.ID, ledger); err != nil {
+			return err
+		}
+		if err := uow.Audit().Add(ctx, &domain.AuditEntry{
+			WalletID: wallet.ID,
+			Action:   domain.AuditBalanceRepaired,
+			OldValue: wallet.Balance.String(),
+			NewValue: ledger.String(),
+			Actor:    actor,
+			Reason:   fmt.Sprintf("reconciliation drift %s", drift.Drift()),
+		}); err != nil {
+			return err
+		}
+
+		drift.Repaired = true
+		return nil
+	})
+}
+
+func publishReconcileMetrics(report *domain.ReconcileReport) {
+	var unresolved int64
+	total := decimal.Zero
+	for _, d := range report.Drifts {
+		if !d.Repaired {
+			unresolved++
+		}
+		total = total.Add(d.Drift().Abs())
+	}
+
+	reconcileCheckedWallets.Set(int64(report.Checked))
+	reconcileDriftWallets.Set(unresolved)
+	reconcileDriftAmount.Set(total.String())
+	reconcileRepairedTotal.Add(int64(report.Repaired()))
+	reconcileLastRun.Set(report.FinishedAt.Format(time.RFC3339))
+}
+
+// RunReconciliation периодически запускает сверку до отмены контекста
+func (s *WalletService) RunReconciliation(ctx context.Context, interval time.Duration, opts ReconcileOptions) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reconcile(ctx, opts); err != nil {
+				s.log.Error("Reconciliation failed", zap.Error(err))
+			}
+		}
+	}
+}
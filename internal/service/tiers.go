@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+)
+
+// UpgradeTier переводит кошелек на более высокий уровень и записывает изменение в журнал аудита
+func (s *WalletService) UpgradeTier(ctx context.Context, id uuid.UUID, tier domain.WalletTier, actor string, reason string) (*domain.Wallet, error) {
+	s.log.Debug("Upgrade tier", zap.Stringer("wallet_id", id), zap.String("tier", string(tier)), zap.String("actor", actor))
+	if id == uuid.Nil {
+		return nil, domain.ErrIDIsNil
+	}
+
+	var updated *domain.Wallet
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		wallet, err := uow.Wallets().GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		current, err := uow.Tiers().GetRules(ctx, wallet.Tier)
+		if err != nil {
+			return err
+		}
+		target, err := uow.Tiers().GetRules(ctx, tier)
+		if err != nil {
+			return err
+		}
+		if target.Rank <= current.Rank {
+			s.log.Warn("Target tier is not an upgrade", zap.Stringer("wallet_id", id),
+				zap.String("current", string(wallet.Tier)), zap.String("target", string(tier)))
+			return domain.ErrTierNotUpgrade
+		}
+
+		if err := uow.Wallets().UpdateTier(ctx, id, tier); err != nil {
+			return err
+		}
+		if err := uow.Audit().Add(ctx, &domain.AuditEntry{
+			WalletID: id,
+			Action:   domain.AuditTierChanged,
+			OldValue: string(wallet.Tier),
+			NewValue: string(tier),
+			Actor:    actor,
+			Reason:   reason,
+		}); err != nil {
+			return err
+		}
+
+		wallet.Tier = tier
+		updated = wallet
+		return nil
+	})
+	if err != nil {
+		s.log.Error("Failed to upgrade tier", zap.Error(err), zap.Stringer("wallet_id", id))
+		return nil, err
+	}
+
+	s.log.Info("Wallet tier upgraded", zap.Stringer("wallet_id", id), zap.String("tier", string(tier)), zap.String("actor", actor))
+	return updated, nil
+}
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"go.uber.org/zap"
+	"slices"
+	"time"
 
 	"testtask/internal/domain"
 
@@ -15,87 +18,563 @@ import (
 var initialBalance = decimal.NewFromInt(0)
 
 type WalletService struct {
-	uowFactory domain.UnitOfWork
-	log        *zap.Logger
+	uowFactory      domain.UnitOfWork
+	log             *zap.Logger
+	now             func() time.Time
+	feeSchedule     domain.FeeSchedule
+	revenueWalletID uuid.UUID
+	maxBatchSize    int
+	idempotencyTTL  time.Duration
+	// importWake будит RunImports, когда загружен новый импорт
+	importWake chan struct{}
 }
 
-func NewWalletService(uowFactory domain.UnitOfWork, log *zap.Logger) *WalletService {
-	return &WalletService{
-		uowFactory: uowFactory,
-		log:        log.Named("WalletService"),
+type Option func(s *WalletService)
+
+// WithFeeSchedule задает расписание комиссий вместо хранящегося в БД
+func WithFeeSchedule(schedule domain.FeeSchedule) Option {
+	return func(s *WalletService) {
+		s.feeSchedule = schedule
+	}
+}
+
+// WithRevenueWallet задает кошелек, на который зачисляются комиссии
+func WithRevenueWallet(id uuid.UUID) Option {
+	return func(s *WalletService) {
+		s.revenueWalletID = id
+	}
+}
+
+func NewWalletService(uowFactory domain.UnitOfWork, log *zap.Logger, opts ...Option) *WalletService {
+	s := &WalletService{
+		uowFactory:     uowFactory,
+		log:            log.Named("WalletService"),
+		now:            time.Now,
+		maxBatchSize:   DefaultMaxBatchSize,
+		idempotencyTTL: DefaultIdempotencyTTL,
+		importWake:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// do выполняет fn в транзакции. Если в ctx уже передана транзакция, fn выполняется во вложенной,
+// и ее ошибка откатывает только изменения fn. Контекст, переданный в fn, несет транзакцию,
+// поэтому сервисные методы, вызванные из fn, присоединяются к ней
+func (s *WalletService) do(ctx context.Context, fn func(ctx context.Context, uow domain.UnitOfWork) error, opts ...domain.TxOption) error {
+	run := func(uow domain.UnitOfWork) error {
+		return fn(domain.ContextWithUnitOfWork(ctx, uow), uow)
+	}
+	if uow, ok := domain.UnitOfWorkFromContext(ctx); ok {
+		return uow.Do(ctx, run, opts...)
+	}
+	return s.uowFactory.Do(ctx, run, opts...)
+}
+
+// read выполняет чтение без блокировок в транзакции только для чтения. Хранилище может обслужить его с реплики,
+// если та воспроизвела журнал до позиции, переданной клиентом в ctx
+func (s *WalletService) read(ctx context.Context, fn func(ctx context.Context, uow domain.UnitOfWork) error, opts ...domain.TxOption) error {
+	opts = append(opts, domain.ReadOnly(), domain.FromReplica(domain.MinLSNFromContext(ctx)))
+	return s.do(ctx, fn, opts...)
+}
+
+// store возвращает репозитории транзакции, переданной в ctx, или хранилище для чтения вне транзакции
+func (s *WalletService) store(ctx context.Context) domain.UnitOfWork {
+	if uow, ok := domain.UnitOfWorkFromContext(ctx); ok {
+		return uow
+	}
+	return s.uowFactory
+}
+
+func (s *WalletService) PerformOperation(ctx context.Context, req domain.OperationRequest) (*domain.OperationResult, error) {
+	s.log.Debug("Perform operation", zap.Object("req", req))
+	if err := s.validateOperation(req); err != nil {
+		return nil, err
 	}
+
+	var result *domain.OperationResult
+	var lsn string
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		if replayed, err := s.replay(ctx, uow, &result); err != nil || replayed {
+			return err
+		}
+		wallets, err := s.lockWallets(ctx, uow, s.walletsToLock(req))
+		if err != nil {
+			return err
+		}
+
+		if result, err = s.apply(ctx, uow, wallets, req); err != nil {
+			return err
+		}
+		return s.remember(ctx, uow, result)
+	}, domain.WithCommitLSN(&lsn))
+	if err != nil {
+		return nil, err
+	}
+
+	result.LSN = lsn
+	return result, nil
 }
 
-func (s *WalletService) PerformOperation(ctx context.Context, req domain.OperationRequest) error {
-	s.log.Debug("Perform operation", zap.Any("req", req))
+func (s *WalletService) validateOperation(req domain.OperationRequest) error {
 	if req.ID == uuid.Nil {
-		s.log.Warn("Wallet ID is nil", zap.Any("req", req))
+		s.log.Warn("Wallet ID is nil", zap.Object("req", req))
 		return domain.ErrIDIsNil
 	}
 
 	if req.Amount.IsZero() || req.Amount.IsNegative() {
-		s.log.Warn("Wallet amount is zero or is negative", zap.Any("req", req))
+		s.log.Warn("Wallet amount is zero or is negative", zap.Object("req", req))
 		return domain.ErrAmountZeroOrNegative
 	}
 
-	return s.uowFactory.Do(ctx, func(uow domain.UnitOfWork) error {
-		walletRepo := uow.Wallets()
+	switch req.OperationType {
+	case domain.Deposit, domain.Withdraw:
+	case domain.Transfer:
+		if req.TargetID == uuid.Nil {
+			s.log.Warn("Target wallet ID is nil", zap.Object("req", req))
+			return domain.ErrIDIsNil
+		}
+		if req.TargetID == req.ID {
+			s.log.Warn("Transfer to the same wallet", zap.Object("req", req))
+			return domain.ErrTransferToSameWallet
+		}
+	default:
+		s.log.Warn("Unknown operation type", zap.Object("req", req))
+		return domain.ErrUnknownOperationType
+	}
+
+	return nil
+}
 
-		balance, err := walletRepo.GetBalanceForUpdate(ctx, req.ID)
+// walletsToLock возвращает кошельки, которые изменит операция, включая кошелек доходов,
+// если с операции может удерживаться комиссия
+func (s *WalletService) walletsToLock(req domain.OperationRequest) []uuid.UUID {
+	ids := []uuid.UUID{req.ID}
+	if req.OperationType == domain.Transfer {
+		ids = append(ids, req.TargetID)
+	}
+	if s.revenueWalletID != uuid.Nil && slices.Contains(domain.FeeableOperationTypes, req.OperationType) {
+		ids = append(ids, s.revenueWalletID)
+	}
+	return ids
+}
+
+// lockWallets блокирует кошельки в порядке возрастания ID, чтобы параллельные операции
+// над одними и теми же кошельками не могли взаимно заблокироваться
+func (s *WalletService) lockWallets(ctx context.Context, uow domain.UnitOfWork, ids []uuid.UUID) (map[uuid.UUID]*domain.Wallet, error) {
+	sorted := slices.Clone(ids)
+	slices.SortFunc(sorted, func(a, b uuid.UUID) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	sorted = slices.Compact(sorted)
+
+	wallets := make(map[uuid.UUID]*domain.Wallet, len(sorted))
+	for _, id := range sorted {
+		wallet, err := uow.Wallets().GetForUpdate(ctx, id)
 		if err != nil {
 			if errors.Is(err, domain.ErrWalletNotFound) {
-				s.log.Error("Wallet not found", zap.Any("req", req))
-				return domain.ErrWalletNotFound
+				s.log.Warn("Wallet not found", zap.Stringer("wallet_id", id))
+				return nil, domain.ErrWalletNotFound
 			}
-			s.log.Error("Error getting balance for req", zap.Any("req", req), zap.Error(err))
-			return fmt.Errorf("failed to get balance: %w", err)
-		}
-
-		var newBalance decimal.Decimal
-		switch req.OperationType {
-		case domain.Deposit:
-			s.log.Debug("Deposit operation", zap.String("balance", balance.String()), zap.Any("req", req))
-			newBalance = balance.Add(req.Amount)
-		case domain.Withdraw:
-			s.log.Debug("Withdraw operation", zap.String("balance", balance.String()), zap.Any("req", req))
-			if balance.LessThan(req.Amount) {
-				s.log.Warn("Insufficient funds", zap.String("balance", balance.String()), zap.Any("req", req))
-				return domain.ErrInsufficientFunds
+			s.log.Error("Error getting wallet for update", zap.Stringer("wallet_id", id), zap.Error(err))
+			return nil, fmt.Errorf("failed to get balance: %w", err)
+		}
+		wallets[id] = wallet
+	}
+
+	return wallets, nil
+}
+
+// apply исполняет операцию над заранее заблокированными кошельками и проводит ее
+// сбалансированным журналом: каждое изменение баланса кошелька уравновешивается
+// проводкой по другому кошельку или системному счету
+func (s *WalletService) apply(ctx context.Context, uow domain.UnitOfWork, wallets map[uuid.UUID]*domain.Wallet, req domain.OperationRequest) (*domain.OperationResult, error) {
+	wallet := wallets[req.ID]
+	result := &domain.OperationResult{
+		ID:            uuid.New(),
+		WalletID:      req.ID,
+		OperationType: req.OperationType,
+		Gross:         req.Amount,
+		Fee:           decimal.Zero,
+		Net:           req.Amount,
+	}
+	journal := domain.NewJournal(result.ID, string(req.OperationType))
+	journal.CreatedAt = req.OccurredAt
+
+	if err := s.checkActive(wallet); err != nil {
+		return nil, err
+	}
+	if req.OperationType == domain.Transfer {
+		if err := s.checkActive(wallets[req.TargetID]); err != nil {
+			return nil, err
+		}
+	}
+
+	switch req.OperationType {
+	case domain.Deposit:
+		s.log.Debug("Deposit operation", zap.String("balance", wallet.Balance.String()), zap.Object("req", req))
+		if err := s.credit(ctx, uow, journal, wallet, req.Amount, domain.Deposit); err != nil {
+			return nil, err
+		}
+		if err := s.postSystem(ctx, uow, journal, domain.CashIn, req.Amount.Neg(), wallet.Currency); err != nil {
+			return nil, err
+		}
+	case domain.Withdraw, domain.Transfer:
+		s.log.Debug("Debit operation", zap.String("balance", wallet.Balance.String()), zap.Object("req", req))
+		if req.OperationType == domain.Transfer && wallets[req.TargetID].Currency != wallet.Currency {
+			s.log.Warn("Transfer between wallets in different currencies", zap.Object("req", req),
+				zap.String("currency", wallet.Currency), zap.String("target_currency", wallets[req.TargetID].Currency))
+			return nil, domain.ErrCurrencyMismatch
+		}
+		fee, err := s.calculateFee(ctx, uow, wallet, req)
+		if err != nil {
+			return nil, err
+		}
+		result.Fee = fee
+		result.Net = req.Amount.Sub(fee)
+
+		debitType := domain.Withdraw
+		if req.OperationType == domain.Transfer {
+			debitType = domain.TransferOut
+		}
+		if err := s.debit(ctx, uow, journal, wallet, req.Amount, debitType); err != nil {
+			return nil, err
+		}
+		if req.OperationType == domain.Transfer {
+			if err := s.credit(ctx, uow, journal, wallets[req.TargetID], result.Net, domain.TransferIn); err != nil {
+				return nil, err
 			}
-			newBalance = balance.Sub(req.Amount)
-		default:
-			s.log.Warn("Unknown operation type", zap.Any("req", req))
-			return domain.ErrUnknownOperationType
+		} else {
+			if err := s.postSystem(ctx, uow, journal, domain.CashOut, result.Net, wallet.Currency); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.collectFee(ctx, uow, journal, wallet, wallets, fee); err != nil {
+			return nil, err
 		}
-		return walletRepo.UpdateBalance(ctx, req.ID, newBalance)
+	default:
+		s.log.Warn("Unknown operation type", zap.Object("req", req))
+		return nil, domain.ErrUnknownOperationType
+	}
+
+	if err := s.postJournal(ctx, uow, journal); err != nil {
+		return nil, err
+	}
+
+	result.Balance = wallet.Balance
+	return result, nil
+}
+
+// debit списывает amount с кошелька с проверкой лимитов на списания и доступных средств
+func (s *WalletService) debit(ctx context.Context, uow domain.UnitOfWork, journal *domain.Journal, wallet *domain.Wallet, amount decimal.Decimal, operationType domain.OperationType) error {
+	if err := s.checkSpendingLimits(ctx, uow, wallet.ID, amount); err != nil {
+		return err
+	}
+	if wallet.Available().LessThan(amount) {
+		s.log.Warn("Insufficient funds", zap.Stringer("wallet_id", wallet.ID), zap.String("balance", wallet.Balance.String()),
+			zap.String("credit_limit", wallet.CreditLimit.String()), zap.String("amount", amount.String()))
+		return &domain.InsufficientFundsError{Available: wallet.Available(), Amount: amount}
+	}
 
+	return s.record(ctx, uow, journal, wallet, amount, operationType, wallet.Balance.Sub(amount))
+}
+
+// credit зачисляет amount на кошелек с проверкой ограничений его уровня
+func (s *WalletService) credit(ctx context.Context, uow domain.UnitOfWork, journal *domain.Journal, wallet *domain.Wallet, amount decimal.Decimal, operationType domain.OperationType) error {
+	if err := s.checkTierCaps(ctx, uow, wallet, amount, journal.Backdated()); err != nil {
+		return err
+	}
+
+	return s.record(ctx, uow, journal, wallet, amount, operationType, wallet.Balance.Add(amount))
+}
+
+// record сохраняет новый баланс кошелька, запись в истории операций и добавляет проводку по счету кошелька.
+// Баланс в wallets - проекция журнала, поэтому обновляется в той же транзакции
+func (s *WalletService) record(ctx context.Context, uow domain.UnitOfWork, journal *domain.Journal, wallet *domain.Wallet, amount decimal.Decimal, operationType domain.OperationType, newBalance decimal.Decimal) error {
+	if err := uow.Wallets().UpdateBalance(ctx, wallet.ID, newBalance); err != nil {
+		return err
+	}
+	operationID := uuid.New()
+	journal.PostOperation(operationID, wallet.ID, newBalance.Sub(wallet.Balance), wallet.Currency)
+	wallet.Balance = newBalance
+
+	return uow.Operations().Add(ctx, &domain.Operation{
+		ID:            operationID,
+		CorrelationID: journal.CorrelationID,
+		WalletID:      wallet.ID,
+		OperationType: operationType,
+		Amount:        amount,
+		CreatedAt:     journal.CreatedAt,
 	})
+}
 
+// postSystem добавляет проводку по системному счету, через который деньги входят в систему или покидают ее
+func (s *WalletService) postSystem(ctx context.Context, uow domain.UnitOfWork, journal *domain.Journal, account domain.SystemAccount, amount decimal.Decimal, currency string) error {
+	accountID, err := uow.Ledger().SystemAccountID(ctx, account, currency)
+	if err != nil {
+		s.log.Error("Failed to get system account", zap.String("account", string(account)),
+			zap.String("currency", currency), zap.Error(err))
+		return err
+	}
+
+	journal.Post(accountID, amount, currency)
+	return nil
+}
+
+// postJournal проверяет баланс журнала и сохраняет его
+func (s *WalletService) postJournal(ctx context.Context, uow domain.UnitOfWork, journal *domain.Journal) error {
+	if err := journal.Validate(); err != nil {
+		s.log.Error("Journal is not balanced", zap.Object("journal", journal), zap.Error(err))
+		return err
+	}
+
+	return uow.Ledger().PostJournal(ctx, journal)
 }
 
-func (s *WalletService) GetBalance(ctx context.Context, id uuid.UUID) (decimal.Decimal, error) {
-	s.log.Debug("Get balance", zap.Any("id", id))
-	balance, err := s.uowFactory.Wallets().GetBalance(ctx, id)
+func (s *WalletService) GetWallet(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	s.log.Debug("Get wallet", zap.Stringer("wallet_id", id))
+	var wallet *domain.Wallet
+	err := s.read(ctx, func(ctx context.Context, uow domain.UnitOfWork) (err error) {
+		wallet, err = uow.Wallets().Get(ctx, id)
+		return err
+	})
 	if err != nil {
-		s.log.Error("Failed to get balance", zap.Error(err), zap.Any("id", id))
-		return decimal.Zero, err
+		s.log.Error("Failed to get wallet", zap.Error(err), zap.Stringer("wallet_id", id))
+		return nil, err
 	}
-	return balance, nil
+	return wallet, nil
 }
-func (s *WalletService) CreateWallet(ctx context.Context) (*domain.Wallet, error) {
+
+// SetCreditLimit устанавливает кредитный лимит кошелька.
+// Новый лимит должен покрывать текущую задолженность, иначе баланс нарушит ограничение в БД
+func (s *WalletService) SetCreditLimit(ctx context.Context, id uuid.UUID, creditLimit decimal.Decimal) (*domain.Wallet, error) {
+	s.log.Debug("Set credit limit", zap.Stringer("wallet_id", id), zap.String("credit_limit", creditLimit.String()))
+	if id == uuid.Nil {
+		return nil, domain.ErrIDIsNil
+	}
+	if creditLimit.IsNegative() {
+		s.log.Warn("Credit limit is negative", zap.Stringer("wallet_id", id), zap.String("credit_limit", creditLimit.String()))
+		return nil, domain.ErrCreditLimitNegative
+	}
+
+	var updated *domain.Wallet
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		walletRepo := uow.Wallets()
+
+		wallet, err := walletRepo.GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if wallet.Balance.Add(creditLimit).IsNegative() {
+			s.log.Warn("Credit limit does not cover current debt", zap.Stringer("wallet_id", id),
+				zap.String("balance", wallet.Balance.String()), zap.String("credit_limit", creditLimit.String()))
+			return domain.ErrCreditLimitTooLow
+		}
+
+		if err := walletRepo.UpdateCreditLimit(ctx, id, creditLimit); err != nil {
+			return err
+		}
+		wallet.CreditLimit = creditLimit
+		updated = wallet
+		return nil
+	})
+	if err != nil {
+		s.log.Error("Failed to set credit limit", zap.Error(err), zap.Stringer("wallet_id", id))
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// CreateWallet создает кошелек с реквизитами details. Если у клиента details.ClientID уже есть кошелек
+// с details.ExternalID, новый не создается: возвращается существующий и created = false
+func (s *WalletService) CreateWallet(ctx context.Context, details domain.WalletDetails) (wallet *domain.Wallet, created bool, err error) {
+	if err := details.Validate(); err != nil {
+		s.log.Warn("Invalid wallet details", zap.Error(err))
+		return nil, false, err
+	}
 	newID := uuid.New()
-	s.log.Debug("Generated new wallet ID", zap.String("id", newID.String()))
+	s.log.Debug("Generated new wallet ID", zap.Stringer("wallet_id", newID))
 
+	metadata := details.Metadata
+	if metadata == nil {
+		metadata = map[string]any{}
+	}
 	newWallet := &domain.Wallet{
-		ID:      newID,
-		Balance: initialBalance,
+		ID:          newID,
+		Balance:     initialBalance,
+		CreditLimit: decimal.Zero,
+		Tier:        domain.DefaultTier,
+		Currency:    domain.DefaultCurrency,
+		Status:      domain.WalletActive,
+		ClientID:    details.ClientID,
+		ExternalID:  details.ExternalID,
+		OwnerID:     details.OwnerID,
+		Label:       details.Label,
+		Metadata:    metadata,
 	}
 
-	err := s.uowFactory.Wallets().Create(ctx, newWallet)
+	var result createdWallet
+	err = s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		if replayed, err := s.replay(ctx, uow, &result); err != nil || replayed {
+			return err
+		}
+		if details.ExternalID != "" {
+			existing, err := uow.Wallets().GetByExternalID(ctx, details.ClientID, details.ExternalID)
+			if err == nil {
+				s.log.Debug("Wallet with external ID already exists", zap.Stringer("wallet_id", existing.ID))
+				result = createdWallet{Wallet: existing}
+				return s.remember(ctx, uow, result)
+			}
+			if !errors.Is(err, domain.ErrWalletNotFound) {
+				s.log.Error("Failed to find wallet by external ID", zap.Error(err))
+				return fmt.Errorf("failed to find wallet by external id: %w", err)
+			}
+		}
+
+		if err := uow.Wallets().Create(ctx, newWallet); err != nil {
+			return err
+		}
+		if err := uow.Ledger().CreateWalletAccount(ctx, newWallet); err != nil {
+			return err
+		}
+		result = createdWallet{Wallet: newWallet, Created: true}
+		return s.remember(ctx, uow, result)
+	})
+	if errors.Is(err, domain.ErrExternalIDExists) {
+		// Параллельный запрос с тем же внешним ID успел создать кошелек первым
+		existing, getErr := s.store(ctx).Wallets().GetByExternalID(ctx, details.ClientID, details.ExternalID)
+		if getErr != nil {
+			s.log.Error("Failed to find wallet by external ID", zap.Error(getErr))
+			return nil, false, fmt.Errorf("failed to find wallet by external id: %w", getErr)
+		}
+		return existing, false, nil
+	}
+	if errors.Is(err, domain.ErrIdempotencyKeyReused) {
+		return nil, false, err
+	}
 	if err != nil {
 		s.log.Error("Failed to save new wallet to repository", zap.Error(err))
-		return nil, fmt.Errorf("failed to save new wallet to repository: %w", err)
+		return nil, false, fmt.Errorf("failed to save new wallet to repository: %w", err)
 	}
 
-	return newWallet, nil
+	return result.Wallet, result.Created, nil
+}
+
+// createdWallet - результат CreateWallet, сохраняемый по ключу идемпотентности
+type createdWallet struct {
+	Wallet  *domain.Wallet
+	Created bool
+}
+
+// FindWalletByExternalID возвращает кошелек клиента по внешнему ID
+func (s *WalletService) FindWalletByExternalID(ctx context.Context, clientID, externalID string) (*domain.Wallet, error) {
+	var wallet *domain.Wallet
+	err := s.read(ctx, func(ctx context.Context, uow domain.UnitOfWork) (err error) {
+		wallet, err = uow.Wallets().GetByExternalID(ctx, clientID, externalID)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, domain.ErrWalletNotFound) {
+			s.log.Error("Failed to find wallet by external ID", zap.Error(err))
+		}
+		return nil, err
+	}
+	return wallet, nil
+}
+
+// ListWallets возвращает страницу списка кошельков. Страница и количество читаются в одной транзакции,
+// чтобы Total соответствовал выдаче
+func (s *WalletService) ListWallets(ctx context.Context, query domain.WalletListQuery) (*domain.WalletPage, error) {
+	if query.Sort == "" {
+		query.Sort = domain.SortByCreatedAt
+	}
+	if query.Limit <= 0 {
+		query.Limit = domain.DefaultWalletPageSize
+	}
+	if err := s.validateListQuery(query); err != nil {
+		s.log.Warn("Invalid wallet list query", zap.Error(err))
+		return nil, err
+	}
+
+	page := &domain.WalletPage{}
+	err := s.read(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		// Лишний кошелек показывает, что за страницей есть следующая
+		pageQuery := query
+		pageQuery.Limit++
+		wallets, err := uow.Wallets().List(ctx, pageQuery)
+		if err != nil {
+			return err
+		}
+		if len(wallets) > query.Limit {
+			wallets = wallets[:query.Limit]
+			page.Next = domain.CursorAfter(&wallets[len(wallets)-1], query.Sort, query.Desc)
+		}
+		page.Wallets = wallets
+
+		if query.WithTotal {
+			total, err := uow.Wallets().Count(ctx, query.Filter)
+			if err != nil {
+				return err
+			}
+			page.Total = &total
+		}
+		return nil
+	}, domain.WithIsolation(domain.RepeatableRead))
+	if err != nil {
+		s.log.Error("Failed to list wallets", zap.Error(err))
+		return nil, fmt.Errorf("failed to list wallets: %w", err)
+	}
+	return page, nil
+}
+
+func (s *WalletService) validateListQuery(query domain.WalletListQuery) error {
+	if query.Sort != domain.SortByCreatedAt && query.Sort != domain.SortByBalance {
+		return fmt.Errorf("%w: unknown sort %q", domain.ErrInvalidWalletQuery, query.Sort)
+	}
+	if query.Limit > domain.MaxWalletPageSize {
+		return fmt.Errorf("%w: limit must not exceed %d", domain.ErrInvalidWalletQuery, domain.MaxWalletPageSize)
+	}
+	if query.After != nil && (query.After.Sort != query.Sort || query.After.Desc != query.Desc) {
+		return fmt.Errorf("%w: cursor was issued for a different sort order", domain.ErrInvalidWalletQuery)
+	}
+	return query.Filter.Validate()
+}
+
+// UpdateWallet меняет название и метаданные кошелька. Метаданные объединяются с текущими по правилам JSON Merge Patch
+func (s *WalletService) UpdateWallet(ctx context.Context, id uuid.UUID, update domain.WalletUpdate) (*domain.Wallet, error) {
+	s.log.Debug("Update wallet", zap.Stringer("wallet_id", id))
+	if id == uuid.Nil {
+		return nil, domain.ErrIDIsNil
+	}
+
+	var wallet *domain.Wallet
+	err := s.do(ctx, func(ctx context.Context, uow domain.UnitOfWork) error {
+		var err error
+		wallet, err = uow.Wallets().GetForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		details := update.Apply(wallet)
+		if err := details.Validate(); err != nil {
+			return err
+		}
+		if err := uow.Wallets().UpdateDetails(ctx, id, details.Label, details.Metadata); err != nil {
+			return err
+		}
+		wallet.Label, wallet.Metadata = details.Label, details.Metadata
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, domain.ErrWalletNotFound) || errors.Is(err, domain.ErrInvalidWalletDetails) {
+			s.log.Warn("Failed to update wallet", zap.Stringer("wallet_id", id), zap.Error(err))
+			return nil, err
+		}
+		s.log.Error("Failed to update wallet", zap.Stringer("wallet_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to update wallet: %w", err)
+	}
+	return wallet, nil
 }
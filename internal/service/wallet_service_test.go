@@ -2,25 +2,36 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"go.uber.org/zap"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"testtask/internal/domain"
+	"testtask/internal/repository/memory"
 
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockWalletRepository struct {
 	mock.Mock
 }
 
-func (m *MockWalletRepository) GetBalanceForUpdate(ctx context.Context, walletID uuid.UUID) (decimal.Decimal, error) {
+func (m *MockWalletRepository) GetForUpdate(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
 	args := m.Called(ctx, walletID)
-	return args.Get(0).(decimal.Decimal), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
 }
 
 func (m *MockWalletRepository) UpdateBalance(ctx context.Context, walletID uuid.UUID, newBalance decimal.Decimal) error {
@@ -28,25 +39,365 @@ func (m *MockWalletRepository) UpdateBalance(ctx context.Context, walletID uuid.
 	return args.Error(0)
 }
 
-func (m *MockWalletRepository) GetBalance(ctx context.Context, walletID uuid.UUID) (decimal.Decimal, error) {
-	// ...
-	return decimal.Zero, nil
+func (m *MockWalletRepository) UpdateCreditLimit(ctx context.Context, walletID uuid.UUID, creditLimit decimal.Decimal) error {
+	args := m.Called(ctx, walletID, creditLimit)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) UpdateTier(ctx context.Context, walletID uuid.UUID, tier domain.WalletTier) error {
+	args := m.Called(ctx, walletID, tier)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) UpdateStatus(ctx context.Context, walletID uuid.UUID, status domain.WalletStatus) error {
+	args := m.Called(ctx, walletID, status)
+	return args.Error(0)
+}
+
+func (m *MockWalletRepository) Get(ctx context.Context, walletID uuid.UUID) (*domain.Wallet, error) {
+	args := m.Called(ctx, walletID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+func (m *MockWalletRepository) UpdateDetails(ctx context.Context, walletID uuid.UUID, label string, metadata map[string]any) error {
+	args := m.Called(ctx, walletID, label, metadata)
+	return args.Error(0)
 }
+
+func (m *MockWalletRepository) GetByExternalID(ctx context.Context, clientID, externalID string) (*domain.Wallet, error) {
+	args := m.Called(ctx, clientID, externalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) List(ctx context.Context, query domain.WalletListQuery) ([]domain.Wallet, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Wallet), args.Error(1)
+}
+
+func (m *MockWalletRepository) Count(ctx context.Context, filter domain.WalletFilter) (int, error) {
+	args := m.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
 func (m *MockWalletRepository) Create(ctx context.Context, wallet *domain.Wallet) error {
 	// ...
 	return nil
 }
 
+type MockOperationRepository struct {
+	mock.Mock
+}
+
+func (m *MockOperationRepository) Add(ctx context.Context, operation *domain.Operation) error {
+	args := m.Called(ctx, operation)
+	return args.Error(0)
+}
+
+func (m *MockOperationRepository) SumSince(ctx context.Context, walletID uuid.UUID, operationTypes []domain.OperationType, since time.Time) (decimal.Decimal, error) {
+	args := m.Called(ctx, walletID, operationTypes, since)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func (m *MockOperationRepository) ListRecent(ctx context.Context, walletID uuid.UUID, limit int) ([]domain.Operation, error) {
+	args := m.Called(ctx, walletID, limit)
+	return args.Get(0).([]domain.Operation), args.Error(1)
+}
+
+type MockLimitRepository struct {
+	mock.Mock
+}
+
+func (m *MockLimitRepository) GetEffective(ctx context.Context, walletID uuid.UUID) (domain.SpendingLimits, error) {
+	args := m.Called(ctx, walletID)
+	return args.Get(0).(domain.SpendingLimits), args.Error(1)
+}
+
+func (m *MockLimitRepository) SetForWallet(ctx context.Context, walletID uuid.UUID, limits domain.SpendingLimits) error {
+	args := m.Called(ctx, walletID, limits)
+	return args.Error(0)
+}
+
+func (m *MockLimitRepository) SetForTier(ctx context.Context, tier domain.WalletTier, limits domain.SpendingLimits) error {
+	args := m.Called(ctx, tier, limits)
+	return args.Error(0)
+}
+
+type MockTierRepository struct {
+	mock.Mock
+}
+
+func (m *MockTierRepository) GetRules(ctx context.Context, tier domain.WalletTier) (domain.TierRules, error) {
+	args := m.Called(ctx, tier)
+	return args.Get(0).(domain.TierRules), args.Error(1)
+}
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Add(ctx context.Context, entry *domain.AuditEntry) error {
+	args := m.Called(ctx, entry)
+	return args.Error(0)
+}
+
+type MockFeeSchedule struct {
+	mock.Mock
+}
+
+func (m *MockFeeSchedule) FindRule(ctx context.Context, operationType domain.OperationType, tier domain.WalletTier) (*domain.FeeRule, error) {
+	args := m.Called(ctx, operationType, tier)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.FeeRule), args.Error(1)
+}
+
+type MockLedgerRepository struct {
+	mock.Mock
+}
+
+func (m *MockLedgerRepository) CreateWalletAccount(ctx context.Context, wallet *domain.Wallet) error {
+	args := m.Called(ctx, wallet)
+	return args.Error(0)
+}
+
+func (m *MockLedgerRepository) SystemAccountID(ctx context.Context, account domain.SystemAccount, currency string) (uuid.UUID, error) {
+	args := m.Called(ctx, account, currency)
+	return args.Get(0).(uuid.UUID), args.Error(1)
+}
+
+func (m *MockLedgerRepository) PostJournal(ctx context.Context, journal *domain.Journal) error {
+	args := m.Called(ctx, journal)
+	return args.Error(0)
+}
+
+func (m *MockLedgerRepository) WalletBalances(ctx context.Context, after uuid.UUID, limit int) ([]domain.WalletBalance, error) {
+	args := m.Called(ctx, after, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.WalletBalance), args.Error(1)
+}
+
+func (m *MockLedgerRepository) AccountBalance(ctx context.Context, accountID uuid.UUID) (decimal.Decimal, error) {
+	args := m.Called(ctx, accountID)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func (m *MockLedgerRepository) BalanceAt(ctx context.Context, accountID uuid.UUID, at time.Time) (decimal.Decimal, error) {
+	args := m.Called(ctx, accountID, at)
+	return args.Get(0).(decimal.Decimal), args.Error(1)
+}
+
+func (m *MockLedgerRepository) DailyChanges(ctx context.Context, accountID uuid.UUID, from, to time.Time, loc *time.Location) ([]domain.DailyChange, error) {
+	args := m.Called(ctx, accountID, from, to, loc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.DailyChange), args.Error(1)
+}
+
+func (m *MockLedgerRepository) CreateSnapshots(ctx context.Context, at time.Time, after uuid.UUID, limit int) (int, uuid.UUID, error) {
+	args := m.Called(ctx, at, after, limit)
+	return args.Int(0), args.Get(1).(uuid.UUID), args.Error(2)
+}
+
+func (m *MockLedgerRepository) StreamPostings(ctx context.Context, accountID uuid.UUID, from, to time.Time, fn func(line domain.StatementLine) error) error {
+	args := m.Called(ctx, accountID, from, to, fn)
+	if lines, ok := args.Get(0).([]domain.StatementLine); ok {
+		for _, line := range lines {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+	}
+	return args.Error(1)
+}
+
+type MockImportRepository struct {
+	mock.Mock
+}
+
+func (m *MockImportRepository) Create(ctx context.Context, imp *domain.Import) error {
+	args := m.Called(ctx, imp)
+	return args.Error(0)
+}
+
+func (m *MockImportRepository) Get(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) Stage(ctx context.Context, importID uuid.UUID, next domain.ImportRowSource) (int, error) {
+	args := m.Called(ctx, importID, next)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockImportRepository) SetStatus(ctx context.Context, id uuid.UUID, status domain.ImportStatus, errMsg string) (*domain.Import, error) {
+	args := m.Called(ctx, id, status, errMsg)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) Claim(ctx context.Context, id uuid.UUID, staleBefore time.Time) (*domain.Import, error) {
+	args := m.Called(ctx, id, staleBefore)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Import), args.Error(1)
+}
+
+func (m *MockImportRepository) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockImportRepository) Claimable(ctx context.Context, staleBefore time.Time, limit int) ([]uuid.UUID, error) {
+	args := m.Called(ctx, staleBefore, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockImportRepository) PendingRows(ctx context.Context, importID uuid.UUID, afterLine int, limit int) ([]domain.ImportRow, error) {
+	args := m.Called(ctx, importID, afterLine, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ImportRow), args.Error(1)
+}
+
+func (m *MockImportRepository) MarkRow(ctx context.Context, row *domain.ImportRow) error {
+	args := m.Called(ctx, *row)
+	return args.Error(0)
+}
+
+func (m *MockImportRepository) StreamFailedRows(ctx context.Context, importID uuid.UUID, fn func(row domain.ImportRow) error) error {
+	args := m.Called(ctx, importID, fn)
+	return args.Error(0)
+}
+
+// recordingStatementWriter запоминает записанную выписку
+type recordingStatementWriter struct {
+	header domain.StatementHeader
+	lines  []domain.StatementLine
+	footer domain.StatementFooter
+}
+
+func (w *recordingStatementWriter) WriteHeader(header domain.StatementHeader) error {
+	w.header = header
+	return nil
+}
+
+func (w *recordingStatementWriter) WriteLine(line domain.StatementLine) error {
+	w.lines = append(w.lines, line)
+	return nil
+}
+
+func (w *recordingStatementWriter) WriteFooter(footer domain.StatementFooter) error {
+	w.footer = footer
+	return nil
+}
+
+// Фиксированные ID системных счетов для проверки проводок в тестах
+var (
+	cashInAccountID  = uuid.MustParse("c0000000-0000-0000-0000-000000000001")
+	cashOutAccountID = uuid.MustParse("c0000000-0000-0000-0000-000000000002")
+	adjustAccountID  = uuid.MustParse("c0000000-0000-0000-0000-000000000003")
+)
+
 type MockUoW struct {
 	mock.Mock
-	Repo *MockWalletRepository
+	Repo       *MockWalletRepository
+	Ops        *MockOperationRepository
+	LimitsRepo *MockLimitRepository
+	TiersRepo  *MockTierRepository
+	AuditRepo  *MockAuditRepository
+	FeesRepo   *MockFeeSchedule
+	LedgerRepo *MockLedgerRepository
+	ImportRepo *MockImportRepository
+	// TxOptions - параметры транзакций, открытых через Do
+	TxOptions []domain.TxOptions
+}
+
+// newMockUoW создает UoW, в котором история операций и журнал аудита принимают любые записи,
+// журнал проводок принимает любые журналы, а лимиты на списания, ограничения уровней и комиссии не заданы
+func newMockUoW(repo *MockWalletRepository) *MockUoW {
+	ops := new(MockOperationRepository)
+	ops.On("Add", mock.Anything, mock.Anything).Return(nil).Maybe()
+	limits := new(MockLimitRepository)
+	limits.On("GetEffective", mock.Anything, mock.Anything).Return(domain.SpendingLimits{}, nil).Maybe()
+	tiers := new(MockTierRepository)
+	tiers.On("GetRules", mock.Anything, mock.Anything).Return(domain.TierRules{}, nil).Maybe()
+	audit := new(MockAuditRepository)
+	audit.On("Add", mock.Anything, mock.Anything).Return(nil).Maybe()
+	fees := new(MockFeeSchedule)
+	fees.On("FindRule", mock.Anything, mock.Anything, mock.Anything).Return(nil, nil).Maybe()
+	ledger := new(MockLedgerRepository)
+	ledger.On("SystemAccountID", mock.Anything, domain.CashIn, mock.Anything).Return(cashInAccountID, nil).Maybe()
+	ledger.On("SystemAccountID", mock.Anything, domain.CashOut, mock.Anything).Return(cashOutAccountID, nil).Maybe()
+	ledger.On("SystemAccountID", mock.Anything, domain.Adjustments, mock.Anything).Return(adjustAccountID, nil).Maybe()
+	ledger.On("PostJournal", mock.Anything, mock.Anything).Return(nil).Maybe()
+	ledger.On("CreateWalletAccount", mock.Anything, mock.Anything).Return(nil).Maybe()
+	return &MockUoW{
+		Repo: repo, Ops: ops, LimitsRepo: limits, TiersRepo: tiers, AuditRepo: audit, FeesRepo: fees,
+		LedgerRepo: ledger, ImportRepo: new(MockImportRepository),
+	}
 }
 
 func (m *MockUoW) Wallets() domain.WalletRepository {
 	return m.Repo
 }
 
-func (m *MockUoW) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error) error {
+func (m *MockUoW) Operations() domain.OperationRepository {
+	return m.Ops
+}
+
+func (m *MockUoW) Limits() domain.LimitRepository {
+	return m.LimitsRepo
+}
+
+func (m *MockUoW) Tiers() domain.TierRepository {
+	return m.TiersRepo
+}
+
+func (m *MockUoW) Audit() domain.AuditRepository {
+	return m.AuditRepo
+}
+
+func (m *MockUoW) Fees() domain.FeeSchedule {
+	return m.FeesRepo
+}
+
+func (m *MockUoW) Ledger() domain.LedgerRepository {
+	return m.LedgerRepo
+}
+
+func (m *MockUoW) Imports() domain.ImportRepository {
+	return m.ImportRepo
+}
+
+// Idempotency не нужен тестам с моками: они не передают ключ идемпотентности
+func (m *MockUoW) Idempotency() domain.IdempotencyRepository {
+	return nil
+}
+
+func (m *MockUoW) Do(ctx context.Context, fn func(uow domain.UnitOfWork) error, opts ...domain.TxOption) error {
+	m.TxOptions = append(m.TxOptions, domain.NewTxOptions(opts...))
 	return fn(m)
 }
 
@@ -64,7 +415,7 @@ func TestWalletService_PerformOperation(t *testing.T) {
 			name: "Успешное пополнение",
 			req:  domain.OperationRequest{ID: walletID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)},
 			setupMock: func(repo *MockWalletRepository) {
-				repo.On("GetBalanceForUpdate", mock.Anything, walletID).Return(decimal.NewFromInt(50), nil)
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(50)}, nil)
 				repo.On("UpdateBalance", mock.Anything, walletID, decimal.NewFromInt(150)).Return(nil)
 			},
 			expectedError: nil,
@@ -73,7 +424,7 @@ func TestWalletService_PerformOperation(t *testing.T) {
 			name: "Успешное списание",
 			req:  domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(50)},
 			setupMock: func(repo *MockWalletRepository) {
-				repo.On("GetBalanceForUpdate", mock.Anything, walletID).Return(decimal.NewFromInt(100), nil)
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(100)}, nil)
 				repo.On("UpdateBalance", mock.Anything, walletID, decimal.NewFromInt(50)).Return(nil)
 			},
 			expectedError: nil,
@@ -82,7 +433,7 @@ func TestWalletService_PerformOperation(t *testing.T) {
 			name: "Ошибка: кошелек не найден",
 			req:  domain.OperationRequest{ID: walletID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)},
 			setupMock: func(repo *MockWalletRepository) {
-				repo.On("GetBalanceForUpdate", mock.Anything, walletID).Return(decimal.Zero, domain.ErrWalletNotFound)
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(nil, domain.ErrWalletNotFound)
 			},
 			expectedError: domain.ErrWalletNotFound,
 		},
@@ -90,7 +441,24 @@ func TestWalletService_PerformOperation(t *testing.T) {
 			name: "Ошибка: недостаточно средств",
 			req:  domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(200)},
 			setupMock: func(repo *MockWalletRepository) {
-				repo.On("GetBalanceForUpdate", mock.Anything, walletID).Return(decimal.NewFromInt(100), nil)
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(100)}, nil)
+			},
+			expectedError: domain.ErrInsufficientFunds,
+		},
+		{
+			name: "Успешное списание в пределах кредитного лимита",
+			req:  domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(150)},
+			setupMock: func(repo *MockWalletRepository) {
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(100), CreditLimit: decimal.NewFromInt(100)}, nil)
+				repo.On("UpdateBalance", mock.Anything, walletID, decimal.NewFromInt(-50)).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name: "Ошибка: превышен кредитный лимит",
+			req:  domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(250)},
+			setupMock: func(repo *MockWalletRepository) {
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(100), CreditLimit: decimal.NewFromInt(100)}, nil)
 			},
 			expectedError: domain.ErrInsufficientFunds,
 		},
@@ -99,21 +467,1366 @@ func TestWalletService_PerformOperation(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockWalletRepository)
-			mockUOW := &MockUoW{Repo: mockRepo}
+			mockUOW := newMockUoW(mockRepo)
+
+			tt.setupMock(mockRepo)
+
+			service := NewWalletService(mockUOW, logger)
+			_, err := service.PerformOperation(context.Background(), tt.req)
+
+			if tt.expectedError != nil {
+				assert.Error(t, err)
+				assert.True(t, errors.Is(err, tt.expectedError))
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletService_SetCreditLimit(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+
+	tests := []struct {
+		name          string
+		creditLimit   decimal.Decimal
+		setupMock     func(repo *MockWalletRepository)
+		expectedError error
+	}{
+		{
+			name:        "Успешная установка лимита",
+			creditLimit: decimal.NewFromInt(500),
+			setupMock: func(repo *MockWalletRepository) {
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(-100), CreditLimit: decimal.NewFromInt(200)}, nil)
+				repo.On("UpdateCreditLimit", mock.Anything, walletID, decimal.NewFromInt(500)).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:          "Ошибка: отрицательный лимит",
+			creditLimit:   decimal.NewFromInt(-1),
+			setupMock:     func(repo *MockWalletRepository) {},
+			expectedError: domain.ErrCreditLimitNegative,
+		},
+		{
+			name:        "Ошибка: лимит не покрывает задолженность",
+			creditLimit: decimal.NewFromInt(50),
+			setupMock: func(repo *MockWalletRepository) {
+				repo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(-100), CreditLimit: decimal.NewFromInt(200)}, nil)
+			},
+			expectedError: domain.ErrCreditLimitTooLow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockWalletRepository)
+			mockUOW := newMockUoW(mockRepo)
 
 			tt.setupMock(mockRepo)
 
 			service := NewWalletService(mockUOW, logger)
-			err := service.PerformOperation(context.Background(), tt.req)
+			wallet, err := service.SetCreditLimit(context.Background(), walletID, tt.creditLimit)
 
 			if tt.expectedError != nil {
 				assert.Error(t, err)
 				assert.True(t, errors.Is(err, tt.expectedError))
 			} else {
 				assert.NoError(t, err)
+				assert.True(t, tt.creditLimit.Equal(wallet.CreditLimit))
 			}
 
 			mockRepo.AssertExpectations(t)
 		})
 	}
 }
+
+func TestWalletService_PerformOperation_SpendingLimits(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+	now := time.Date(2025, 3, 31, 20, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name              string
+		amount            decimal.Decimal
+		limits            domain.SpendingLimits
+		setupOps          func(ops *MockOperationRepository)
+		expectedLimit     domain.LimitKind
+		expectedRemaining decimal.Decimal
+	}{
+		{
+			name:              "Превышен лимит на одну операцию",
+			amount:            decimal.NewFromInt(600),
+			limits:            domain.SpendingLimits{SingleWithdrawal: decimal.NullDecimal{Decimal: decimal.NewFromInt(500), Valid: true}},
+			setupOps:          func(ops *MockOperationRepository) {},
+			expectedLimit:     domain.LimitSingleWithdrawal,
+			expectedRemaining: decimal.NewFromInt(500),
+		},
+		{
+			name:   "Превышен дневной лимит",
+			amount: decimal.NewFromInt(300),
+			limits: domain.SpendingLimits{DailyWithdrawal: decimal.NullDecimal{Decimal: decimal.NewFromInt(1000), Valid: true}},
+			setupOps: func(ops *MockOperationRepository) {
+				ops.On("SumSince", mock.Anything, walletID, domain.OutgoingOperationTypes, now.Add(-domain.DailyLimitWindow)).Return(decimal.NewFromInt(800), nil)
+			},
+			expectedLimit:     domain.LimitDailyWithdrawal,
+			expectedRemaining: decimal.NewFromInt(200),
+		},
+		{
+			name:   "Превышен месячный лимит при непревышенном дневном",
+			amount: decimal.NewFromInt(300),
+			limits: domain.SpendingLimits{
+				DailyWithdrawal:   decimal.NullDecimal{Decimal: decimal.NewFromInt(1000), Valid: true},
+				MonthlyWithdrawal: decimal.NullDecimal{Decimal: decimal.NewFromInt(5000), Valid: true},
+			},
+			setupOps: func(ops *MockOperationRepository) {
+				ops.On("SumSince", mock.Anything, walletID, domain.OutgoingOperationTypes, now.Add(-domain.DailyLimitWindow)).Return(decimal.Zero, nil)
+				ops.On("SumSince", mock.Anything, walletID, domain.OutgoingOperationTypes, now.Add(-domain.MonthlyLimitWindow)).Return(decimal.NewFromInt(4900), nil)
+			},
+			expectedLimit:     domain.LimitMonthlyWithdrawal,
+			expectedRemaining: decimal.NewFromInt(100),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockWalletRepository)
+			mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(10000)}, nil)
+			mockOps := new(MockOperationRepository)
+			tt.setupOps(mockOps)
+			mockLimits := new(MockLimitRepository)
+			mockLimits.On("GetEffective", mock.Anything, walletID).Return(tt.limits, nil)
+			mockUOW := newMockUoW(mockRepo)
+			mockUOW.Ops = mockOps
+			mockUOW.LimitsRepo = mockLimits
+
+			service := NewWalletService(mockUOW, logger)
+			service.now = func() time.Time { return now }
+			_, err := service.PerformOperation(context.Background(), domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: tt.amount})
+
+			assert.True(t, errors.Is(err, domain.ErrLimitExceeded))
+			var limitErr *domain.LimitExceededError
+			if assert.True(t, errors.As(err, &limitErr)) {
+				assert.Equal(t, tt.expectedLimit, limitErr.Limit)
+				assert.True(t, tt.expectedRemaining.Equal(limitErr.Remaining))
+			}
+
+			mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything)
+			mockOps.AssertExpectations(t)
+		})
+	}
+}
+
+func TestWalletService_PerformOperation_TierCaps(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+	now := time.Date(2025, 3, 31, 20, 59, 0, 0, time.UTC)
+	rules := domain.TierRules{
+		Tier:               domain.TierAnonymous,
+		Rank:               1,
+		MaxBalance:         decimal.NullDecimal{Decimal: decimal.NewFromInt(15000), Valid: true},
+		MaxMonthlyIncoming: decimal.NullDecimal{Decimal: decimal.NewFromInt(40000), Valid: true},
+	}
+
+	tests := []struct {
+		name              string
+		balance           decimal.Decimal
+		amount            decimal.Decimal
+		incoming          decimal.Decimal
+		expectedLimit     domain.LimitKind
+		expectedRemaining decimal.Decimal
+	}{
+		{
+			name:              "Превышен максимальный баланс",
+			balance:           decimal.NewFromInt(14000),
+			amount:            decimal.NewFromInt(2000),
+			expectedLimit:     domain.LimitMaxBalance,
+			expectedRemaining: decimal.NewFromInt(1000),
+		},
+		{
+			name:              "Превышен месячный объем пополнений",
+			balance:           decimal.NewFromInt(1000),
+			amount:            decimal.NewFromInt(5000),
+			incoming:          decimal.NewFromInt(38000),
+			expectedLimit:     domain.LimitMonthlyIncoming,
+			expectedRemaining: decimal.NewFromInt(2000),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockWalletRepository)
+			mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: tt.balance, Tier: domain.TierAnonymous}, nil)
+			mockUOW := newMockUoW(mockRepo)
+			mockUOW.TiersRepo = new(MockTierRepository)
+			mockUOW.TiersRepo.On("GetRules", mock.Anything, domain.TierAnonymous).Return(rules, nil)
+			mockUOW.Ops = new(MockOperationRepository)
+			mockUOW.Ops.On("SumSince", mock.Anything, walletID, domain.IncomingOperationTypes, now.Add(-domain.MonthlyLimitWindow)).Return(tt.incoming, nil).Maybe()
+
+			service := NewWalletService(mockUOW, logger)
+			service.now = func() time.Time { return now }
+			_, err := service.PerformOperation(context.Background(), domain.OperationRequest{ID: walletID, OperationType: domain.Deposit, Amount: tt.amount})
+
+			var limitErr *domain.LimitExceededError
+			if assert.True(t, errors.As(err, &limitErr)) {
+				assert.Equal(t, tt.expectedLimit, limitErr.Limit)
+				assert.True(t, tt.expectedRemaining.Equal(limitErr.Remaining))
+			}
+			mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestWalletService_UpgradeTier(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+	anonymous := domain.TierRules{Tier: domain.TierAnonymous, Rank: 1}
+	verified := domain.TierRules{Tier: domain.TierVerified, Rank: 3}
+
+	t.Run("Успешное повышение уровня с записью в аудит", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Tier: domain.TierAnonymous}, nil)
+		mockRepo.On("UpdateTier", mock.Anything, walletID, domain.TierVerified).Return(nil)
+		mockUOW := newMockUoW(mockRepo)
+		mockUOW.TiersRepo = new(MockTierRepository)
+		mockUOW.TiersRepo.On("GetRules", mock.Anything, domain.TierAnonymous).Return(anonymous, nil)
+		mockUOW.TiersRepo.On("GetRules", mock.Anything, domain.TierVerified).Return(verified, nil)
+		mockUOW.AuditRepo = new(MockAuditRepository)
+		mockUOW.AuditRepo.On("Add", mock.Anything, mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+			return entry.WalletID == walletID && entry.Action == domain.AuditTierChanged &&
+				entry.OldValue == "ANONYMOUS" && entry.NewValue == "VERIFIED" && entry.Actor == "support"
+		})).Return(nil)
+
+		service := NewWalletService(mockUOW, logger)
+		wallet, err := service.UpgradeTier(context.Background(), walletID, domain.TierVerified, "support", "passport checked")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.TierVerified, wallet.Tier)
+		mockRepo.AssertExpectations(t)
+		mockUOW.AuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Ошибка: понижение уровня", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Tier: domain.TierVerified}, nil)
+		mockUOW := newMockUoW(mockRepo)
+		mockUOW.TiersRepo = new(MockTierRepository)
+		mockUOW.TiersRepo.On("GetRules", mock.Anything, domain.TierVerified).Return(verified, nil)
+		mockUOW.TiersRepo.On("GetRules", mock.Anything, domain.TierAnonymous).Return(anonymous, nil)
+
+		service := NewWalletService(mockUOW, logger)
+		_, err := service.UpgradeTier(context.Background(), walletID, domain.TierAnonymous, "support", "mistake")
+
+		assert.True(t, errors.Is(err, domain.ErrTierNotUpgrade))
+		mockRepo.AssertNotCalled(t, "UpdateTier", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWalletService_SetWalletStatus(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+	targetID := uuid.New()
+
+	t.Run("Заморозка кошелька с записью в аудит", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Status: domain.WalletActive}, nil)
+		mockRepo.On("UpdateStatus", mock.Anything, walletID, domain.WalletFrozen).Return(nil)
+		mockUOW := newMockUoW(mockRepo)
+		mockUOW.AuditRepo = new(MockAuditRepository)
+		mockUOW.AuditRepo.On("Add", mock.Anything, mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+			return entry.WalletID == walletID && entry.Action == domain.AuditWalletFrozen &&
+				entry.OldValue == "ACTIVE" && entry.NewValue == "FROZEN" && entry.Reason == "chargeback"
+		})).Return(nil)
+
+		service := NewWalletService(mockUOW, logger)
+		wallet, err := service.SetWalletStatus(context.Background(), walletID, domain.WalletFrozen, "support", "chargeback")
+
+		assert.NoError(t, err)
+		assert.Equal(t, domain.WalletFrozen, wallet.Status)
+		mockRepo.AssertExpectations(t)
+		mockUOW.AuditRepo.AssertExpectations(t)
+	})
+
+	t.Run("Повторная заморозка ничего не меняет", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Status: domain.WalletFrozen}, nil)
+		mockUOW := newMockUoW(mockRepo)
+
+		service := NewWalletService(mockUOW, logger)
+		_, err := service.SetWalletStatus(context.Background(), walletID, domain.WalletFrozen, "support", "chargeback")
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+		mockUOW.AuditRepo.AssertNotCalled(t, "Add", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Ошибка: причина не указана", func(t *testing.T) {
+		service := NewWalletService(newMockUoW(new(MockWalletRepository)), logger)
+		_, err := service.SetWalletStatus(context.Background(), walletID, domain.WalletFrozen, "support", "")
+
+		assert.True(t, errors.Is(err, domain.ErrReasonRequired))
+	})
+
+	t.Run("Ошибка: перевод на замороженный кошелек", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(1000), Status: domain.WalletActive}, nil)
+		mockRepo.On("GetForUpdate", mock.Anything, targetID).Return(&domain.Wallet{ID: targetID, Status: domain.WalletFrozen}, nil)
+		mockUOW := newMockUoW(mockRepo)
+
+		service := NewWalletService(mockUOW, logger)
+		_, err := service.PerformOperation(context.Background(), domain.OperationRequest{
+			ID: walletID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(100), TargetID: targetID,
+		})
+
+		assert.True(t, errors.Is(err, domain.ErrWalletFrozen))
+		mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestWalletService_Adjust(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+
+	tests := []struct {
+		name             string
+		amount           decimal.Decimal
+		expectedType     domain.OperationType
+		expectedBalance  string
+		expectedPostings map[uuid.UUID]string
+	}{
+		{
+			name:             "Зачисление проводится против счета корректировок",
+			amount:           decimal.NewFromInt(50),
+			expectedType:     domain.AdjustmentIn,
+			expectedBalance:  "150",
+			expectedPostings: map[uuid.UUID]string{walletID: "50", adjustAccountID: "-50"},
+		},
+		{
+			name:             "Списание с замороженного кошелька",
+			amount:           decimal.NewFromInt(-30),
+			expectedType:     domain.AdjustmentOut,
+			expectedBalance:  "70",
+			expectedPostings: map[uuid.UUID]string{walletID: "-30", adjustAccountID: "30"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockWalletRepository)
+			mockRepo.On("GetForUpdate", mock.Anything, walletID).
+				Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(100), Currency: domain.DefaultCurrency, Status: domain.WalletFrozen}, nil)
+			mockRepo.On("UpdateBalance", mock.Anything, walletID, decimalEq(decimal.RequireFromString(tt.expectedBalance))).Return(nil)
+			mockUOW := newMockUoW(mockRepo)
+			mockUOW.Ops = new(MockOperationRepository)
+			mockUOW.Ops.On("Add", mock.Anything, mock.MatchedBy(func(op *domain.Operation) bool {
+				return op.OperationType == tt.expectedType && op.Amount.Equal(tt.amount.Abs())
+			})).Return(nil)
+			mockUOW.AuditRepo = new(MockAuditRepository)
+			mockUOW.AuditRepo.On("Add", mock.Anything, mock.MatchedBy(func(entry *domain.AuditEntry) bool {
+				return entry.Action == domain.AuditAdjustment && entry.OldValue == "100" &&
+					entry.NewValue == tt.expectedBalance && entry.Reason == "manual fix"
+			})).Return(nil)
+
+			service := NewWalletService(mockUOW, logger)
+			result, err := service.Adjust(context.Background(), walletID, tt.amount, "support", "manual fix")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.OperationType)
+			assert.Equal(t, tt.expectedBalance, result.Balance.String())
+			mockUOW.LedgerRepo.AssertCalled(t, "PostJournal", mock.Anything, mock.MatchedBy(func(j *domain.Journal) bool {
+				return j.Validate() == nil && assert.ObjectsAreEqual(tt.expectedPostings, postingsOf(j))
+			}))
+			mockUOW.Ops.AssertExpectations(t)
+			mockUOW.AuditRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("Ошибка: списание больше доступных средств", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(100)}, nil)
+		mockUOW := newMockUoW(mockRepo)
+
+		service := NewWalletService(mockUOW, logger)
+		_, err := service.Adjust(context.Background(), walletID, decimal.NewFromInt(-150), "support", "manual fix")
+
+		assert.True(t, errors.Is(err, domain.ErrInsufficientFunds))
+		mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Ошибка: нулевая корректировка", func(t *testing.T) {
+		service := NewWalletService(newMockUoW(new(MockWalletRepository)), logger)
+		_, err := service.Adjust(context.Background(), walletID, decimal.Zero, "support", "manual fix")
+
+		assert.True(t, errors.Is(err, domain.ErrAdjustmentZero))
+	})
+}
+
+// decimalEq сравнивает суммы по значению: после округления комиссии у них может отличаться экспонента
+func decimalEq(expected decimal.Decimal) interface{} {
+	return mock.MatchedBy(func(actual decimal.Decimal) bool {
+		return actual.Equal(expected)
+	})
+}
+
+func TestWalletService_PerformOperation_Fees(t *testing.T) {
+	logger := zap.NewNop()
+	// Кошельки блокируются в порядке возрастания ID, поэтому порядок задан явно
+	walletID := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	targetID := uuid.MustParse("20000000-0000-0000-0000-000000000000")
+	revenueID := uuid.MustParse("30000000-0000-0000-0000-000000000000")
+	schedule := StaticFeeSchedule{
+		{OperationType: domain.Withdraw, Fixed: decimal.NewFromInt(10), Percent: decimal.NewFromInt(1)},
+		{
+			OperationType: domain.Transfer,
+			Percent:       decimal.RequireFromString("0.5"),
+			Min:           decimal.NullDecimal{Decimal: decimal.NewFromInt(5), Valid: true},
+		},
+		{OperationType: domain.Transfer, Tier: domain.TierVerified},
+	}
+
+	t.Run("Списание с комиссией на кошелек доходов", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(1000), Tier: domain.TierBasic}, nil)
+		mockRepo.On("GetForUpdate", mock.Anything, revenueID).Return(&domain.Wallet{ID: revenueID, Balance: decimal.NewFromInt(50)}, nil)
+		mockRepo.On("UpdateBalance", mock.Anything, walletID, decimalEq(decimal.NewFromInt(500))).Return(nil)
+		mockRepo.On("UpdateBalance", mock.Anything, revenueID, decimalEq(decimal.NewFromInt(65))).Return(nil)
+		mockUOW := newMockUoW(mockRepo)
+
+		service := NewWalletService(mockUOW, logger, WithFeeSchedule(schedule), WithRevenueWallet(revenueID))
+		result, err := service.PerformOperation(context.Background(), domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(500)})
+
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(500).Equal(result.Gross))
+		assert.True(t, decimal.NewFromInt(15).Equal(result.Fee))
+		assert.True(t, decimal.NewFromInt(485).Equal(result.Net))
+		assert.True(t, decimal.NewFromInt(500).Equal(result.Balance))
+		mockRepo.AssertExpectations(t)
+		mockUOW.Ops.AssertCalled(t, "Add", mock.Anything, mock.MatchedBy(func(op *domain.Operation) bool {
+			return op.WalletID == revenueID && op.OperationType == domain.Fee && op.CorrelationID == result.ID
+		}))
+	})
+
+	t.Run("Перевод с минимальной комиссией", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(1000), Tier: domain.TierBasic}, nil)
+		mockRepo.On("GetForUpdate", mock.Anything, targetID).Return(&domain.Wallet{ID: targetID, Balance: decimal.Zero}, nil)
+		mockRepo.On("GetForUpdate", mock.Anything, revenueID).Return(&domain.Wallet{ID: revenueID, Balance: decimal.Zero}, nil)
+		mockRepo.On("UpdateBalance", mock.Anything, walletID, decimalEq(decimal.NewFromInt(900))).Return(nil)
+		mockRepo.On("UpdateBalance", mock.Anything, targetID, decimalEq(decimal.NewFromInt(95))).Return(nil)
+		mockRepo.On("UpdateBalance", mock.Anything, revenueID, decimalEq(decimal.NewFromInt(5))).Return(nil)
+		mockUOW := newMockUoW(mockRepo)
+
+		service := NewWalletService(mockUOW, logger, WithFeeSchedule(schedule), WithRevenueWallet(revenueID))
+		result, err := service.PerformOperation(context.Background(), domain.OperationRequest{
+			ID: walletID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(100), TargetID: targetID,
+		})
+
+		assert.NoError(t, err)
+		assert.True(t, decimal.NewFromInt(5).Equal(result.Fee))
+		assert.True(t, decimal.NewFromInt(95).Equal(result.Net))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Правило уровня приоритетнее общего", func(t *testing.T) {
+		rule, err := schedule.FindRule(context.Background(), domain.Transfer, domain.TierVerified)
+
+		assert.NoError(t, err)
+		assert.True(t, rule.Calculate(decimal.NewFromInt(100)).IsZero())
+	})
+
+	t.Run("Ошибка: перевод на тот же кошелек", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		service := NewWalletService(newMockUoW(mockRepo), logger)
+		_, err := service.PerformOperation(context.Background(), domain.OperationRequest{
+			ID: walletID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(100), TargetID: walletID,
+		})
+
+		assert.True(t, errors.Is(err, domain.ErrTransferToSameWallet))
+		mockRepo.AssertNotCalled(t, "GetForUpdate", mock.Anything, mock.Anything)
+	})
+}
+
+// postingsOf возвращает проводки журнала в виде "счет -> сумма"
+func postingsOf(journal *domain.Journal) map[uuid.UUID]string {
+	postings := make(map[uuid.UUID]string, len(journal.Postings))
+	for _, p := range journal.Postings {
+		postings[p.AccountID] = p.Amount.String()
+	}
+	return postings
+}
+
+func TestWalletService_PerformOperation_Ledger(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	targetID := uuid.MustParse("20000000-0000-0000-0000-000000000000")
+	revenueID := uuid.MustParse("30000000-0000-0000-0000-000000000000")
+	schedule := StaticFeeSchedule{
+		{OperationType: domain.Withdraw, Fixed: decimal.NewFromInt(10)},
+		{OperationType: domain.Transfer, Fixed: decimal.NewFromInt(5)},
+	}
+
+	tests := []struct {
+		name             string
+		req              domain.OperationRequest
+		expectedPostings map[uuid.UUID]string
+	}{
+		{
+			name:             "Пополнение проводится против счета cash-in",
+			req:              domain.OperationRequest{ID: walletID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)},
+			expectedPostings: map[uuid.UUID]string{walletID: "100", cashInAccountID: "-100"},
+		},
+		{
+			name:             "Вывод проводится на cash-out за вычетом комиссии",
+			req:              domain.OperationRequest{ID: walletID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(100)},
+			expectedPostings: map[uuid.UUID]string{walletID: "-100", cashOutAccountID: "90", revenueID: "10"},
+		},
+		{
+			name:             "Перевод проводится между кошельками",
+			req:              domain.OperationRequest{ID: walletID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(100), TargetID: targetID},
+			expectedPostings: map[uuid.UUID]string{walletID: "-100", targetID: "95", revenueID: "5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockWalletRepository)
+			for _, id := range []uuid.UUID{walletID, targetID, revenueID} {
+				mockRepo.On("GetForUpdate", mock.Anything, id).
+					Return(&domain.Wallet{ID: id, Balance: decimal.NewFromInt(1000), Currency: domain.DefaultCurrency}, nil).Maybe()
+			}
+			mockRepo.On("UpdateBalance", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			mockUOW := newMockUoW(mockRepo)
+
+			service := NewWalletService(mockUOW, logger, WithFeeSchedule(schedule), WithRevenueWallet(revenueID))
+			result, err := service.PerformOperation(context.Background(), tt.req)
+
+			assert.NoError(t, err)
+			mockUOW.LedgerRepo.AssertCalled(t, "PostJournal", mock.Anything, mock.MatchedBy(func(j *domain.Journal) bool {
+				return j.CorrelationID == result.ID && j.Validate() == nil && assert.ObjectsAreEqual(tt.expectedPostings, postingsOf(j))
+			}))
+		})
+	}
+
+	t.Run("Ошибка: перевод между кошельками в разных валютах", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(1000), Currency: "RUB"}, nil)
+		mockRepo.On("GetForUpdate", mock.Anything, targetID).Return(&domain.Wallet{ID: targetID, Currency: "USD"}, nil)
+		mockUOW := newMockUoW(mockRepo)
+
+		service := NewWalletService(mockUOW, logger)
+		_, err := service.PerformOperation(context.Background(), domain.OperationRequest{
+			ID: walletID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(100), TargetID: targetID,
+		})
+
+		assert.True(t, errors.Is(err, domain.ErrCurrencyMismatch))
+		mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything)
+		mockUOW.LedgerRepo.AssertNotCalled(t, "PostJournal", mock.Anything, mock.Anything)
+	})
+}
+
+func TestWalletService_PerformBatch(t *testing.T) {
+	logger := zap.NewNop()
+	firstID := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	secondID := uuid.MustParse("20000000-0000-0000-0000-000000000000")
+
+	t.Run("Atomic: кошельки блокируются в порядке возрастания ID", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, firstID).Return(&domain.Wallet{ID: firstID, Balance: decimal.NewFromInt(100)}, nil)
+		mockRepo.On("GetForUpdate", mock.Anything, secondID).Return(&domain.Wallet{ID: secondID, Balance: decimal.Zero}, nil)
+		mockRepo.On("UpdateBalance", mock.Anything, secondID, decimal.NewFromInt(10)).Return(nil)
+		mockRepo.On("UpdateBalance", mock.Anything, firstID, decimal.NewFromInt(150)).Return(nil)
+		mockRepo.On("UpdateBalance", mock.Anything, firstID, decimal.NewFromInt(120)).Return(nil)
+
+		service := NewWalletService(newMockUoW(mockRepo), logger)
+		results, err := service.PerformBatch(context.Background(), domain.BatchAtomic, []domain.OperationRequest{
+			{ID: secondID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(10)},
+			{ID: firstID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(50)},
+			{ID: firstID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(30)},
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, results, 3)
+		assert.True(t, decimal.NewFromInt(120).Equal(results[2].Result.Balance))
+		assert.Equal(t, firstID, mockRepo.Calls[0].Arguments.Get(1))
+		assert.Equal(t, secondID, mockRepo.Calls[1].Arguments.Get(1))
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("Atomic: ошибка операции возвращается с ее номером", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, firstID).Return(&domain.Wallet{ID: firstID, Balance: decimal.NewFromInt(100)}, nil)
+		mockRepo.On("UpdateBalance", mock.Anything, firstID, decimal.NewFromInt(40)).Return(nil)
+
+		service := NewWalletService(newMockUoW(mockRepo), logger)
+		_, err := service.PerformBatch(context.Background(), domain.BatchAtomic, []domain.OperationRequest{
+			{ID: firstID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(60)},
+			{ID: firstID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(60)},
+		})
+
+		var itemErr *domain.BatchItemError
+		if assert.True(t, errors.As(err, &itemErr)) {
+			assert.Equal(t, 1, itemErr.Index)
+		}
+		assert.True(t, errors.Is(err, domain.ErrInsufficientFunds))
+	})
+
+	t.Run("Atomic: ошибки проверки собираются по всем операциям", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		service := NewWalletService(newMockUoW(mockRepo), logger)
+		_, err := service.PerformBatch(context.Background(), domain.BatchAtomic, []domain.OperationRequest{
+			{ID: uuid.Nil, OperationType: domain.Deposit, Amount: decimal.NewFromInt(10)},
+			{ID: firstID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(10)},
+			{ID: firstID, OperationType: domain.Deposit, Amount: decimal.Zero},
+		})
+
+		var validationErr *domain.BatchValidationError
+		if assert.True(t, errors.As(err, &validationErr)) {
+			assert.Len(t, validationErr.Items, 2)
+			assert.Equal(t, 0, validationErr.Items[0].Index)
+			assert.Equal(t, 2, validationErr.Items[1].Index)
+		}
+		mockRepo.AssertNotCalled(t, "GetForUpdate", mock.Anything, mock.Anything)
+	})
+
+	t.Run("Independent: результат по каждой операции", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, firstID).Return(&domain.Wallet{ID: firstID, Balance: decimal.NewFromInt(100)}, nil)
+		mockRepo.On("UpdateBalance", mock.Anything, firstID, decimal.NewFromInt(110)).Return(nil)
+
+		service := NewWalletService(newMockUoW(mockRepo), logger)
+		results, err := service.PerformBatch(context.Background(), domain.BatchIndependent, []domain.OperationRequest{
+			{ID: firstID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(10)},
+			{ID: firstID, OperationType: "UNKNOWN", Amount: decimal.NewFromInt(10)},
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.True(t, errors.Is(results[1].Err, domain.ErrUnknownOperationType))
+	})
+
+	t.Run("Ошибка: превышен размер пакета", func(t *testing.T) {
+		service := NewWalletService(newMockUoW(new(MockWalletRepository)), logger, WithMaxBatchSize(1))
+		_, err := service.PerformBatch(context.Background(), domain.BatchIndependent, make([]domain.OperationRequest, 2))
+
+		assert.True(t, errors.Is(err, domain.ErrBatchTooLarge))
+	})
+}
+
+func TestWalletService_Idempotency(t *testing.T) {
+	store := memory.NewStore(zap.NewNop())
+	service := NewWalletService(store, zap.NewNop())
+	// Второй экземпляр сервиса с тем же хранилищем, как после перезапуска или за балансировщиком
+	restarted := NewWalletService(store, zap.NewNop())
+	fingerprint := sha256.Sum256([]byte("request"))
+	withKey := func(key string) (context.Context, *domain.IdempotencyKey) {
+		idempotencyKey := &domain.IdempotencyKey{Key: key, Fingerprint: fingerprint}
+		return domain.ContextWithIdempotencyKey(context.Background(), idempotencyKey), idempotencyKey
+	}
+	balance := func(id uuid.UUID) string {
+		wallet, err := service.GetWallet(context.Background(), id)
+		require.NoError(t, err)
+		return wallet.Balance.String()
+	}
+
+	ctx, key := withKey("create")
+	wallet, created, err := service.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+	require.True(t, created)
+	assert.False(t, key.Replayed)
+	ctx, key = withKey("create")
+	replayedWallet, created, err := restarted.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+	assert.True(t, created)
+	assert.True(t, key.Replayed)
+	assert.Equal(t, wallet.ID, replayedWallet.ID)
+
+	deposit := domain.OperationRequest{ID: wallet.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)}
+	ctx, _ = withKey("deposit")
+	first, err := service.PerformOperation(ctx, deposit)
+	require.NoError(t, err)
+	ctx, key = withKey("deposit")
+	second, err := restarted.PerformOperation(ctx, deposit)
+	require.NoError(t, err)
+	assert.True(t, key.Replayed)
+	assert.Equal(t, first.ID, second.ID)
+	assert.NotEmpty(t, second.LSN)
+	assert.Equal(t, "100", balance(wallet.ID))
+
+	t.Run("Ключ с другим запросом отклоняется", func(t *testing.T) {
+		ctx := domain.ContextWithIdempotencyKey(context.Background(), &domain.IdempotencyKey{Key: "deposit"})
+		_, err := service.PerformOperation(ctx, deposit)
+		assert.ErrorIs(t, err, domain.ErrIdempotencyKeyReused)
+	})
+
+	t.Run("Отказ не сохраняется, повтор выполняет операцию", func(t *testing.T) {
+		withdraw := domain.OperationRequest{ID: wallet.ID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(150)}
+		ctx, _ := withKey("withdraw")
+		_, err := service.PerformOperation(ctx, withdraw)
+		require.ErrorIs(t, err, domain.ErrInsufficientFunds)
+
+		_, err = service.PerformOperation(context.Background(), deposit)
+		require.NoError(t, err)
+		ctx, key := withKey("withdraw")
+		_, err = restarted.PerformOperation(ctx, withdraw)
+		require.NoError(t, err)
+		assert.False(t, key.Replayed)
+		assert.Equal(t, "50", balance(wallet.ID))
+	})
+
+	t.Run("Повтор независимого пакета не применяет операции заново", func(t *testing.T) {
+		reqs := []domain.OperationRequest{deposit, {ID: wallet.ID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(1000)}}
+		ctx, _ := withKey("batch")
+		results, err := service.PerformBatch(ctx, domain.BatchIndependent, reqs)
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		require.ErrorIs(t, results[1].Err, domain.ErrInsufficientFunds)
+
+		ctx, key := withKey("batch")
+		replayed, err := restarted.PerformBatch(ctx, domain.BatchIndependent, reqs)
+		require.NoError(t, err)
+		assert.True(t, key.Replayed)
+		assert.Equal(t, results[0].Result.ID, replayed[0].Result.ID)
+		assert.ErrorIs(t, replayed[1].Err, domain.ErrInsufficientFunds)
+		assert.Equal(t, "150", balance(wallet.ID))
+	})
+
+	t.Run("Истекшие ключи удаляются", func(t *testing.T) {
+		service.now = func() time.Time { return time.Now().Add(DefaultIdempotencyTTL + time.Minute) }
+		defer func() { service.now = time.Now }()
+
+		// create, deposit, withdraw и первая операция пакета; отклоненная операция пакета ключ не заняла
+		purged, err := service.PurgeIdempotencyKeys(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 4, purged)
+	})
+}
+
+func TestWalletService_MemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(zap.NewNop())
+	service := NewWalletService(store, zap.NewNop())
+
+	from, _, err := service.CreateWallet(ctx, domain.WalletDetails{})
+	assert.NoError(t, err)
+	to, _, err := service.CreateWallet(ctx, domain.WalletDetails{})
+	assert.NoError(t, err)
+
+	t.Run("Атомарный пакет откатывается целиком", func(t *testing.T) {
+		_, err := service.PerformBatch(ctx, domain.BatchAtomic, []domain.OperationRequest{
+			{ID: from.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)},
+			{ID: from.ID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(500), TargetID: to.ID},
+		})
+		assert.True(t, errors.Is(err, domain.ErrInsufficientFunds))
+
+		wallet, err := service.GetWallet(ctx, from.ID)
+		assert.NoError(t, err)
+		assert.True(t, wallet.Balance.IsZero())
+	})
+
+	t.Run("Перевод сходится с журналом", func(t *testing.T) {
+		_, err := service.PerformOperation(ctx, domain.OperationRequest{ID: from.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)})
+		assert.NoError(t, err)
+		_, err = service.PerformOperation(ctx, domain.OperationRequest{
+			ID: from.ID, OperationType: domain.Transfer, Amount: decimal.NewFromInt(40), TargetID: to.ID,
+		})
+		assert.NoError(t, err)
+
+		report, err := service.Reconcile(ctx, ReconcileOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, report.Checked)
+		assert.Empty(t, report.Drifts)
+
+		wallet, err := service.GetWallet(ctx, to.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "40", wallet.Balance.String())
+	})
+
+	t.Run("Операция возвращает позицию журнала", func(t *testing.T) {
+		result, err := service.PerformOperation(ctx, domain.OperationRequest{ID: to.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(1)})
+		assert.NoError(t, err)
+		assert.True(t, domain.ValidLSN(result.LSN), result.LSN)
+
+		_, err = service.PerformOperation(ctx, domain.OperationRequest{ID: to.ID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(1)})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Операции присоединяются к транзакции из контекста", func(t *testing.T) {
+		errRollback := errors.New("rollback")
+		err := store.Do(ctx, func(uow domain.UnitOfWork) error {
+			txCtx := domain.ContextWithUnitOfWork(ctx, uow)
+			_, err := service.PerformOperation(txCtx, domain.OperationRequest{ID: from.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(30)})
+			assert.NoError(t, err)
+			// Отказ откатывает только свою вложенную транзакцию
+			_, err = service.PerformOperation(txCtx, domain.OperationRequest{ID: from.ID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(1000)})
+			assert.True(t, errors.Is(err, domain.ErrInsufficientFunds))
+
+			wallet, err := service.GetWallet(txCtx, from.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "90", wallet.Balance.String())
+			return errRollback
+		})
+		assert.True(t, errors.Is(err, errRollback))
+
+		wallet, err := service.GetWallet(ctx, from.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "60", wallet.Balance.String())
+	})
+}
+
+func TestWalletService_WalletDetails(t *testing.T) {
+	ctx := context.Background()
+	service := NewWalletService(memory.NewStore(zap.NewNop()), zap.NewNop())
+
+	t.Run("Повторное создание с тем же внешним ID возвращает существующий кошелек", func(t *testing.T) {
+		details := domain.WalletDetails{ExternalID: "customer-1", Label: "Основной", Metadata: map[string]any{"segment": "retail"}}
+		wallet, created, err := service.CreateWallet(ctx, details)
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.Equal(t, "customer-1", wallet.ExternalID)
+
+		again, created, err := service.CreateWallet(ctx, domain.WalletDetails{ExternalID: "customer-1", Label: "Другой"})
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, wallet.ID, again.ID)
+		assert.Equal(t, "Основной", again.Label)
+
+		found, err := service.FindWalletByExternalID(ctx, "", "customer-1")
+		require.NoError(t, err)
+		assert.Equal(t, wallet.ID, found.ID)
+		_, err = service.FindWalletByExternalID(ctx, "acme", "customer-1")
+		assert.ErrorIs(t, err, domain.ErrWalletNotFound)
+	})
+
+	t.Run("Внешний ID уникален в пределах клиента", func(t *testing.T) {
+		first, created, err := service.CreateWallet(ctx, domain.WalletDetails{ClientID: "acme", ExternalID: "customer-2"})
+		require.NoError(t, err)
+		assert.True(t, created)
+
+		// Тот же внешний ID другого клиента - другой кошелек, а не чужой существующий
+		second, created, err := service.CreateWallet(ctx, domain.WalletDetails{ClientID: "globex", ExternalID: "customer-2"})
+		require.NoError(t, err)
+		assert.True(t, created)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, "globex", second.ClientID)
+
+		again, created, err := service.CreateWallet(ctx, domain.WalletDetails{ClientID: "acme", ExternalID: "customer-2"})
+		require.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, first.ID, again.ID)
+	})
+
+	t.Run("Параллельное создание с одним внешним ID создает один кошелек", func(t *testing.T) {
+		const workers = 10
+		ids := make(chan uuid.UUID, workers)
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				wallet, _, err := service.CreateWallet(ctx, domain.WalletDetails{ExternalID: "customer-concurrent"})
+				assert.NoError(t, err)
+				if wallet != nil {
+					ids <- wallet.ID
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		unique := make(map[uuid.UUID]bool)
+		for id := range ids {
+			unique[id] = true
+		}
+		assert.Len(t, unique, 1)
+	})
+
+	t.Run("Метаданные объединяются с текущими", func(t *testing.T) {
+		wallet, _, err := service.CreateWallet(ctx, domain.WalletDetails{
+			Metadata: map[string]any{"segment": "retail", "manager": "ivanov", "flags": map[string]any{"vip": false, "beta": true}},
+		})
+		require.NoError(t, err)
+
+		label := "Накопления"
+		updated, err := service.UpdateWallet(ctx, wallet.ID, domain.WalletUpdate{
+			Label:    &label,
+			Metadata: map[string]any{"manager": nil, "flags": map[string]any{"vip": true}},
+		})
+		require.NoError(t, err)
+		expected := map[string]any{"segment": "retail", "flags": map[string]any{"vip": true, "beta": true}}
+		assert.Equal(t, "Накопления", updated.Label)
+		assert.Equal(t, expected, updated.Metadata)
+
+		got, err := service.GetWallet(ctx, wallet.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Накопления", got.Label)
+		assert.Equal(t, expected, got.Metadata)
+
+		// Без названия в изменении название сохраняется
+		updated, err = service.UpdateWallet(ctx, wallet.ID, domain.WalletUpdate{Metadata: map[string]any{"segment": "vip"}})
+		require.NoError(t, err)
+		assert.Equal(t, "Накопления", updated.Label)
+		assert.Equal(t, "vip", updated.Metadata["segment"])
+	})
+
+	t.Run("Некорректные реквизиты отклоняются", func(t *testing.T) {
+		_, _, err := service.CreateWallet(ctx, domain.WalletDetails{ExternalID: strings.Repeat("x", domain.MaxExternalIDLength+1)})
+		assert.ErrorIs(t, err, domain.ErrInvalidWalletDetails)
+
+		wallet, _, err := service.CreateWallet(ctx, domain.WalletDetails{})
+		require.NoError(t, err)
+		_, err = service.UpdateWallet(ctx, wallet.ID, domain.WalletUpdate{
+			Metadata: map[string]any{"blob": strings.Repeat("x", domain.MaxMetadataSize)},
+		})
+		assert.ErrorIs(t, err, domain.ErrInvalidWalletDetails)
+
+		_, err = service.UpdateWallet(ctx, uuid.New(), domain.WalletUpdate{})
+		assert.ErrorIs(t, err, domain.ErrWalletNotFound)
+	})
+}
+
+func TestWalletService_Reconcile(t *testing.T) {
+	logger := zap.NewNop()
+	okID := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	driftID := uuid.MustParse("20000000-0000-0000-0000-000000000000")
+	lastID := uuid.MustParse("30000000-0000-0000-0000-000000000000")
+
+	// Две страницы по два кошелька, расхождение только у driftID
+	setupPages := func(ledger *MockLedgerRepository) {
+		ledger.On("WalletBalances", mock.Anything, uuid.Nil, 2).Return([]domain.WalletBalance{
+			{WalletID: okID, Stored: decimal.NewFromInt(100), Ledger: decimal.NewFromInt(100)},
+			{WalletID: driftID, Stored: decimal.NewFromInt(150), Ledger: decimal.NewFromInt(120)},
+		}, nil).Once()
+		ledger.On("WalletBalances", mock.Anything, driftID, 2).Return([]domain.WalletBalance{
+			{WalletID: lastID, Stored: decimal.Zero, Ledger: decimal.Zero},
+		}, nil).Once()
+	}
+
+	t.Run("Отчет о расхождениях без исправления", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockUOW := newMockUoW(mockRepo)
+		setupPages(mockUOW.LedgerRepo)
+
+		service := NewWalletService(mockUOW, logger)
+		report, err := service.Reconcile(context.Background(), ReconcileOptions{PageSize: 2})
+
+		assert.NoError(t, err)
+		// Каждая страница читается в своей транзакции
+		assert.Equal(t, []domain.TxOptions{{ReadOnly: true}, {ReadOnly: true}}, mockUOW.TxOptions)
+		assert.Equal(t, 3, report.Checked)
+		assert.Len(t, report.Drifts, 1)
+		assert.Equal(t, driftID, report.Drifts[0].WalletID)
+		assert.True(t, decimal.NewFromInt(30).Equal(report.Drifts[0].Drift()))
+		assert.False(t, report.Drifts[0].Repaired)
+		mockUOW.LedgerRepo.AssertExpectations(t)
+		mockRepo.AssertNotCalled(t, "UpdateBalance", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Исправление баланса по журналу с записью в аудит", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("GetForUpdate", mock.Anything, driftID).Return(&domain.Wallet{ID: driftID, Balance: decimal.NewFromInt(150)}, nil)
+		mockRepo.On("UpdateBalance", mock.Anything, driftID, decimalEq(decimal.NewFromInt(120))).Return(nil)
+		mockUOW := newMockUoW(mockRepo)
+		setupPages(mockUOW.LedgerRepo)
+		mockUOW.LedgerRepo.On("AccountBalance", mock.Anything, driftID).Return(decimal.NewFromInt(120), nil)
+
+		service := NewWalletService(mockUOW, logger)
+		report, err := service.Reconcile(context.Background(), ReconcileOptions{PageSize: 2, Repair: true, Actor: "ops"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, report.Repaired())
+		// Расхождение первой страницы исправляется до чтения второй
+		assert.Equal(t, []domain.TxOptions{{ReadOnly: true}, {}, {ReadOnly: true}}, mockUOW.TxOptions)
+		mockRepo.AssertExpectations(t)
+		mockUOW.AuditRepo.AssertCalled(t, "Add", mock.Anything, mock.MatchedBy(func(e *domain.AuditEntry) bool {
+			return e.WalletID == driftID && e.Action == domain.AuditBalanceRepaired &&
+				e.OldValue == "150" && e.NewValue == "120" && e.Actor == "ops"
+		}))
+	})
+}
+
+func TestWalletService_BalanceSeries(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+	msk, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	from := time.Date(2024, time.March, 30, 0, 0, 0, 0, msk)
+	to := time.Date(2024, time.April, 1, 0, 0, 0, 0, msk)
+
+	t.Run("Балансы на конец дня накапливают изменения", func(t *testing.T) {
+		mockRepo := new(MockWalletRepository)
+		mockRepo.On("Get", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID}, nil)
+		mockUOW := newMockUoW(mockRepo)
+		end := time.Date(2024, time.April, 2, 0, 0, 0, 0, msk)
+		mockUOW.LedgerRepo.On("BalanceAt", mock.Anything, walletID, from.Add(-time.Microsecond)).Return(decimal.NewFromInt(100), nil)
+		mockUOW.LedgerRepo.On("DailyChanges", mock.Anything, walletID, from, end, msk).Return([]domain.DailyChange{
+			{Date: time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), Amount: decimal.NewFromInt(-30)},
+			{Date: time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), Amount: decimal.NewFromInt(50)},
+		}, nil)
+
+		service := NewWalletService(mockUOW, logger)
+		points, err := service.BalanceSeries(context.Background(), walletID, from, to, msk)
+
+		assert.NoError(t, err)
+		balances := make([]string, 0, len(points))
+		for _, p := range points {
+			balances = append(balances, p.Date.Format("2006-01-02")+"="+p.Balance.String())
+		}
+		assert.Equal(t, []string{"2024-03-30=100", "2024-03-31=70", "2024-04-01=120"}, balances)
+	})
+
+	t.Run("Ошибка: конец периода раньше начала", func(t *testing.T) {
+		service := NewWalletService(newMockUoW(new(MockWalletRepository)), logger)
+		_, err := service.BalanceSeries(context.Background(), walletID, to, from, msk)
+
+		assert.True(t, errors.Is(err, domain.ErrInvalidTimeRange))
+	})
+
+	t.Run("Ошибка: слишком длинный период", func(t *testing.T) {
+		service := NewWalletService(newMockUoW(new(MockWalletRepository)), logger)
+		_, err := service.BalanceSeries(context.Background(), walletID, from, from.AddDate(2, 0, 0), msk)
+
+		assert.True(t, errors.Is(err, domain.ErrTimeRangeTooLarge))
+
+		// Период проверяется до обхода дней, поэтому огромный период отклоняется сразу
+		_, err = service.BalanceSeries(context.Background(), walletID, from, time.Date(1000000, time.January, 1, 0, 0, 0, 0, msk), msk)
+		assert.True(t, errors.Is(err, domain.ErrTimeRangeTooLarge))
+	})
+
+	t.Run("Ошибка: на день длиннее предела с переходом на летнее время", func(t *testing.T) {
+		ny, err := time.LoadLocation("America/New_York")
+		assert.NoError(t, err)
+		start := time.Date(2024, time.January, 1, 0, 0, 0, 0, ny)
+
+		service := NewWalletService(newMockUoW(new(MockWalletRepository)), logger)
+		_, err = service.BalanceSeries(context.Background(), walletID, start, start.AddDate(0, 0, domain.MaxBalanceSeriesDays), ny)
+
+		assert.True(t, errors.Is(err, domain.ErrTimeRangeTooLarge))
+	})
+}
+
+func TestWalletService_Statement(t *testing.T) {
+	logger := zap.NewNop()
+	walletID := uuid.New()
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(MockWalletRepository)
+	mockRepo.On("Get", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Currency: "RUB"}, nil)
+	mockUOW := newMockUoW(mockRepo)
+	mockUOW.LedgerRepo.On("BalanceAt", mock.Anything, walletID, from.Add(-time.Microsecond)).Return(decimal.NewFromInt(100), nil)
+	mockUOW.LedgerRepo.On("StreamPostings", mock.Anything, walletID, from, to, mock.Anything).Return([]domain.StatementLine{
+		{PostingID: 1, OperationType: string(domain.Deposit), Amount: decimal.NewFromInt(50)},
+		{PostingID: 2, OperationType: string(domain.Withdraw), Amount: decimal.NewFromInt(-120)},
+	}, nil)
+
+	service := NewWalletService(mockUOW, logger)
+	w := &recordingStatementWriter{}
+	ctx := domain.ContextWithMinLSN(context.Background(), "0/16B3748")
+	err := service.Statement(ctx, walletID, from, to, w)
+
+	assert.NoError(t, err)
+	// Выписка читается из одного снимка и может обслуживаться репликой, догнавшей позицию клиента
+	assert.Equal(t, []domain.TxOptions{{
+		Isolation: domain.RepeatableRead, ReadOnly: true, Replica: true, MinLSN: "0/16B3748",
+	}}, mockUOW.TxOptions)
+	assert.True(t, decimal.NewFromInt(100).Equal(w.header.Opening))
+	assert.Equal(t, "RUB", w.header.Currency)
+	assert.Len(t, w.lines, 2)
+	assert.True(t, decimal.NewFromInt(150).Equal(w.lines[0].Balance))
+	assert.True(t, decimal.NewFromInt(30).Equal(w.lines[1].Balance))
+	assert.True(t, decimal.NewFromInt(30).Equal(w.footer.Closing))
+	assert.Equal(t, 2, w.footer.Lines)
+}
+
+func TestWalletService_Statement_TransferToRevenueWallet(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore(zap.NewNop())
+	setup := NewWalletService(store, zap.NewNop())
+	payer, _, err := setup.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+	revenue, _, err := setup.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+
+	schedule := StaticFeeSchedule{{OperationType: domain.Transfer, Fixed: decimal.NewFromInt(10)}}
+	service := NewWalletService(store, zap.NewNop(), WithFeeSchedule(schedule), WithRevenueWallet(revenue.ID))
+	_, err = service.PerformOperation(ctx, domain.OperationRequest{ID: payer.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(100)})
+	require.NoError(t, err)
+	_, err = service.PerformOperation(ctx, domain.OperationRequest{
+		ID: payer.ID, OperationType: domain.Transfer, TargetID: revenue.ID, Amount: decimal.NewFromInt(100),
+	})
+	require.NoError(t, err)
+
+	// Кошелек доходов получает перевод и комиссию одной операцией: каждая проводка попадает в выписку один раз
+	w := &recordingStatementWriter{}
+	require.NoError(t, service.Statement(ctx, revenue.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), w))
+	require.Len(t, w.lines, 2)
+	types := []string{w.lines[0].OperationType, w.lines[1].OperationType}
+	assert.ElementsMatch(t, []string{string(domain.TransferIn), string(domain.Fee)}, types)
+	assert.Equal(t, "100", w.footer.Closing.String())
+	assert.Equal(t, 2, w.footer.Lines)
+}
+
+func TestWalletService_RunImport(t *testing.T) {
+	logger := zap.NewNop()
+	importID := uuid.New()
+	walletID := uuid.New()
+	rows := []domain.ImportRow{
+		{ImportID: importID, Line: 2, WalletID: walletID.String(), OperationType: "DEPOSIT", Amount: "100"},
+		{ImportID: importID, Line: 3, WalletID: walletID.String(), OperationType: "DEPOSIT", Amount: "сто"},
+		{ImportID: importID, Line: 4, WalletID: walletID.String(), OperationType: "WITHDRAW", Amount: "500"},
+	}
+
+	mockRepo := new(MockWalletRepository)
+	mockRepo.On("GetForUpdate", mock.Anything, walletID).Return(&domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(50)}, nil)
+	mockRepo.On("UpdateBalance", mock.Anything, walletID, decimalEq(decimal.NewFromInt(150))).Return(nil).Once()
+	mockUOW := newMockUoW(mockRepo)
+	imports := mockUOW.ImportRepo
+	imports.On("Claim", mock.Anything, importID, mock.Anything).Return(&domain.Import{ID: importID, Status: domain.ImportRunning}, nil)
+	imports.On("PendingRows", mock.Anything, importID, 0, importPageSize).Return(rows, nil)
+	imports.On("MarkRow", mock.Anything, mock.MatchedBy(func(row domain.ImportRow) bool {
+		return row.Line == 2 && row.Status == domain.ImportRowApplied && row.OperationID != uuid.Nil
+	})).Return(nil).Once()
+	imports.On("MarkRow", mock.Anything, mock.MatchedBy(func(row domain.ImportRow) bool {
+		return row.Line == 3 && row.Status == domain.ImportRowFailed && strings.HasPrefix(row.Error, "invalid import row: invalid amount")
+	})).Return(nil).Once()
+	imports.On("MarkRow", mock.Anything, mock.MatchedBy(func(row domain.ImportRow) bool {
+		return row.Line == 4 && row.Status == domain.ImportRowFailed && row.Error == domain.ErrInsufficientFunds.Error()
+	})).Return(nil).Once()
+	imports.On("SetStatus", mock.Anything, importID, domain.ImportCompleted, "").
+		Return(&domain.Import{ID: importID, Status: domain.ImportCompleted, TotalRows: 3, AppliedRows: 1, FailedRows: 2}, nil)
+
+	service := NewWalletService(mockUOW, logger)
+	imp, err := service.RunImport(context.Background(), importID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, domain.ImportCompleted, imp.Status)
+	imports.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWalletService_RunImport_Resume(t *testing.T) {
+	ctx := context.Background()
+	service := NewWalletService(memory.NewStore(zap.NewNop()), zap.NewNop())
+	wallet, _, err := service.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+
+	file := "wallet,operation_type,amount\n" +
+		wallet.ID.String() + ",DEPOSIT,100\n" +
+		wallet.ID.String() + ",DEPOSIT,50\n"
+	imp, err := service.StartImport(ctx, "history.csv", strings.NewReader(file))
+	require.NoError(t, err)
+
+	// Остановленный обработчик возвращает импорт в очередь, не помечая его сбойным
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = service.RunImport(stopped, imp.ID)
+	require.ErrorIs(t, err, context.Canceled)
+	imp, err = service.GetImport(ctx, imp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ImportPending, imp.Status)
+
+	imp, err = service.RunImport(ctx, imp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ImportCompleted, imp.Status)
+	assert.Equal(t, 2, imp.AppliedRows)
+
+	// Завершенный импорт повторно не применяется
+	_, err = service.RunImport(ctx, imp.ID)
+	assert.ErrorIs(t, err, domain.ErrImportNotClaimable)
+	_, balance, err := service.BalanceAt(ctx, wallet.ID, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, "150", balance.String())
+}
+
+func TestWalletService_RunImport_Backdated(t *testing.T) {
+	ctx := context.Background()
+	service := NewWalletService(memory.NewStore(zap.NewNop()), zap.NewNop())
+	wallet, _, err := service.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+
+	// Входящие за текущий месяц исчерпаны: лимит ANONYMOUS - 40 000
+	for range 4 {
+		_, err := service.PerformOperation(ctx, domain.OperationRequest{ID: wallet.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(10000)})
+		require.NoError(t, err)
+		_, err = service.PerformOperation(ctx, domain.OperationRequest{ID: wallet.ID, OperationType: domain.Withdraw, Amount: decimal.NewFromInt(10000)})
+		require.NoError(t, err)
+	}
+
+	occurredAt := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	file := "wallet,operation_type,amount,occurred_at\n" +
+		wallet.ID.String() + ",DEPOSIT,100," + occurredAt.Format(time.RFC3339) + "\n" +
+		wallet.ID.String() + ",DEPOSIT,100," + future + "\n" +
+		wallet.ID.String() + ",DEPOSIT,100,вчера\n" +
+		wallet.ID.String() + ",WITHDRAW,50," + occurredAt.Format(time.RFC3339) + "\n"
+	imp, err := service.StartImport(ctx, "history.csv", strings.NewReader(file))
+	require.NoError(t, err)
+
+	imp, err = service.RunImport(ctx, imp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, imp.AppliedRows)
+	assert.Equal(t, 3, imp.FailedRows)
+
+	var reasons []string
+	require.NoError(t, service.ImportErrors(ctx, imp.ID, func(row domain.ImportRow) error {
+		reasons = append(reasons, row.Error)
+		return nil
+	}))
+	assert.Equal(t, "invalid import row: occurred_at is in the future", reasons[0])
+	assert.True(t, strings.HasPrefix(reasons[1], "invalid import row: invalid occurred_at"), reasons[1])
+	// Списание задним числом обошло бы дневной и месячный лимиты на вывод
+	assert.Equal(t, "invalid import row: occurred_at is allowed only for DEPOSIT", reasons[2])
+
+	// Деньги учтены на дату операции, а не на дату импорта
+	_, before, err := service.BalanceAt(ctx, wallet.ID, occurredAt.Add(-time.Second))
+	require.NoError(t, err)
+	assert.True(t, before.IsZero(), before.String())
+	_, after, err := service.BalanceAt(ctx, wallet.ID, occurredAt)
+	require.NoError(t, err)
+	assert.Equal(t, "100", after.String())
+
+	// Операция задним числом не попадает в окно лимита, а текущие по-прежнему проверяются
+	_, err = service.PerformOperation(ctx, domain.OperationRequest{ID: wallet.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(1)})
+	assert.ErrorIs(t, err, domain.ErrLimitExceeded)
+}
+
+func TestNewImportRowSource(t *testing.T) {
+	t.Run("Номера строк и необязательная колонка", func(t *testing.T) {
+		next, err := newImportRowSource(strings.NewReader("Wallet,operation_type,amount\n" +
+			"11111111-1111-1111-1111-111111111111,deposit,10.5\n" +
+			"\n" +
+			"22222222-2222-2222-2222-222222222222,DEPOSIT\n"))
+		assert.NoError(t, err)
+
+		first, err := next()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, first.Line)
+		assert.Equal(t, "DEPOSIT", first.OperationType)
+		assert.Equal(t, "10.5", first.Amount)
+
+		second, err := next()
+		assert.NoError(t, err)
+		assert.Equal(t, 4, second.Line)
+		assert.Empty(t, second.Amount)
+
+		end, err := next()
+		assert.NoError(t, err)
+		assert.Nil(t, end)
+	})
+
+	t.Run("Ошибка: нет обязательной колонки", func(t *testing.T) {
+		_, err := newImportRowSource(strings.NewReader("wallet,amount\n"))
+
+		assert.True(t, errors.Is(err, domain.ErrInvalidImportFile))
+	})
+}
+
+func TestWalletService_ListWallets(t *testing.T) {
+	ctx := context.Background()
+	service := NewWalletService(memory.NewStore(zap.NewNop()), zap.NewNop())
+
+	balances := []int64{50, 10, 40, 10, 30}
+	for _, balance := range balances {
+		wallet, _, err := service.CreateWallet(ctx, domain.WalletDetails{Metadata: map[string]any{"batch": "list"}})
+		require.NoError(t, err)
+		_, err = service.PerformOperation(ctx, domain.OperationRequest{ID: wallet.ID, OperationType: domain.Deposit, Amount: decimal.NewFromInt(balance)})
+		require.NoError(t, err)
+	}
+	_, _, err := service.CreateWallet(ctx, domain.WalletDetails{})
+	require.NoError(t, err)
+
+	t.Run("Страницы по курсору без пропусков и повторов", func(t *testing.T) {
+		query := domain.WalletListQuery{
+			Filter:    domain.WalletFilter{Metadata: map[string]any{"batch": "list"}},
+			Sort:      domain.SortByBalance,
+			Desc:      true,
+			Limit:     2,
+			WithTotal: true,
+		}
+		var (
+			got   []string
+			seen  = make(map[uuid.UUID]bool)
+			pages int
+		)
+		for {
+			page, err := service.ListWallets(ctx, query)
+			require.NoError(t, err)
+			require.NotNil(t, page.Total)
+			assert.Equal(t, len(balances), *page.Total)
+			for _, w := range page.Wallets {
+				assert.False(t, seen[w.ID], "wallet %s returned twice", w.ID)
+				seen[w.ID] = true
+				got = append(got, w.Balance.String())
+			}
+			pages++
+			if page.Next == nil {
+				break
+			}
+			query.After = page.Next
+		}
+		assert.Equal(t, []string{"50", "40", "30", "10", "10"}, got)
+		assert.Equal(t, 3, pages)
+	})
+
+	t.Run("Фильтр по балансу", func(t *testing.T) {
+		minBalance, maxBalance := decimal.NewFromInt(10), decimal.NewFromInt(40)
+		page, err := service.ListWallets(ctx, domain.WalletListQuery{
+			Filter: domain.WalletFilter{MinBalance: &minBalance, MaxBalance: &maxBalance},
+			Sort:   domain.SortByBalance,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Wallets, 4)
+		assert.Equal(t, "10", page.Wallets[0].Balance.String())
+		assert.Equal(t, "40", page.Wallets[3].Balance.String())
+		assert.Nil(t, page.Next)
+		assert.Nil(t, page.Total)
+	})
+
+	t.Run("Некорректный запрос отклоняется", func(t *testing.T) {
+		page, err := service.ListWallets(ctx, domain.WalletListQuery{Sort: domain.SortByBalance, Limit: 1})
+		require.NoError(t, err)
+		require.NotNil(t, page.Next)
+
+		minBalance, maxBalance := decimal.NewFromInt(10), decimal.NewFromInt(5)
+		tests := []struct {
+			name  string
+			query domain.WalletListQuery
+		}{
+			{name: "Курсор другой сортировки", query: domain.WalletListQuery{Sort: domain.SortByCreatedAt, After: page.Next}},
+			{name: "Слишком большая страница", query: domain.WalletListQuery{Limit: domain.MaxWalletPageSize + 1}},
+			{name: "Неизвестная сортировка", query: domain.WalletListQuery{Sort: "label"}},
+			{name: "Перепутаны границы баланса", query: domain.WalletListQuery{Filter: domain.WalletFilter{MinBalance: &minBalance, MaxBalance: &maxBalance}}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := service.ListWallets(ctx, tt.query)
+				assert.ErrorIs(t, err, domain.ErrInvalidWalletQuery)
+			})
+		}
+	})
+}
+
+func TestLoadFeeScheduleFile(t *testing.T) {
+	writeSchedule := func(t *testing.T, content string) string {
+		path := filepath.Join(t.TempDir(), "fees.json")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("Правила с максимумом без минимума", func(t *testing.T) {
+		schedule, err := LoadFeeScheduleFile(writeSchedule(t, `[{"operation_type": "WITHDRAW", "percent": "1", "max": "50"}]`))
+
+		require.NoError(t, err)
+		require.Len(t, schedule, 1)
+		assert.True(t, decimal.NewFromInt(50).Equal(schedule[0].Max.Decimal))
+	})
+
+	t.Run("Ошибка: отрицательный максимум без минимума", func(t *testing.T) {
+		_, err := LoadFeeScheduleFile(writeSchedule(t, `[{"operation_type": "WITHDRAW", "percent": "1", "max": "-1"}]`))
+
+		assert.ErrorContains(t, err, "must be non-negative")
+	})
+}
@@ -0,0 +1,57 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"testtask/internal/domain"
+)
+
+// csvWriter записывает выписку таблицей: строка входящего остатка, движения и строка исходящего остатка
+type csvWriter struct {
+	w      *csv.Writer
+	opts   Options
+	header domain.StatementHeader
+}
+
+func newCSVWriter(w io.Writer, opts Options) *csvWriter {
+	cw := csv.NewWriter(w)
+	cw.Comma = opts.Locale.CSVDelimiter
+	return &csvWriter{w: cw, opts: opts}
+}
+
+func (w *csvWriter) WriteHeader(header domain.StatementHeader) error {
+	w.header = header
+	if err := w.w.Write([]string{"date", "operation_id", "type", "amount", "balance", "currency"}); err != nil {
+		return err
+	}
+	return w.w.Write([]string{
+		w.formatTime(header.From), "", "OPENING", "", w.opts.Locale.FormatAmount(header.Opening), header.Currency,
+	})
+}
+
+func (w *csvWriter) WriteLine(line domain.StatementLine) error {
+	return w.w.Write([]string{
+		w.formatTime(line.CreatedAt),
+		line.CorrelationID.String(),
+		line.OperationType,
+		w.opts.Locale.FormatAmount(line.Amount),
+		w.opts.Locale.FormatAmount(line.Balance),
+		w.header.Currency,
+	})
+}
+
+func (w *csvWriter) WriteFooter(footer domain.StatementFooter) error {
+	if err := w.w.Write([]string{
+		w.formatTime(w.header.To), "", "CLOSING", "", w.opts.Locale.FormatAmount(footer.Closing), w.header.Currency,
+	}); err != nil {
+		return err
+	}
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func (w *csvWriter) formatTime(t time.Time) string {
+	return t.In(w.opts.Location).Format(w.opts.Locale.DateLayout)
+}
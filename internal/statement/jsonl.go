@@ -0,0 +1,76 @@
+package statement
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+// Записи JSON Lines различаются полем type: opening, operation, closing
+type jsonlOpening struct {
+	Type     string          `json:"type"`
+	WalletID uuid.UUID       `json:"wallet"`
+	Currency string          `json:"currency"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Balance  decimal.Decimal `json:"balance"`
+}
+
+type jsonlOperation struct {
+	Type          string          `json:"type"`
+	OperationID   uuid.UUID       `json:"operation_id"`
+	OperationType string          `json:"operation_type"`
+	Amount        decimal.Decimal `json:"amount"`
+	Balance       decimal.Decimal `json:"balance"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type jsonlClosing struct {
+	Type       string          `json:"type"`
+	Balance    decimal.Decimal `json:"balance"`
+	Operations int             `json:"operations"`
+}
+
+type jsonlWriter struct {
+	enc  *json.Encoder
+	opts Options
+}
+
+func newJSONLWriter(w io.Writer, opts Options) *jsonlWriter {
+	return &jsonlWriter{enc: json.NewEncoder(w), opts: opts}
+}
+
+func (w *jsonlWriter) WriteHeader(header domain.StatementHeader) error {
+	return w.enc.Encode(jsonlOpening{
+		Type:     "opening",
+		WalletID: header.WalletID,
+		Currency: header.Currency,
+		From:     header.From.In(w.opts.Location),
+		To:       header.To.In(w.opts.Location),
+		Balance:  header.Opening.Round(amountPrecision),
+	})
+}
+
+func (w *jsonlWriter) WriteLine(line domain.StatementLine) error {
+	return w.enc.Encode(jsonlOperation{
+		Type:          "operation",
+		OperationID:   line.CorrelationID,
+		OperationType: line.OperationType,
+		Amount:        line.Amount.Round(amountPrecision),
+		Balance:       line.Balance.Round(amountPrecision),
+		CreatedAt:     line.CreatedAt.In(w.opts.Location),
+	})
+}
+
+func (w *jsonlWriter) WriteFooter(footer domain.StatementFooter) error {
+	return w.enc.Encode(jsonlClosing{
+		Type:       "closing",
+		Balance:    footer.Closing.Round(amountPrecision),
+		Operations: footer.Lines,
+	})
+}
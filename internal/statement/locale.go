@@ -0,0 +1,79 @@
+package statement
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// amountPrecision - количество знаков после запятой в суммах выписки
+const amountPrecision = 2
+
+// Locale - правила оформления чисел и дат
+type Locale struct {
+	Name             string
+	DecimalSeparator string
+	// GroupSeparator разделяет разряды целой части; пустая строка отключает группировку
+	GroupSeparator string
+	// CSVDelimiter - разделитель полей CSV; для локалей с десятичной запятой это точка с запятой
+	CSVDelimiter rune
+	DateLayout   string
+}
+
+var (
+	LocaleEN = Locale{
+		Name:             "en",
+		DecimalSeparator: ".",
+		CSVDelimiter:     ',',
+		DateLayout:       time.RFC3339,
+	}
+	// LocaleRU оформляет числа как "1 234,56" с неразрывным пробелом, как их ожидает русскоязычный Excel
+	LocaleRU = Locale{
+		Name:             "ru",
+		DecimalSeparator: ",",
+		GroupSeparator:   "\u00a0",
+		CSVDelimiter:     ';',
+		DateLayout:       "02.01.2006 15:04:05",
+	}
+)
+
+// LookupLocale возвращает локаль по имени
+func LookupLocale(name string) (Locale, bool) {
+	switch name {
+	case "", LocaleEN.Name:
+		return LocaleEN, true
+	case LocaleRU.Name:
+		return LocaleRU, true
+	default:
+		return Locale{}, false
+	}
+}
+
+// FormatAmount форматирует сумму с двумя знаками после запятой по правилам локали
+func (l Locale) FormatAmount(amount decimal.Decimal) string {
+	s := amount.StringFixed(amountPrecision)
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+
+	if l.GroupSeparator != "" && len(integer) > 3 {
+		var b strings.Builder
+		head := len(integer) % 3
+		if head > 0 {
+			b.WriteString(integer[:head])
+		}
+		for i := head; i < len(integer); i += 3 {
+			if b.Len() > 0 {
+				b.WriteString(l.GroupSeparator)
+			}
+			b.WriteString(integer[i : i+3])
+		}
+		integer = b.String()
+	}
+
+	return sign + integer + l.DecimalSeparator + fraction
+}
@@ -0,0 +1,95 @@
+package statement
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/shopspring/decimal"
+)
+
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+`
+
+// ofxWriter записывает выписку в формате OFX 2.1 как банковский счет.
+// Входящего остатка в OFX нет, поэтому выгружаются только движения и исходящий остаток
+type ofxWriter struct {
+	w      *bufio.Writer
+	opts   Options
+	header domain.StatementHeader
+}
+
+func newOFXWriter(w io.Writer, opts Options) *ofxWriter {
+	return &ofxWriter{w: bufio.NewWriter(w), opts: opts}
+}
+
+func (w *ofxWriter) WriteHeader(header domain.StatementHeader) error {
+	w.header = header
+	w.w.WriteString(ofxHeader)
+	w.w.WriteString("<OFX>\n")
+	w.w.WriteString("<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>")
+	fmt.Fprintf(w.w, "<DTSERVER>%s</DTSERVER><LANGUAGE>RUS</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", w.formatTime(time.Now()))
+	w.w.WriteString("<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n")
+	fmt.Fprintf(w.w, "<STMTRS><CURDEF>%s</CURDEF>\n", escapeXML(header.Currency))
+	fmt.Fprintf(w.w, "<BANKACCTFROM><BANKID>WALLET</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", header.WalletID)
+	fmt.Fprintf(w.w, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", w.formatTime(header.From), w.formatTime(header.To))
+	return nil
+}
+
+func (w *ofxWriter) WriteLine(line domain.StatementLine) error {
+	fmt.Fprintf(w.w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT>"+
+		"<FITID>%s</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+		ofxTransactionType(line), w.formatTime(line.CreatedAt), formatOFXAmount(line.Amount),
+		strconv.FormatInt(line.PostingID, 10), escapeXML(line.OperationType), line.CorrelationID)
+	// Буфер сбрасывается по мере заполнения, поэтому выписка не накапливается в памяти
+	if w.w.Available() < 512 {
+		return w.w.Flush()
+	}
+	return nil
+}
+
+func (w *ofxWriter) WriteFooter(footer domain.StatementFooter) error {
+	w.w.WriteString("</BANKTRANLIST>\n")
+	fmt.Fprintf(w.w, "<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n",
+		formatOFXAmount(footer.Closing), w.formatTime(w.header.To))
+	w.w.WriteString("</STMTRS></STMTTRNRS></BANKMSGSRSV1>\n</OFX>\n")
+	return w.w.Flush()
+}
+
+// formatTime форматирует время как 20240331235900.000[+3:MSK]
+func (w *ofxWriter) formatTime(t time.Time) string {
+	t = t.In(w.opts.Location)
+	name, offset := t.Zone()
+	hours := float64(offset) / 3600
+	return fmt.Sprintf("%s[%s:%s]", t.Format("20060102150405.000"), strconv.FormatFloat(hours, 'f', -1, 64), name)
+}
+
+func ofxTransactionType(line domain.StatementLine) string {
+	switch domain.OperationType(line.OperationType) {
+	case domain.Fee:
+		return "SRVCHG"
+	case domain.TransferIn, domain.TransferOut:
+		return "XFER"
+	}
+	if line.Amount.IsNegative() {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+func formatOFXAmount(amount decimal.Decimal) string {
+	return amount.StringFixed(amountPrecision)
+}
+
+func escapeXML(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,60 @@
+// Package statement содержит форматы выгрузки выписок по кошельку
+package statement
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"testtask/internal/domain"
+)
+
+var ErrUnknownFormat = errors.New("unknown statement format")
+
+type Format string
+
+const (
+	FormatCSV   Format = "csv"
+	FormatJSONL Format = "jsonl"
+	FormatOFX   Format = "ofx"
+)
+
+// ContentType возвращает MIME-тип выгрузки
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatOFX:
+		return "application/x-ofx"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Options - параметры оформления выписки
+type Options struct {
+	// Locale задает формат чисел и дат в CSV; JSON Lines и OFX всегда используют точку как разделитель
+	Locale Locale
+	// Location - часовой пояс дат в выписке
+	Location *time.Location
+}
+
+// NewWriter возвращает запись выписки в формате format
+func NewWriter(format Format, w io.Writer, opts Options) (domain.StatementWriter, error) {
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, opts), nil
+	case FormatJSONL:
+		return newJSONLWriter(w, opts), nil
+	case FormatOFX:
+		return newOFXWriter(w, opts), nil
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
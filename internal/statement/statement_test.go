@@ -0,0 +1,71 @@
+package statement
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"testtask/internal/domain"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocale_FormatAmount(t *testing.T) {
+	tests := []struct {
+		name     string
+		locale   Locale
+		amount   string
+		expected string
+	}{
+		{name: "en без группировки", locale: LocaleEN, amount: "1234567.5", expected: "1234567.50"},
+		{name: "ru с группировкой разрядов", locale: LocaleRU, amount: "1234567.5", expected: "1\u00a0234\u00a0567,50"},
+		{name: "ru отрицательная сумма", locale: LocaleRU, amount: "-1000", expected: "-1\u00a0000,00"},
+		{name: "ru короткая сумма", locale: LocaleRU, amount: "999.999", expected: "1\u00a0000,00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.locale.FormatAmount(decimal.RequireFromString(tt.amount)))
+		})
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	walletID := uuid.MustParse("10000000-0000-0000-0000-000000000000")
+	operationID := uuid.MustParse("20000000-0000-0000-0000-000000000000")
+
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, Options{Locale: LocaleRU, Location: msk})
+	assert.NoError(t, err)
+
+	assert.NoError(t, w.WriteHeader(domain.StatementHeader{
+		WalletID: walletID,
+		Currency: "RUB",
+		From:     time.Date(2024, time.March, 1, 0, 0, 0, 0, msk),
+		To:       time.Date(2024, time.April, 1, 0, 0, 0, 0, msk),
+		Opening:  decimal.NewFromInt(1000),
+	}))
+	assert.NoError(t, w.WriteLine(domain.StatementLine{
+		CorrelationID: operationID,
+		OperationType: string(domain.Withdraw),
+		Amount:        decimal.RequireFromString("-250.5"),
+		Balance:       decimal.RequireFromString("749.5"),
+		CreatedAt:     time.Date(2024, time.March, 31, 20, 59, 0, 0, time.UTC),
+	}))
+	assert.NoError(t, w.WriteFooter(domain.StatementFooter{Closing: decimal.RequireFromString("749.5"), Lines: 1}))
+
+	expected := "date;operation_id;type;amount;balance;currency\n" +
+		"01.03.2024 00:00:00;;OPENING;;1\u00a0000,00;RUB\n" +
+		"31.03.2024 23:59:00;20000000-0000-0000-0000-000000000000;WITHDRAW;-250,50;749,50;RUB\n" +
+		"01.04.2024 00:00:00;;CLOSING;;749,50;RUB\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestNewWriter_UnknownFormat(t *testing.T) {
+	_, err := NewWriter(Format("xlsx"), &bytes.Buffer{}, Options{})
+
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
@@ -1,17 +1,207 @@
 package dto
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 )
 
 type OperationWalletRequestDTO struct {
+	WalletID       uuid.UUID       `json:"wallet"`
+	OperationType  string          `json:"operation_type"`
+	Amount         decimal.Decimal `json:"amount"`
+	TargetWalletID uuid.UUID       `json:"target_wallet"`
+}
+
+type OperationResponseDTO struct {
+	OperationID   uuid.UUID       `json:"operation_id"`
 	WalletID      uuid.UUID       `json:"wallet"`
 	OperationType string          `json:"operation_type"`
-	Amount        decimal.Decimal `json:"amount"`
+	Gross         decimal.Decimal `json:"gross"`
+	Fee           decimal.Decimal `json:"fee"`
+	Net           decimal.Decimal `json:"net"`
+	Balance       decimal.Decimal `json:"balance"`
+	// LSN передается в заголовке X-Min-LSN следующих чтений, чтобы они увидели эту операцию
+	LSN string `json:"lsn,omitempty"`
+}
+
+type CreateWalletRequestDTO struct {
+	// ClientID - клиент, в системе которого заведена запись external_id
+	ClientID string `json:"client_id"`
+	// ExternalID - ID записи клиента во внешней системе; повторное создание с ним вернет тот же кошелек
+	ExternalID string `json:"external_id"`
+	// OwnerID - владелец кошелька во внешней системе, у него может быть несколько кошельков
+	OwnerID  string         `json:"owner_id"`
+	Label    string         `json:"label"`
+	Metadata map[string]any `json:"metadata"`
 }
 
 type CreateWalletResponseDTO struct {
-	ID      uuid.UUID       `json:"id"`
+	ID         uuid.UUID       `json:"id"`
+	Balance    decimal.Decimal `json:"balance"`
+	ClientID   string          `json:"client_id,omitempty"`
+	ExternalID string          `json:"external_id,omitempty"`
+	OwnerID    string          `json:"owner_id,omitempty"`
+	Label      string          `json:"label,omitempty"`
+	Metadata   map[string]any  `json:"metadata"`
+}
+
+// UpdateWalletRequestDTO - изменение реквизитов кошелька; отсутствующее поле не меняется,
+// metadata объединяется с текущими метаданными как JSON Merge Patch
+type UpdateWalletRequestDTO struct {
+	Label    *string        `json:"label"`
+	Metadata map[string]any `json:"metadata"`
+}
+
+type WalletBalanceResponseDTO struct {
+	WalletID    uuid.UUID       `json:"walletID"`
+	Balance     decimal.Decimal `json:"balance"`
+	CreditLimit decimal.Decimal `json:"credit_limit"`
+	Available   decimal.Decimal `json:"available"`
+	Tier        string          `json:"tier"`
+	Currency    string          `json:"currency"`
+	Status      string          `json:"status"`
+	ClientID    string          `json:"client_id,omitempty"`
+	ExternalID  string          `json:"external_id,omitempty"`
+	OwnerID     string          `json:"owner_id,omitempty"`
+	Label       string          `json:"label,omitempty"`
+	Metadata    map[string]any  `json:"metadata"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+type WalletListResponseDTO struct {
+	Wallets []WalletBalanceResponseDTO `json:"wallets"`
+	// NextCursor передается в cursor для следующей страницы; пустой на последней странице
+	NextCursor string `json:"next_cursor,omitempty"`
+	// Total - количество кошельков по фильтру, если запрошено include_total=true
+	Total *int `json:"total,omitempty"`
+}
+
+type SetCreditLimitRequestDTO struct {
+	CreditLimit decimal.Decimal `json:"credit_limit"`
+}
+
+type SpendingLimitsDTO struct {
+	DailyWithdrawal   decimal.NullDecimal `json:"daily_withdrawal"`
+	MonthlyWithdrawal decimal.NullDecimal `json:"monthly_withdrawal"`
+	SingleWithdrawal  decimal.NullDecimal `json:"single_withdrawal"`
+}
+
+type UpgradeTierRequestDTO struct {
+	Tier   string `json:"tier" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+type BatchOperationsRequestDTO struct {
+	Mode       string                      `json:"mode" binding:"required"`
+	Operations []OperationWalletRequestDTO `json:"operations" binding:"required"`
+}
+
+type BatchItemResultDTO struct {
+	Index     int                   `json:"index"`
+	Status    string                `json:"status"`
+	Operation *OperationResponseDTO `json:"operation,omitempty"`
+	Error     *ProblemDTO           `json:"error,omitempty"`
+}
+
+type BatchResponseDTO struct {
+	Mode    string               `json:"mode"`
+	Results []BatchItemResultDTO `json:"results"`
+}
+
+type BalanceDriftDTO struct {
+	WalletID    uuid.UUID       `json:"wallet"`
+	Currency    string          `json:"currency"`
+	Stored      decimal.Decimal `json:"stored"`
+	Ledger      decimal.Decimal `json:"ledger"`
+	Drift       decimal.Decimal `json:"drift"`
+	Repaired    bool            `json:"repaired"`
+	RepairError string          `json:"repair_error,omitempty"`
+}
+
+type ReconcileReportDTO struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Checked    int               `json:"checked"`
+	Repaired   int               `json:"repaired"`
+	Drifts     []BalanceDriftDTO `json:"drifts"`
+}
+
+type BalanceAtResponseDTO struct {
+	WalletID uuid.UUID       `json:"wallet"`
+	At       time.Time       `json:"at"`
+	Balance  decimal.Decimal `json:"balance"`
+	Currency string          `json:"currency"`
+}
+
+type BalancePointDTO struct {
+	Date    string          `json:"date"`
 	Balance decimal.Decimal `json:"balance"`
 }
+
+type BalanceSeriesResponseDTO struct {
+	WalletID uuid.UUID         `json:"wallet"`
+	Timezone string            `json:"timezone"`
+	Points   []BalancePointDTO `json:"points"`
+}
+
+type ImportResponseDTO struct {
+	ID          uuid.UUID  `json:"id"`
+	FileName    string     `json:"file_name"`
+	Status      string     `json:"status"`
+	TotalRows   int        `json:"total_rows"`
+	AppliedRows int        `json:"applied_rows"`
+	FailedRows  int        `json:"failed_rows"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+type SetLogLevelRequestDTO struct {
+	// Logger - имя логгера, например WalletService; пустое меняет общий уровень
+	Logger string `json:"logger"`
+	Level  string `json:"level" binding:"required"`
+}
+
+type LogLevelsResponseDTO struct {
+	Level   string            `json:"level"`
+	Loggers map[string]string `json:"loggers"`
+}
+
+// ProblemDTO - ошибка в формате RFC 7807. Поля после RequestID заполняются только для ошибок, к которым относятся
+type ProblemDTO struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Available и Amount - доступные средства и запрошенная сумма при нехватке средств
+	Available *decimal.Decimal `json:"available,omitempty"`
+	Amount    *decimal.Decimal `json:"amount,omitempty"`
+	// Limit, Max и Remaining описывают превышенный лимит
+	Limit     string           `json:"limit,omitempty"`
+	Max       *decimal.Decimal `json:"max,omitempty"`
+	Remaining *decimal.Decimal `json:"remaining,omitempty"`
+	// Index и Cause - операция, из-за которой откатился атомарный пакет, и ее ошибка
+	Index *int        `json:"index,omitempty"`
+	Cause *ProblemDTO `json:"cause,omitempty"`
+	// Items - операции пакета, не прошедшие проверку
+	Items []ProblemItemDTO `json:"items,omitempty"`
+	// Errors - поля запроса, не соответствующие спецификации API
+	Errors []ProblemFieldErrorDTO `json:"errors,omitempty"`
+}
+
+type ProblemItemDTO struct {
+	Index  int    `json:"index"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+type ProblemFieldErrorDTO struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+
+	"testtask/internal/domain"
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	batchItemStatusOK    = "ok"
+	batchItemStatusError = "error"
+)
+
+func (h *Handler) Batch(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	var req dto.BatchOperationsRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	reqs := make([]domain.OperationRequest, len(req.Operations))
+	for i, op := range req.Operations {
+		reqs[i] = domain.OperationRequest{
+			ID:            op.WalletID,
+			OperationType: domain.OperationType(op.OperationType),
+			Amount:        op.Amount,
+			TargetID:      op.TargetWalletID,
+		}
+	}
+
+	mode := domain.BatchMode(req.Mode)
+	results, err := h.walletService.PerformBatch(c.Request.Context(), mode, reqs)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("mode", req.Mode), zap.Int("size", len(reqs))), "Batch rejected", err)
+		return
+	}
+
+	response := dto.BatchResponseDTO{
+		Mode:    req.Mode,
+		Results: make([]dto.BatchItemResultDTO, len(results)),
+	}
+	failed := 0
+	for i, result := range results {
+		item := dto.BatchItemResultDTO{Index: result.Index, Status: batchItemStatusOK}
+		if result.Err != nil {
+			failed++
+			item.Status = batchItemStatusError
+			p := problem.FromError(c, result.Err)
+			if p.Status == http.StatusInternalServerError {
+				log.Error("Batch operation failed", zap.Int("index", result.Index), zap.Error(result.Err))
+			}
+			item.Error = &p
+		} else {
+			operation := newOperationResponse(result.Result)
+			item.Operation = &operation
+		}
+		response.Results[i] = item
+	}
+
+	log.Info("Batch performed", zap.String("mode", req.Mode), zap.Int("size", len(results)), zap.Int("failed", failed))
+	c.JSON(http.StatusOK, response)
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
+	"time"
 
 	"testtask/internal/domain"
 	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/middleware"
+	"testtask/internal/transport/http/problem"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -15,19 +19,44 @@ import (
 )
 
 type WalletService interface {
-	CreateWallet(ctx context.Context) (*domain.Wallet, error)
-	PerformOperation(ctx context.Context, wallet domain.OperationRequest) error
-	GetBalance(ctx context.Context, id uuid.UUID) (decimal.Decimal, error)
+	CreateWallet(ctx context.Context, details domain.WalletDetails) (*domain.Wallet, bool, error)
+	FindWalletByExternalID(ctx context.Context, clientID, externalID string) (*domain.Wallet, error)
+	ListWallets(ctx context.Context, query domain.WalletListQuery) (*domain.WalletPage, error)
+	UpdateWallet(ctx context.Context, id uuid.UUID, update domain.WalletUpdate) (*domain.Wallet, error)
+	PerformOperation(ctx context.Context, wallet domain.OperationRequest) (*domain.OperationResult, error)
+	GetWallet(ctx context.Context, id uuid.UUID) (*domain.Wallet, error)
+	SetCreditLimit(ctx context.Context, id uuid.UUID, creditLimit decimal.Decimal) (*domain.Wallet, error)
+	GetLimits(ctx context.Context, id uuid.UUID) (domain.SpendingLimits, error)
+	SetWalletLimits(ctx context.Context, id uuid.UUID, limits domain.SpendingLimits) (domain.SpendingLimits, error)
+	SetTierLimits(ctx context.Context, tier domain.WalletTier, limits domain.SpendingLimits) error
+	UpgradeTier(ctx context.Context, id uuid.UUID, tier domain.WalletTier, actor string, reason string) (*domain.Wallet, error)
+	PerformBatch(ctx context.Context, mode domain.BatchMode, reqs []domain.OperationRequest) ([]domain.BatchItemResult, error)
+	BalanceAt(ctx context.Context, id uuid.UUID, at time.Time) (*domain.Wallet, decimal.Decimal, error)
+	BalanceSeries(ctx context.Context, id uuid.UUID, from, to time.Time, loc *time.Location) ([]domain.BalancePoint, error)
+	Statement(ctx context.Context, id uuid.UUID, from, to time.Time, w domain.StatementWriter) error
+	StartImport(ctx context.Context, fileName string, r io.Reader) (*domain.Import, error)
+	GetImport(ctx context.Context, id uuid.UUID) (*domain.Import, error)
+	ImportErrors(ctx context.Context, id uuid.UUID, fn func(row domain.ImportRow) error) error
+}
+
+// principal возвращает имя администратора, аутентифицированного middleware.AdminAuth, - автора изменения для журнала аудита
+func principal(c *gin.Context) string {
+	return c.GetString(middleware.PrincipalKey)
 }
 
 type Handler struct {
 	walletService WalletService
+	logLevels     LogLevels
 }
 
-func NewHandler(walletService WalletService) *Handler {
-	return &Handler{
+func NewHandler(walletService WalletService, opts ...Option) *Handler {
+	h := &Handler{
 		walletService: walletService,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) Operation(c *gin.Context) {
@@ -35,7 +64,7 @@ func (h *Handler) Operation(c *gin.Context) {
 	var req dto.OperationWalletRequestDTO
 	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Warn("Failed to decode request body", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
 		return
 	}
 
@@ -43,78 +72,308 @@ func (h *Handler) Operation(c *gin.Context) {
 		ID:            req.WalletID,
 		OperationType: domain.OperationType(req.OperationType),
 		Amount:        req.Amount,
+		TargetID:      req.TargetWalletID,
 	}
 
-	err := h.walletService.PerformOperation(c.Request.Context(), wallet)
+	result, err := h.walletService.PerformOperation(c.Request.Context(), wallet)
 	if err != nil {
-		switch {
-		case errors.Is(err, domain.ErrIDIsNil), errors.Is(err, domain.ErrAmountZeroOrNegative):
-			log.Warn("Invalid request data", zap.Error(err))
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		case errors.Is(err, domain.ErrWalletNotFound):
-			log.Warn("Wallet not found", zap.String("wallet_id", wallet.ID.String()))
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		case errors.Is(err, domain.ErrInsufficientFunds):
-			log.Warn("Insufficient funds", zap.String("wallet_id", wallet.ID.String()))
-			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
-		case errors.Is(err, domain.ErrUnknownOperationType):
-			log.Warn("Unknown operation type", zap.String("operation_type", req.OperationType))
-			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		default:
-			log.Error("Failed to perform operation", zap.Error(err))
-			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
-		}
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", wallet.ID.String()),
+			zap.String("operation_type", req.OperationType)), "Operation rejected", err)
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	c.JSON(http.StatusOK, newOperationResponse(result))
+}
+
+func newOperationResponse(result *domain.OperationResult) dto.OperationResponseDTO {
+	return dto.OperationResponseDTO{
+		OperationID:   result.ID,
+		WalletID:      result.WalletID,
+		OperationType: string(result.OperationType),
+		Gross:         result.Gross,
+		Fee:           result.Fee,
+		Net:           result.Net,
+		Balance:       result.Balance,
+		LSN:           result.LSN,
+	}
 }
 
 func (h *Handler) GetBalance(c *gin.Context) {
 	log := c.MustGet("logger").(*zap.Logger)
-	walletIDStr := c.Param("id")
-	walletID, err := uuid.Parse(walletIDStr)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+
+	wallet, err := h.walletService.GetWallet(c.Request.Context(), walletID)
 	if err != nil {
-		log.Warn("Failed to parse walletID", zap.String("walletIDStr", walletIDStr), zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "walletID is not a valid UUID"})
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to get balance", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newWalletBalanceResponse(wallet))
+}
+
+func (h *Handler) SetCreditLimit(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
 		return
 	}
 
-	balance, err := h.walletService.GetBalance(c.Request.Context(), walletID)
+	var req dto.SetCreditLimitRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	wallet, err := h.walletService.SetCreditLimit(c.Request.Context(), walletID, req.CreditLimit)
 	if err != nil {
-		if errors.Is(err, domain.ErrWalletNotFound) {
-			log.Warn("Wallet not found for get balance", zap.String("walletID", walletID.String()))
-			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		log.Error("Failed to get balance", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to set credit limit", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"walletID": walletID,
-		"balance":  balance.String()},
-	)
+	log.Info("Credit limit updated", zap.String("wallet_id", walletID.String()),
+		zap.String("credit_limit", wallet.CreditLimit.String()))
+	c.JSON(http.StatusOK, newWalletBalanceResponse(wallet))
 }
 
+// CreateWallet создает кошелек. Если кошелек с переданным external_id уже есть, возвращает его со статусом 200
 func (h *Handler) CreateWallet(c *gin.Context) {
 	log := c.MustGet("logger").(*zap.Logger)
 
 	log.Info("Handling create wallet request")
 
-	newWallet, err := h.walletService.CreateWallet(c.Request.Context())
+	// Тело необязательно: кошелек без реквизитов создается пустым запросом
+	var req dto.CreateWalletRequestDTO
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			log.Warn("Failed to decode request body", zap.Error(err))
+			problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+			return
+		}
+	}
+
+	newWallet, created, err := h.walletService.CreateWallet(c.Request.Context(), domain.WalletDetails{
+		ClientID:   req.ClientID,
+		ExternalID: req.ExternalID,
+		OwnerID:    req.OwnerID,
+		Label:      req.Label,
+		Metadata:   req.Metadata,
+	})
 	if err != nil {
-		log.Error("Failed to create wallet", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		problem.AbortWithError(c, log, "Failed to create wallet", err)
 		return
 	}
 
-	log.Info("Successfully created new wallet", zap.String("wallet_id", newWallet.ID.String()))
-
 	responseDTO := dto.CreateWalletResponseDTO{
-		ID:      newWallet.ID,
-		Balance: newWallet.Balance,
+		ID:         newWallet.ID,
+		Balance:    newWallet.Balance,
+		ClientID:   newWallet.ClientID,
+		ExternalID: newWallet.ExternalID,
+		OwnerID:    newWallet.OwnerID,
+		Label:      newWallet.Label,
+		Metadata:   metadataResponse(newWallet.Metadata),
+	}
+	if !created {
+		log.Info("Wallet with external ID already exists", zap.String("wallet_id", newWallet.ID.String()),
+			zap.String("client_id", newWallet.ClientID), zap.String("external_id", newWallet.ExternalID))
+		c.JSON(http.StatusOK, responseDTO)
+		return
 	}
+
+	log.Info("Successfully created new wallet", zap.String("wallet_id", newWallet.ID.String()))
 	c.JSON(http.StatusCreated, responseDTO)
 }
+
+// FindWallet ищет кошелек клиента ?client_id= по внешнему ID ?external_id=
+func (h *Handler) FindWallet(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	clientID, externalID := c.Query("client_id"), c.Query("external_id")
+	if externalID == "" {
+		log.Warn("External ID is missing")
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "external_id is required")
+		return
+	}
+
+	wallet, err := h.walletService.FindWalletByExternalID(c.Request.Context(), clientID, externalID)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("client_id", clientID), zap.String("external_id", externalID)),
+			"Failed to find wallet", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newWalletBalanceResponse(wallet))
+}
+
+// UpdateWallet меняет название и метаданные кошелька
+func (h *Handler) UpdateWallet(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+
+	var req dto.UpdateWalletRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	wallet, err := h.walletService.UpdateWallet(c.Request.Context(), walletID, domain.WalletUpdate{
+		Label:    req.Label,
+		Metadata: req.Metadata,
+	})
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to update wallet", err)
+		return
+	}
+
+	log.Info("Wallet details updated", zap.String("wallet_id", walletID.String()))
+	c.JSON(http.StatusOK, newWalletBalanceResponse(wallet))
+}
+
+func newWalletBalanceResponse(wallet *domain.Wallet) dto.WalletBalanceResponseDTO {
+	return dto.WalletBalanceResponseDTO{
+		WalletID:    wallet.ID,
+		Balance:     wallet.Balance,
+		CreditLimit: wallet.CreditLimit,
+		Available:   wallet.Available(),
+		Tier:        string(wallet.Tier),
+		Currency:    wallet.Currency,
+		Status:      string(wallet.Status),
+		ClientID:    wallet.ClientID,
+		ExternalID:  wallet.ExternalID,
+		OwnerID:     wallet.OwnerID,
+		Label:       wallet.Label,
+		Metadata:    metadataResponse(wallet.Metadata),
+		CreatedAt:   wallet.CreatedAt,
+	}
+}
+
+// metadataResponse возвращает пустой объект вместо nil, чтобы в ответе было {}, а не null
+func metadataResponse(metadata map[string]any) map[string]any {
+	if metadata == nil {
+		return map[string]any{}
+	}
+	return metadata
+}
+
+func (h *Handler) GetLimits(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+
+	limits, err := h.walletService.GetLimits(c.Request.Context(), walletID)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to get limits", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newSpendingLimitsResponse(limits))
+}
+
+func (h *Handler) SetWalletLimits(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+
+	var req dto.SpendingLimitsDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	limits, err := h.walletService.SetWalletLimits(c.Request.Context(), walletID, newSpendingLimits(req))
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to set wallet limits", err)
+		return
+	}
+
+	log.Info("Wallet spending limits updated", zap.String("wallet_id", walletID.String()))
+	c.JSON(http.StatusOK, newSpendingLimitsResponse(limits))
+}
+
+func (h *Handler) SetTierLimits(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	tier := domain.WalletTier(c.Param("tier"))
+
+	var req dto.SpendingLimitsDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	if err := h.walletService.SetTierLimits(c.Request.Context(), tier, newSpendingLimits(req)); err != nil {
+		if errors.Is(err, domain.ErrUnknownTier) {
+			// Уровень в пути запроса - это ресурс, поэтому неизвестный уровень означает 404, а не 400
+			log.Warn("Unknown tier", zap.String("tier", string(tier)))
+			problem.Abort(c, problem.New(c, http.StatusNotFound, problem.CodeTierNotFound, err.Error()))
+			return
+		}
+		problem.AbortWithError(c, log.With(zap.String("tier", string(tier))), "Failed to set tier limits", err)
+		return
+	}
+
+	log.Info("Tier spending limits updated", zap.String("tier", string(tier)))
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) UpgradeTier(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+
+	var req dto.UpgradeTierRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+
+	wallet, err := h.walletService.UpgradeTier(c.Request.Context(), walletID, domain.WalletTier(req.Tier), principal(c), req.Reason)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String()), zap.String("tier", req.Tier)),
+			"Failed to upgrade tier", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newWalletBalanceResponse(wallet))
+}
+
+func parseWalletID(c *gin.Context, log *zap.Logger) (uuid.UUID, bool) {
+	walletIDStr := c.Param("id")
+	walletID, err := uuid.Parse(walletIDStr)
+	if err != nil {
+		log.Warn("Failed to parse walletID", zap.String("wallet_id", walletIDStr), zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidWalletID, "walletID is not a valid UUID")
+		return uuid.Nil, false
+	}
+	return walletID, true
+}
+
+func newSpendingLimits(req dto.SpendingLimitsDTO) domain.SpendingLimits {
+	return domain.SpendingLimits{
+		DailyWithdrawal:   req.DailyWithdrawal,
+		MonthlyWithdrawal: req.MonthlyWithdrawal,
+		SingleWithdrawal:  req.SingleWithdrawal,
+	}
+}
+
+func newSpendingLimitsResponse(limits domain.SpendingLimits) dto.SpendingLimitsDTO {
+	return dto.SpendingLimitsDTO{
+		DailyWithdrawal:   limits.DailyWithdrawal,
+		MonthlyWithdrawal: limits.MonthlyWithdrawal,
+		SingleWithdrawal:  limits.SingleWithdrawal,
+	}
+}
@@ -5,71 +5,246 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"go.uber.org/zap"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"testtask/internal/domain"
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/middleware"
+	"testtask/internal/transport/http/openapi"
+	"testtask/pkg/logger"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 type MockWalletService struct {
 	mock.Mock
 }
 
-func (m *MockWalletService) CreateWallet(ctx context.Context) (*domain.Wallet, error) {
-	args := m.Called(ctx)
+func (m *MockWalletService) CreateWallet(ctx context.Context, details domain.WalletDetails) (*domain.Wallet, bool, error) {
+	args := m.Called(ctx, details)
+	if args.Get(0) == nil {
+		return nil, false, args.Error(2)
+	}
+	return args.Get(0).(*domain.Wallet), args.Bool(1), args.Error(2)
+}
+
+func (m *MockWalletService) FindWalletByExternalID(ctx context.Context, clientID, externalID string) (*domain.Wallet, error) {
+	args := m.Called(ctx, clientID, externalID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+func (m *MockWalletService) ListWallets(ctx context.Context, query domain.WalletListQuery) (*domain.WalletPage, error) {
+	args := m.Called(ctx, query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.WalletPage), args.Error(1)
+}
+
+func (m *MockWalletService) UpdateWallet(ctx context.Context, id uuid.UUID, update domain.WalletUpdate) (*domain.Wallet, error) {
+	args := m.Called(ctx, id, update)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*domain.Wallet), args.Error(1)
 }
 
-func (m *MockWalletService) PerformOperation(ctx context.Context, req domain.OperationRequest) error {
+func (m *MockWalletService) PerformOperation(ctx context.Context, req domain.OperationRequest) (*domain.OperationResult, error) {
 	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.OperationResult), args.Error(1)
+}
+
+func (m *MockWalletService) GetWallet(ctx context.Context, id uuid.UUID) (*domain.Wallet, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+func (m *MockWalletService) SetCreditLimit(ctx context.Context, id uuid.UUID, creditLimit decimal.Decimal) (*domain.Wallet, error) {
+	args := m.Called(ctx, id, creditLimit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+func (m *MockWalletService) GetLimits(ctx context.Context, id uuid.UUID) (domain.SpendingLimits, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(domain.SpendingLimits), args.Error(1)
+}
+
+func (m *MockWalletService) SetWalletLimits(ctx context.Context, id uuid.UUID, limits domain.SpendingLimits) (domain.SpendingLimits, error) {
+	args := m.Called(ctx, id, limits)
+	return args.Get(0).(domain.SpendingLimits), args.Error(1)
+}
+
+func (m *MockWalletService) SetTierLimits(ctx context.Context, tier domain.WalletTier, limits domain.SpendingLimits) error {
+	args := m.Called(ctx, tier, limits)
+	return args.Error(0)
+}
+
+func (m *MockWalletService) UpgradeTier(ctx context.Context, id uuid.UUID, tier domain.WalletTier, actor string, reason string) (*domain.Wallet, error) {
+	args := m.Called(ctx, id, tier, actor, reason)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Wallet), args.Error(1)
+}
+
+func (m *MockWalletService) PerformBatch(ctx context.Context, mode domain.BatchMode, reqs []domain.OperationRequest) ([]domain.BatchItemResult, error) {
+	args := m.Called(ctx, mode, reqs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.BatchItemResult), args.Error(1)
+}
+
+func (m *MockWalletService) BalanceAt(ctx context.Context, id uuid.UUID, at time.Time) (*domain.Wallet, decimal.Decimal, error) {
+	args := m.Called(ctx, id, at)
+	if args.Get(0) == nil {
+		return nil, decimal.Zero, args.Error(2)
+	}
+	return args.Get(0).(*domain.Wallet), args.Get(1).(decimal.Decimal), args.Error(2)
+}
+
+func (m *MockWalletService) BalanceSeries(ctx context.Context, id uuid.UUID, from, to time.Time, loc *time.Location) ([]domain.BalancePoint, error) {
+	args := m.Called(ctx, id, from, to, loc)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.BalancePoint), args.Error(1)
+}
+
+func (m *MockWalletService) Statement(ctx context.Context, id uuid.UUID, from, to time.Time, w domain.StatementWriter) error {
+	args := m.Called(ctx, id, from, to, w)
 	return args.Error(0)
 }
 
-func (m *MockWalletService) GetBalance(ctx context.Context, id uuid.UUID) (decimal.Decimal, error) {
+func (m *MockWalletService) StartImport(ctx context.Context, fileName string, r io.Reader) (*domain.Import, error) {
+	args := m.Called(ctx, fileName, r)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Import), args.Error(1)
+}
+
+func (m *MockWalletService) GetImport(ctx context.Context, id uuid.UUID) (*domain.Import, error) {
 	args := m.Called(ctx, id)
-	return args.Get(0).(decimal.Decimal), args.Error(1)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Import), args.Error(1)
+}
+
+func (m *MockWalletService) ImportErrors(ctx context.Context, id uuid.UUID, fn func(row domain.ImportRow) error) error {
+	args := m.Called(ctx, id, fn)
+	return args.Error(0)
+}
+
+// responseRecorder сохраняет тело ответа для проверки по спецификации
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// conformToSpec проверяет, что каждый ответ описанного в спецификации маршрута ей соответствует
+func conformToSpec(t *testing.T) gin.HandlerFunc {
+	spec := openapi.MustLoad()
+	return func(c *gin.Context) {
+		w := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		if spec.Operation(c.Request.Method, c.FullPath()) == nil {
+			return
+		}
+		err := spec.ValidateResponse(c.Request.Method, c.FullPath(), w.Status(), w.Header(), w.body.Bytes())
+		assert.NoError(t, err)
+	}
 }
 
-func setupTest() (*gin.Engine, *MockWalletService) {
+// adminToken - токен администратора "support" в тестовом роутере
+const adminToken = "test-admin-token"
+
+func setupTest(t *testing.T) (*gin.Engine, *MockWalletService) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	router.Use(func(c *gin.Context) {
+	router.Use(conformToSpec(t), middleware.RequestID(), func(c *gin.Context) {
 		c.Set("logger", zap.NewNop())
 		c.Next()
-	})
+	}, middleware.Recovery(zap.NewNop()))
 
 	mockService := new(MockWalletService)
-	handler := NewHandler(mockService)
+	handler := NewHandler(mockService, WithLogLevels(logger.NewLevels(zap.InfoLevel)))
 
 	v1 := router.Group("/api/v1")
+	v1.Use(middleware.ReadYourWrites())
 	{
 		v1.POST("/wallets", handler.CreateWallet)
-		v1.GET("/wallets/:walletID", handler.GetBalance)
+		v1.GET("/wallets", handler.ListWallets)
+		v1.GET("/wallets/lookup", handler.FindWallet)
+		v1.GET("/wallets/:id", handler.GetBalance)
+		v1.PATCH("/wallets/:id", handler.UpdateWallet)
+		v1.GET("/wallets/:id/balance", handler.GetBalanceAt)
+		v1.GET("/wallets/:id/balance/daily", handler.GetBalanceSeries)
+		v1.GET("/wallets/:id/statement", handler.GetStatement)
 		v1.POST("/wallet", handler.Operation)
+		v1.POST("/operations/batch", handler.Batch)
+		v1.POST("/imports", handler.CreateImport)
+		v1.GET("/imports/:id", handler.GetImport)
+		v1.GET("/imports/:id/errors", handler.GetImportErrors)
+		v1.GET("/panic", func(c *gin.Context) { panic("boom") })
+	}
+	admin := v1.Group("/admin", middleware.AdminAuth(map[string]string{adminToken: "support"}))
+	{
+		admin.PUT("/wallets/:id/credit-limit", handler.SetCreditLimit)
+		admin.PUT("/wallets/:id/limits", handler.SetWalletLimits)
+		admin.PUT("/wallets/:id/tier", handler.UpgradeTier)
+		admin.GET("/log-level", handler.GetLogLevels)
+		admin.PUT("/log-level", handler.SetLogLevel)
+		admin.DELETE("/log-level/:logger", handler.ResetLogLevel)
 	}
 
 	return router, mockService
 }
 
 func TestHandler_CreateWallet(t *testing.T) {
-	router, mockService := setupTest()
+	router, mockService := setupTest(t)
 
 	t.Run("Success", func(t *testing.T) {
 		walletID := uuid.New()
-		expectedWallet := &domain.Wallet{ID: walletID, Balance: decimal.Zero}
+		expectedWallet := &domain.Wallet{ID: walletID, Balance: decimal.Zero, Tier: domain.TierAnonymous, Status: domain.WalletActive}
 
-		mockService.On("CreateWallet", mock.Anything).Return(expectedWallet, nil).Once()
+		mockService.On("CreateWallet", mock.Anything, domain.WalletDetails{}).Return(expectedWallet, true, nil).Once()
 
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallets", nil)
 		w := httptest.NewRecorder()
@@ -87,7 +262,7 @@ func TestHandler_CreateWallet(t *testing.T) {
 	})
 
 	t.Run("Internal Server Error", func(t *testing.T) {
-		mockService.On("CreateWallet", mock.Anything).Return(nil, errors.New("db is down")).Once()
+		mockService.On("CreateWallet", mock.Anything, domain.WalletDetails{}).Return(nil, false, errors.New("db is down")).Once()
 
 		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallets", nil)
 		w := httptest.NewRecorder()
@@ -97,16 +272,246 @@ func TestHandler_CreateWallet(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		mockService.AssertExpectations(t)
 	})
+
+	t.Run("With Details", func(t *testing.T) {
+		walletID := uuid.New()
+		details := domain.WalletDetails{ExternalID: "customer-42", Label: "Основной", Metadata: map[string]any{"segment": "retail"}}
+		created := &domain.Wallet{ID: walletID, Balance: decimal.Zero, ExternalID: details.ExternalID, Label: details.Label, Metadata: details.Metadata}
+		mockService.On("CreateWallet", mock.Anything, details).Return(created, true, nil).Once()
+
+		body := `{"external_id": "customer-42", "label": "Основной", "metadata": {"segment": "retail"}}`
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallets", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var respBody map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "customer-42", respBody["external_id"])
+		assert.Equal(t, map[string]any{"segment": "retail"}, respBody["metadata"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Existing External ID", func(t *testing.T) {
+		existing := &domain.Wallet{ID: uuid.New(), Balance: decimal.NewFromInt(10), ExternalID: "customer-42"}
+		mockService.On("CreateWallet", mock.Anything, domain.WalletDetails{ExternalID: "customer-42"}).Return(existing, false, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallets", bytes.NewBufferString(`{"external_id": "customer-42"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, existing.ID.String(), respBody["id"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Details", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallets", bytes.NewBufferString(`{"metadata": "retail"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_ListWallets(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Filters And Next Page", func(t *testing.T) {
+		wallets := []domain.Wallet{
+			{ID: uuid.New(), Balance: decimal.NewFromInt(300), Tier: domain.TierAnonymous, Status: domain.WalletFrozen, Currency: "RUB",
+				ExternalID: "customer-42", Metadata: map[string]any{"segment": "retail"}, CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+			{ID: uuid.New(), Balance: decimal.NewFromInt(200), Tier: domain.TierAnonymous, Status: domain.WalletFrozen, Currency: "RUB",
+				CreatedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)},
+		}
+		minBalance, maxBalance := decimal.NewFromInt(100), decimal.RequireFromString("500.50")
+		query := domain.WalletListQuery{
+			Filter: domain.WalletFilter{
+				Status:       domain.WalletFrozen,
+				OwnerID:      "customer-42",
+				Currency:     "RUB",
+				MinBalance:   &minBalance,
+				MaxBalance:   &maxBalance,
+				CreatedFrom:  time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				MetadataKeys: []string{"segment", "manager"},
+				Metadata:     map[string]any{"segment": "retail"},
+			},
+			Sort:      domain.SortByBalance,
+			Desc:      true,
+			Limit:     2,
+			WithTotal: true,
+		}
+		total := 5
+		page := &domain.WalletPage{Wallets: wallets, Next: domain.CursorAfter(&wallets[1], domain.SortByBalance, true), Total: &total}
+		mockService.On("ListWallets", mock.Anything, query).Return(page, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets?status=FROZEN&owner_id=customer-42&currency=RUB&balance_min=100&balance_max=500.50"+
+			"&created_from=2024-03-01T00:00:00Z&metadata_key=segment,manager&metadata=%7B%22segment%22%3A%22retail%22%7D"+
+			"&sort=-balance&limit=2&include_total=true", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp dto.WalletListResponseDTO
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Wallets, 2)
+		assert.Equal(t, wallets[0].ID, resp.Wallets[0].WalletID)
+		assert.Equal(t, "customer-42", resp.Wallets[0].ExternalID)
+		require.NotNil(t, resp.Total)
+		assert.Equal(t, 5, *resp.Total)
+
+		cursor, err := domain.DecodeWalletCursor(resp.NextCursor)
+		require.NoError(t, err)
+		assert.Equal(t, wallets[1].ID, cursor.ID)
+		assert.True(t, cursor.Balance.Equal(decimal.NewFromInt(200)))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		after := &domain.WalletCursor{Sort: domain.SortByCreatedAt, Desc: true, CreatedAt: time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC), ID: uuid.New()}
+		query := domain.WalletListQuery{Sort: domain.SortByCreatedAt, Desc: true, After: after}
+		mockService.On("ListWallets", mock.Anything, query).Return(&domain.WalletPage{}, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets?cursor="+after.Encode(), nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"wallets": []}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "Malformed Cursor", query: "cursor=not-a-cursor"},
+		{name: "Invalid Balance", query: "balance_min=abc"},
+		{name: "Invalid Created Date", query: "created_to=2024-03-01"},
+		{name: "Metadata Is Not An Object", query: "metadata=%5B1%5D"},
+		{name: "Invalid Limit", query: "limit=0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var problem dto.ProblemDTO
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+			assert.Equal(t, "INVALID_WALLET_QUERY", problem.Code)
+		})
+	}
+}
+
+func TestHandler_UpdateWallet(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		walletID := uuid.New()
+		label := "Накопления"
+		update := domain.WalletUpdate{Label: &label, Metadata: map[string]any{"manager": nil}}
+		updated := &domain.Wallet{ID: walletID, Tier: domain.TierAnonymous, Status: domain.WalletActive, Label: label, Metadata: map[string]any{}}
+		mockService.On("UpdateWallet", mock.Anything, walletID, update).Return(updated, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodPatch, "/api/v1/wallets/"+walletID.String(),
+			bytes.NewBufferString(`{"label": "Накопления", "metadata": {"manager": null}}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp dto.WalletBalanceResponseDTO
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Накопления", resp.Label)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid Details", func(t *testing.T) {
+		walletID := uuid.New()
+		err := fmt.Errorf("%w: metadata is larger than 16384 bytes", domain.ErrInvalidWalletDetails)
+		mockService.On("UpdateWallet", mock.Anything, walletID, mock.Anything).Return(nil, err).Once()
+
+		req, _ := http.NewRequest(http.MethodPatch, "/api/v1/wallets/"+walletID.String(), bytes.NewBufferString(`{"metadata": {}}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var problem dto.ProblemDTO
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+		assert.Equal(t, "INVALID_WALLET_DETAILS", problem.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestHandler_FindWallet(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		wallet := &domain.Wallet{ID: uuid.New(), Tier: domain.TierAnonymous, Status: domain.WalletActive, Currency: "RUB",
+			ClientID: "acme", ExternalID: "customer-42", OwnerID: "person-7"}
+		mockService.On("FindWalletByExternalID", mock.Anything, "acme", "customer-42").Return(wallet, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/lookup?client_id=acme&external_id=customer-42", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp dto.WalletBalanceResponseDTO
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, wallet.ID, resp.WalletID)
+		assert.Equal(t, "acme", resp.ClientID)
+		assert.Equal(t, "person-7", resp.OwnerID)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Wallet Not Found", func(t *testing.T) {
+		mockService.On("FindWalletByExternalID", mock.Anything, "", "customer-43").Return(nil, domain.ErrWalletNotFound).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/lookup?external_id=customer-43", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("External ID Is Missing", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/lookup?client_id=acme", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
 }
 
 func TestHandler_GetBalance(t *testing.T) {
-	router, mockService := setupTest()
+	router, mockService := setupTest(t)
 
 	t.Run("Success", func(t *testing.T) {
 		walletID := uuid.New()
 		expectedBalance, _ := decimal.NewFromString("123.45")
+		expectedWallet := &domain.Wallet{ID: walletID, Balance: expectedBalance, CreditLimit: decimal.NewFromInt(100), Tier: domain.TierAnonymous, Status: domain.WalletActive}
 
-		mockService.On("GetBalance", mock.Anything, walletID).Return(expectedBalance, nil).Once()
+		mockService.On("GetWallet", mock.Anything, walletID).Return(expectedWallet, nil).Once()
 
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String(), nil)
 		w := httptest.NewRecorder()
@@ -117,12 +522,14 @@ func TestHandler_GetBalance(t *testing.T) {
 		var respBody map[string]string
 		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
 		assert.Equal(t, "123.45", respBody["balance"])
+		assert.Equal(t, "100", respBody["credit_limit"])
+		assert.Equal(t, "223.45", respBody["available"])
 		mockService.AssertExpectations(t)
 	})
 
 	t.Run("Wallet Not Found", func(t *testing.T) {
 		walletID := uuid.New()
-		mockService.On("GetBalance", mock.Anything, walletID).Return(decimal.Zero, domain.ErrWalletNotFound).Once()
+		mockService.On("GetWallet", mock.Anything, walletID).Return(nil, domain.ErrWalletNotFound).Once()
 
 		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String(), nil)
 		w := httptest.NewRecorder()
@@ -132,69 +539,59 @@ func TestHandler_GetBalance(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		mockService.AssertExpectations(t)
 	})
-}
-
-func TestHandler_Operation(t *testing.T) {
-	router, mockService := setupTest()
 
-	t.Run("Success - Deposit", func(t *testing.T) {
+	t.Run("Read Your Writes", func(t *testing.T) {
 		walletID := uuid.New()
-		amount, _ := decimal.NewFromString("100")
-		reqBody := map[string]interface{}{
-			"walletId":      walletID,
-			"operationType": "DEPOSIT",
-			"amount":        amount,
-		}
-		jsonBody, _ := json.Marshal(reqBody)
-
-		expectedReq := domain.OperationRequest{
-			ID:            walletID,
-			OperationType: "DEPOSIT",
-			Amount:        amount,
-		}
-		mockService.On("PerformOperation", mock.Anything, expectedReq).Return(nil).Once()
+		withLSN := mock.MatchedBy(func(ctx context.Context) bool {
+			return domain.MinLSNFromContext(ctx) == "0/16B3748"
+		})
+		mockService.On("GetWallet", withLSN, walletID).Return(&domain.Wallet{ID: walletID, Tier: domain.TierAnonymous, Status: domain.WalletActive}, nil).Once()
 
-		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String(), nil)
+		req.Header.Set(middleware.MinLSNHeader, "0/16B3748")
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
 		mockService.AssertExpectations(t)
 	})
 
-	t.Run("Insufficient Funds", func(t *testing.T) {
-		walletID := uuid.New()
-		amount, _ := decimal.NewFromString("500")
-		reqBody := map[string]interface{}{
-			"walletId":      walletID,
-			"operationType": "WITHDRAW",
-			"amount":        amount,
-		}
-		jsonBody, _ := json.Marshal(reqBody)
+	t.Run("Invalid Min LSN", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.New().String(), nil)
+		req.Header.Set(middleware.MinLSNHeader, "16B3748")
+		w := httptest.NewRecorder()
 
-		expectedReq := domain.OperationRequest{
-			ID:            walletID,
-			OperationType: "WITHDRAW",
-			Amount:        amount,
-		}
-		mockService.On("PerformOperation", mock.Anything, expectedReq).Return(domain.ErrInsufficientFunds).Once()
+		router.ServeHTTP(w, req)
 
-		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(jsonBody))
-		req.Header.Set("Content-Type", "application/json")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetBalanceAt(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		walletID := uuid.New()
+		at := time.Date(2024, time.March, 31, 23, 59, 0, 0, time.FixedZone("MSK", 3*60*60))
+		mockService.On("BalanceAt", mock.Anything, walletID, mock.MatchedBy(at.Equal)).
+			Return(&domain.Wallet{ID: walletID, Currency: "RUB"}, decimal.RequireFromString("42.50"), nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/balance?at=2024-03-31T23:59:00%2B03:00", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "42.5", respBody["balance"])
+		assert.Equal(t, "RUB", respBody["currency"])
 		mockService.AssertExpectations(t)
 	})
 
-	t.Run("Bad Request - Invalid JSON", func(t *testing.T) {
-		invalidJson := []byte(`{"walletId": "not-a-uuid"`)
-		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(invalidJson))
-		req.Header.Set("Content-Type", "application/json")
+	t.Run("Invalid at", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.New().String()+"/balance?at=31.03.2024", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
@@ -202,3 +599,590 @@ func TestHandler_Operation(t *testing.T) {
 		assert.Equal(t, http.StatusBadRequest, w.Code)
 	})
 }
+
+func TestHandler_GetBalanceSeries(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		walletID := uuid.New()
+		mockService.On("BalanceSeries", mock.Anything, walletID, mock.Anything, mock.Anything, mock.Anything).
+			Return([]domain.BalancePoint{
+				{Date: time.Date(2024, time.March, 30, 0, 0, 0, 0, time.UTC), Balance: decimal.NewFromInt(100)},
+				{Date: time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC), Balance: decimal.NewFromInt(70)},
+			}, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/balance/daily?from=2024-03-30&to=2024-03-31&tz=Europe/Moscow", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"wallet":"`+walletID.String()+`","timezone":"Europe/Moscow","points":[`+
+			`{"date":"2024-03-30","balance":"100"},{"date":"2024-03-31","balance":"70"}]}`, w.Body.String())
+		mockService.AssertExpectations(t)
+	})
+
+	for _, tz := range []string{"Mars/Olympus", "Local"} {
+		t.Run("Unknown timezone "+tz, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.New().String()+"/balance/daily?from=2024-03-30&to=2024-03-31&tz="+tz, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
+
+func TestHandler_GetStatement(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success - JSON Lines", func(t *testing.T) {
+		walletID := uuid.New()
+		from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+		to := time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)
+		mockService.On("Statement", mock.Anything, walletID, from, to, mock.Anything).
+			Run(func(args mock.Arguments) {
+				w := args.Get(4).(domain.StatementWriter)
+				_ = w.WriteHeader(domain.StatementHeader{WalletID: walletID, Currency: "RUB", From: from, To: to, Opening: decimal.Zero})
+				_ = w.WriteLine(domain.StatementLine{OperationType: string(domain.Deposit), Amount: decimal.NewFromInt(10), Balance: decimal.NewFromInt(10)})
+				_ = w.WriteFooter(domain.StatementFooter{Closing: decimal.NewFromInt(10), Lines: 1})
+			}).Return(nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/statement?from=2024-03-01&to=2024-03-31&format=jsonl", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "statement-"+walletID.String()+"-2024-03-01-2024-03-31.jsonl")
+		assert.Equal(t, 3, bytes.Count(w.Body.Bytes(), []byte("\n")))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Wallet Not Found", func(t *testing.T) {
+		walletID := uuid.New()
+		mockService.On("Statement", mock.Anything, walletID, mock.Anything, mock.Anything, mock.Anything).
+			Return(domain.ErrWalletNotFound).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+walletID.String()+"/statement?from=2024-03-01&to=2024-03-31", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "application/problem+json")
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("Unknown format", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/"+uuid.New().String()+"/statement?from=2024-03-01&to=2024-03-31&format=xlsx", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_Imports(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success - CSV body", func(t *testing.T) {
+		importID := uuid.New()
+		mockService.On("StartImport", mock.Anything, "legacy.csv", mock.Anything).
+			Return(&domain.Import{ID: importID, Status: domain.ImportPending, TotalRows: 2}, nil).Once()
+
+		body := "wallet,operation_type,amount\n" + uuid.NewString() + ",DEPOSIT,10\n"
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/imports?name=legacy.csv", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "/api/v1/imports/"+importID.String(), w.Header().Get("Location"))
+		var respBody map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "PENDING", respBody["status"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		mockService.On("StartImport", mock.Anything, "upload.csv", mock.Anything).
+			Return(nil, domain.ErrInvalidImportFile).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/imports", bytes.NewBufferString("garbage"))
+		req.Header.Set("Content-Type", "text/csv")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Error report", func(t *testing.T) {
+		importID := uuid.New()
+		mockService.On("ImportErrors", mock.Anything, importID, mock.Anything).
+			Run(func(args mock.Arguments) {
+				fn := args.Get(2).(func(row domain.ImportRow) error)
+				_ = fn(domain.ImportRow{Line: 3, Error: "insufficient funds", WalletID: "w", OperationType: "WITHDRAW", Amount: "5"})
+			}).Return(nil).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/imports/"+importID.String()+"/errors", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "line,error,wallet,operation_type,amount,target_wallet,occurred_at\n3,insufficient funds,w,WITHDRAW,5,,\n", w.Body.String())
+	})
+
+	t.Run("Import Not Found", func(t *testing.T) {
+		importID := uuid.New()
+		mockService.On("GetImport", mock.Anything, importID).Return(nil, domain.ErrImportNotFound).Once()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/imports/"+importID.String(), nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestHandler_Operation(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success - Deposit", func(t *testing.T) {
+		walletID := uuid.New()
+		amount, _ := decimal.NewFromString("100")
+		reqBody := map[string]interface{}{
+			"wallet":         walletID,
+			"operation_type": "DEPOSIT",
+			"amount":         amount,
+		}
+		jsonBody, _ := json.Marshal(reqBody)
+
+		expectedReq := domain.OperationRequest{
+			ID:            walletID,
+			OperationType: "DEPOSIT",
+			Amount:        amount,
+		}
+		expectedResult := &domain.OperationResult{
+			ID:            uuid.New(),
+			WalletID:      walletID,
+			OperationType: domain.Deposit,
+			Gross:         amount,
+			Fee:           decimal.Zero,
+			Net:           amount,
+			Balance:       amount,
+		}
+		mockService.On("PerformOperation", mock.Anything, expectedReq).Return(expectedResult, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "100", respBody["gross"])
+		assert.Equal(t, "0", respBody["fee"])
+		assert.Equal(t, "100", respBody["net"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Insufficient Funds", func(t *testing.T) {
+		walletID := uuid.New()
+		amount, _ := decimal.NewFromString("500")
+		reqBody := map[string]interface{}{
+			"wallet":         walletID,
+			"operation_type": "WITHDRAW",
+			"amount":         amount,
+		}
+		jsonBody, _ := json.Marshal(reqBody)
+
+		expectedReq := domain.OperationRequest{
+			ID:            walletID,
+			OperationType: "WITHDRAW",
+			Amount:        amount,
+		}
+		insufficientErr := &domain.InsufficientFundsError{Available: decimal.NewFromInt(120), Amount: amount}
+		mockService.On("PerformOperation", mock.Anything, expectedReq).Return(nil, insufficientErr).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middleware.RequestIDHeader, "req-42")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Equal(t, "req-42", w.Header().Get(middleware.RequestIDHeader))
+		var respBody map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "INSUFFICIENT_FUNDS", respBody["code"])
+		assert.Equal(t, float64(http.StatusUnprocessableEntity), respBody["status"])
+		assert.Equal(t, "/api/v1/wallet", respBody["instance"])
+		assert.Equal(t, "req-42", respBody["request_id"])
+		assert.Equal(t, "120", respBody["available"])
+		assert.Equal(t, "500", respBody["amount"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Wallet Frozen", func(t *testing.T) {
+		walletID := uuid.New()
+		amount, _ := decimal.NewFromString("500")
+		reqBody := map[string]interface{}{
+			"wallet":         walletID,
+			"operation_type": "WITHDRAW",
+			"amount":         amount,
+		}
+		jsonBody, _ := json.Marshal(reqBody)
+
+		expectedReq := domain.OperationRequest{
+			ID:            walletID,
+			OperationType: "WITHDRAW",
+			Amount:        amount,
+		}
+		mockService.On("PerformOperation", mock.Anything, expectedReq).Return(nil, domain.ErrWalletFrozen).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Limit Exceeded", func(t *testing.T) {
+		walletID := uuid.New()
+		amount, _ := decimal.NewFromString("300")
+		reqBody := map[string]interface{}{
+			"wallet":         walletID,
+			"operation_type": "WITHDRAW",
+			"amount":         amount,
+		}
+		jsonBody, _ := json.Marshal(reqBody)
+
+		expectedReq := domain.OperationRequest{
+			ID:            walletID,
+			OperationType: "WITHDRAW",
+			Amount:        amount,
+		}
+		limitErr := &domain.LimitExceededError{
+			Limit:     domain.LimitDailyWithdrawal,
+			Max:       decimal.NewFromInt(1000),
+			Remaining: decimal.NewFromInt(200),
+		}
+		mockService.On("PerformOperation", mock.Anything, expectedReq).Return(nil, limitErr).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		var respBody map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "LIMIT_EXCEEDED", respBody["code"])
+		assert.Equal(t, "daily_withdrawal", respBody["limit"])
+		assert.Equal(t, "1000", respBody["max"])
+		assert.Equal(t, "200", respBody["remaining"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Bad Request - Invalid JSON", func(t *testing.T) {
+		invalidJson := []byte(`{"wallet": "not-a-uuid"`)
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/wallet", bytes.NewBuffer(invalidJson))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_SetCreditLimit(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		walletID := uuid.New()
+		creditLimit := decimal.NewFromInt(1000)
+		updatedWallet := &domain.Wallet{ID: walletID, Balance: decimal.NewFromInt(-200), CreditLimit: creditLimit, Tier: domain.TierBasic, Status: domain.WalletActive}
+
+		mockService.On("SetCreditLimit", mock.Anything, walletID, creditLimit).Return(updatedWallet, nil).Once()
+
+		jsonBody, _ := json.Marshal(map[string]interface{}{"credit_limit": creditLimit})
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+walletID.String()+"/credit-limit", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "1000", respBody["credit_limit"])
+		assert.Equal(t, "800", respBody["available"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Credit Limit Too Low", func(t *testing.T) {
+		walletID := uuid.New()
+		creditLimit := decimal.NewFromInt(10)
+
+		mockService.On("SetCreditLimit", mock.Anything, walletID, creditLimit).Return(nil, domain.ErrCreditLimitTooLow).Once()
+
+		jsonBody, _ := json.Marshal(map[string]interface{}{"credit_limit": creditLimit})
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+walletID.String()+"/credit-limit", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestHandler_SetWalletLimits(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		walletID := uuid.New()
+		limits := domain.SpendingLimits{DailyWithdrawal: decimal.NullDecimal{Decimal: decimal.NewFromInt(1000), Valid: true}}
+
+		mockService.On("SetWalletLimits", mock.Anything, walletID, limits).Return(limits, nil).Once()
+
+		jsonBody := []byte(`{"daily_withdrawal": "1000", "monthly_withdrawal": null}`)
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+walletID.String()+"/limits", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "1000", respBody["daily_withdrawal"])
+		assert.Nil(t, respBody["monthly_withdrawal"])
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestHandler_UpgradeTier(t *testing.T) {
+	router, mockService := setupTest(t)
+
+	t.Run("Success", func(t *testing.T) {
+		walletID := uuid.New()
+		upgraded := &domain.Wallet{ID: walletID, Balance: decimal.Zero, Tier: domain.TierVerified, Status: domain.WalletActive}
+
+		mockService.On("UpgradeTier", mock.Anything, walletID, domain.TierVerified, "support", "passport checked").Return(upgraded, nil).Once()
+
+		jsonBody := []byte(`{"tier": "VERIFIED", "reason": "passport checked"}`)
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+walletID.String()+"/tier", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody map[string]string
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "VERIFIED", respBody["tier"])
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Not An Upgrade", func(t *testing.T) {
+		walletID := uuid.New()
+		mockService.On("UpgradeTier", mock.Anything, walletID, domain.TierAnonymous, "support", "mistake").Return(nil, domain.ErrTierNotUpgrade).Once()
+
+		jsonBody := []byte(`{"tier": "ANONYMOUS", "reason": "mistake"}`)
+		req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+walletID.String()+"/tier", bytes.NewBuffer(jsonBody))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		for _, authorization := range []string{"", "Bearer wrong-token", adminToken} {
+			jsonBody := []byte(`{"tier": "VERIFIED", "reason": "passport checked"}`)
+			req, _ := http.NewRequest(http.MethodPut, "/api/v1/admin/wallets/"+uuid.NewString()+"/tier", bytes.NewBuffer(jsonBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", authorization)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+			assert.Contains(t, w.Body.String(), `"code":"UNAUTHORIZED"`)
+			assert.Equal(t, `Bearer realm="admin"`, w.Header().Get("WWW-Authenticate"))
+		}
+	})
+}
+
+func TestHandler_LogLevels(t *testing.T) {
+	router, _ := setupTest(t)
+
+	do := func(method string, path string, body string) (int, map[string]any) {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var respBody map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		return w.Code, respBody
+	}
+
+	t.Run("Set Named Logger Level", func(t *testing.T) {
+		code, respBody := do(http.MethodPut, "/api/v1/admin/log-level", `{"logger": "WalletService", "level": "debug"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "info", respBody["level"])
+		assert.Equal(t, map[string]any{"WalletService": "debug"}, respBody["loggers"])
+
+		code, respBody = do(http.MethodGet, "/api/v1/admin/log-level", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]any{"WalletService": "debug"}, respBody["loggers"])
+	})
+
+	t.Run("Set Root Level", func(t *testing.T) {
+		code, respBody := do(http.MethodPut, "/api/v1/admin/log-level", `{"level": "warn"}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "warn", respBody["level"])
+	})
+
+	t.Run("Reset Named Logger Level", func(t *testing.T) {
+		code, respBody := do(http.MethodDelete, "/api/v1/admin/log-level/WalletService", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, map[string]any{}, respBody["loggers"])
+	})
+
+	t.Run("Invalid Level", func(t *testing.T) {
+		code, _ := do(http.MethodPut, "/api/v1/admin/log-level", `{"level": "verbose"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+}
+
+func TestHandler_Batch(t *testing.T) {
+	router, mockService := setupTest(t)
+	walletID := uuid.New()
+	amount := decimal.NewFromInt(100)
+	jsonBody := func(mode string) []byte {
+		body, _ := json.Marshal(map[string]interface{}{
+			"mode": mode,
+			"operations": []map[string]interface{}{
+				{"wallet": walletID, "operation_type": "DEPOSIT", "amount": amount},
+				{"wallet": walletID, "operation_type": "WITHDRAW", "amount": amount},
+			},
+		})
+		return body
+	}
+	expectedReqs := []domain.OperationRequest{
+		{ID: walletID, OperationType: domain.Deposit, Amount: amount},
+		{ID: walletID, OperationType: domain.Withdraw, Amount: amount},
+	}
+
+	t.Run("Independent - Per Item Results", func(t *testing.T) {
+		results := []domain.BatchItemResult{
+			{Index: 0, Result: &domain.OperationResult{ID: uuid.New(), WalletID: walletID, OperationType: domain.Deposit, Gross: amount, Net: amount, Balance: amount}},
+			{Index: 1, Err: domain.ErrInsufficientFunds},
+		}
+		mockService.On("PerformBatch", mock.Anything, domain.BatchIndependent, expectedReqs).Return(results, nil).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/operations/batch", bytes.NewBuffer(jsonBody("independent")))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var respBody struct {
+			Results []struct {
+				Index  int                    `json:"index"`
+				Status string                 `json:"status"`
+				Error  map[string]interface{} `json:"error"`
+			} `json:"results"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		if assert.Len(t, respBody.Results, 2) {
+			assert.Equal(t, "ok", respBody.Results[0].Status)
+			assert.Equal(t, "error", respBody.Results[1].Status)
+			assert.Equal(t, "INSUFFICIENT_FUNDS", respBody.Results[1].Error["code"])
+		}
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("Atomic - Rolled Back", func(t *testing.T) {
+		batchErr := &domain.BatchItemError{Index: 1, Err: domain.ErrInsufficientFunds}
+		mockService.On("PerformBatch", mock.Anything, domain.BatchAtomic, expectedReqs).Return(nil, batchErr).Once()
+
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/operations/batch", bytes.NewBuffer(jsonBody("atomic")))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		var respBody map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, float64(1), respBody["index"])
+		assert.Equal(t, "BATCH_ROLLED_BACK", respBody["code"])
+		assert.Equal(t, "INSUFFICIENT_FUNDS", respBody["cause"].(map[string]interface{})["code"])
+		mockService.AssertExpectations(t)
+	})
+}
+
+func TestHandler_Problem(t *testing.T) {
+	router, _ := setupTest(t)
+
+	t.Run("Invalid Wallet ID", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/wallets/not-a-uuid", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		var respBody map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "INVALID_WALLET_ID", respBody["code"])
+		assert.Equal(t, "Bad Request", respBody["title"])
+		assert.NotEmpty(t, respBody["request_id"])
+		assert.Equal(t, w.Header().Get(middleware.RequestIDHeader), respBody["request_id"])
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/api/v1/panic", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		var respBody map[string]interface{}
+		_ = json.Unmarshal(w.Body.Bytes(), &respBody)
+		assert.Equal(t, "INTERNAL_ERROR", respBody["code"])
+		assert.Equal(t, "internal server error", respBody["detail"])
+	})
+}
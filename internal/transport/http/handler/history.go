@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+
+	"testtask/internal/statement"
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dateLayout = "2006-01-02"
+	// defaultTimezone - часовой пояс ряда балансов, если он не указан в запросе
+	defaultTimezone = "UTC"
+)
+
+// GetBalanceAt возвращает баланс кошелька на момент ?at=<RFC3339>
+func (h *Handler) GetBalanceAt(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+
+	at, err := time.Parse(time.RFC3339, c.Query("at"))
+	if err != nil {
+		log.Warn("Failed to parse at", zap.String("at", c.Query("at")), zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "at must be an RFC3339 timestamp")
+		return
+	}
+
+	wallet, balance, err := h.walletService.BalanceAt(c.Request.Context(), walletID, at)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to get balance history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.BalanceAtResponseDTO{
+		WalletID: wallet.ID,
+		At:       at,
+		Balance:  balance,
+		Currency: wallet.Currency,
+	})
+}
+
+// GetBalanceSeries возвращает балансы кошелька на конец каждого дня ?from=..&to=.. в часовом поясе ?tz=
+func (h *Handler) GetBalanceSeries(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+	from, to, loc, ok := parseDateRange(c, log)
+	if !ok {
+		return
+	}
+
+	points, err := h.walletService.BalanceSeries(c.Request.Context(), walletID, from, to, loc)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to get balance history", err)
+		return
+	}
+
+	resp := dto.BalanceSeriesResponseDTO{
+		WalletID: walletID,
+		Timezone: loc.String(),
+		Points:   make([]dto.BalancePointDTO, 0, len(points)),
+	}
+	for _, p := range points {
+		resp.Points = append(resp.Points, dto.BalancePointDTO{Date: p.Date.Format(dateLayout), Balance: p.Balance})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetStatement выгружает выписку по кошельку за дни ?from=..&to=.. в формате ?format=csv|jsonl|ofx.
+// Выписка передается потоком по мере чтения проводок
+func (h *Handler) GetStatement(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	walletID, ok := parseWalletID(c, log)
+	if !ok {
+		return
+	}
+	from, to, loc, ok := parseDateRange(c, log)
+	if !ok {
+		return
+	}
+
+	format := statement.Format(c.DefaultQuery("format", string(statement.FormatCSV)))
+	locale, ok := statement.LookupLocale(c.Query("locale"))
+	if !ok {
+		log.Warn("Unknown locale", zap.String("locale", c.Query("locale")))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "unknown locale")
+		return
+	}
+	writer, err := statement.NewWriter(format, c.Writer, statement.Options{Locale: locale, Location: loc})
+	if err != nil {
+		log.Warn("Unknown statement format", zap.String("format", string(format)))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="statement-%s-%s-%s.%s"`,
+		walletID, from.Format(dateLayout), to.Format(dateLayout), format))
+
+	end := to.AddDate(0, 0, 1)
+	if err := h.walletService.Statement(c.Request.Context(), walletID, from, end, writer); err != nil {
+		if c.Writer.Written() {
+			// Заголовки уже отправлены, остается только оборвать выписку
+			log.Error("Statement interrupted", zap.String("wallet_id", walletID.String()), zap.Error(err))
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		problem.AbortWithError(c, log.With(zap.String("wallet_id", walletID.String())), "Failed to get balance history", err)
+	}
+}
+
+// parseDateRange читает период ?from=YYYY-MM-DD&to=YYYY-MM-DD в часовом поясе ?tz=.
+// Local означает пояс сервера, который клиенту неизвестен, поэтому принимаются только имена IANA и UTC
+func parseDateRange(c *gin.Context, log *zap.Logger) (time.Time, time.Time, *time.Location, bool) {
+	tz := c.DefaultQuery("tz", defaultTimezone)
+	loc, err := time.LoadLocation(tz)
+	if err == nil && tz == "Local" {
+		err = errors.New("server local timezone is not allowed")
+	}
+	if err != nil {
+		log.Warn("Unknown timezone", zap.String("tz", tz), zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "unknown timezone")
+		return time.Time{}, time.Time{}, nil, false
+	}
+	from, errFrom := time.ParseInLocation(dateLayout, c.Query("from"), loc)
+	to, errTo := time.ParseInLocation(dateLayout, c.Query("to"), loc)
+	if errFrom != nil || errTo != nil {
+		log.Warn("Failed to parse date range", zap.String("from", c.Query("from")), zap.String("to", c.Query("to")))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "from and to must be dates in YYYY-MM-DD format")
+		return time.Time{}, time.Time{}, nil, false
+	}
+	return from, to, loc, true
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+
+	"testtask/internal/domain"
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// importFileField - поле multipart-формы с CSV-файлом
+const importFileField = "file"
+
+// CreateImport загружает CSV-файл операций; строки применяет фоновый обработчик импортов.
+// Файл принимается как поле file multipart-формы или как тело запроса с Content-Type text/csv
+func (h *Handler) CreateImport(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+
+	var (
+		file     io.Reader
+		fileName string
+	)
+	if c.ContentType() == "text/csv" {
+		file, fileName = c.Request.Body, c.DefaultQuery("name", "upload.csv")
+	} else {
+		header, err := c.FormFile(importFileField)
+		if err != nil {
+			log.Warn("Import file is missing", zap.Error(err))
+			problem.AbortInvalid(c, problem.CodeInvalidImportFile, "csv file is required")
+			return
+		}
+		f, err := header.Open()
+		if err != nil {
+			problem.AbortWithError(c, log, "Failed to open uploaded file", err)
+			return
+		}
+		defer f.Close()
+		file, fileName = f, header.Filename
+	}
+
+	imp, err := h.walletService.StartImport(c.Request.Context(), fileName, file)
+	if err != nil {
+		problem.AbortWithError(c, log, "Failed to start import", err)
+		return
+	}
+
+	log.Info("Import staged", zap.String("import_id", imp.ID.String()), zap.Int("rows", imp.TotalRows))
+	c.Header("Location", "/api/v1/imports/"+imp.ID.String())
+	c.JSON(http.StatusAccepted, newImportResponse(imp))
+}
+
+func (h *Handler) GetImport(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	importID, ok := parseImportID(c, log)
+	if !ok {
+		return
+	}
+
+	imp, err := h.walletService.GetImport(c.Request.Context(), importID)
+	if err != nil {
+		problem.AbortWithError(c, log.With(zap.String("import_id", importID.String())), "Failed to get import", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newImportResponse(imp))
+}
+
+// GetImportErrors выгружает CSV со строками импорта, которые не удалось применить, и причинами отказа
+func (h *Handler) GetImportErrors(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	importID, ok := parseImportID(c, log)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%s-errors.csv"`, importID))
+	// csv.Writer буферизует вывод, поэтому до первого сброса буфера ошибку еще можно вернуть статусом
+	w := csv.NewWriter(c.Writer)
+	err := w.Write([]string{"line", "error", "wallet", "operation_type", "amount", "target_wallet", "occurred_at"})
+	if err == nil {
+		err = h.walletService.ImportErrors(c.Request.Context(), importID, func(row domain.ImportRow) error {
+			return w.Write([]string{
+				strconv.Itoa(row.Line), row.Error, row.WalletID, row.OperationType, row.Amount, row.TargetWalletID, row.OccurredAt,
+			})
+		})
+	}
+	if err == nil {
+		w.Flush()
+		err = w.Error()
+	}
+	if err != nil {
+		if c.Writer.Written() {
+			log.Error("Import error report interrupted", zap.String("import_id", importID.String()), zap.Error(err))
+			c.Abort()
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		problem.AbortWithError(c, log.With(zap.String("import_id", importID.String())), "Failed to get import", err)
+	}
+}
+
+func parseImportID(c *gin.Context, log *zap.Logger) (uuid.UUID, bool) {
+	importID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		log.Warn("Failed to parse import ID", zap.String("id", c.Param("id")), zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "import ID is not a valid UUID")
+		return uuid.Nil, false
+	}
+	return importID, true
+}
+
+func newImportResponse(imp *domain.Import) dto.ImportResponseDTO {
+	return dto.ImportResponseDTO{
+		ID:          imp.ID,
+		FileName:    imp.FileName,
+		Status:      string(imp.Status),
+		TotalRows:   imp.TotalRows,
+		AppliedRows: imp.AppliedRows,
+		FailedRows:  imp.FailedRows,
+		Error:       imp.Error,
+		CreatedAt:   imp.CreatedAt,
+		FinishedAt:  imp.FinishedAt,
+	}
+}
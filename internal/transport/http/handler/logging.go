@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"net/http"
+
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevels - уровни логирования, изменяемые во время работы. Пустое имя означает общий уровень
+type LogLevels interface {
+	Levels() map[string]zapcore.Level
+	SetLevel(name string, level zapcore.Level)
+	ResetLevel(name string)
+}
+
+type Option func(h *Handler)
+
+// WithLogLevels включает административное управление уровнями логирования
+func WithLogLevels(levels LogLevels) Option {
+	return func(h *Handler) {
+		h.logLevels = levels
+	}
+}
+
+func (h *Handler) GetLogLevels(c *gin.Context) {
+	if !h.logLevelsEnabled(c) {
+		return
+	}
+	c.JSON(http.StatusOK, newLogLevelsResponse(h.logLevels.Levels()))
+}
+
+// SetLogLevel меняет общий уровень логирования или уровень логгера, например WalletService или repository
+func (h *Handler) SetLogLevel(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	if !h.logLevelsEnabled(c) {
+		return
+	}
+
+	var req dto.SetLogLevelRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Warn("Failed to decode request body", zap.Error(err))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid request body")
+		return
+	}
+	level, err := zapcore.ParseLevel(req.Level)
+	if err != nil {
+		log.Warn("Invalid log level", zap.String("level", req.Level))
+		problem.AbortInvalid(c, problem.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	h.logLevels.SetLevel(req.Logger, level)
+	log.Info("Log level changed", zap.String("name", req.Logger), zap.Stringer("level", level),
+		zap.String("actor", principal(c)))
+	c.JSON(http.StatusOK, newLogLevelsResponse(h.logLevels.Levels()))
+}
+
+// ResetLogLevel возвращает логгеру общий уровень логирования
+func (h *Handler) ResetLogLevel(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	if !h.logLevelsEnabled(c) {
+		return
+	}
+
+	name := c.Param("logger")
+	h.logLevels.ResetLevel(name)
+	log.Info("Log level reset", zap.String("name", name), zap.String("actor", principal(c)))
+	c.JSON(http.StatusOK, newLogLevelsResponse(h.logLevels.Levels()))
+}
+
+func (h *Handler) logLevelsEnabled(c *gin.Context) bool {
+	if h.logLevels == nil {
+		problem.Abort(c, problem.New(c, http.StatusNotFound, problem.CodeNotFound, "log level control is disabled"))
+		return false
+	}
+	return true
+}
+
+func newLogLevelsResponse(levels map[string]zapcore.Level) dto.LogLevelsResponseDTO {
+	resp := dto.LogLevelsResponseDTO{Level: levels[""].String(), Loggers: make(map[string]string, len(levels)-1)}
+	for name, level := range levels {
+		if name != "" {
+			resp.Loggers[name] = level.String()
+		}
+	}
+	return resp
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"testtask/internal/domain"
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// defaultWalletSort - порядок списка кошельков, если sort не указан: сначала новые
+const defaultWalletSort = "-created_at"
+
+// ListWallets возвращает страницу списка кошельков по фильтрам из параметров запроса
+func (h *Handler) ListWallets(c *gin.Context) {
+	log := c.MustGet("logger").(*zap.Logger)
+	query, err := parseWalletListQuery(c)
+	if err != nil {
+		problem.AbortWithError(c, log, "Invalid wallet list parameters", err)
+		return
+	}
+
+	page, err := h.walletService.ListWallets(c.Request.Context(), query)
+	if err != nil {
+		problem.AbortWithError(c, log, "Failed to list wallets", err)
+		return
+	}
+
+	resp := dto.WalletListResponseDTO{
+		Wallets: make([]dto.WalletBalanceResponseDTO, 0, len(page.Wallets)),
+		Total:   page.Total,
+	}
+	for i := range page.Wallets {
+		resp.Wallets = append(resp.Wallets, newWalletBalanceResponse(&page.Wallets[i]))
+	}
+	if page.Next != nil {
+		resp.NextCursor = page.Next.Encode()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseWalletListQuery читает фильтры, сортировку и курсор. Ошибки оборачивают domain.ErrInvalidWalletQuery
+func parseWalletListQuery(c *gin.Context) (domain.WalletListQuery, error) {
+	query := domain.WalletListQuery{
+		Filter: domain.WalletFilter{
+			Status:     domain.WalletStatus(c.Query("status")),
+			ClientID:   c.Query("client_id"),
+			ExternalID: c.Query("external_id"),
+			OwnerID:    c.Query("owner_id"),
+			Currency:   c.Query("currency"),
+		},
+	}
+	filter := &query.Filter
+
+	var err error
+	if filter.MinBalance, err = decimalParam(c, "balance_min"); err != nil {
+		return query, err
+	}
+	if filter.MaxBalance, err = decimalParam(c, "balance_max"); err != nil {
+		return query, err
+	}
+	if filter.CreatedFrom, err = timeParam(c, "created_from"); err != nil {
+		return query, err
+	}
+	if filter.CreatedTo, err = timeParam(c, "created_to"); err != nil {
+		return query, err
+	}
+	if keys := c.Query("metadata_key"); keys != "" {
+		filter.MetadataKeys = strings.Split(keys, ",")
+	}
+	if metadata := c.Query("metadata"); metadata != "" {
+		if err := json.Unmarshal([]byte(metadata), &filter.Metadata); err != nil {
+			return query, fmt.Errorf("%w: metadata must be a JSON object", domain.ErrInvalidWalletQuery)
+		}
+	}
+
+	sort := c.DefaultQuery("sort", defaultWalletSort)
+	query.Desc = strings.HasPrefix(sort, "-")
+	query.Sort = domain.WalletSort(strings.TrimPrefix(sort, "-"))
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		if query.After, err = domain.DecodeWalletCursor(cursor); err != nil {
+			return query, err
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			return query, fmt.Errorf("%w: limit must be a positive integer", domain.ErrInvalidWalletQuery)
+		}
+	}
+	query.WithTotal = c.Query("include_total") == "true"
+	return query, nil
+}
+
+func decimalParam(c *gin.Context, name string) (*decimal.Decimal, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+	d, err := decimal.NewFromString(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a decimal number", domain.ErrInvalidWalletQuery, name)
+	}
+	return &d, nil
+}
+
+func timeParam(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be an RFC3339 timestamp", domain.ErrInvalidWalletQuery, name)
+	}
+	return t, nil
+}
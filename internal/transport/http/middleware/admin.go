@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PrincipalKey - ключ контекста gin с именем аутентифицированного администратора
+const PrincipalKey = "principal"
+
+// AdminAuth пропускает запросы с заголовком "Authorization: Bearer <токен>", где токен есть в principals
+// (токен -> имя администратора), и кладет имя в контекст по ключу PrincipalKey.
+// Если principals пуст, административные методы недоступны
+func AdminAuth(principals map[string]string) gin.HandlerFunc {
+	// Токены сравниваются по хэшам за постоянное время, чтобы время ответа не выдавало совпадающий префикс
+	hashes := make(map[[sha256.Size]byte]string, len(principals))
+	for token, name := range principals {
+		hashes[sha256.Sum256([]byte(token))] = name
+	}
+
+	return func(c *gin.Context) {
+		log := c.MustGet("logger").(*zap.Logger)
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			abortUnauthorized(c, "missing bearer token")
+			return
+		}
+
+		sum := sha256.Sum256([]byte(token))
+		principal := ""
+		for hash, name := range hashes {
+			if subtle.ConstantTimeCompare(hash[:], sum[:]) == 1 {
+				principal = name
+			}
+		}
+		if principal == "" {
+			log.Warn("Invalid admin token")
+			abortUnauthorized(c, "invalid bearer token")
+			return
+		}
+
+		c.Set(PrincipalKey, principal)
+		c.Set("logger", log.With(zap.String("principal", principal)))
+		c.Next()
+	}
+}
+
+func abortUnauthorized(c *gin.Context, detail string) {
+	c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+	problem.Abort(c, problem.New(c, http.StatusUnauthorized, problem.CodeUnauthorized, detail))
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+
+	"testtask/internal/domain"
+	"testtask/internal/transport/http/problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader - заголовок, которым клиент помечает повторы одного запроса
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader отмечает ответ, возвращенный из сохраненных, а не полученный выполнением запроса
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency передает сервису ключ из заголовка Idempotency-Key вместе с отпечатком запроса.
+// Сервис занимает ключ и сохраняет результат в транзакции самой операции, поэтому повтор с тем же ключом
+// получает сохраненный результат и после перезапуска, и на другом экземпляре сервиса
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.AbortInvalid(c, problem.CodeInvalidRequest, IdempotencyKeyHeader+" header is too long")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			problem.AbortInvalid(c, problem.CodeInvalidRequest, "failed to read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		idempotencyKey := &domain.IdempotencyKey{
+			Key:         key,
+			Fingerprint: sha256.Sum256(append([]byte(c.Request.Method+" "+c.Request.URL.RequestURI()+"\n"), body...)),
+		}
+		c.Request = c.Request.WithContext(domain.ContextWithIdempotencyKey(c.Request.Context(), idempotencyKey))
+		c.Writer = &replayMarker{ResponseWriter: c.Writer, key: idempotencyKey}
+		c.Next()
+	}
+}
+
+// replayMarker добавляет к ответу заголовок Idempotent-Replayed, если сервис вернул сохраненный результат
+type replayMarker struct {
+	gin.ResponseWriter
+	key *domain.IdempotencyKey
+}
+
+func (w *replayMarker) WriteHeader(code int) {
+	if w.key.Replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
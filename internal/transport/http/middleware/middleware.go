@@ -1,17 +1,51 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"go.uber.org/zap"
+	"net/http"
+
+	"testtask/internal/domain"
+	"testtask/internal/transport/http/dto"
+	"testtask/internal/transport/http/openapi"
+	"testtask/internal/transport/http/problem"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 )
 
+// MinLSNHeader - заголовок, которым клиент передает позицию журнала из ответа на операцию,
+// чтобы чтение с реплики увидело эту операцию
+const MinLSNHeader = "X-Min-LSN"
+
+// RequestIDHeader - заголовок с идентификатором запроса; он же возвращается в ответах с ошибками
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает идентификатор, переданный клиентом, чтобы он не раздувал логи
+const maxRequestIDLength = 128
+
+// RequestID берет идентификатор запроса из заголовка X-Request-ID или создает новый
+// и возвращает его в одноименном заголовке ответа
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+		c.Set("request_id", id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
 func LoggingMiddleware(log *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestLog := log.With(
 			zap.String("method", c.Request.Method),
-			zap.String("path", c.Request.URL.Path),
+			zap.String("path", c.FullPath()),
 			zap.String("remote_addr", c.Request.RemoteAddr),
+			zap.String("request_id", c.GetString("request_id")),
 		)
 
 		requestLog.Info("Request started")
@@ -21,3 +55,71 @@ func LoggingMiddleware(log *zap.Logger) gin.HandlerFunc {
 		requestLog.Info("Request completed")
 	}
 }
+
+// ReadYourWrites передает в контекст запроса позицию журнала из заголовка X-Min-LSN
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lsn := c.GetHeader(MinLSNHeader)
+		if lsn == "" {
+			c.Next()
+			return
+		}
+		if !domain.ValidLSN(lsn) {
+			log := c.MustGet("logger").(*zap.Logger)
+			log.Warn("Invalid min LSN header", zap.String("lsn", lsn))
+			problem.AbortInvalid(c, problem.CodeInvalidRequest, "invalid "+MinLSNHeader+" header")
+			return
+		}
+
+		c.Request = c.Request.WithContext(domain.ContextWithMinLSN(c.Request.Context(), lsn))
+		c.Next()
+	}
+}
+
+// ValidateRequest отклоняет запросы, не соответствующие спецификации API: неизвестные поля тела,
+// неверные типы и форматы значений, пропущенные обязательные параметры
+func ValidateRequest(spec *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := spec.ValidateRequest(c.Request, c.FullPath())
+		if err == nil {
+			c.Next()
+			return
+		}
+
+		log := c.MustGet("logger").(*zap.Logger)
+		var validationErr *openapi.ValidationError
+		if !errors.As(err, &validationErr) {
+			problem.AbortWithError(c, log, "Failed to validate request", err)
+			return
+		}
+		log.Warn("Request does not match the API specification", zap.Error(err))
+		p := problem.New(c, http.StatusBadRequest, problem.CodeInvalidRequest, "request does not match the API specification")
+		for _, fe := range validationErr.Errors {
+			p.Errors = append(p.Errors, dto.ProblemFieldErrorDTO{Field: fe.Field, Detail: fe.Message})
+		}
+		problem.Abort(c, p)
+	}
+}
+
+// Recovery отвечает на панику обработчика ошибкой 500 в формате problem+json, не раскрывая ее причину клиенту
+func Recovery(log *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			log.Error("Handler panicked", zap.String("path", c.FullPath()),
+				zap.String("request_id", c.GetString("request_id")), zap.Error(fmt.Errorf("%v", recovered)), zap.Stack("stack"))
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			problem.Abort(c, problem.New(c, http.StatusInternalServerError, problem.CodeInternal, "internal server error"))
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,230 @@
+// Package openapi содержит спецификацию HTTP API и проверяет по ней запросы и ответы.
+// Поддерживается подмножество JSON Schema, которое используется в спецификации:
+// type, format, pattern, enum, minLength, maxLength, properties, required, additionalProperties, items и $ref
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+const schemaRefPrefix = "#/components/schemas/"
+
+// Document - разобранная спецификация
+type Document struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type Operation struct {
+	OperationID string              `json:"operationId"`
+	Parameters  []Parameter         `json:"parameters"`
+	RequestBody *RequestBody        `json:"requestBody"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 Types              `json:"type"`
+	Format               string             `json:"format"`
+	Pattern              string             `json:"pattern"`
+	Enum                 []any              `json:"enum"`
+	MinLength            int                `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Additional        `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+
+	pattern *regexp.Regexp
+}
+
+// Types - допустимые типы значения; в OpenAPI 3.1 type может быть строкой или списком
+type Types []string
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+// Additional - значение additionalProperties: false запрещает поля вне properties, схема задает их тип
+type Additional struct {
+	Allowed bool
+	Schema  *Schema
+}
+
+func (a *Additional) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &a.Allowed); err == nil {
+		return nil
+	}
+	a.Allowed = true
+	return json.Unmarshal(data, &a.Schema)
+}
+
+// JSON возвращает спецификацию в исходном виде
+func JSON() []byte {
+	return spec
+}
+
+// Load разбирает встроенную спецификацию и проверяет ссылки и шаблоны ее схем
+func Load() (*Document, error) {
+	var d Document
+	if err := json.Unmarshal(spec, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi spec: %w", err)
+	}
+	for _, s := range d.Components.Schemas {
+		if err := d.prepare(s); err != nil {
+			return nil, err
+		}
+	}
+	for path, item := range d.Paths {
+		for method, op := range item {
+			for _, p := range op.Parameters {
+				if err := d.prepare(p.Schema); err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, path, err)
+				}
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					if err := d.prepare(media.Schema); err != nil {
+						return nil, fmt.Errorf("%s %s: %w", method, path, err)
+					}
+				}
+			}
+			for _, resp := range op.Responses {
+				for _, media := range resp.Content {
+					if err := d.prepare(media.Schema); err != nil {
+						return nil, fmt.Errorf("%s %s: %w", method, path, err)
+					}
+				}
+			}
+		}
+	}
+	return &d, nil
+}
+
+// MustLoad - Load, завершающийся паникой: встроенная спецификация проверяется тестами
+func MustLoad() *Document {
+	d, err := Load()
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// prepare компилирует шаблоны схемы и проверяет, что ее ссылки ведут на существующие схемы
+func (d *Document) prepare(s *Schema) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		if d.resolve(s) == nil {
+			return fmt.Errorf("unresolved schema reference %q", s.Ref)
+		}
+		return nil
+	}
+	if s.Pattern != "" && s.pattern == nil {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	for _, prop := range s.Properties {
+		if err := d.prepare(prop); err != nil {
+			return err
+		}
+	}
+	if s.AdditionalProperties != nil {
+		if err := d.prepare(s.AdditionalProperties.Schema); err != nil {
+			return err
+		}
+	}
+	return d.prepare(s.Items)
+}
+
+func (d *Document) resolve(s *Schema) *Schema {
+	if s.Ref == "" {
+		return s
+	}
+	return d.Components.Schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)]
+}
+
+// routeParam - параметр пути gin (:id) или (*path)
+var routeParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+// Operation возвращает описание операции по методу и шаблону пути gin, например /api/v1/wallets/:id
+func (d *Document) Operation(method, route string) *Operation {
+	item := d.Paths[routeParam.ReplaceAllString(route, "{$1}")]
+	if item == nil {
+		return nil
+	}
+	return item[strings.ToLower(method)]
+}
+
+// ServeSpec отдает спецификацию
+func ServeSpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", spec)
+}
+
+// ServeUI отдает страницу Swagger UI для спецификации
+func ServeUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(uiPage))
+}
+
+const uiPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Wallet API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
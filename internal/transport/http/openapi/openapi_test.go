@@ -0,0 +1,148 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRequest(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+
+	const walletID = "a1b2c3d4-e5f6-7890-1234-567890abcdef"
+	tests := []struct {
+		name        string
+		method      string
+		route       string
+		target      string
+		contentType string
+		body        string
+		wantFields  []string
+	}{
+		{
+			name:   "Valid Operation",
+			method: http.MethodPost, route: "/api/v1/wallet", target: "/api/v1/wallet",
+			contentType: "application/json",
+			body:        `{"wallet": "` + walletID + `", "operation_type": "DEPOSIT", "amount": "100.50"}`,
+		},
+		{
+			name:   "Numeric Amount",
+			method: http.MethodPost, route: "/api/v1/wallet", target: "/api/v1/wallet",
+			body: `{"wallet": "` + walletID + `", "operation_type": "WITHDRAW", "amount": 100}`,
+		},
+		{
+			name:   "Unknown Field",
+			method: http.MethodPost, route: "/api/v1/wallet", target: "/api/v1/wallet",
+			contentType: "application/json",
+			body:        `{"walletId": "` + walletID + `", "wallet": "` + walletID + `", "operation_type": "DEPOSIT", "amount": "1"}`,
+			wantFields:  []string{"body.walletId"},
+		},
+		{
+			name:   "Missing And Invalid Fields",
+			method: http.MethodPost, route: "/api/v1/wallet", target: "/api/v1/wallet",
+			contentType: "application/json",
+			body:        `{"wallet": "not-a-uuid", "operation_type": "REFUND"}`,
+			wantFields:  []string{"body.amount", "body.operation_type", "body.wallet"},
+		},
+		{
+			name:   "Nested Batch Operation",
+			method: http.MethodPost, route: "/api/v1/operations/batch", target: "/api/v1/operations/batch",
+			contentType: "application/json",
+			body:        `{"mode": "atomic", "operations": [{"wallet": "` + walletID + `", "operation_type": "DEPOSIT", "amount": "1,5"}]}`,
+			wantFields:  []string{"body.operations[0].amount"},
+		},
+		{
+			name:   "Empty Body",
+			method: http.MethodPut, route: "/api/v1/admin/wallets/:id/credit-limit", target: "/api/v1/admin/wallets/" + walletID + "/credit-limit",
+			contentType: "application/json",
+			wantFields:  []string{"body"},
+		},
+		{
+			name:   "Null Limit",
+			method: http.MethodPut, route: "/api/v1/admin/wallets/:id/limits", target: "/api/v1/admin/wallets/" + walletID + "/limits",
+			contentType: "application/json",
+			body:        `{"daily_withdrawal": "1000", "single_withdrawal": null}`,
+		},
+		{
+			name:   "Invalid Path Parameter",
+			method: http.MethodGet, route: "/api/v1/wallets/:id", target: "/api/v1/wallets/123",
+			wantFields: []string{"path.id"},
+		},
+		{
+			name:   "Missing And Invalid Query Parameters",
+			method: http.MethodGet, route: "/api/v1/wallets/:id/statement", target: "/api/v1/wallets/" + walletID + "/statement?from=01.03.2024&format=xlsx",
+			wantFields: []string{"query.from", "query.to", "query.format"},
+		},
+		{
+			name:   "CSV Import",
+			method: http.MethodPost, route: "/api/v1/imports", target: "/api/v1/imports?name=legacy.csv",
+			contentType: "text/csv",
+			body:        "wallet,operation_type,amount\n",
+		},
+		{
+			name:   "Unsupported Media Type",
+			method: http.MethodPost, route: "/api/v1/imports", target: "/api/v1/imports",
+			contentType: "application/xml",
+			body:        "<rows/>",
+			wantFields:  []string{"header.Content-Type"},
+		},
+		{
+			name:   "Undocumented Route",
+			method: http.MethodGet, route: "/debug/vars", target: "/debug/vars",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+
+			err := spec.ValidateRequest(req, tt.route)
+
+			if len(tt.wantFields) == 0 {
+				assert.NoError(t, err)
+			} else {
+				var validationErr *ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				fields := make([]string, len(validationErr.Errors))
+				for i, fe := range validationErr.Errors {
+					fields[i] = fe.Field
+				}
+				assert.ElementsMatch(t, tt.wantFields, fields)
+			}
+
+			body, _ := io.ReadAll(req.Body)
+			assert.Equal(t, tt.body, string(body), "body must stay readable for the handler")
+		})
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	spec, err := Load()
+	require.NoError(t, err)
+
+	jsonHeader := http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}
+	problemHeader := http.Header{"Content-Type": []string{"application/problem+json"}}
+
+	assert.NoError(t, spec.ValidateResponse(http.MethodPost, "/api/v1/wallets", http.StatusCreated, jsonHeader,
+		[]byte(`{"id": "a1b2c3d4-e5f6-7890-1234-567890abcdef", "balance": "0", "metadata": {}}`)))
+	assert.NoError(t, spec.ValidateResponse(http.MethodPost, "/api/v1/wallets", http.StatusInternalServerError, problemHeader,
+		[]byte(`{"type": "about:blank", "title": "Internal Server Error", "status": 500, "code": "INTERNAL_ERROR"}`)))
+	assert.NoError(t, spec.ValidateResponse(http.MethodPut, "/api/v1/admin/tiers/:tier/limits", http.StatusNoContent, http.Header{}, nil))
+
+	assert.Error(t, spec.ValidateResponse(http.MethodPost, "/api/v1/wallets", http.StatusCreated, jsonHeader,
+		[]byte(`{"id": "a1b2c3d4-e5f6-7890-1234-567890abcdef", "balance": "0", "metadata": {}, "extra": true}`)), "undocumented field")
+	assert.Error(t, spec.ValidateResponse(http.MethodPost, "/api/v1/wallets", http.StatusCreated, jsonHeader,
+		[]byte(`{"error": "internal server error"}`)), "missing fields")
+	assert.Error(t, spec.ValidateResponse(http.MethodPost, "/api/v1/wallets", http.StatusCreated, http.Header{"Content-Type": []string{"text/plain"}},
+		[]byte(`ok`)), "undocumented content type")
+	assert.Error(t, spec.ValidateResponse(http.MethodDelete, "/api/v1/wallets", http.StatusOK, jsonHeader, nil), "undocumented route")
+}
@@ -0,0 +1,340 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const jsonMediaType = "application/json"
+
+// FieldError - несоответствие одного значения спецификации
+type FieldError struct {
+	// Field - путь к значению: body.operations[0].amount, query.from, path.id
+	Field   string
+	Message string
+}
+
+// ValidationError перечисляет все несоответствия запроса или ответа спецификации
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "does not match the API specification: " + strings.Join(parts, "; ")
+}
+
+type validator struct {
+	d      *Document
+	errors []FieldError
+}
+
+func (v *validator) fail(field, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errors) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errors}
+}
+
+// ValidateRequest проверяет параметры и JSON-тело запроса к маршруту gin route.
+// Тело читается целиком и подменяется копией, чтобы обработчик мог прочитать его снова.
+// Маршруты, которых нет в спецификации, не проверяются
+func (d *Document) ValidateRequest(r *http.Request, route string) error {
+	op := d.Operation(r.Method, route)
+	if op == nil {
+		return nil
+	}
+	v := &validator{d: d}
+
+	path := strings.Split(route, "/")
+	actual := strings.Split(r.URL.Path, "/")
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var (
+			value   string
+			present bool
+		)
+		switch p.In {
+		case "path":
+			for i, segment := range path {
+				if i < len(actual) && len(segment) > 1 && segment[1:] == p.Name && (segment[0] == ':' || segment[0] == '*') {
+					value, present = actual[i], true
+				}
+			}
+		case "query":
+			present = query.Has(p.Name)
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+			present = value != ""
+		}
+		field := p.In + "." + p.Name
+		if !present {
+			if p.Required {
+				v.fail(field, "is required")
+			}
+			continue
+		}
+		v.validate(p.Schema, value, field)
+	}
+
+	if op.RequestBody != nil {
+		if err := v.validateRequestBody(r, op.RequestBody); err != nil {
+			return err
+		}
+	}
+	return v.err()
+}
+
+func (v *validator) validateRequestBody(r *http.Request, spec *RequestBody) error {
+	mediaType := jsonMediaType
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		parsed, _, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			v.fail("header.Content-Type", "invalid media type")
+			return nil
+		}
+		mediaType = parsed
+	}
+	media, ok := spec.Content[mediaType]
+	if !ok {
+		v.fail("header.Content-Type", "unsupported media type %s, expected one of %s", mediaType, strings.Join(mediaTypes(spec.Content), ", "))
+		return nil
+	}
+	if mediaType != jsonMediaType {
+		return nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read request body: %w", err)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if spec.Required {
+			v.fail("body", "is required")
+		}
+		return nil
+	}
+	value, err := decodeJSON(data)
+	if err != nil {
+		v.fail("body", "invalid JSON: %v", err)
+		return nil
+	}
+	v.validate(media.Schema, value, "body")
+	return nil
+}
+
+// ValidateResponse проверяет, что статус и тип ответа описаны в спецификации, а JSON-тело соответствует схеме
+func (d *Document) ValidateResponse(method, route string, status int, header http.Header, body []byte) error {
+	op := d.Operation(method, route)
+	if op == nil {
+		return fmt.Errorf("%s %s is not documented", method, route)
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if resp, ok = op.Responses["default"]; !ok {
+			return fmt.Errorf("%s %s: status %d is not documented", method, route, status)
+		}
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("%s %s: status %d must not have a body", method, route, status)
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid content type %q", method, route, header.Get("Content-Type"))
+	}
+	media, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s %s: content type %s is not documented for status %d", method, route, mediaType, status)
+	}
+	if mediaType != jsonMediaType && !strings.HasSuffix(mediaType, "+json") {
+		return nil
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid JSON: %w", method, route, err)
+	}
+	v := &validator{d: d}
+	v.validate(media.Schema, value, "body")
+	if err := v.err(); err != nil {
+		return fmt.Errorf("%s %s: response %w", method, route, err)
+	}
+	return nil
+}
+
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return value, nil
+}
+
+func (v *validator) validate(s *Schema, value any, field string) {
+	if s == nil {
+		return
+	}
+	if s = v.d.resolve(s); s == nil {
+		v.fail(field, "schema is not defined")
+		return
+	}
+
+	kind := jsonType(value)
+	if len(s.Type) > 0 && !s.allows(kind) {
+		v.fail(field, "expected %s, got %s", strings.Join(s.Type, " or "), kind)
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		v.fail(field, "must be one of %s", formatEnum(s.Enum))
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		v.validateString(s, value, field)
+	case []any:
+		for i, item := range value {
+			v.validate(s.Items, item, fmt.Sprintf("%s[%d]", field, i))
+		}
+	case map[string]any:
+		v.validateObject(s, value, field)
+	}
+}
+
+func (v *validator) validateString(s *Schema, value string, field string) {
+	if len(value) < s.MinLength {
+		v.fail(field, "must not be empty")
+		return
+	}
+	if s.MaxLength != nil && len(value) > *s.MaxLength {
+		v.fail(field, "must be at most %d characters", *s.MaxLength)
+		return
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		v.fail(field, "must match %s", s.Pattern)
+		return
+	}
+
+	var err error
+	switch s.Format {
+	case "uuid":
+		_, err = uuid.Parse(value)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	case "date":
+		_, err = time.Parse(time.DateOnly, value)
+	}
+	if err != nil {
+		v.fail(field, "must be a valid %s", s.Format)
+	}
+}
+
+func (v *validator) validateObject(s *Schema, value map[string]any, field string) {
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			v.fail(field+"."+name, "is required")
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if prop, ok := s.Properties[name]; ok {
+			v.validate(prop, value[name], field+"."+name)
+			continue
+		}
+		switch {
+		case s.AdditionalProperties == nil:
+		case !s.AdditionalProperties.Allowed:
+			v.fail(field+"."+name, "unknown field")
+		default:
+			v.validate(s.AdditionalProperties.Schema, value[name], field+"."+name)
+		}
+	}
+}
+
+func (s *Schema) allows(kind string) bool {
+	for _, t := range s.Type {
+		if t == kind || (t == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatEnum(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, allowed := range enum {
+		parts[i] = fmt.Sprint(allowed)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func mediaTypes(content map[string]MediaType) []string {
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
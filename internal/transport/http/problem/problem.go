@@ -0,0 +1,186 @@
+// Package problem формирует ответы с ошибками в формате RFC 7807 (application/problem+json)
+// со стабильными машинными кодами, по которым клиенты различают ошибки вместо разбора текста
+package problem
+
+import (
+	"errors"
+	"net/http"
+
+	"testtask/internal/domain"
+	"testtask/internal/transport/http/dto"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ContentType - тип ответа с ошибкой
+const ContentType = "application/problem+json"
+
+// Code - стабильный код ошибки. Коды не меняются между версиями API, в отличие от текста detail
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "INVALID_REQUEST"
+	CodeInvalidWalletID      Code = "INVALID_WALLET_ID"
+	CodeInvalidAmount        Code = "INVALID_AMOUNT"
+	CodeUnknownOperationType Code = "UNKNOWN_OPERATION_TYPE"
+	CodeTransferToSameWallet Code = "TRANSFER_TO_SAME_WALLET"
+	CodeCreditLimitNegative  Code = "CREDIT_LIMIT_NEGATIVE"
+	CodeLimitNotPositive     Code = "LIMIT_NOT_POSITIVE"
+	CodeUnknownTier          Code = "UNKNOWN_TIER"
+	CodeInvalidTimeRange     Code = "INVALID_TIME_RANGE"
+	CodeTimeRangeTooLarge    Code = "TIME_RANGE_TOO_LARGE"
+	CodeInvalidImportFile    Code = "INVALID_IMPORT_FILE"
+	CodeBatchEmpty           Code = "BATCH_EMPTY"
+	CodeUnknownBatchMode     Code = "UNKNOWN_BATCH_MODE"
+	CodeBatchInvalid         Code = "BATCH_INVALID"
+	CodeInvalidWalletDetails Code = "INVALID_WALLET_DETAILS"
+	CodeInvalidWalletQuery   Code = "INVALID_WALLET_QUERY"
+
+	// CodeUnauthorized - нет токена администратора или он неверный
+	CodeUnauthorized Code = "UNAUTHORIZED"
+
+	CodeNotFound       Code = "NOT_FOUND"
+	CodeWalletNotFound Code = "WALLET_NOT_FOUND"
+	CodeImportNotFound Code = "IMPORT_NOT_FOUND"
+	CodeTierNotFound   Code = "TIER_NOT_FOUND"
+
+	CodeWalletFrozen     Code = "WALLET_FROZEN"
+	CodeConcurrentUpdate Code = "CONCURRENT_UPDATE"
+
+	CodeBatchTooLarge Code = "BATCH_TOO_LARGE"
+
+	CodeInsufficientFunds Code = "INSUFFICIENT_FUNDS"
+	CodeLimitExceeded     Code = "LIMIT_EXCEEDED"
+	CodeFeeExceedsAmount  Code = "FEE_EXCEEDS_AMOUNT"
+	CodeCurrencyMismatch  Code = "CURRENCY_MISMATCH"
+	CodeCreditLimitTooLow Code = "CREDIT_LIMIT_TOO_LOW"
+	CodeTierNotUpgrade    Code = "TIER_NOT_UPGRADE"
+	CodeBatchRolledBack   Code = "BATCH_ROLLED_BACK"
+	// CodeIdempotencyKeyReused - ключ идемпотентности уже использован для запроса с другим телом
+	CodeIdempotencyKeyReused Code = "IDEMPOTENCY_KEY_REUSED"
+
+	CodeInternal Code = "INTERNAL_ERROR"
+)
+
+// domainErrors сопоставляет ошибки домена с HTTP-статусом и кодом
+var domainErrors = []struct {
+	err    error
+	status int
+	code   Code
+}{
+	{domain.ErrIDIsNil, http.StatusBadRequest, CodeInvalidWalletID},
+	{domain.ErrAmountZeroOrNegative, http.StatusBadRequest, CodeInvalidAmount},
+	{domain.ErrUnknownOperationType, http.StatusBadRequest, CodeUnknownOperationType},
+	{domain.ErrTransferToSameWallet, http.StatusBadRequest, CodeTransferToSameWallet},
+	{domain.ErrCreditLimitNegative, http.StatusBadRequest, CodeCreditLimitNegative},
+	{domain.ErrLimitNotPositive, http.StatusBadRequest, CodeLimitNotPositive},
+	{domain.ErrUnknownTier, http.StatusBadRequest, CodeUnknownTier},
+	{domain.ErrInvalidTimeRange, http.StatusBadRequest, CodeInvalidTimeRange},
+	{domain.ErrTimeRangeTooLarge, http.StatusBadRequest, CodeTimeRangeTooLarge},
+	{domain.ErrInvalidImportFile, http.StatusBadRequest, CodeInvalidImportFile},
+	{domain.ErrBatchEmpty, http.StatusBadRequest, CodeBatchEmpty},
+	{domain.ErrUnknownBatchMode, http.StatusBadRequest, CodeUnknownBatchMode},
+	{domain.ErrBatchInvalid, http.StatusBadRequest, CodeBatchInvalid},
+	{domain.ErrInvalidWalletDetails, http.StatusBadRequest, CodeInvalidWalletDetails},
+	{domain.ErrInvalidWalletQuery, http.StatusBadRequest, CodeInvalidWalletQuery},
+	{domain.ErrWalletNotFound, http.StatusNotFound, CodeWalletNotFound},
+	{domain.ErrImportNotFound, http.StatusNotFound, CodeImportNotFound},
+	{domain.ErrWalletFrozen, http.StatusConflict, CodeWalletFrozen},
+	{domain.ErrSerializationFailure, http.StatusConflict, CodeConcurrentUpdate},
+	{domain.ErrBatchTooLarge, http.StatusRequestEntityTooLarge, CodeBatchTooLarge},
+	{domain.ErrInsufficientFunds, http.StatusUnprocessableEntity, CodeInsufficientFunds},
+	{domain.ErrLimitExceeded, http.StatusUnprocessableEntity, CodeLimitExceeded},
+	{domain.ErrFeeExceedsAmount, http.StatusUnprocessableEntity, CodeFeeExceedsAmount},
+	{domain.ErrCurrencyMismatch, http.StatusUnprocessableEntity, CodeCurrencyMismatch},
+	{domain.ErrCreditLimitTooLow, http.StatusUnprocessableEntity, CodeCreditLimitTooLow},
+	{domain.ErrTierNotUpgrade, http.StatusUnprocessableEntity, CodeTierNotUpgrade},
+	{domain.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, CodeIdempotencyKeyReused},
+}
+
+// New создает ответ с ошибкой для текущего запроса
+func New(c *gin.Context, status int, code Code, detail string) dto.ProblemDTO {
+	return dto.ProblemDTO{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  c.Request.URL.Path,
+		Code:      string(code),
+		RequestID: c.GetString("request_id"),
+	}
+}
+
+// FromError сопоставляет ошибку с ответом. Неизвестная ошибка становится 500 без подробностей,
+// чтобы не раскрывать внутреннее устройство сервиса
+func FromError(c *gin.Context, err error) dto.ProblemDTO {
+	var batchItemErr *domain.BatchItemError
+	if errors.As(err, &batchItemErr) && !errors.Is(err, domain.ErrBatchInvalid) {
+		cause := FromError(c, batchItemErr.Err)
+		p := New(c, cause.Status, CodeBatchRolledBack, "batch rolled back")
+		p.Index = &batchItemErr.Index
+		p.Cause = &cause
+		return p
+	}
+
+	for _, known := range domainErrors {
+		if !errors.Is(err, known.err) {
+			continue
+		}
+		p := New(c, known.status, known.code, err.Error())
+		addDetails(&p, err)
+		return p
+	}
+	return New(c, http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// addDetails добавляет к ответу поля, по которым клиент может исправить запрос
+func addDetails(p *dto.ProblemDTO, err error) {
+	var insufficientErr *domain.InsufficientFundsError
+	var limitErr *domain.LimitExceededError
+	var validationErr *domain.BatchValidationError
+	switch {
+	case errors.As(err, &insufficientErr):
+		p.Available = &insufficientErr.Available
+		p.Amount = &insufficientErr.Amount
+	case errors.As(err, &limitErr):
+		p.Limit = string(limitErr.Limit)
+		p.Max = &limitErr.Max
+		p.Remaining = &limitErr.Remaining
+	case errors.As(err, &validationErr):
+		p.Detail = domain.ErrBatchInvalid.Error()
+		for _, item := range validationErr.Items {
+			code := CodeInvalidRequest
+			for _, known := range domainErrors {
+				if errors.Is(item.Err, known.err) {
+					code = known.code
+					break
+				}
+			}
+			p.Items = append(p.Items, dto.ProblemItemDTO{Index: item.Index, Code: string(code), Detail: item.Err.Error()})
+		}
+	}
+}
+
+// Abort завершает запрос ответом с ошибкой
+func Abort(c *gin.Context, p dto.ProblemDTO) {
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
+
+// AbortWithError завершает запрос ответом, соответствующим err. Отказы по бизнес-правилам пишутся в лог
+// предупреждением, остальные ошибки - ошибкой
+func AbortWithError(c *gin.Context, log *zap.Logger, msg string, err error) {
+	p := FromError(c, err)
+	if p.Status >= http.StatusInternalServerError {
+		log.Error(msg, zap.Error(err))
+	} else {
+		log.Warn(msg, zap.String("code", p.Code), zap.Error(err))
+	}
+	Abort(c, p)
+}
+
+// AbortInvalid завершает запрос ответом 400 о некорректном запросе
+func AbortInvalid(c *gin.Context, code Code, detail string) {
+	Abort(c, New(c, http.StatusBadRequest, code, detail))
+}
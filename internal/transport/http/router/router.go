@@ -1,21 +1,46 @@
 package router
 
 import (
+	"expvar"
 	"go.uber.org/zap"
+	"net/http"
 
 	"testtask/internal/transport/http/handler"
 	"testtask/internal/transport/http/middleware"
+	"testtask/internal/transport/http/openapi"
+	"testtask/internal/transport/http/problem"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Router struct {
-	rout *gin.Engine
-	h    *handler.Handler
-	log  *zap.Logger
+	rout        *gin.Engine
+	h           *handler.Handler
+	log         *zap.Logger
+	idempotency bool
+	// adminPrincipals - имена администраторов по токенам доступа к /api/v1/admin и /debug/vars
+	adminPrincipals map[string]string
 }
 
-func NewRouter(h *handler.Handler, mode string, log *zap.Logger) *Router {
+type Option func(r *Router)
+
+// WithIdempotency включает повторное использование ответов на операции с заголовком Idempotency-Key.
+// Ответы хранит сервис, сколько они хранятся, задается service.WithIdempotencyTTL
+func WithIdempotency() Option {
+	return func(r *Router) {
+		r.idempotency = true
+	}
+}
+
+// WithAdminAuth разрешает административные методы и метрики по токенам principals (токен -> имя администратора).
+// Без этой опции административные методы отвечают 401
+func WithAdminAuth(principals map[string]string) Option {
+	return func(r *Router) {
+		r.adminPrincipals = principals
+	}
+}
+
+func NewRouter(h *handler.Handler, mode string, log *zap.Logger, opts ...Option) *Router {
 	switch mode {
 	case "debug":
 		gin.SetMode(gin.DebugMode)
@@ -23,28 +48,73 @@ func NewRouter(h *handler.Handler, mode string, log *zap.Logger) *Router {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := &Router{
-		rout: gin.Default(),
+		rout: gin.New(),
 		h:    h,
 		log:  log.Named("router"),
 	}
+	for _, opt := range opts {
+		opt(router)
+	}
 	router.setupRouter()
 
 	return router
 }
 
 func (r *Router) setupRouter() {
-	r.rout.Use(middleware.LoggingMiddleware(r.log))
+	r.rout.Use(middleware.RequestID(), middleware.LoggingMiddleware(r.log), middleware.Recovery(r.log))
+	r.rout.NoRoute(func(c *gin.Context) {
+		problem.Abort(c, problem.New(c, http.StatusNotFound, problem.CodeNotFound, "route not found"))
+	})
 
 	gr := r.rout.Group("/")
 	r.addApi(gr)
 
+	// Метрики процесса, в том числе результаты сверки балансов, доступны только администраторам
+	r.rout.GET("/debug/vars", middleware.AdminAuth(r.adminPrincipals), gin.WrapH(expvar.Handler()))
 }
+
 func (r *Router) addApi(rg *gin.RouterGroup) {
+	spec := openapi.MustLoad()
 	api := r.rout.Group("/api/v1")
+	api.Use(middleware.ReadYourWrites(), middleware.ValidateRequest(spec))
+
+	api.GET("/openapi.json", openapi.ServeSpec)
+	api.GET("/docs", openapi.ServeUI)
 
+	api.GET("/wallets", r.h.ListWallets)
+	api.GET("/wallets/lookup", r.h.FindWallet)
 	api.GET("/wallets/:id", r.h.GetBalance)
-	api.POST("/wallet", r.h.Operation)
-	api.POST("/wallets", r.h.CreateWallet)
+	api.PATCH("/wallets/:id", r.h.UpdateWallet)
+	api.GET("/wallets/:id/balance", r.h.GetBalanceAt)
+	api.GET("/wallets/:id/balance/daily", r.h.GetBalanceSeries)
+	api.GET("/wallets/:id/statement", r.h.GetStatement)
+	// Операции изменяют данные, поэтому клиент может безопасно повторить их только с ключом идемпотентности
+	operations := api.Group("")
+	if r.idempotency {
+		operations.Use(middleware.Idempotency())
+	}
+	operations.POST("/wallet", r.h.Operation)
+	operations.POST("/wallets", r.h.CreateWallet)
+	operations.POST("/operations/batch", r.h.Batch)
+
+	// Импорт проводит операции от имени любых кошельков, поэтому доступен только администраторам
+	imports := r.rout.Group("/api/v1/imports", middleware.AdminAuth(r.adminPrincipals),
+		middleware.ReadYourWrites(), middleware.ValidateRequest(spec))
+	imports.POST("", r.h.CreateImport)
+	imports.GET("/:id", r.h.GetImport)
+	imports.GET("/:id/errors", r.h.GetImportErrors)
+
+	// Аутентификация идет до проверки запроса, чтобы без токена не раскрывать схему административных методов
+	admin := r.rout.Group("/api/v1/admin", middleware.AdminAuth(r.adminPrincipals),
+		middleware.ReadYourWrites(), middleware.ValidateRequest(spec))
+	admin.PUT("/wallets/:id/credit-limit", r.h.SetCreditLimit)
+	admin.GET("/wallets/:id/limits", r.h.GetLimits)
+	admin.PUT("/wallets/:id/limits", r.h.SetWalletLimits)
+	admin.PUT("/wallets/:id/tier", r.h.UpgradeTier)
+	admin.PUT("/tiers/:tier/limits", r.h.SetTierLimits)
+	admin.GET("/log-level", r.h.GetLogLevels)
+	admin.PUT("/log-level", r.h.SetLogLevel)
+	admin.DELETE("/log-level/:logger", r.h.ResetLogLevel)
 }
 
 func (r *Router) GetEngine() *gin.Engine {
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"testtask/internal/transport/http/handler"
+	"testtask/internal/transport/http/openapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRouter_RoutesAreDocumented(t *testing.T) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	r := NewRouter(handler.NewHandler(nil), "release", zap.NewNop())
+
+	routed := make(map[*openapi.Operation]bool)
+	for _, route := range r.GetEngine().Routes() {
+		if !strings.HasPrefix(route.Path, "/api/") {
+			continue
+		}
+		op := spec.Operation(route.Method, route.Path)
+		assert.NotNil(t, op, "%s %s is not documented in openapi.json", route.Method, route.Path)
+		routed[op] = true
+	}
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			assert.True(t, routed[op], "%s %s is documented but not routed", strings.ToUpper(method), path)
+		}
+	}
+}
+
+func TestRouter_AdminRequiresToken(t *testing.T) {
+	r := NewRouter(handler.NewHandler(nil), "release", zap.NewNop(), WithAdminAuth(map[string]string{"secret": "ops"}))
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{name: "Метрики без токена", path: "/debug/vars", expected: http.StatusUnauthorized},
+		{name: "Метрики с токеном", path: "/debug/vars", authorization: "Bearer secret", expected: http.StatusOK},
+		{name: "Импорт без токена", path: "/api/v1/imports/00000000-0000-0000-0000-000000000001", expected: http.StatusUnauthorized},
+		{name: "Уровни логирования без токена", path: "/api/v1/admin/log-level", expected: http.StatusUnauthorized},
+		{name: "Уровни логирования с чужим токеном", path: "/api/v1/admin/log-level", authorization: "Bearer guess", expected: http.StatusUnauthorized},
+		// Управление уровнями выключено в обработчике, но токен уже проверен
+		{name: "Уровни логирования с токеном", path: "/api/v1/admin/log-level", authorization: "Bearer secret", expected: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			r.GetEngine().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
@@ -0,0 +1,5 @@
+ALTER TABLE wallets
+    DROP CONSTRAINT balance_must_be_within_credit_limit,
+    DROP CONSTRAINT credit_limit_must_be_non_negative,
+    DROP COLUMN credit_limit,
+    ADD CONSTRAINT balance_must_be_non_negative CHECK (balance >= 0);
@@ -0,0 +1,5 @@
+ALTER TABLE wallets
+    ADD COLUMN credit_limit NUMERIC(15, 2) NOT NULL DEFAULT 0.00,
+    ADD CONSTRAINT credit_limit_must_be_non_negative CHECK (credit_limit >= 0),
+    DROP CONSTRAINT balance_must_be_non_negative,
+    ADD CONSTRAINT balance_must_be_within_credit_limit CHECK (balance >= -credit_limit);
@@ -0,0 +1,10 @@
+CREATE TABLE operations (
+    id UUID PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    operation_type_id SMALLINT NOT NULL REFERENCES operation_types (id),
+    amount NUMERIC(15, 2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT amount_must_be_positive CHECK (amount > 0)
+);
+
+CREATE INDEX operations_wallet_type_created_at_idx ON operations (wallet_id, operation_type_id, created_at);
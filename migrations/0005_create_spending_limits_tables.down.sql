@@ -0,0 +1,4 @@
+DROP TABLE wallet_spending_limits;
+DROP TABLE tier_spending_limits;
+
+ALTER TABLE wallets DROP COLUMN tier;
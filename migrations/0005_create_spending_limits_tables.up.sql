@@ -0,0 +1,23 @@
+ALTER TABLE wallets ADD COLUMN tier TEXT NOT NULL DEFAULT 'BASIC';
+
+CREATE TABLE tier_spending_limits (
+    tier TEXT PRIMARY KEY,
+    daily_withdrawal NUMERIC(15, 2),
+    monthly_withdrawal NUMERIC(15, 2),
+    single_withdrawal NUMERIC(15, 2),
+    CONSTRAINT tier_limits_must_be_positive CHECK (
+        daily_withdrawal > 0 AND monthly_withdrawal > 0 AND single_withdrawal > 0
+    )
+);
+
+CREATE TABLE wallet_spending_limits (
+    wallet_id UUID PRIMARY KEY REFERENCES wallets (id) ON DELETE CASCADE,
+    daily_withdrawal NUMERIC(15, 2),
+    monthly_withdrawal NUMERIC(15, 2),
+    single_withdrawal NUMERIC(15, 2),
+    CONSTRAINT wallet_limits_must_be_positive CHECK (
+        daily_withdrawal > 0 AND monthly_withdrawal > 0 AND single_withdrawal > 0
+    )
+);
+
+INSERT INTO tier_spending_limits (tier) VALUES ('BASIC');
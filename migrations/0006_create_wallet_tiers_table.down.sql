@@ -0,0 +1,10 @@
+DROP TABLE wallet_audit_log;
+
+ALTER TABLE tier_spending_limits DROP CONSTRAINT tier_spending_limits_tier_fkey;
+DELETE FROM tier_spending_limits WHERE tier IN ('ANONYMOUS', 'VERIFIED');
+
+ALTER TABLE wallets
+    DROP CONSTRAINT wallets_tier_fkey,
+    ALTER COLUMN tier SET DEFAULT 'BASIC';
+
+DROP TABLE wallet_tiers;
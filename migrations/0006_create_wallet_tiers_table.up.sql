@@ -0,0 +1,34 @@
+CREATE TABLE wallet_tiers (
+    name TEXT PRIMARY KEY,
+    rank SMALLINT UNIQUE NOT NULL,
+    max_balance NUMERIC(15, 2),
+    max_monthly_incoming NUMERIC(15, 2),
+    CONSTRAINT tier_caps_must_be_positive CHECK (max_balance > 0 AND max_monthly_incoming > 0)
+);
+
+INSERT INTO wallet_tiers (name, rank, max_balance, max_monthly_incoming) VALUES
+('ANONYMOUS', 1, 15000.00, 40000.00),
+('BASIC', 2, 60000.00, 200000.00),
+('VERIFIED', 3, NULL, NULL);
+
+INSERT INTO tier_spending_limits (tier) VALUES ('ANONYMOUS'), ('VERIFIED');
+
+ALTER TABLE wallets
+    ALTER COLUMN tier SET DEFAULT 'ANONYMOUS',
+    ADD CONSTRAINT wallets_tier_fkey FOREIGN KEY (tier) REFERENCES wallet_tiers (name);
+
+ALTER TABLE tier_spending_limits
+    ADD CONSTRAINT tier_spending_limits_tier_fkey FOREIGN KEY (tier) REFERENCES wallet_tiers (name);
+
+CREATE TABLE wallet_audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    action TEXT NOT NULL,
+    old_value TEXT,
+    new_value TEXT,
+    actor TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX wallet_audit_log_wallet_id_idx ON wallet_audit_log (wallet_id, created_at);
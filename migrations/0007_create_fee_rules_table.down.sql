@@ -0,0 +1,7 @@
+DROP TABLE fee_rules;
+
+DROP INDEX operations_correlation_id_idx;
+ALTER TABLE operations DROP COLUMN correlation_id;
+
+-- Откат невозможен, пока в истории есть операции этих типов: внешний ключ не даст удалить их
+DELETE FROM operation_types WHERE id IN (3, 4, 5);
@@ -0,0 +1,25 @@
+INSERT INTO operation_types (id, name) VALUES
+(3, 'TRANSFER_OUT'),
+(4, 'TRANSFER_IN'),
+(5, 'FEE');
+
+ALTER TABLE operations ADD COLUMN correlation_id UUID;
+UPDATE operations SET correlation_id = id;
+ALTER TABLE operations ALTER COLUMN correlation_id SET NOT NULL;
+
+CREATE INDEX operations_correlation_id_idx ON operations (correlation_id);
+
+CREATE TABLE fee_rules (
+    id SERIAL PRIMARY KEY,
+    operation_type TEXT NOT NULL,
+    tier TEXT REFERENCES wallet_tiers (name),
+    fixed NUMERIC(15, 2) NOT NULL DEFAULT 0.00,
+    percent NUMERIC(7, 4) NOT NULL DEFAULT 0.0000,
+    min_fee NUMERIC(15, 2),
+    max_fee NUMERIC(15, 2),
+    CONSTRAINT fee_rules_operation_type_check CHECK (operation_type IN ('WITHDRAW', 'TRANSFER')),
+    CONSTRAINT fee_rules_must_be_non_negative CHECK (
+        fixed >= 0 AND percent >= 0 AND min_fee >= 0 AND max_fee >= min_fee
+    ),
+    CONSTRAINT fee_rules_operation_type_tier_key UNIQUE NULLS NOT DISTINCT (operation_type, tier)
+);
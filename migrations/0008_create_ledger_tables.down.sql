@@ -0,0 +1,8 @@
+-- Журнал проводок удаляется целиком; балансы в wallets при этом не меняются
+DROP TABLE postings;
+DROP FUNCTION reject_posting_change();
+DROP FUNCTION check_journal_balanced();
+DROP TABLE journals;
+DROP TABLE accounts;
+
+ALTER TABLE wallets DROP COLUMN currency;
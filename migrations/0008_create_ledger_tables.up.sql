@@ -0,0 +1,90 @@
+ALTER TABLE wallets ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB';
+
+CREATE TABLE accounts (
+    id UUID PRIMARY KEY,
+    code TEXT UNIQUE NOT NULL,
+    kind TEXT NOT NULL,
+    wallet_id UUID UNIQUE REFERENCES wallets (id),
+    currency CHAR(3) NOT NULL,
+    CONSTRAINT accounts_id_currency_key UNIQUE (id, currency),
+    CONSTRAINT accounts_kind_check CHECK (kind IN ('WALLET', 'SYSTEM')),
+    CONSTRAINT accounts_wallet_kind_check CHECK ((kind = 'WALLET') = (wallet_id IS NOT NULL))
+);
+
+CREATE TABLE journals (
+    id UUID PRIMARY KEY,
+    correlation_id UUID NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX journals_correlation_id_idx ON journals (correlation_id);
+
+-- Знак суммы проводки - изменение баланса счета: баланс счета равен сумме его проводок
+CREATE TABLE postings (
+    id BIGSERIAL PRIMARY KEY,
+    journal_id UUID NOT NULL REFERENCES journals (id),
+    account_id UUID NOT NULL,
+    amount NUMERIC(15, 2) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT postings_account_currency_fkey FOREIGN KEY (account_id, currency) REFERENCES accounts (id, currency),
+    CONSTRAINT postings_amount_must_be_non_zero CHECK (amount <> 0)
+);
+
+CREATE INDEX postings_account_id_created_at_idx ON postings (account_id, created_at);
+CREATE INDEX postings_journal_id_idx ON postings (journal_id);
+
+-- Проверка выполняется при коммите, когда все проводки журнала уже вставлены
+CREATE FUNCTION check_journal_balanced() RETURNS trigger AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM postings
+        WHERE journal_id = NEW.journal_id
+        GROUP BY currency
+        HAVING SUM(amount) <> 0
+    ) THEN
+        RAISE EXCEPTION 'journal % is not balanced', NEW.journal_id USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER postings_journal_balanced
+    AFTER INSERT ON postings
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION check_journal_balanced();
+
+CREATE FUNCTION reject_posting_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'postings are append-only' USING ERRCODE = 'restrict_violation';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER postings_append_only
+    BEFORE UPDATE OR DELETE ON postings
+    FOR EACH ROW EXECUTE FUNCTION reject_posting_change();
+
+INSERT INTO accounts (id, code, kind, currency) VALUES
+(gen_random_uuid(), 'cash-in:RUB', 'SYSTEM', 'RUB'),
+(gen_random_uuid(), 'cash-out:RUB', 'SYSTEM', 'RUB'),
+(gen_random_uuid(), 'opening-balance:RUB', 'SYSTEM', 'RUB');
+
+INSERT INTO accounts (id, code, kind, wallet_id, currency)
+SELECT id, 'wallet:' || id, 'WALLET', id, currency FROM wallets;
+
+-- Текущие балансы переносятся в журнал проводками против счета входящих остатков
+CREATE TEMPORARY TABLE opening_journals ON COMMIT DROP AS
+SELECT gen_random_uuid() AS journal_id, id AS wallet_id, balance, currency
+FROM wallets
+WHERE balance <> 0;
+
+INSERT INTO journals (id, correlation_id, description)
+SELECT journal_id, journal_id, 'opening balance' FROM opening_journals;
+
+INSERT INTO postings (journal_id, account_id, amount, currency)
+SELECT journal_id, wallet_id, balance, currency FROM opening_journals
+UNION ALL
+SELECT oj.journal_id, a.id, -oj.balance, oj.currency
+FROM opening_journals oj
+JOIN accounts a ON a.code = 'opening-balance:' || oj.currency;
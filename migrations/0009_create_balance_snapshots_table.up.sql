@@ -0,0 +1,8 @@
+-- Снимок хранит баланс счета кошелька с учетом всех проводок с created_at <= taken_at
+CREATE TABLE balance_snapshots (
+    wallet_id UUID NOT NULL REFERENCES wallets (id),
+    taken_at TIMESTAMPTZ NOT NULL,
+    balance NUMERIC(15, 2) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (wallet_id, taken_at)
+);
@@ -0,0 +1,30 @@
+CREATE TABLE imports (
+    id UUID PRIMARY KEY,
+    file_name TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    total_rows INTEGER NOT NULL DEFAULT 0,
+    applied_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    finished_at TIMESTAMPTZ,
+    CONSTRAINT imports_status_check CHECK (status IN ('PENDING', 'RUNNING', 'COMPLETED', 'FAILED'))
+);
+
+-- Строки файла хранятся как текст, чтобы COPY не прерывался на некорректных значениях;
+-- они проверяются при применении и попадают в отчет об ошибках с номером строки
+CREATE TABLE import_rows (
+    import_id UUID NOT NULL REFERENCES imports (id),
+    line INTEGER NOT NULL,
+    wallet_id TEXT NOT NULL DEFAULT '',
+    operation_type TEXT NOT NULL DEFAULT '',
+    amount TEXT NOT NULL DEFAULT '',
+    target_wallet_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'PENDING',
+    error TEXT NOT NULL DEFAULT '',
+    operation_id UUID,
+    PRIMARY KEY (import_id, line),
+    CONSTRAINT import_rows_status_check CHECK (status IN ('PENDING', 'APPLIED', 'FAILED'))
+);
+
+CREATE INDEX import_rows_import_id_status_idx ON import_rows (import_id, status, line);
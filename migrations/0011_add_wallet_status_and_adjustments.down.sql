@@ -0,0 +1,7 @@
+-- Откат невозможен, пока есть корректировки: на счет и типы операций ссылаются проводки и история
+DELETE FROM accounts WHERE code = 'adjustments:RUB';
+DELETE FROM operation_types WHERE id IN (6, 7);
+
+ALTER TABLE wallets
+    DROP CONSTRAINT wallets_status_check,
+    DROP COLUMN status;
@@ -0,0 +1,9 @@
+ALTER TABLE wallets ADD COLUMN status TEXT NOT NULL DEFAULT 'ACTIVE';
+ALTER TABLE wallets ADD CONSTRAINT wallets_status_check CHECK (status IN ('ACTIVE', 'FROZEN'));
+
+INSERT INTO operation_types (id, name) VALUES
+(6, 'ADJUSTMENT_IN'),
+(7, 'ADJUSTMENT_OUT');
+
+INSERT INTO accounts (id, code, kind, currency) VALUES
+(gen_random_uuid(), 'adjustments:RUB', 'SYSTEM', 'RUB');
@@ -0,0 +1,6 @@
+ALTER TABLE wallets
+    DROP CONSTRAINT wallets_metadata_is_object,
+    DROP CONSTRAINT wallets_external_id_key,
+    DROP COLUMN metadata,
+    DROP COLUMN label,
+    DROP COLUMN external_id;
@@ -0,0 +1,7 @@
+-- external_id связывает кошелек с записью клиента во внешней системе; NULL - кошелек не связан
+ALTER TABLE wallets
+    ADD COLUMN external_id TEXT,
+    ADD COLUMN label TEXT NOT NULL DEFAULT '',
+    ADD COLUMN metadata JSONB NOT NULL DEFAULT '{}',
+    ADD CONSTRAINT wallets_external_id_key UNIQUE (external_id),
+    ADD CONSTRAINT wallets_metadata_is_object CHECK (jsonb_typeof(metadata) = 'object');
@@ -0,0 +1,7 @@
+DROP INDEX wallets_metadata_idx;
+DROP INDEX wallets_currency_created_at_id_idx;
+DROP INDEX wallets_status_created_at_id_idx;
+DROP INDEX wallets_balance_id_idx;
+DROP INDEX wallets_created_at_id_idx;
+
+ALTER TABLE wallets DROP COLUMN created_at;
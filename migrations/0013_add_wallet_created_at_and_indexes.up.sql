@@ -0,0 +1,16 @@
+-- Время создания кошелька. Существующим кошелькам проставляется время первой проводки по их счету,
+-- кошелькам без проводок - время миграции
+ALTER TABLE wallets ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+UPDATE wallets w SET created_at = p.first_posting_at
+FROM (SELECT account_id, MIN(created_at) AS first_posting_at FROM postings GROUP BY account_id) p
+WHERE p.account_id = w.id;
+
+-- Список кошельков читается по курсору (ключ сортировки, id); id в индексе делает порядок однозначным
+CREATE INDEX wallets_created_at_id_idx ON wallets (created_at, id);
+CREATE INDEX wallets_balance_id_idx ON wallets (balance, id);
+-- Частые отборы бэк-офиса: по статусу и по валюте в порядке создания
+CREATE INDEX wallets_status_created_at_id_idx ON wallets (status, created_at, id);
+CREATE INDEX wallets_currency_created_at_id_idx ON wallets (currency, created_at, id);
+-- Отбор по ключам (?&) и содержимому (@>) метаданных
+CREATE INDEX wallets_metadata_idx ON wallets USING GIN (metadata);
@@ -0,0 +1 @@
+ALTER TABLE import_rows DROP COLUMN occurred_at;
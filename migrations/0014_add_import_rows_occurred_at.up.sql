@@ -0,0 +1,3 @@
+-- Время операции из файла импорта, как и остальные поля строки, хранится текстом и разбирается при применении;
+-- пустое значение - операция проводится временем применения
+ALTER TABLE import_rows ADD COLUMN occurred_at TEXT NOT NULL DEFAULT '';
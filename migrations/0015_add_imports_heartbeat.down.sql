@@ -0,0 +1,3 @@
+DROP INDEX imports_unfinished_created_at_idx;
+
+ALTER TABLE imports DROP COLUMN heartbeat_at;
@@ -0,0 +1,5 @@
+-- Обработчик периодически отмечает, что продолжает применять импорт. RUNNING-импорт без свежей отметки
+-- брошен остановленным процессом и подхватывается другим обработчиком
+ALTER TABLE imports ADD COLUMN heartbeat_at TIMESTAMPTZ;
+
+CREATE INDEX imports_unfinished_created_at_idx ON imports (created_at) WHERE status IN ('PENDING', 'RUNNING');
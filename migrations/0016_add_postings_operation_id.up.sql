@@ -0,0 +1,24 @@
+-- Проводка ссылается на запись истории, которую отражает: у одного кошелька в журнале может быть
+-- несколько записей (перевод на кошелек доходов с комиссией), и связь по correlation_id неоднозначна
+ALTER TABLE postings ADD COLUMN operation_id UUID REFERENCES operations (id);
+
+-- Существующие проводки связываются с записями того же журнала и кошелька по сумме;
+-- одинаковые суммы сопоставляются по порядку создания
+ALTER TABLE postings DISABLE TRIGGER postings_append_only;
+
+WITH p AS (
+    SELECT p.id, j.correlation_id, p.account_id, abs(p.amount) AS amount,
+        row_number() OVER (PARTITION BY j.correlation_id, p.account_id, abs(p.amount) ORDER BY p.id) AS n
+    FROM postings p
+    JOIN journals j ON j.id = p.journal_id
+), o AS (
+    SELECT id, correlation_id, wallet_id, amount,
+        row_number() OVER (PARTITION BY correlation_id, wallet_id, amount ORDER BY created_at, id) AS n
+    FROM operations
+)
+UPDATE postings SET operation_id = o.id
+FROM p
+JOIN o ON o.correlation_id = p.correlation_id AND o.wallet_id = p.account_id AND o.amount = p.amount AND o.n = p.n
+WHERE postings.id = p.id;
+
+ALTER TABLE postings ENABLE TRIGGER postings_append_only;
@@ -0,0 +1,4 @@
+ALTER TABLE wallets
+    DROP CONSTRAINT wallets_client_id_external_id_key,
+    ADD CONSTRAINT wallets_external_id_key UNIQUE (external_id),
+    DROP COLUMN client_id;
@@ -0,0 +1,6 @@
+-- Внешний ID ссылается на запись в системе клиента, поэтому уникален только в пределах клиента;
+-- пустой client_id - клиент по умолчанию, к нему относятся существующие кошельки
+ALTER TABLE wallets
+    ADD COLUMN client_id TEXT NOT NULL DEFAULT '',
+    DROP CONSTRAINT wallets_external_id_key,
+    ADD CONSTRAINT wallets_client_id_external_id_key UNIQUE (client_id, external_id);
@@ -0,0 +1,3 @@
+DROP INDEX wallets_owner_id_created_at_id_idx;
+
+ALTER TABLE wallets DROP COLUMN owner_id;
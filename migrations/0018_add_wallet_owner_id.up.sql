@@ -0,0 +1,6 @@
+-- Владелец кошелька во внешней системе; в отличие от external_id не уникален: у владельца может быть
+-- несколько кошельков. NULL - владелец не указан
+ALTER TABLE wallets ADD COLUMN owner_id TEXT;
+
+-- Отбор кошельков владельца в порядке создания
+CREATE INDEX wallets_owner_id_created_at_id_idx ON wallets (owner_id, created_at, id) WHERE owner_id IS NOT NULL;
@@ -0,0 +1,13 @@
+-- Результаты запросов с заголовком Idempotency-Key. Ключ занимается и результат сохраняется в транзакции
+-- самой операции, поэтому повтор после перезапуска сервиса или на другом экземпляре не выполнит операцию дважды.
+-- response пуст, пока транзакция, занявшая ключ, не завершилась
+CREATE TABLE idempotency_keys
+(
+    key         TEXT PRIMARY KEY,
+    fingerprint BYTEA       NOT NULL,
+    response    JSONB,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,5 @@
+ALTER TABLE fee_rules
+    DROP CONSTRAINT fee_rules_must_be_non_negative,
+    ADD CONSTRAINT fee_rules_must_be_non_negative CHECK (
+        fixed >= 0 AND percent >= 0 AND min_fee >= 0 AND max_fee >= min_fee
+    );
@@ -0,0 +1,7 @@
+-- max_fee >= min_fee не ограничивает max_fee, когда min_fee не задан: сравнение с NULL не нарушает CHECK.
+-- Отрицательный максимум превращал бы комиссию в начисление
+ALTER TABLE fee_rules
+    DROP CONSTRAINT fee_rules_must_be_non_negative,
+    ADD CONSTRAINT fee_rules_must_be_non_negative CHECK (
+        fixed >= 0 AND percent >= 0 AND min_fee >= 0 AND max_fee >= 0 AND max_fee >= min_fee
+    );
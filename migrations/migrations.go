@@ -0,0 +1,10 @@
+// Package migrations содержит SQL-миграции схемы БД. Файлы встраиваются в бинарный файл,
+// поэтому сервису и walletctl не нужен каталог миграций рядом с исполняемым файлом
+package migrations
+
+import "embed"
+
+// FS - файлы миграций в формате golang-migrate: <версия>_<название>.up.sql и .down.sql
+//
+//go:embed *.sql
+var FS embed.FS
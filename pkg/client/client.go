@@ -0,0 +1,270 @@
+// Package client - клиент HTTP API кошельков.
+//
+// Операции отправляются с заголовком Idempotency-Key, который сохраняется между повторами,
+// поэтому повтор после сбоя сети или ответа 5xx не выполнит операцию дважды.
+// Запросы повторяются с экспоненциальной задержкой при ошибках сети, ответах 5xx и 429
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultTimeout ограничивает одну попытку запроса
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxRetries - число повторов после первой попытки
+	DefaultMaxRetries = 3
+	// DefaultMinBackoff и DefaultMaxBackoff - задержка перед первым повтором и ее предел
+	DefaultMinBackoff = 100 * time.Millisecond
+	DefaultMaxBackoff = 5 * time.Second
+
+	idempotencyKeyHeader = "Idempotency-Key"
+	minLSNHeader         = "X-Min-LSN"
+	userAgent            = "wallet-go-client/1"
+)
+
+// Client - клиент API кошельков. Безопасен для одновременного использования из нескольких горутин
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	timeout    time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	newKey     func() string
+}
+
+type Option func(c *Client)
+
+// WithHTTPClient задает HTTP-клиент, например с собственным транспортом
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTimeout ограничивает каждую попытку запроса; 0 - только контекстом вызова
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries задает число повторов после первой попытки; 0 - без повторов
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithBackoff задает задержку перед первым повтором и ее предел; задержка удваивается с каждым повтором
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) {
+		c.minBackoff = min
+		c.maxBackoff = max
+	}
+}
+
+// WithIdempotencyKeys задает генератор ключей идемпотентности вместо случайных UUID
+func WithIdempotencyKeys(newKey func() string) Option {
+	return func(c *Client) {
+		c.newKey = newKey
+	}
+}
+
+// New создает клиент для сервера baseURL, например http://localhost:8080
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		timeout:    DefaultTimeout,
+		maxRetries: DefaultMaxRetries,
+		minBackoff: DefaultMinBackoff,
+		maxBackoff: DefaultMaxBackoff,
+		newKey:     uuid.NewString,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+type minLSNKey struct{}
+
+// WithMinLSN возвращает контекст, чтения в котором видят операцию с позицией журнала lsn (Operation.LSN),
+// даже если сервер читает с отстающей реплики
+func WithMinLSN(ctx context.Context, lsn string) context.Context {
+	return context.WithValue(ctx, minLSNKey{}, lsn)
+}
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	// stream - тело ответа читает вызывающий, поэтому попытка не ограничивается таймаутом клиента
+	stream bool
+}
+
+// do выполняет запрос и разбирает JSON-ответ в out
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send выполняет запрос с повторами и возвращает успешный ответ; ответ с ошибкой возвращается как *APIError
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("failed to encode %s %s request: %w", req.method, req.path, err)
+		}
+	}
+	// Ключ один на все попытки: сервер выполнит операцию один раз, а на повторы вернет сохраненный ответ
+	var key string
+	if req.method != http.MethodGet {
+		key = c.newKey()
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req, body, key)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError:
+			wait = retryAfter(resp.Header)
+		case resp.StatusCode >= http.StatusBadRequest:
+			defer resp.Body.Close()
+			return nil, newAPIError(resp)
+		default:
+			return resp, nil
+		}
+
+		if attempt >= c.maxRetries {
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+			return nil, newAPIError(resp)
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := sleep(ctx, max(wait, c.backoff(attempt))); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// attempt выполняет одну попытку запроса
+func (c *Client) attempt(ctx context.Context, req request, body []byte, key string) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.timeout > 0 && !req.stream {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	}
+
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, target, reader)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to create %s %s request: %w", req.method, req.path, err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if key != "" {
+		httpReq.Header.Set(idempotencyKeyHeader, key)
+	}
+	if lsn, ok := ctx.Value(minLSNKey{}).(string); ok && lsn != "" {
+		httpReq.Header.Set(minLSNHeader, lsn)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%s %s: %w", req.method, req.path, err)
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// backoff возвращает задержку перед повтором attempt+1: экспоненциальную, со случайным разбросом в половину значения
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.minBackoff << attempt
+	if d > c.maxBackoff || d <= 0 {
+		d = c.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter читает задержку из заголовка Retry-After в секундах
+func retryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.Atoi(header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// cancelOnClose освобождает контекст попытки, когда тело ответа закрыто
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
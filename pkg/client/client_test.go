@@ -0,0 +1,334 @@
+package client_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"testtask/internal/repository/memory"
+	"testtask/internal/service"
+	"testtask/internal/transport/http/handler"
+	"testtask/internal/transport/http/router"
+	"testtask/pkg/client"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newServer поднимает настоящий роутер поверх хранилища в памяти; wrap подменяет ответы сервера
+func newServer(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	log := zap.NewNop()
+	walletSrv := service.NewWalletService(memory.NewStore(log), log)
+	rout := router.NewRouter(handler.NewHandler(walletSrv), "release", log, router.WithIdempotency())
+
+	var h http.Handler = rout.GetEngine()
+	if wrap != nil {
+		h = wrap(h)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(srv *httptest.Server, opts ...client.Option) *client.Client {
+	opts = append([]client.Option{client.WithBackoff(time.Millisecond, 5*time.Millisecond)}, opts...)
+	return client.New(srv.URL, opts...)
+}
+
+func TestClient_WalletLifecycle(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil))
+
+	walletID, err := c.CreateWallet(ctx, client.WalletDetails{})
+	require.NoError(t, err)
+	targetID, err := c.CreateWallet(ctx, client.WalletDetails{})
+	require.NoError(t, err)
+
+	op, err := c.Deposit(ctx, walletID, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	assert.Equal(t, client.OperationDeposit, op.Type)
+	assert.True(t, op.Balance.Equal(decimal.NewFromInt(100)))
+
+	op, err = c.Withdraw(ctx, walletID, decimal.NewFromInt(30))
+	require.NoError(t, err)
+	assert.True(t, op.Balance.Equal(decimal.NewFromInt(70)))
+
+	_, err = c.Transfer(ctx, walletID, targetID, decimal.NewFromInt(20))
+	require.NoError(t, err)
+
+	wallet, err := c.GetBalance(ctx, walletID)
+	require.NoError(t, err)
+	assert.Equal(t, walletID, wallet.ID)
+	assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(50)), wallet.Balance.String())
+	assert.NotEmpty(t, wallet.Currency)
+
+	target, err := c.GetBalance(ctx, targetID)
+	require.NoError(t, err)
+	assert.True(t, target.Balance.Equal(decimal.NewFromInt(20)), target.Balance.String())
+
+	at, err := c.BalanceAt(ctx, walletID, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.True(t, at.Balance.Equal(decimal.NewFromInt(50)), at.Balance.String())
+
+	today := time.Now().UTC()
+	series, err := c.DailyBalances(ctx, walletID, today, today, time.UTC)
+	require.NoError(t, err)
+	require.Len(t, series.Points, 1)
+	assert.True(t, series.Points[0].Balance.Equal(decimal.NewFromInt(50)))
+
+	statement, err := c.Statement(ctx, walletID, today, today, client.StatementOptions{Format: "jsonl", Location: time.UTC})
+	require.NoError(t, err)
+	data, err := io.ReadAll(statement)
+	require.NoError(t, err)
+	require.NoError(t, statement.Close())
+	assert.Contains(t, string(data), "DEPOSIT")
+}
+
+func TestClient_WalletDetails(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil))
+
+	details := client.WalletDetails{ClientID: "acme", ExternalID: "customer-42", OwnerID: "person-7", Label: "Основной", Metadata: map[string]any{"segment": "retail"}}
+	walletID, err := c.CreateWallet(ctx, details)
+	require.NoError(t, err)
+	again, err := c.CreateWallet(ctx, details)
+	require.NoError(t, err)
+	assert.Equal(t, walletID, again)
+
+	found, err := c.FindWallet(ctx, "acme", "customer-42")
+	require.NoError(t, err)
+	assert.Equal(t, walletID, found.ID)
+	assert.Equal(t, "acme", found.ClientID)
+
+	// У владельца может быть несколько кошельков
+	savingsID, err := c.CreateWallet(ctx, client.WalletDetails{ClientID: "acme", ExternalID: "customer-42-savings", OwnerID: "person-7"})
+	require.NoError(t, err)
+	owned, err := c.ListWallets(ctx, client.ListOptions{OwnerID: "person-7"})
+	require.NoError(t, err)
+	require.Len(t, owned.Wallets, 2)
+	assert.ElementsMatch(t, []uuid.UUID{walletID, savingsID}, []uuid.UUID{owned.Wallets[0].ID, owned.Wallets[1].ID})
+	assert.Equal(t, "Основной", found.Label)
+	assert.Equal(t, map[string]any{"segment": "retail"}, found.Metadata)
+
+	label := "Накопления"
+	updated, err := c.UpdateWallet(ctx, walletID, client.WalletUpdate{Label: &label, Metadata: map[string]any{"segment": nil, "manager": "ivanov"}})
+	require.NoError(t, err)
+	assert.Equal(t, "Накопления", updated.Label)
+	assert.Equal(t, map[string]any{"manager": "ivanov"}, updated.Metadata)
+
+	_, err = c.FindWallet(ctx, "acme", "customer-43")
+	assert.ErrorIs(t, err, client.ErrWalletNotFound)
+
+	_, err = c.CreateWallet(ctx, client.WalletDetails{Metadata: map[string]any{"note": strings.Repeat("x", 16<<10)}})
+	assert.ErrorIs(t, err, client.ErrInvalidWalletDetails)
+}
+
+func TestClient_ListWallets(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil))
+
+	for _, amount := range []int64{30, 10, 20} {
+		walletID, err := c.CreateWallet(ctx, client.WalletDetails{Metadata: map[string]any{"segment": "retail"}})
+		require.NoError(t, err)
+		_, err = c.Deposit(ctx, walletID, decimal.NewFromInt(amount))
+		require.NoError(t, err)
+	}
+	_, err := c.CreateWallet(ctx, client.WalletDetails{})
+	require.NoError(t, err)
+
+	opts := client.ListOptions{Metadata: map[string]any{"segment": "retail"}, Sort: "-balance", Limit: 2, IncludeTotal: true}
+	var balances []string
+	for {
+		list, err := c.ListWallets(ctx, opts)
+		require.NoError(t, err)
+		require.NotNil(t, list.Total)
+		assert.Equal(t, 3, *list.Total)
+		for _, w := range list.Wallets {
+			assert.False(t, w.CreatedAt.IsZero())
+			balances = append(balances, w.Balance.String())
+		}
+		if list.NextCursor == "" {
+			break
+		}
+		opts.Cursor = list.NextCursor
+	}
+	assert.Equal(t, []string{"30", "20", "10"}, balances)
+
+	_, err = c.ListWallets(ctx, client.ListOptions{Sort: "balance", Cursor: opts.Cursor})
+	assert.ErrorIs(t, err, client.ErrInvalidWalletQuery)
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(newServer(t, nil))
+
+	walletID, err := c.CreateWallet(ctx, client.WalletDetails{})
+	require.NoError(t, err)
+	_, err = c.Deposit(ctx, walletID, decimal.NewFromInt(10))
+	require.NoError(t, err)
+
+	_, err = c.Withdraw(ctx, walletID, decimal.NewFromInt(25))
+	require.ErrorIs(t, err, client.ErrInsufficientFunds)
+	var apiErr *client.APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiErr.Status)
+	assert.Equal(t, "INSUFFICIENT_FUNDS", apiErr.Code)
+	assert.NotEmpty(t, apiErr.RequestID)
+	require.NotNil(t, apiErr.Available)
+	require.NotNil(t, apiErr.Amount)
+	assert.True(t, apiErr.Available.Equal(decimal.NewFromInt(10)))
+	assert.True(t, apiErr.Amount.Equal(decimal.NewFromInt(25)))
+
+	_, err = c.GetBalance(ctx, uuid.New())
+	assert.ErrorIs(t, err, client.ErrWalletNotFound)
+
+	_, err = c.Deposit(ctx, walletID, decimal.NewFromInt(-1))
+	assert.ErrorIs(t, err, client.ErrAmountZeroOrNegative)
+
+	_, err = c.Transfer(ctx, walletID, walletID, decimal.NewFromInt(1))
+	assert.ErrorIs(t, err, client.ErrTransferToSameWallet)
+}
+
+func TestClient_RetryReplaysOperation(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	// Первый ответ на операцию теряется: сервер ее выполнил, а клиент получил 502
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || r.URL.Path != "/api/v1/wallet" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			mu.Lock()
+			keys = append(keys, r.Header.Get("Idempotency-Key"))
+			first := len(keys) == 1
+			mu.Unlock()
+			if first {
+				next.ServeHTTP(httptest.NewRecorder(), r)
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(srv)
+
+	walletID, err := c.CreateWallet(ctx, client.WalletDetails{})
+	require.NoError(t, err)
+	op, err := c.Deposit(ctx, walletID, decimal.NewFromInt(100))
+	require.NoError(t, err)
+	assert.True(t, op.Balance.Equal(decimal.NewFromInt(100)))
+
+	require.Len(t, keys, 2)
+	assert.NotEmpty(t, keys[0])
+	assert.Equal(t, keys[0], keys[1])
+
+	wallet, err := c.GetBalance(ctx, walletID)
+	require.NoError(t, err)
+	assert.True(t, wallet.Balance.Equal(decimal.NewFromInt(100)), "deposit applied twice: %s", wallet.Balance)
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retries  int
+		calls    int
+		wantErr  error
+	}{
+		{
+			name:     "Too many requests then success",
+			statuses: []int{http.StatusTooManyRequests},
+			retries:  3,
+			calls:    2,
+		},
+		{
+			name:     "Server errors then success",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError},
+			retries:  3,
+			calls:    3,
+		},
+		{
+			name:     "Retries exhausted",
+			statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			retries:  2,
+			calls:    3,
+			wantErr:  client.ErrInternal,
+		},
+		{
+			name:     "Client error is not retried",
+			statuses: []int{http.StatusBadRequest},
+			retries:  3,
+			calls:    1,
+			wantErr:  client.ErrInvalidRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				calls int
+			)
+			srv := newServer(t, func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					call := calls
+					calls++
+					mu.Unlock()
+					if call < len(tt.statuses) {
+						w.Header().Set("Content-Type", "application/problem+json")
+						w.WriteHeader(tt.statuses[call])
+						code := "INTERNAL_ERROR"
+						if tt.statuses[call] < http.StatusInternalServerError {
+							code = "INVALID_REQUEST"
+						}
+						_, _ = io.WriteString(w, `{"code":"`+code+`"}`)
+						return
+					}
+					next.ServeHTTP(w, r)
+				})
+			})
+			c := newClient(srv, client.WithRetries(tt.retries))
+
+			_, err := c.CreateWallet(context.Background(), client.WalletDetails{})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	srv := newServer(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		})
+	})
+	c := newClient(srv, client.WithTimeout(20*time.Millisecond), client.WithRetries(1))
+
+	start := time.Now()
+	_, err := c.GetBalance(context.Background(), uuid.New())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.GetBalance(ctx, uuid.New())
+	assert.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Ошибки API. Ответ с ошибкой возвращается как *APIError, который сравнивается с ними через errors.Is
+var (
+	ErrInvalidRequest        = errors.New("invalid request")
+	ErrAmountZeroOrNegative  = errors.New("amount is zero or is negative")
+	ErrTransferToSameWallet  = errors.New("transfer to the same wallet")
+	ErrInvalidWalletDetails  = errors.New("invalid wallet details")
+	ErrInvalidWalletQuery    = errors.New("invalid wallet list query")
+	ErrWalletNotFound        = errors.New("wallet not found")
+	ErrWalletFrozen          = errors.New("wallet is frozen")
+	ErrConcurrentUpdate      = errors.New("could not serialize access due to concurrent update")
+	ErrInsufficientFunds     = errors.New("insufficient funds")
+	ErrLimitExceeded         = errors.New("limit exceeded")
+	ErrFeeExceedsAmount      = errors.New("fee exceeds operation amount")
+	ErrCurrencyMismatch      = errors.New("wallet currencies do not match")
+	ErrInvalidTimeRange      = errors.New("invalid time range")
+	ErrTimeRangeTooLarge     = errors.New("time range is too large")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key reused for a different request")
+	ErrInternal              = errors.New("internal server error")
+	ErrUnexpectedContentType = errors.New("unexpected response content type")
+)
+
+// errorCodes сопоставляет стабильные коды ошибок сервера с ошибками клиента
+var errorCodes = map[string]error{
+	"INVALID_REQUEST":         ErrInvalidRequest,
+	"INVALID_WALLET_ID":       ErrInvalidRequest,
+	"UNKNOWN_OPERATION_TYPE":  ErrInvalidRequest,
+	"INVALID_AMOUNT":          ErrAmountZeroOrNegative,
+	"TRANSFER_TO_SAME_WALLET": ErrTransferToSameWallet,
+	"INVALID_WALLET_DETAILS":  ErrInvalidWalletDetails,
+	"INVALID_WALLET_QUERY":    ErrInvalidWalletQuery,
+	"WALLET_NOT_FOUND":        ErrWalletNotFound,
+	"WALLET_FROZEN":           ErrWalletFrozen,
+	"CONCURRENT_UPDATE":       ErrConcurrentUpdate,
+	"INSUFFICIENT_FUNDS":      ErrInsufficientFunds,
+	"LIMIT_EXCEEDED":          ErrLimitExceeded,
+	"FEE_EXCEEDS_AMOUNT":      ErrFeeExceedsAmount,
+	"CURRENCY_MISMATCH":       ErrCurrencyMismatch,
+	"INVALID_TIME_RANGE":      ErrInvalidTimeRange,
+	"TIME_RANGE_TOO_LARGE":    ErrTimeRangeTooLarge,
+	"IDEMPOTENCY_KEY_REUSED":  ErrIdempotencyKeyReused,
+	"INTERNAL_ERROR":          ErrInternal,
+}
+
+// APIError - ответ сервера с ошибкой в формате RFC 7807
+type APIError struct {
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail"`
+	RequestID string `json:"request_id"`
+
+	// Available и Amount заполнены при нехватке средств
+	Available *decimal.Decimal `json:"available"`
+	Amount    *decimal.Decimal `json:"amount"`
+	// Limit, Max и Remaining заполнены при превышении лимита
+	Limit     string           `json:"limit"`
+	Max       *decimal.Decimal `json:"max"`
+	Remaining *decimal.Decimal `json:"remaining"`
+	// Errors - поля запроса, не прошедшие проверку
+	Errors []FieldError `json:"errors"`
+}
+
+type FieldError struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("wallet api: %d %s", e.Status, e.Code)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Unwrap возвращает ошибку клиента, соответствующую коду, чтобы работал errors.Is(err, client.ErrInsufficientFunds)
+func (e *APIError) Unwrap() error {
+	if err, ok := errorCodes[e.Code]; ok {
+		return err
+	}
+	if e.Code == "" && e.Status >= http.StatusInternalServerError {
+		return ErrInternal
+	}
+	return nil
+}
+
+// newAPIError читает ошибку из ответа. Ответ не в формате problem+json, например от прокси, сохраняется в Detail
+func newAPIError(resp *http.Response) error {
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read error response: %w", err)
+	}
+	apiErr := &APIError{}
+	if !strings.Contains(resp.Header.Get("Content-Type"), "json") || json.Unmarshal(data, apiErr) != nil {
+		apiErr = &APIError{Detail: strings.TrimSpace(string(data))}
+	}
+	apiErr.Status = resp.StatusCode
+	return apiErr
+}
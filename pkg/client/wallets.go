@@ -0,0 +1,332 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+)
+
+const dateLayout = "2006-01-02"
+
+// Типы операций
+const (
+	OperationDeposit  = "DEPOSIT"
+	OperationWithdraw = "WITHDRAW"
+	OperationTransfer = "TRANSFER"
+)
+
+type Wallet struct {
+	ID          uuid.UUID       `json:"walletID"`
+	Balance     decimal.Decimal `json:"balance"`
+	CreditLimit decimal.Decimal `json:"credit_limit"`
+	Available   decimal.Decimal `json:"available"`
+	Tier        string          `json:"tier"`
+	Currency    string          `json:"currency"`
+	Status      string          `json:"status"`
+	ClientID    string          `json:"client_id"`
+	ExternalID  string          `json:"external_id"`
+	OwnerID     string          `json:"owner_id"`
+	Label       string          `json:"label"`
+	Metadata    map[string]any  `json:"metadata"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// WalletDetails - реквизиты нового кошелька; все поля необязательны
+type WalletDetails struct {
+	// ClientID - клиент, в системе которого заведен ExternalID
+	ClientID string `json:"client_id,omitempty"`
+	// ExternalID - ID кошелька в системе клиента. Если у клиента кошелек с ним уже есть, CreateWallet вернет его ID
+	ExternalID string `json:"external_id,omitempty"`
+	// OwnerID - владелец кошелька в системе клиента, у него может быть несколько кошельков
+	OwnerID  string         `json:"owner_id,omitempty"`
+	Label    string         `json:"label,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// WalletUpdate - изменение реквизитов кошелька. Nil Label не меняет название, Metadata применяется
+// к текущим метаданным как JSON Merge Patch: ключ со значением nil удаляется
+type WalletUpdate struct {
+	Label    *string        `json:"label,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ListOptions - фильтры и сортировка списка кошельков; пустые поля не ограничивают выборку
+type ListOptions struct {
+	// Status - ACTIVE или FROZEN
+	Status     string
+	ClientID   string
+	ExternalID string
+	// OwnerID - все кошельки владельца
+	OwnerID  string
+	Currency string
+	// MinBalance и MaxBalance - границы баланса включительно
+	MinBalance *decimal.Decimal
+	MaxBalance *decimal.Decimal
+	// CreatedFrom и CreatedTo - период создания, CreatedTo не входит
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	// MetadataKeys - ключи, которые все должны быть в метаданных
+	MetadataKeys []string
+	// Metadata должны содержаться в метаданных кошелька
+	Metadata map[string]any
+	// Sort - created_at или balance, с минусом - по убыванию; по умолчанию -created_at
+	Sort string
+	// Limit - размер страницы; 0 - размер сервера по умолчанию
+	Limit int
+	// Cursor - NextCursor предыдущей страницы
+	Cursor string
+	// IncludeTotal запрашивает количество кошельков по фильтру
+	IncludeTotal bool
+}
+
+type WalletList struct {
+	Wallets []Wallet `json:"wallets"`
+	// NextCursor передается в ListOptions.Cursor за следующей страницей; пустой на последней странице
+	NextCursor string `json:"next_cursor"`
+	// Total заполнен, если запрошен IncludeTotal
+	Total *int `json:"total"`
+}
+
+// Operation - результат операции. Gross - сумма операции, Net - сумма после комиссии Fee
+type Operation struct {
+	ID       uuid.UUID       `json:"operation_id"`
+	WalletID uuid.UUID       `json:"wallet"`
+	Type     string          `json:"operation_type"`
+	Gross    decimal.Decimal `json:"gross"`
+	Fee      decimal.Decimal `json:"fee"`
+	Net      decimal.Decimal `json:"net"`
+	Balance  decimal.Decimal `json:"balance"`
+	// LSN передается в WithMinLSN, чтобы следующие чтения увидели операцию
+	LSN string `json:"lsn,omitempty"`
+}
+
+type BalanceAt struct {
+	WalletID uuid.UUID       `json:"wallet"`
+	At       time.Time       `json:"at"`
+	Balance  decimal.Decimal `json:"balance"`
+	Currency string          `json:"currency"`
+}
+
+type BalancePoint struct {
+	// Date - день в формате YYYY-MM-DD
+	Date    string          `json:"date"`
+	Balance decimal.Decimal `json:"balance"`
+}
+
+type BalanceSeries struct {
+	WalletID uuid.UUID      `json:"wallet"`
+	Timezone string         `json:"timezone"`
+	Points   []BalancePoint `json:"points"`
+}
+
+// StatementOptions - параметры выписки; пустые значения заменяются значениями сервера
+type StatementOptions struct {
+	// Format - csv, jsonl или ofx
+	Format string
+	// Locale - язык заголовков и формат чисел, например ru
+	Locale string
+	// Location - часовой пояс дней выписки
+	Location *time.Location
+}
+
+type operationRequest struct {
+	WalletID       uuid.UUID       `json:"wallet"`
+	OperationType  string          `json:"operation_type"`
+	Amount         decimal.Decimal `json:"amount"`
+	TargetWalletID *uuid.UUID      `json:"target_wallet,omitempty"`
+}
+
+// CreateWallet создает кошелек с нулевым балансом и возвращает его идентификатор
+func (c *Client) CreateWallet(ctx context.Context, details WalletDetails) (uuid.UUID, error) {
+	req := request{method: http.MethodPost, path: "/api/v1/wallets"}
+	if details.ClientID != "" || details.ExternalID != "" || details.OwnerID != "" || details.Label != "" || details.Metadata != nil {
+		req.body = details
+	}
+	var resp struct {
+		ID uuid.UUID `json:"id"`
+	}
+	if err := c.do(ctx, req, &resp); err != nil {
+		return uuid.Nil, err
+	}
+	return resp.ID, nil
+}
+
+// ListWallets возвращает страницу списка кошельков
+func (c *Client) ListWallets(ctx context.Context, opts ListOptions) (*WalletList, error) {
+	query, err := opts.values()
+	if err != nil {
+		return nil, err
+	}
+	var list WalletList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/wallets", query: query}, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// FindWallet ищет кошелек клиента по внешнему ID; ErrWalletNotFound, если его нет
+func (c *Client) FindWallet(ctx context.Context, clientID, externalID string) (*Wallet, error) {
+	query := url.Values{"external_id": {externalID}}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	var wallet Wallet
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/v1/wallets/lookup", query: query}, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// UpdateWallet меняет название и метаданные кошелька и возвращает его новое состояние
+func (c *Client) UpdateWallet(ctx context.Context, walletID uuid.UUID, update WalletUpdate) (*Wallet, error) {
+	var wallet Wallet
+	if err := c.do(ctx, request{method: http.MethodPatch, path: walletPath(walletID, ""), body: update}, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// Deposit зачисляет amount на кошелек
+func (c *Client) Deposit(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) (*Operation, error) {
+	return c.operation(ctx, operationRequest{WalletID: walletID, OperationType: OperationDeposit, Amount: amount})
+}
+
+// Withdraw списывает amount с кошелька
+func (c *Client) Withdraw(ctx context.Context, walletID uuid.UUID, amount decimal.Decimal) (*Operation, error) {
+	return c.operation(ctx, operationRequest{WalletID: walletID, OperationType: OperationWithdraw, Amount: amount})
+}
+
+// Transfer переводит amount с кошелька from на кошелек to
+func (c *Client) Transfer(ctx context.Context, from, to uuid.UUID, amount decimal.Decimal) (*Operation, error) {
+	return c.operation(ctx, operationRequest{WalletID: from, OperationType: OperationTransfer, Amount: amount, TargetWalletID: &to})
+}
+
+func (c *Client) operation(ctx context.Context, body operationRequest) (*Operation, error) {
+	var op Operation
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/v1/wallet", body: body}, &op); err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// GetBalance возвращает текущее состояние кошелька
+func (c *Client) GetBalance(ctx context.Context, walletID uuid.UUID) (*Wallet, error) {
+	var wallet Wallet
+	if err := c.do(ctx, request{method: http.MethodGet, path: walletPath(walletID, "")}, &wallet); err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// BalanceAt возвращает баланс кошелька на момент at
+func (c *Client) BalanceAt(ctx context.Context, walletID uuid.UUID, at time.Time) (*BalanceAt, error) {
+	req := request{
+		method: http.MethodGet,
+		path:   walletPath(walletID, "/balance"),
+		query:  url.Values{"at": {at.Format(time.RFC3339Nano)}},
+	}
+	var balance BalanceAt
+	if err := c.do(ctx, req, &balance); err != nil {
+		return nil, err
+	}
+	return &balance, nil
+}
+
+// DailyBalances возвращает балансы кошелька на конец каждого дня с from по to включительно в часовом поясе loc;
+// nil - часовой пояс сервера по умолчанию
+func (c *Client) DailyBalances(ctx context.Context, walletID uuid.UUID, from, to time.Time, loc *time.Location) (*BalanceSeries, error) {
+	req := request{
+		method: http.MethodGet,
+		path:   walletPath(walletID, "/balance/daily"),
+		query:  dateRange(from, to, loc),
+	}
+	var series BalanceSeries
+	if err := c.do(ctx, req, &series); err != nil {
+		return nil, err
+	}
+	return &series, nil
+}
+
+// Statement выгружает выписку по кошельку за дни с from по to включительно. Выписка читается потоком,
+// поэтому не ограничивается WithTimeout; вызывающий закрывает ее
+func (c *Client) Statement(ctx context.Context, walletID uuid.UUID, from, to time.Time, opts StatementOptions) (io.ReadCloser, error) {
+	query := dateRange(from, to, opts.Location)
+	if opts.Format != "" {
+		query.Set("format", opts.Format)
+	}
+	if opts.Locale != "" {
+		query.Set("locale", opts.Locale)
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: walletPath(walletID, "/statement"), query: query, stream: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (o ListOptions) values() (url.Values, error) {
+	query := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	set("status", o.Status)
+	set("client_id", o.ClientID)
+	set("external_id", o.ExternalID)
+	set("owner_id", o.OwnerID)
+	set("currency", o.Currency)
+	if o.MinBalance != nil {
+		set("balance_min", o.MinBalance.String())
+	}
+	if o.MaxBalance != nil {
+		set("balance_max", o.MaxBalance.String())
+	}
+	if !o.CreatedFrom.IsZero() {
+		set("created_from", o.CreatedFrom.Format(time.RFC3339Nano))
+	}
+	if !o.CreatedTo.IsZero() {
+		set("created_to", o.CreatedTo.Format(time.RFC3339Nano))
+	}
+	set("metadata_key", strings.Join(o.MetadataKeys, ","))
+	if len(o.Metadata) > 0 {
+		data, err := json.Marshal(o.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode metadata filter: %w", err)
+		}
+		set("metadata", string(data))
+	}
+	set("sort", o.Sort)
+	if o.Limit > 0 {
+		set("limit", strconv.Itoa(o.Limit))
+	}
+	set("cursor", o.Cursor)
+	if o.IncludeTotal {
+		set("include_total", "true")
+	}
+	return query, nil
+}
+
+func walletPath(walletID uuid.UUID, suffix string) string {
+	return "/api/v1/wallets/" + walletID.String() + suffix
+}
+
+func dateRange(from, to time.Time, loc *time.Location) url.Values {
+	query := url.Values{}
+	if loc != nil {
+		query.Set("tz", loc.String())
+		from, to = from.In(loc), to.In(loc)
+	}
+	query.Set("from", from.Format(dateLayout))
+	query.Set("to", to.Format(dateLayout))
+	return query
+}
@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Levels - уровни логирования, изменяемые во время работы: общий и переопределенные для именованных логгеров,
+// например WalletService или repository. Уровень дочернего логгера repository.replica берется у repository,
+// если для него не задан свой
+type Levels struct {
+	root zap.AtomicLevel
+
+	mu sync.Mutex
+	// named заменяется целиком при изменении, чтобы проверка уровня при записи обходилась без блокировки
+	named atomic.Pointer[map[string]zapcore.Level]
+	// min - минимальный из всех уровней, ниже него записи отбрасываются сразу
+	min atomic.Int32
+}
+
+func NewLevels(level zapcore.Level) *Levels {
+	l := &Levels{root: zap.NewAtomicLevelAt(level)}
+	l.named.Store(&map[string]zapcore.Level{})
+	l.min.Store(int32(level))
+	return l
+}
+
+// SetLevel задает уровень логгера name; пустое имя меняет общий уровень
+func (l *Levels) SetLevel(name string, level zapcore.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if name == "" {
+		l.root.SetLevel(level)
+	} else {
+		named := maps.Clone(*l.named.Load())
+		named[name] = level
+		l.named.Store(&named)
+	}
+	l.updateMin()
+}
+
+// ResetLevel возвращает логгеру name общий уровень
+func (l *Levels) ResetLevel(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	named := maps.Clone(*l.named.Load())
+	delete(named, name)
+	l.named.Store(&named)
+	l.updateMin()
+}
+
+// Levels возвращает общий уровень под пустым именем и переопределенные уровни логгеров
+func (l *Levels) Levels() map[string]zapcore.Level {
+	levels := maps.Clone(*l.named.Load())
+	levels[""] = l.root.Level()
+	return levels
+}
+
+// Level возвращает уровень, действующий для логгера name
+func (l *Levels) Level(name string) zapcore.Level {
+	named := *l.named.Load()
+	for name != "" {
+		if level, ok := named[name]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return l.root.Level()
+}
+
+// Enabled реализует zapcore.LevelEnabler: уровень включен, если он включен хотя бы для одного логгера
+func (l *Levels) Enabled(level zapcore.Level) bool {
+	return level >= zapcore.Level(l.min.Load())
+}
+
+func (l *Levels) updateMin() {
+	minLevel := l.root.Level()
+	for _, level := range *l.named.Load() {
+		minLevel = min(minLevel, level)
+	}
+	l.min.Store(int32(minLevel))
+}
+
+// levelCore отбрасывает записи ниже уровня, действующего для их логгера
+type levelCore struct {
+	zapcore.Core
+	levels *Levels
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.levels.Level(ent.LoggerName) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+// debugSampler ограничивает частоту одинаковых отладочных сообщений, остальные уровни пишутся полностью
+type debugSampler struct {
+	zapcore.Core
+	sampled zapcore.Core
+}
+
+func (c *debugSampler) With(fields []zapcore.Field) zapcore.Core {
+	return &debugSampler{Core: c.Core.With(fields), sampled: c.sampled.With(fields)}
+}
+
+func (c *debugSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level == zapcore.DebugLevel {
+		return c.sampled.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
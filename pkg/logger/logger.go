@@ -4,10 +4,70 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"os"
 	"time"
 )
 
-func NewLogger(logLevel string) (*zap.Logger, error) {
+// File - файл для логов в дополнение к stdout. Файл переименовывается, когда превышает MaxSizeMB,
+// и хранится не больше MaxBackups копий не старше MaxAgeDays; 0 снимает соответствующее ограничение
+type File struct {
+	Path       string
+	MaxSizeMB  int
+	MaxBackups int
+	MaxAgeDays int
+}
+
+type options struct {
+	levels *Levels
+	file   *File
+	// sampleFirst и sampleThereafter - сколько одинаковых отладочных сообщений в секунду пишется полностью
+	// и каждое какое пишется после этого
+	sampleFirst      int
+	sampleThereafter int
+	timeEncoder      zapcore.TimeEncoder
+	redactor         *Redactor
+}
+
+type Option func(o *options)
+
+// WithLevels позволяет менять уровни логирования во время работы через levels
+func WithLevels(levels *Levels) Option {
+	return func(o *options) {
+		o.levels = levels
+	}
+}
+
+// WithFile дублирует логи в файл с ротацией
+func WithFile(file File) Option {
+	return func(o *options) {
+		o.file = &file
+	}
+}
+
+// WithDebugSampling пишет в секунду первые first одинаковых отладочных сообщений, а дальше каждое thereafter-е
+func WithDebugSampling(first int, thereafter int) Option {
+	return func(o *options) {
+		o.sampleFirst = first
+		o.sampleThereafter = thereafter
+	}
+}
+
+// WithRedactor скрывает в логах значения полей по политикам redactor
+func WithRedactor(redactor *Redactor) Option {
+	return func(o *options) {
+		o.redactor = redactor
+	}
+}
+
+// WithUTCTime пишет время в формате ISO-8601 в UTC вместо местного времени
+func WithUTCTime() Option {
+	return func(o *options) {
+		o.timeEncoder = utcTimeEncoder
+	}
+}
+
+func NewLogger(logLevel string, opts ...Option) (*zap.Logger, error) {
 	var level zapcore.Level
 	var encoding string
 	var encodeLevel zapcore.LevelEncoder
@@ -27,39 +87,83 @@ func NewLogger(logLevel string) (*zap.Logger, error) {
 		encodeLevel = zapcore.LowercaseLevelEncoder
 	}
 
-	config := zap.Config{
-		Encoding:         encoding,
-		Level:            zap.NewAtomicLevelAt(level),
-		OutputPaths:      []string{"stdout"},
-		ErrorOutputPaths: []string{"stderr"},
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "time",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller",
-			FunctionKey:    zapcore.OmitKey,
-			MessageKey:     "msg",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    encodeLevel,
-			EncodeTime:     customTimeEncoder,
-			EncodeDuration: zapcore.StringDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
+	o := options{timeEncoder: customTimeEncoder}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.levels == nil {
+		o.levels = NewLevels(level)
+	} else {
+		o.levels.SetLevel("", level)
+	}
+
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		FunctionKey:    zapcore.OmitKey,
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    encodeLevel,
+		EncodeTime:     o.timeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	var encoder zapcore.Encoder
+	if encoding == "console" {
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
 	}
+	cores := []zapcore.Core{zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), o.levels)}
 
-	logger, err := config.Build(zap.AddCallerSkip(1))
-	if err != nil {
-		return nil, fmt.Errorf("error building zap logger: %w", err)
+	if o.file != nil {
+		// В файл всегда пишется JSON без цветовых кодов
+		fileConfig := encoderConfig
+		fileConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+		file := &lumberjack.Logger{
+			Filename:   o.file.Path,
+			MaxSize:    o.file.MaxSizeMB,
+			MaxBackups: o.file.MaxBackups,
+			MaxAge:     o.file.MaxAgeDays,
+		}
+		// Файл открывается при первой записи; пустая запись проверяет, что он доступен, до начала работы
+		if _, err := file.Write(nil); err != nil {
+			return nil, fmt.Errorf("error opening log file: %w", err)
+		}
+		cores = append(cores, zapcore.NewCore(zapcore.NewJSONEncoder(fileConfig), zapcore.AddSync(file), o.levels))
 	}
 
+	logger := zap.New(newCore(zapcore.NewTee(cores...), o),
+		zap.AddCaller(), zap.AddCallerSkip(1), zap.AddStacktrace(zapcore.ErrorLevel), zap.ErrorOutput(zapcore.Lock(os.Stderr)))
+
 	logger.Info("Logger initialized",
 		zap.String("level", level.String()),
-		zap.String("encoding", config.Encoding))
+		zap.String("encoding", encoding))
 
 	return logger, nil
 }
 
+// newCore добавляет к core скрытие полей, выборку отладочных сообщений и уровни именованных логгеров
+func newCore(core zapcore.Core, o options) zapcore.Core {
+	if o.redactor != nil {
+		core = &redactCore{Core: core, r: o.redactor}
+	}
+	if o.sampleFirst > 0 {
+		core = &debugSampler{
+			Core:    core,
+			sampled: zapcore.NewSamplerWithOptions(core, time.Second, o.sampleFirst, o.sampleThereafter),
+		}
+	}
+	return &levelCore{Core: core, levels: o.levels}
+}
+
 func customTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
 	enc.AppendString(t.Format("02-01-2006 15:04:05"))
 }
+
+func utcTimeEncoder(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+	zapcore.ISO8601TimeEncoder(t.UTC(), enc)
+}
@@ -0,0 +1,163 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func newTestLogger(levels *Levels, opts ...Option) (*zap.Logger, *bytes.Buffer) {
+	o := options{levels: levels}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg", NameKey: "logger"})
+	return zap.New(newCore(zapcore.NewCore(encoder, zapcore.AddSync(&buf), levels), o)), &buf
+}
+
+func TestLevels(t *testing.T) {
+	levels := NewLevels(zapcore.InfoLevel)
+	log, buf := newTestLogger(levels)
+	service := log.Named("WalletService")
+	replica := log.Named("repository").Named("replica")
+
+	levels.SetLevel("WalletService", zapcore.DebugLevel)
+	levels.SetLevel("repository", zapcore.WarnLevel)
+
+	service.Debug("service debug")
+	service.With(zap.String("wallet_id", "1")).Debug("service debug with fields")
+	log.Debug("root debug")
+	log.Info("root info")
+	replica.Info("replica info")
+	replica.Warn("replica warn")
+
+	assert.Equal(t, []string{"service debug", "service debug with fields", "root info", "replica warn"}, messages(buf))
+	assert.Equal(t, map[string]zapcore.Level{
+		"": zapcore.InfoLevel, "WalletService": zapcore.DebugLevel, "repository": zapcore.WarnLevel,
+	}, levels.Levels())
+
+	buf.Reset()
+	levels.ResetLevel("WalletService")
+	levels.SetLevel("", zapcore.ErrorLevel)
+	service.Debug("service debug")
+	log.Warn("root warn")
+	replica.Warn("replica warn")
+
+	assert.Equal(t, []string{"replica warn"}, messages(buf))
+	assert.False(t, levels.Enabled(zapcore.InfoLevel))
+}
+
+func TestDebugSampling(t *testing.T) {
+	levels := NewLevels(zapcore.DebugLevel)
+	log, buf := newTestLogger(levels, WithDebugSampling(2, 3))
+
+	for i := 0; i < 8; i++ {
+		log.Debug("debug")
+		log.Info("info")
+	}
+
+	var debug, info int
+	for _, msg := range messages(buf) {
+		if msg == "debug" {
+			debug++
+		} else {
+			info++
+		}
+	}
+	// Первые 2 сообщения, затем каждое третье из оставшихся 6
+	assert.Equal(t, 4, debug)
+	assert.Equal(t, 8, info)
+}
+
+func TestUTCTimeEncoder(t *testing.T) {
+	enc := zapcore.NewMapObjectEncoder()
+	at := time.Date(2024, 3, 1, 15, 4, 5, 0, time.FixedZone("MSK", 3*60*60))
+
+	assert.NoError(t, enc.AddArray("t", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		utcTimeEncoder(at, arr)
+		return nil
+	})))
+	assert.Equal(t, []any{"2024-03-01T12:04:05.000Z"}, enc.Fields["t"])
+}
+
+func messages(buf *bytes.Buffer) []string {
+	var msgs []string
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var entry struct {
+			Msg string `json:"msg"`
+		}
+		if err := dec.Decode(&entry); err != nil {
+			panic(err)
+		}
+		msgs = append(msgs, entry.Msg)
+	}
+	return msgs
+}
+
+type testRequest struct {
+	walletID string
+	amount   string
+	token    string
+	fee      float64
+}
+
+func (r testRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("wallet_id", r.walletID)
+	enc.AddString("amount", r.amount)
+	enc.AddString("token", r.token)
+	enc.AddFloat64("fee", r.fee)
+	return nil
+}
+
+type testRequests []testRequest
+
+func (rs testRequests) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, r := range rs {
+		if err := enc.AppendObject(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRedactor(t *testing.T) {
+	policies, err := ParsePolicies("balance=keep, X-Request-ID=mask")
+	assert.NoError(t, err)
+	redactor := NewRedactor(policies, []byte("key"))
+	log, buf := newTestLogger(NewLevels(zapcore.DebugLevel), WithRedactor(redactor))
+
+	walletID := "8f14e45f-ceea-467a-9af0-7b5a1f3a6b11"
+	log.With(zap.String("x_request_id", "0123456789abcdef")).Info("operation",
+		zap.Object("req", testRequest{walletID: walletID, amount: "150.00", token: "secret", fee: 1.5}),
+		zap.Array("batch", testRequests{{walletID: walletID, amount: "20.00", fee: 0.2}}),
+		zap.String("wallet_id", walletID),
+		zap.String("balance", "150.00"),
+		zap.String("Authorization", "Bearer secret"),
+		zap.Int("fee", 3),
+	)
+
+	var entry map[string]any
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	hash := redactor.redact(PolicyHash, walletID)
+	assert.Equal(t, map[string]any{
+		"msg":          "operation",
+		"x_request_id": "****cdef",
+		"req":          map[string]any{"wallet_id": hash, "amount": "***", "fee": "***"},
+		"batch":        []any{map[string]any{"wallet_id": hash, "amount": "***", "fee": "***"}},
+		"wallet_id":    hash,
+		"balance":      "150.00",
+		"fee":          "***",
+	}, entry)
+	assert.Len(t, hash, 16)
+	assert.NotEqual(t, hash, NewRedactor(policies, []byte("other")).redact(PolicyHash, walletID))
+
+	_, err = ParsePolicies("amount=hide")
+	assert.Error(t, err)
+}
@@ -0,0 +1,385 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Policy - как поле скрывается в логах
+type Policy string
+
+const (
+	// PolicyKeep пишет поле как есть
+	PolicyKeep Policy = "keep"
+	// PolicyMask заменяет значение звездочками, оставляя у длинных значений последние 4 символа
+	PolicyMask Policy = "mask"
+	// PolicyHash заменяет значение HMAC-SHA256: одинаковые значения можно сопоставить, не раскрывая их
+	PolicyHash Policy = "hash"
+	// PolicyDrop убирает поле
+	PolicyDrop Policy = "drop"
+)
+
+// DefaultPolicies - политики для полей с идентификаторами кошельков, суммами, токенами и заголовками
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		"wallet_id":        PolicyHash,
+		"target_wallet_id": PolicyHash,
+		"account_id":       PolicyHash,
+		"external_id":      PolicyHash,
+
+		"amount":       PolicyMask,
+		"balance":      PolicyMask,
+		"fee":          PolicyMask,
+		"gross":        PolicyMask,
+		"net":          PolicyMask,
+		"credit_limit": PolicyMask,
+		"available":    PolicyMask,
+		"remaining":    PolicyMask,
+		"limit":        PolicyMask,
+		"stored":       PolicyMask,
+		"ledger":       PolicyMask,
+
+		"token":         PolicyDrop,
+		"password":      PolicyDrop,
+		"authorization": PolicyDrop,
+		"cookie":        PolicyDrop,
+		"set_cookie":    PolicyDrop,
+		"x_api_key":     PolicyDrop,
+		"headers":       PolicyDrop,
+	}
+}
+
+// ParsePolicies разбирает политики вида "wallet_id=hash,amount=keep" и добавляет их к политикам по умолчанию
+func ParsePolicies(s string) (map[string]Policy, error) {
+	policies := DefaultPolicies()
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid redaction policy %q, expected key=policy", item)
+		}
+		policy := Policy(strings.TrimSpace(value))
+		switch policy {
+		case PolicyKeep, PolicyMask, PolicyHash, PolicyDrop:
+		default:
+			return nil, fmt.Errorf("unknown redaction policy %q for %s", policy, key)
+		}
+		policies[normalizeKey(key)] = policy
+	}
+	return policies, nil
+}
+
+// Redactor скрывает значения полей логов по их ключам, в том числе внутри объектов zapcore.ObjectMarshaler.
+// Ключи сравниваются без учета регистра, дефис считается подчеркиванием: X-Api-Key совпадает с x_api_key
+type Redactor struct {
+	policies map[string]Policy
+	hashKey  []byte
+}
+
+// NewRedactor создает Redactor. Без hashKey ключ выбирается случайно, и хэши сопоставимы только в пределах процесса
+func NewRedactor(policies map[string]Policy, hashKey []byte) *Redactor {
+	if len(hashKey) == 0 {
+		hashKey = make([]byte, 32)
+		_, _ = rand.Read(hashKey)
+	}
+	normalized := make(map[string]Policy, len(policies))
+	for key, policy := range policies {
+		normalized[normalizeKey(key)] = policy
+	}
+	return &Redactor{policies: normalized, hashKey: hashKey}
+}
+
+func (r *Redactor) policy(key string) Policy {
+	if policy, ok := r.policies[normalizeKey(key)]; ok {
+		return policy
+	}
+	return PolicyKeep
+}
+
+// Fields возвращает поля со скрытыми значениями; поля с политикой drop убираются
+func (r *Redactor) Fields(fields []zapcore.Field) []zapcore.Field {
+	redacted := make([]zapcore.Field, 0, len(fields))
+	for _, f := range fields {
+		switch policy := r.policy(f.Key); policy {
+		case PolicyDrop:
+		case PolicyMask, PolicyHash:
+			redacted = append(redacted, zap.String(f.Key, r.redact(policy, fieldValue(f))))
+		default:
+			switch f.Type {
+			case zapcore.ObjectMarshalerType:
+				f.Interface = redactedObject{ObjectMarshaler: f.Interface.(zapcore.ObjectMarshaler), r: r}
+			case zapcore.ArrayMarshalerType:
+				f.Interface = redactedArray{ArrayMarshaler: f.Interface.(zapcore.ArrayMarshaler), r: r}
+			}
+			redacted = append(redacted, f)
+		}
+	}
+	return redacted
+}
+
+func (r *Redactor) redact(policy Policy, value string) string {
+	switch policy {
+	case PolicyMask:
+		if len(value) > 8 {
+			return "****" + value[len(value)-4:]
+		}
+		return "***"
+	case PolicyHash:
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil)[:8])
+	default:
+		return value
+	}
+}
+
+func normalizeKey(key string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "-", "_")
+}
+
+// fieldValue возвращает значение поля в виде строки
+func fieldValue(f zapcore.Field) string {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.StringerType:
+		return f.Interface.(fmt.Stringer).String()
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return fmt.Sprint(enc.Fields[f.Key])
+}
+
+// redactedObject скрывает поля объекта, которые он пишет в кодировщик
+type redactedObject struct {
+	zapcore.ObjectMarshaler
+	r *Redactor
+}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.ObjectMarshaler.MarshalLogObject(&redactingEncoder{ObjectEncoder: enc, r: o.r})
+}
+
+// redactingEncoder применяет политики ко всем полям объекта: значения с политиками mask и hash пишутся строкой,
+// поэтому скрываются и суммы, переданные числом
+type redactingEncoder struct {
+	zapcore.ObjectEncoder
+	r *Redactor
+}
+
+// redacted пишет значение поля по его политике и сообщает, обработано ли поле. Поля с политикой keep
+// остаются кодировщику, чтобы сохранить их тип
+func (e *redactingEncoder) redacted(key string, value any) bool {
+	switch policy := e.r.policy(key); policy {
+	case PolicyDrop:
+		return true
+	case PolicyMask, PolicyHash:
+		e.ObjectEncoder.AddString(key, e.r.redact(policy, fmt.Sprint(value)))
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *redactingEncoder) AddString(key string, value string) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddString(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddByteString(key string, value []byte) {
+	if !e.redacted(key, string(value)) {
+		e.ObjectEncoder.AddByteString(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddBinary(key string, value []byte) {
+	if !e.redacted(key, hex.EncodeToString(value)) {
+		e.ObjectEncoder.AddBinary(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddBool(key string, value bool) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddBool(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddComplex128(key string, value complex128) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddComplex128(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddComplex64(key string, value complex64) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddComplex64(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddDuration(key string, value time.Duration) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddDuration(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddFloat64(key string, value float64) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddFloat64(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddFloat32(key string, value float32) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddFloat32(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddInt(key string, value int) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddInt(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddInt64(key string, value int64) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddInt64(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddInt32(key string, value int32) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddInt32(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddInt16(key string, value int16) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddInt16(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddInt8(key string, value int8) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddInt8(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddTime(key string, value time.Time) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddTime(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddUint(key string, value uint) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddUint(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddUint64(key string, value uint64) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddUint64(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddUint32(key string, value uint32) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddUint32(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddUint16(key string, value uint16) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddUint16(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddUint8(key string, value uint8) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddUint8(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddUintptr(key string, value uintptr) {
+	if !e.redacted(key, value) {
+		e.ObjectEncoder.AddUintptr(key, value)
+	}
+}
+
+func (e *redactingEncoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	if e.r.policy(key) == PolicyDrop {
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, redactedObject{ObjectMarshaler: marshaler, r: e.r})
+}
+
+func (e *redactingEncoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	if e.r.policy(key) == PolicyDrop {
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, redactedArray{ArrayMarshaler: marshaler, r: e.r})
+}
+
+func (e *redactingEncoder) AddReflected(key string, value any) error {
+	if e.redacted(key, value) {
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, value)
+}
+
+// redactedArray скрывает поля объектов, которые массив пишет в кодировщик
+type redactedArray struct {
+	zapcore.ArrayMarshaler
+	r *Redactor
+}
+
+func (a redactedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.ArrayMarshaler.MarshalLogArray(&redactingArrayEncoder{ArrayEncoder: enc, r: a.r})
+}
+
+// redactingArrayEncoder применяет политики к объектам и вложенным массивам элементов
+type redactingArrayEncoder struct {
+	zapcore.ArrayEncoder
+	r *Redactor
+}
+
+func (e *redactingArrayEncoder) AppendObject(marshaler zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactedObject{ObjectMarshaler: marshaler, r: e.r})
+}
+
+func (e *redactingArrayEncoder) AppendArray(marshaler zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactedArray{ArrayMarshaler: marshaler, r: e.r})
+}
+
+// redactCore скрывает поля перед записью
+type redactCore struct {
+	zapcore.Core
+	r *Redactor
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.r.Fields(fields)), r: c.r}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, c.r.Fields(fields))
+}